@@ -3,6 +3,7 @@ module mcg-app-backend
 go 1.24
 
 require (
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/riandyrn/otelchi v0.12.1
 	github.com/stretchr/testify v1.10.0
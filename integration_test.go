@@ -1,14 +1,23 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
+	inboundmllp "mcg-app-backend/io/inbound/mllp"
+	"mcg-app-backend/service/fhir"
 	"mcg-app-backend/service/models"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 	"unicode"
@@ -17,22 +26,59 @@ import (
 var authToken = "b"
 var patientId = -1
 var conditionId = -1
+var attatchmentId = -1
+var medicationId = -1
+var allergyId = -1
+var observationId = -1
+var encounterId = -1
+var appointmentId = -1
 
 func TestApplication(t *testing.T) {
+	t.Setenv("MCG_CUSTODIAN_OID", "2.16.840.1.113883.19.5")
+	t.Setenv("MCG_CUSTODIAN_NAME", "MCG Clinic")
+	t.Setenv("MCG_CUSTODIAN_PHONE", "555 555 0100")
+	t.Setenv("MCG_CUSTODIAN_ADDRESS", "1 Example Street")
 	go main()
 
-	time.Sleep(time.Second)
+	err := waitForServer("http://localhost:8080/public/docs", time.Second*30)
+	if err != nil {
+		t.Fatal(err)
+	}
 	var results TestResults
 	results = testUserCreate(results)
 	results = testUserLogin(results)
 	results = testPatientCreate(results)
 	results = testPatientUpdate(results)
+	results = testPatientIdentifiers(results)
 	results = testAddAttatchmentToPatient(results)
+	results = testReplaceAttatchmentContent(results)
+	results = testAttatchmentThumbnail(results)
+	results = testDicomAttatchment(results)
+	results = testSearchICD10Codes(results)
 	results = testAddDiagnosedConditionToPatient(results)
+	results = testUpdateDiagnosedCondition(results)
 	results = testSearchPatients(results)
+	results = testSNOMEDConditions(results)
+	results = testDiagnosedConditionStatus(results)
+	results = testMedications(results)
+	results = testAllergies(results)
+	results = testObservations(results)
+	results = testEncounters(results)
+	results = testAppointments(results)
+	results = testFhirRead(results)
+	results = testFhirWrite(results)
+	results = testHl7Messages(results)
+	results = testImports(results)
+	results = testFhirExport(results)
+	results = testCcda(results)
+	results = testPatientSummary(results)
+	results = testDuplicatePatients(results)
 	results = testDeleteCondition(results)
+	results = testRestoreCondition(results)
 	results = testDeleteAttatchment(results)
+	results = testRestoreAttatchment(results)
 	results = testDeletePatient(results)
+	results = testRestorePatient(results)
 
 	for _, res := range results {
 
@@ -44,6 +90,25 @@ func TestApplication(t *testing.T) {
 	}
 }
 
+// starting up loads the keyfile and code tables before the server listens, so it is polled until it answers rather
+// than given a fixed time
+func waitForServer(url string, timeout time.Duration) error {
+	var err error
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(time.Millisecond * 50) {
+		var resp *http.Response
+		resp, err = http.Get(url)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+		err = fmt.Errorf("expected status 200 from %v, but got %v", url, resp.Status)
+	}
+	return fmt.Errorf("server did not start within %v %w", timeout, err)
+}
+
 type TestResult struct {
 	Name  string
 	Error error
@@ -68,136 +133,1351 @@ func testDeletePatient(results TestResults) TestResults {
 	}, 200, &patients))
 	results.Add("test that patients are not returned after deletion", func() error {
 		if len(patients) != 0 {
-			return fmt.Errorf("expected no patients, but got %v", len(patients))
+			return fmt.Errorf("expected no patients, but got %v", len(patients))
+		}
+		return nil
+	}())
+
+	results.Add("test attatchment of deleted patient is deleted", getAndEnsureStatus(fmt.Sprintf("/attatchments/%v", attatchmentId), nil, 400, nil))
+	results.Add("test search patients by attatchment type after patient deletion", getAndEnsureStatus(searchPath, models.PatientSearch{
+		AttatchmentType: "MRI",
+	}, 200, &patients))
+	results.Add("test that no orphaned attatchments match after patient deletion", func() error {
+		if len(patients) != 0 {
+			return fmt.Errorf("expected no patients, but got %v", len(patients))
+		}
+		return nil
+	}())
+	results.Add("test search patients by diagnosed condition after patient deletion", getAndEnsureStatus(searchPath, models.PatientSearch{
+		DiagnosedConditionName: "some condition",
+	}, 200, &patients))
+	results.Add("test that no orphaned conditions match after patient deletion", func() error {
+		if len(patients) != 0 {
+			return fmt.Errorf("expected no patients, but got %v", len(patients))
+		}
+		return nil
+	}())
+	results.Add("test medication of deleted patient is deleted", getAndEnsureStatus(fmt.Sprintf("/patients/%v/medications/%v", patientId, medicationId), nil, 400, nil))
+	results.Add("test allergy of deleted patient is deleted", getAndEnsureStatus(fmt.Sprintf("/patients/%v/allergies/%v", patientId, allergyId), nil, 400, nil))
+	results.Add("test observation of deleted patient is deleted", getAndEnsureStatus(fmt.Sprintf("/observations/%v", observationId), nil, 400, nil))
+	results.Add("test encounter of deleted patient is deleted", getAndEnsureStatus(fmt.Sprintf("/patients/%v/encounters/%v", patientId, encounterId), nil, 400, nil))
+	results.Add("test appointment of deleted patient is cancelled", getAndEnsureStatus(fmt.Sprintf("/patients/%v/appointments/%v", patientId, appointmentId), nil, 400, nil))
+	results.Add("test restore condition of deleted patient", postAndEnsureStatus(fmt.Sprintf("/diagnosedConditions/%v/restore", conditionId), nil, 400, nil))
+
+	return results
+}
+
+func testRestorePatient(results TestResults) TestResults {
+	//the deleted patient's externalIdentifier is given to a new patient, so it cannot be restored
+	results.Add("create patient with the externalIdentifier of the deleted patient", postAndEnsureStatus("/patients", models.PatientRequest{
+		Name:               "New Abc",
+		PhoneNumber:        "8044950000",
+		ExternalIdentifier: "abc",
+		DateOfBirth:        time.Now(),
+	}, 200, nil))
+	results.Add("restore patient with conflicting externalIdentifier", postAndEnsureStatus(fmt.Sprintf("/patients/%v/restore", patientId), nil, 409, nil))
+
+	patient := models.Patient{
+		Name:               "Restored Patient",
+		Address:            "1 restore street",
+		PhoneNumber:        "8044955579",
+		ExternalIdentifier: "restore-1",
+		DateOfBirth:        time.Now(),
+	}
+	results.Add("create patient to restore", postAndEnsureStatus("/patients", patient, 200, &patient))
+	results.Add("add condition to patient to restore", postAndEnsureStatus(fmt.Sprintf("/patients/%v/diagnosedConditions", patient.Id), models.DiagnosedCondition{
+		Name: "restored condition",
+		Code: "R51",
+		Date: time.Now(),
+	}, 200, nil))
+	results.Add("delete patient to restore", deleteAndEnsureStatus(fmt.Sprintf("/patients/%v", patient.Id), 204, nil))
+
+	path := fmt.Sprintf("/patients/%v/restore", patient.Id)
+	results.Add("restore patient", postAndEnsureStatus(path, nil, 204, nil))
+	results.Add("restore patient which is not deleted", postAndEnsureStatus(path, nil, 400, nil))
+	var patients []models.Patient
+	results.Add("test search patients by name after restore", getAndEnsureStatus("/patients", models.PatientSearch{
+		Name: "Restored Patient",
+	}, 200, &patients))
+	results.Add("test that patient is returned with its conditions after restore", func() error {
+		if len(patients) != 1 {
+			return fmt.Errorf("expected 1 patient, but got %v", len(patients))
+		}
+		if len(patients[0].DiagnosedConditions) != 1 {
+			return fmt.Errorf("expected restored patient to have 1 diagnosed condition, but had %v", len(patients[0].DiagnosedConditions))
+		}
+		return nil
+	}())
+	results.Add("delete restored patient", deleteAndEnsureStatus(fmt.Sprintf("/patients/%v", patient.Id), 204, nil))
+	return results
+}
+
+func testRestoreAttatchment(results TestResults) TestResults {
+	path := fmt.Sprintf("/attatchments/%v/restore", conditionId)
+	results.Add("restore attatchment", postAndEnsureStatus(path, nil, 204, nil))
+	results.Add("restore missing attatchment", postAndEnsureStatus("/attatchments/-1/restore", nil, 400, nil))
+	var patients []models.Patient
+	results.Add("test search patients by attatchment type after restore", getAndEnsureStatus("/patients", models.PatientSearch{
+		AttatchmentType: "MRI",
+	}, 200, &patients))
+	results.Add("test that restored attatchment is returned", func() error {
+		if len(patients) != 1 {
+			return fmt.Errorf("expected 1 patient, but got %v", len(patients))
+		}
+		if len(patients[0].Attatchments) != 2 {
+			return fmt.Errorf("expected 2 attatchments for patient, but got %v", len(patients[0].Attatchments))
+		}
+		return nil
+	}())
+	return results
+}
+
+func testRestoreCondition(results TestResults) TestResults {
+	path := fmt.Sprintf("/diagnosedConditions/%v/restore", conditionId)
+	results.Add("restore diagnosed condition", postAndEnsureStatus(path, nil, 204, nil))
+	var patients []models.Patient
+	results.Add("test search patients by diagnosed condition after restore", getAndEnsureStatus("/patients", models.PatientSearch{
+		DiagnosedConditionName: "some condition",
+	}, 200, &patients))
+	results.Add("test that patients are returned for restored condition", func() error {
+		if len(patients) != 1 {
+			return fmt.Errorf("expected 1 patient, but got %v", len(patients))
+		}
+		return nil
+	}())
+	return results
+}
+
+func testDeleteAttatchment(results TestResults) TestResults {
+	path := fmt.Sprintf("/attatchments/%v", conditionId)
+	searchPath := "/patients"
+	results.Add("delete diagnosed condition", deleteAndEnsureStatus(path, 204, nil))
+	var patients []models.Patient
+	results.Add("test search patients by diagnosed condition after deletion", getAndEnsureStatus(searchPath, models.PatientSearch{
+		AttatchmentType: "MRI",
+	}, 200, &patients))
+	results.Add("test that returned patient has only 1 attatchment after delete", func() error {
+		if len(patients) != 1 {
+			return fmt.Errorf("expected 1 patient, but got %v", len(patients))
+		}
+		if len(patients[0].Attatchments) != 1 {
+			return fmt.Errorf("expected 1 attatchment for patient, but got %v", len(patients[0].Attatchments))
+		}
+
+		return nil
+	}())
+
+	return results
+}
+
+func testDeleteCondition(results TestResults) TestResults {
+	path := fmt.Sprintf("/diagnosedConditions/%v", conditionId)
+	searchPath := "/patients"
+	results.Add("delete diagnosed condition", deleteAndEnsureStatus(path, 204, nil))
+	var patients []models.Patient
+	results.Add("test search patients by diagnosed condition after deletion", getAndEnsureStatus(searchPath, models.PatientSearch{
+		Name:                   "mismatch",
+		DiagnosedConditionName: "some condition",
+	}, 200, &patients))
+	results.Add("test that patients are not returned for deleted condition", func() error {
+		if len(patients) != 0 {
+			return fmt.Errorf("expected no patients, but got %v", len(patients))
+		}
+		return nil
+	}())
+
+	return results
+}
+
+func testSearchPatients(results TestResults) TestResults {
+	path := "/patients"
+	realAuth := authToken
+	authToken = "INVALID"
+	results.Add("test search patients without auth token", getAndEnsureStatus(path, nil, 401, nil))
+	authToken = realAuth
+
+	var patients []models.Patient
+	results.Add("test search patients with no conditions", getAndEnsureStatus(path, models.PatientSearch{}, 200, &patients))
+	results.Add("test no patients are returned if no conditions are given", func() error {
+		if len(patients) > 0 {
+			return fmt.Errorf("expected no patients, but found %v", len(patients))
+		}
+		return nil
+	}())
+
+	results.Add("test search patients with no matching criteria", getAndEnsureStatus(path, models.PatientSearch{
+		Name:                   "Invalid name",
+		DiagnosedConditionName: "invalid name",
+	}, 200, &patients))
+	results.Add("test no patients are returned if no matching criteria", func() error {
+		if len(patients) > 0 {
+			return fmt.Errorf("expected no patients, but found %v", len(patients))
+		}
+		return nil
+	}())
+
+	results.Add("test search patients by name", getAndEnsureStatus(path, models.PatientSearch{
+		Name: "Jane Smith",
+	}, 200, &patients))
+
+	results.Add("test that correct patients are returned by name", func() error {
+		if len(patients) != 1 {
+			return fmt.Errorf("expected 1 patient, but found %v", len(patients))
+		}
+		if len(patients[0].Attatchments) != 2 {
+			return fmt.Errorf("expected patient to have 2 attatchments, but had %v", len(patients[0].Attatchments))
+		}
+		if len(patients[0].DiagnosedConditions) != 1 {
+			return fmt.Errorf("expected patient to have 1 diagnosed condition, but had %v", len(patients[0].DiagnosedConditions))
+		}
+		return nil
+	}())
+
+	patients = nil
+
+	results.Add("test search patients by diagnosed condition with mismatching name", getAndEnsureStatus(path, models.PatientSearch{
+		Name:                   "mismatch",
+		DiagnosedConditionName: "some condition",
+	}, 200, &patients))
+
+	results.Add("test that correct patients are returned by diagnosed condition", func() error {
+		if len(patients) != 1 {
+			return fmt.Errorf("expected 1 patient, but found %v", len(patients))
+		}
+		if len(patients[0].Attatchments) != 2 {
+			return fmt.Errorf("expected patient to have 2 attatchments, but had %v", len(patients[0].Attatchments))
+		}
+		if len(patients[0].DiagnosedConditions) != 1 {
+			return fmt.Errorf("expected patient to have 1 diagnosed condition, but had %v", len(patients[0].DiagnosedConditions))
+		}
+		return nil
+	}())
+
+	results.Add("test search patients by attatchment type ", getAndEnsureStatus(path, models.PatientSearch{
+		AttatchmentType: "MRI",
+	}, 200, &patients))
+
+	results.Add("test that correct patients are returned by attatchment type", func() error {
+		if len(patients) != 1 {
+			return fmt.Errorf("expected 1 patient, but found %v", len(patients))
+		}
+		if len(patients[0].Attatchments) != 2 {
+			return fmt.Errorf("expected patient to have 2 attatchments, but had %v", len(patients[0].Attatchments))
+		}
+		if len(patients[0].DiagnosedConditions) != 1 {
+			return fmt.Errorf("expected patient to have 1 diagnosed condition, but had %v", len(patients[0].DiagnosedConditions))
+		}
+		return nil
+	}())
+
+	for name, query := range map[string]map[string]string{
+		"code prefix": {"diagnosedConditionCodePrefix": "e11"},
+		"code range":  {"diagnosedConditionCodeRange": "E08-E13"},
+	} {
+		patients = nil
+		results.Add(fmt.Sprintf("test search patients by diagnosed condition %v", name), getAndEnsureStatus(path, query, 200, &patients))
+		results.Add(fmt.Sprintf("test that correct patients are returned by diagnosed condition %v", name), func() error {
+			if len(patients) != 1 || patients[0].Id != patientId {
+				return fmt.Errorf("expected patient %v, but found %+v", patientId, patients)
+			}
+			return nil
+		}())
+	}
+	results.Add("test search patients by diagnosed condition code range outside of conditions", getAndEnsureStatus(path, map[string]string{"diagnosedConditionCodeRange": "J45-R51"}, 200, &patients))
+	results.Add("test no patients are returned for code range outside of conditions", func() error {
+		if len(patients) > 0 {
+			return fmt.Errorf("expected no patients, but found %v", len(patients))
+		}
+		return nil
+	}())
+	results.Add("test search patients by invalid code range", getAndEnsureStatus(path, map[string]string{"diagnosedConditionCodeRange": "E13-E08"}, 400, nil))
+
+	return results
+}
+
+func testSNOMEDConditions(results TestResults) TestResults {
+	patient := models.Patient{
+		Name:               "Snomed Patient",
+		Address:            "1 snomed street",
+		PhoneNumber:        "8044955579",
+		ExternalIdentifier: "snomed-1",
+		DateOfBirth:        time.Now(),
+	}
+	results.Add("create patient with SNOMED CT conditions", postAndEnsureStatus("/patients", patient, 200, &patient))
+	path := fmt.Sprintf("/patients/%v/diagnosedConditions", patient.Id)
+	results.Add("test add condition with invalid SNOMED CT concept id", postAndEnsureStatus(path, models.DiagnosedCondition{
+		Code:       "38341004",
+		CodeSystem: models.CodeSystemSNOMEDCT,
+		Date:       time.Now(),
+	}, 400, nil))
+	var condition models.DiagnosedCondition
+	results.Add("test add SNOMED CT condition", postAndEnsureStatus(path, models.DiagnosedCondition{
+		Code:       "38341003",
+		CodeSystem: models.CodeSystemSNOMEDCT,
+		Date:       time.Now(),
+	}, 200, &condition))
+	results.Add("test SNOMED CT condition name defaults to concept name", func() error {
+		if condition.Name != "Hypertensive disorder, systemic arterial" {
+			return fmt.Errorf("expected concept name but got %+v", condition)
+		}
+		return nil
+	}())
+
+	var conditions []models.DiagnosedCondition
+	results.Add("test list conditions by mapped ICD-10-CM code", getAndEnsureStatus(path, map[string]string{"code": "I10"}, 200, &conditions))
+	results.Add("test SNOMED CT condition is listed by mapped code", func() error {
+		if len(conditions) != 1 || conditions[0].Id != condition.Id {
+			return fmt.Errorf("expected condition %v but got %+v", condition.Id, conditions)
+		}
+		return nil
+	}())
+
+	var patients []models.Patient
+	results.Add("test search patients by mapped ICD-10-CM code", getAndEnsureStatus("/patients", map[string]string{"diagnosedConditionCode": "I10"}, 200, &patients))
+	results.Add("test patient with SNOMED CT condition is found by mapped code", func() error {
+		if len(patients) != 1 || patients[0].Id != patient.Id {
+			return fmt.Errorf("expected patient %v but got %+v", patient.Id, patients)
+		}
+		return nil
+	}())
+	results.Add("test search patients by mapped SNOMED CT code", getAndEnsureStatus("/patients", map[string]string{
+		"diagnosedConditionCode":       "44054006",
+		"diagnosedConditionCodeSystem": models.CodeSystemSNOMEDCT,
+	}, 200, &patients))
+	results.Add("test patient with ICD-10-CM condition is found by mapped code", func() error {
+		if len(patients) != 1 || patients[0].Id != patientId {
+			return fmt.Errorf("expected patient %v but got %+v", patientId, patients)
+		}
+		return nil
+	}())
+	results.Add("delete patient with SNOMED CT conditions", deleteAndEnsureStatus(fmt.Sprintf("/patients/%v", patient.Id), 204, nil))
+	return results
+}
+
+func testDiagnosedConditionStatus(results TestResults) TestResults {
+	path := fmt.Sprintf("/diagnosedConditions/%v/status", conditionId)
+	listPath := fmt.Sprintf("/patients/%v/diagnosedConditions", patientId)
+
+	var condition models.DiagnosedCondition
+	results.Add("test get condition status", getAndEnsureStatus(fmt.Sprintf("/diagnosedConditions/%v", conditionId), nil, 200, &condition))
+	results.Add("test new condition is active and confirmed", func() error {
+		if condition.ClinicalStatus != models.ClinicalStatusActive || condition.VerificationStatus != models.VerificationStatusConfirmed {
+			return fmt.Errorf("expected active and confirmed condition but got %+v", condition)
+		}
+		return nil
+	}())
+
+	results.Add("test resolve condition", postAndEnsureStatus(path, models.DiagnosedConditionStatus{
+		ClinicalStatus: models.ClinicalStatusResolved,
+	}, 200, &condition))
+	results.Add("test resolved condition has abatement date", func() error {
+		if condition.ClinicalStatus != models.ClinicalStatusResolved || condition.AbatementDate == nil {
+			return fmt.Errorf("expected resolved condition with abatement date but got %+v", condition)
+		}
+		return nil
+	}())
+	results.Add("test relapse of resolved condition is not allowed", postAndEnsureStatus(path, models.DiagnosedConditionStatus{
+		ClinicalStatus: models.ClinicalStatusRelapse,
+	}, 400, nil))
+
+	var conditions []models.DiagnosedCondition
+	results.Add("test list active conditions", getAndEnsureStatus(listPath, map[string]string{"active": "true"}, 200, &conditions))
+	results.Add("test resolved condition is not listed as active", func() error {
+		if len(conditions) != 0 {
+			return fmt.Errorf("expected no active conditions, but got %v", len(conditions))
+		}
+		return nil
+	}())
+	var patients []models.Patient
+	results.Add("test search patients by active condition", getAndEnsureStatus("/patients", map[string]string{
+		"diagnosedConditionCode":   "E11.9",
+		"diagnosedConditionActive": "true",
+	}, 200, &patients))
+	results.Add("test patient with resolved condition is not found by active condition", func() error {
+		if len(patients) != 0 {
+			return fmt.Errorf("expected no patients, but got %v", len(patients))
+		}
+		return nil
+	}())
+
+	results.Add("test condition recurrence", postAndEnsureStatus(path, models.DiagnosedConditionStatus{
+		ClinicalStatus: models.ClinicalStatusRecurrence,
+	}, 200, &condition))
+	results.Add("test recurring condition is listed as active", getAndEnsureStatus(listPath, map[string]string{"active": "true"}, 200, &conditions))
+	results.Add("test recurring condition has no abatement date", func() error {
+		if len(conditions) != 1 || conditions[0].AbatementDate != nil {
+			return fmt.Errorf("expected 1 active condition without abatement date, but got %+v", conditions)
+		}
+		return nil
+	}())
+	results.Add("test change status of missing condition", postAndEnsureStatus("/diagnosedConditions/-1/status", models.DiagnosedConditionStatus{
+		VerificationStatus: models.VerificationStatusRefuted,
+	}, 400, nil))
+	return results
+}
+
+func testMedications(results TestResults) TestResults {
+	listPath := fmt.Sprintf("/patients/%v/medications", patientId)
+
+	var medication models.Medication
+	results.Add("add medication to patient", postAndEnsureStatus(listPath, models.MedicationRequest{
+		DrugName:   "Metformin",
+		RxNormCode: "860975",
+		Dose:       "500 mg",
+		Route:      "oral",
+		Frequency:  "twice daily",
+		StartDate:  time.Now(),
+	}, 200, &medication))
+	medicationId = medication.Id
+	path := fmt.Sprintf("/patients/%v/medications/%v", patientId, medicationId)
+
+	results.Add("add medication with invalid RxNorm code", postAndEnsureStatus(listPath, models.MedicationRequest{
+		DrugName:   "Metformin",
+		RxNormCode: "abc",
+		StartDate:  time.Now(),
+	}, 400, nil))
+	results.Add("add medication to missing patient", postAndEnsureStatus("/patients/-1/medications", models.MedicationRequest{
+		DrugName:  "Metformin",
+		StartDate: time.Now(),
+	}, 400, nil))
+
+	endDate := time.Now().AddDate(0, 1, 0)
+	results.Add("update medication", putAndEnsureStatus(path, models.MedicationRequest{
+		DrugName:   "Metformin",
+		RxNormCode: "860975",
+		Dose:       "1000 mg",
+		Route:      "oral",
+		Frequency:  "twice daily",
+		StartDate:  medication.StartDate,
+		EndDate:    &endDate,
+	}, 200, nil))
+	results.Add("get medication", getAndEnsureStatus(path, nil, 200, &medication))
+	results.Add("test medication was updated", func() error {
+		if medication.Dose != "1000 mg" || medication.EndDate == nil {
+			return fmt.Errorf("expected updated medication but got %+v", medication)
+		}
+		return nil
+	}())
+	results.Add("get medication through another patient", getAndEnsureStatus(fmt.Sprintf("/patients/-1/medications/%v", medicationId), nil, 400, nil))
+
+	var medications []models.Medication
+	results.Add("delete medication", deleteAndEnsureStatus(path, 204, nil))
+	results.Add("get deleted medication", getAndEnsureStatus(path, nil, 400, nil))
+	results.Add("restore medication", postAndEnsureStatus(path+"/restore", nil, 204, nil))
+	results.Add("list patient medications", getAndEnsureStatus(listPath, nil, 200, &medications))
+	results.Add("test restored medication is listed", func() error {
+		if len(medications) != 1 || medications[0].Id != medicationId {
+			return fmt.Errorf("expected only medication %v, but got %+v", medicationId, medications)
+		}
+		return nil
+	}())
+	return results
+}
+
+func testAllergies(results TestResults) TestResults {
+	listPath := fmt.Sprintf("/patients/%v/allergies", patientId)
+
+	var allergy models.Allergy
+	results.Add("add allergy to patient", postAndEnsureStatus(listPath, models.AllergyRequest{
+		Allergen:    "Penicillin",
+		Category:    models.AllergyCategoryMedication,
+		Criticality: models.AllergyCriticalityHigh,
+		Reaction:    "hives",
+		Severity:    models.AllergySeverityModerate,
+	}, 200, &allergy))
+	allergyId = allergy.Id
+	path := fmt.Sprintf("/patients/%v/allergies/%v", patientId, allergyId)
+
+	results.Add("add allergy with invalid category", postAndEnsureStatus(listPath, models.AllergyRequest{
+		Allergen: "Penicillin",
+		Category: "drug",
+	}, 400, nil))
+	results.Add("add allergy to missing patient", postAndEnsureStatus("/patients/-1/allergies", models.AllergyRequest{
+		Allergen: "Penicillin",
+		Category: models.AllergyCategoryMedication,
+	}, 400, nil))
+
+	results.Add("update allergy", putAndEnsureStatus(path, models.AllergyRequest{
+		Allergen:    "Penicillin",
+		Category:    models.AllergyCategoryMedication,
+		Criticality: models.AllergyCriticalityHigh,
+		Reaction:    "anaphylaxis",
+		Severity:    models.AllergySeveritySevere,
+	}, 200, nil))
+	results.Add("get allergy", getAndEnsureStatus(path, nil, 200, &allergy))
+	results.Add("test allergy was updated", func() error {
+		if allergy.Reaction != "anaphylaxis" || allergy.Severity != models.AllergySeveritySevere {
+			return fmt.Errorf("expected updated allergy but got %+v", allergy)
+		}
+		return nil
+	}())
+
+	var patients []models.Patient
+	results.Add("test search patients by allergen", getAndEnsureStatus("/patients", map[string]string{"allergen": "penicillin"}, 200, &patients))
+	results.Add("test patient is found by allergen with its allergies", func() error {
+		if len(patients) != 1 || patients[0].Id != patientId {
+			return fmt.Errorf("expected only patient %v, but got %+v", patientId, patients)
+		}
+		if len(patients[0].Allergies) != 1 || patients[0].Allergies[0].Id != allergyId {
+			return fmt.Errorf("expected patient to have allergy %v, but had %+v", allergyId, patients[0].Allergies)
+		}
+		return nil
+	}())
+
+	results.Add("delete allergy", deleteAndEnsureStatus(path, 204, nil))
+	results.Add("test search patients by deleted allergen", getAndEnsureStatus("/patients", map[string]string{"allergen": "penicillin"}, 200, &patients))
+	results.Add("test patient is not found by deleted allergy", func() error {
+		if len(patients) != 0 {
+			return fmt.Errorf("expected no patients, but got %v", len(patients))
+		}
+		return nil
+	}())
+	results.Add("restore allergy", postAndEnsureStatus(path+"/restore", nil, 204, nil))
+	results.Add("get restored allergy", getAndEnsureStatus(path, nil, 200, nil))
+	return results
+}
+
+func testObservations(results TestResults) TestResults {
+	listPath := fmt.Sprintf("/patients/%v/observations", patientId)
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	heartRate := func(value float64, effectiveDate time.Time) models.ObservationRequest {
+		return models.ObservationRequest{
+			Code:          "8867-4",
+			Name:          "Heart rate",
+			Value:         &value,
+			Unit:          "/min",
+			EffectiveDate: effectiveDate,
+		}
+	}
+
+	var observation models.Observation
+	results.Add("add heart rate observation", postAndEnsureStatus(listPath, heartRate(72, start.Add(2*time.Hour)), 200, &observation))
+	observationId = observation.Id
+	results.Add("add earlier heart rate observation", postAndEnsureStatus(listPath, heartRate(80, start), 200, nil))
+	results.Add("add blood pressure observation", postAndEnsureStatus(listPath, models.ObservationRequest{
+		Code: "85354-9",
+		Name: "Blood pressure panel",
+		Components: []models.ObservationComponent{
+			{Code: "8480-6", Name: "Systolic blood pressure", Value: 120, Unit: "mm[Hg]"},
+			{Code: "8462-4", Name: "Diastolic blood pressure", Value: 80, Unit: "mm[Hg]"},
+		},
+		EffectiveDate: start.Add(time.Hour),
+	}, 200, nil))
+	results.Add("add observation with invalid LOINC code", postAndEnsureStatus(listPath, models.ObservationRequest{
+		Code:          "8867-5",
+		Components:    []models.ObservationComponent{{Code: "8480-6", Value: 120}},
+		EffectiveDate: start,
+	}, 400, nil))
+	results.Add("add observation to missing patient", postAndEnsureStatus("/patients/-1/observations", heartRate(72, start), 400, nil))
+
+	var observations []models.Observation
+	results.Add("list heart rate observations", getAndEnsureStatus(listPath, map[string]string{"code": "8867-4"}, 200, &observations))
+	results.Add("test heart rate observations are in the order they were made", func() error {
+		if len(observations) != 2 || *observations[0].Value != 80 || *observations[1].Value != 72 {
+			return fmt.Errorf("expected heart rates 80 then 72, but got %+v", observations)
+		}
+		return nil
+	}())
+	results.Add("list observations in time range", getAndEnsureStatus(listPath, map[string]string{
+		"from": start.Add(30 * time.Minute).Format(time.RFC3339),
+		"to":   start.Add(90 * time.Minute).Format(time.RFC3339),
+	}, 200, &observations))
+	results.Add("test only the blood pressure observation is in the time range", func() error {
+		if len(observations) != 1 || observations[0].Code != "85354-9" || len(observations[0].Components) != 2 {
+			return fmt.Errorf("expected only the blood pressure observation, but got %+v", observations)
+		}
+		return nil
+	}())
+
+	results.Add("get latest observations", getAndEnsureStatus(listPath+"/latest", nil, 200, &observations))
+	results.Add("test latest observation of each code is returned", func() error {
+		if len(observations) != 2 || observations[1].Id != observationId {
+			return fmt.Errorf("expected latest blood pressure and heart rate %v, but got %+v", observationId, observations)
+		}
+		return nil
+	}())
+
+	path := fmt.Sprintf("/observations/%v", observationId)
+	results.Add("delete observation", deleteAndEnsureStatus(path, 204, nil))
+	results.Add("get deleted observation", getAndEnsureStatus(path, nil, 400, nil))
+	results.Add("get latest observations after deletion", getAndEnsureStatus(listPath+"/latest", nil, 200, &observations))
+	results.Add("test earlier heart rate is latest after deletion", func() error {
+		if len(observations) != 2 || *observations[1].Value != 80 {
+			return fmt.Errorf("expected heart rate of 80 to be latest, but got %+v", observations)
+		}
+		return nil
+	}())
+	results.Add("restore observation", postAndEnsureStatus(path+"/restore", nil, 204, nil))
+	results.Add("get restored observation", getAndEnsureStatus(path, nil, 200, nil))
+	return results
+}
+
+func testEncounters(results TestResults) TestResults {
+	listPath := fmt.Sprintf("/patients/%v/encounters", patientId)
+
+	var encounter models.Encounter
+	results.Add("add encounter to patient", postAndEnsureStatus(listPath, models.EncounterRequest{
+		Type:         models.EncounterTypeAmbulatory,
+		Start:        time.Now().Add(-time.Hour),
+		Location:     "Main Street Clinic",
+		Practitioner: "Dr. Alice Jones",
+	}, 200, &encounter))
+	encounterId = encounter.Id
+	path := fmt.Sprintf("/patients/%v/encounters/%v", patientId, encounterId)
+
+	results.Add("add encounter with invalid type", postAndEnsureStatus(listPath, models.EncounterRequest{
+		Type:  "checkup",
+		Start: time.Now(),
+	}, 400, nil))
+	end := time.Now()
+	results.Add("update encounter", putAndEnsureStatus(path, models.EncounterRequest{
+		Type:         models.EncounterTypeAmbulatory,
+		Start:        encounter.Start,
+		End:          &end,
+		Location:     "Main Street Clinic",
+		Practitioner: "Dr. Alice Jones",
+	}, 200, nil))
+	results.Add("get encounter through another patient", getAndEnsureStatus(fmt.Sprintf("/patients/-1/encounters/%v", encounterId), nil, 400, nil))
+
+	results.Add("document condition at encounter", patchAndEnsureStatus(fmt.Sprintf("/diagnosedConditions/%v", conditionId), models.DiagnosedConditionPatch{
+		EncounterId: &encounterId,
+	}, 200, nil))
+	missingEncounterId := -1
+	results.Add("document condition at missing encounter", patchAndEnsureStatus(fmt.Sprintf("/diagnosedConditions/%v", conditionId), models.DiagnosedConditionPatch{
+		EncounterId: &missingEncounterId,
+	}, 400, nil))
+	var attatchment models.Attatchment
+	results.Add("add attatchment documented at encounter", postAttatchment(models.Attatchment{
+		Name:        "visit-notes",
+		Type:        "report",
+		Data:        []byte("visit notes"),
+		EncounterId: encounterId,
+	}, 200, &attatchment))
+	results.Add("add attatchment documented at missing encounter", postAttatchment(models.Attatchment{
+		Name:        "visit-notes",
+		Type:        "report",
+		Data:        []byte("visit notes"),
+		EncounterId: missingEncounterId,
+	}, 400, nil))
+
+	var documentation models.EncounterDocumentation
+	results.Add("get encounter documentation", getAndEnsureStatus(path+"/documentation", nil, 200, &documentation))
+	results.Add("test encounter documentation includes its condition and attatchment", func() error {
+		if documentation.Id != encounterId || documentation.End == nil {
+			return fmt.Errorf("expected updated encounter %v, but got %+v", encounterId, documentation.Encounter)
+		}
+		if len(documentation.DiagnosedConditions) != 1 || documentation.DiagnosedConditions[0].Id != conditionId {
+			return fmt.Errorf("expected condition %v, but got %+v", conditionId, documentation.DiagnosedConditions)
+		}
+		if len(documentation.Attatchments) != 1 || documentation.Attatchments[0].Id != attatchment.Id {
+			return fmt.Errorf("expected attatchment %v, but got %+v", attatchment.Id, documentation.Attatchments)
+		}
+		return nil
+	}())
+
+	results.Add("delete attatchment documented at encounter", deleteAndEnsureStatus(fmt.Sprintf("/attatchments/%v", attatchment.Id), 204, nil))
+
+	var encounters []models.Encounter
+	results.Add("delete encounter", deleteAndEnsureStatus(path, 204, nil))
+	results.Add("get deleted encounter documentation", getAndEnsureStatus(path+"/documentation", nil, 400, nil))
+	results.Add("restore encounter", postAndEnsureStatus(path+"/restore", nil, 204, nil))
+	results.Add("list patient encounters", getAndEnsureStatus(listPath, nil, 200, &encounters))
+	results.Add("test restored encounter is listed", func() error {
+		if len(encounters) != 1 || encounters[0].Id != encounterId {
+			return fmt.Errorf("expected only encounter %v, but got %+v", encounterId, encounters)
+		}
+		return nil
+	}())
+	return results
+}
+
+func testAppointments(results TestResults) TestResults {
+	day := time.Now().AddDate(0, 0, 7).Truncate(24 * time.Hour)
+	date := day.Format(time.DateOnly)
+	practitioner := "Dr. Alice Jones"
+	slot := func(start time.Time) models.SlotRequest {
+		return models.SlotRequest{Practitioner: practitioner, Start: start, End: start.Add(30 * time.Minute)}
+	}
+
+	var first, second models.Slot
+	results.Add("add slot", postAndEnsureStatus("/slots", slot(day.Add(9*time.Hour)), 200, &first))
+	results.Add("add following slot", postAndEnsureStatus("/slots", slot(day.Add(9*time.Hour+30*time.Minute)), 200, &second))
+	results.Add("add overlapping slot", postAndEnsureStatus("/slots", slot(day.Add(9*time.Hour+15*time.Minute)), 409, nil))
+	results.Add("add slot ending before it starts", postAndEnsureStatus("/slots", models.SlotRequest{
+		Practitioner: practitioner,
+		Start:        day.Add(12 * time.Hour),
+		End:          day.Add(11 * time.Hour),
+	}, 400, nil))
+
+	listPath := fmt.Sprintf("/patients/%v/appointments", patientId)
+	var appointment models.Appointment
+	results.Add("book appointment", postAndEnsureStatus(listPath, models.AppointmentRequest{SlotId: first.Id, Reason: "follow up"}, 200, &appointment))
+	appointmentId = appointment.Id
+	path := fmt.Sprintf("/patients/%v/appointments/%v", patientId, appointmentId)
+	results.Add("book appointment in booked slot", postAndEnsureStatus(listPath, models.AppointmentRequest{SlotId: first.Id}, 409, nil))
+	results.Add("book appointment in missing slot", postAndEnsureStatus(listPath, models.AppointmentRequest{SlotId: -1}, 400, nil))
+	results.Add("book appointment for missing patient", postAndEnsureStatus("/patients/-1/appointments", models.AppointmentRequest{SlotId: second.Id}, 400, nil))
+
+	var slots []models.Slot
+	results.Add("list available slots", getAndEnsureStatus("/slots", map[string]string{"date": date, "practitioner": practitioner, "available": "true"}, 200, &slots))
+	results.Add("test booked slot is not available", func() error {
+		if len(slots) != 1 || slots[0].Id != second.Id {
+			return fmt.Errorf("expected only slot %v to be available, but got %+v", second.Id, slots)
+		}
+		return nil
+	}())
+
+	results.Add("reschedule appointment", postAndEnsureStatus(path+"/reschedule", models.AppointmentRequest{SlotId: second.Id}, 200, &appointment))
+	results.Add("test rescheduled appointment is in the new slot", func() error {
+		if appointment.SlotId != second.Id || !appointment.Start.Equal(second.Start) {
+			return fmt.Errorf("expected appointment in slot %v, but got %+v", second.Id, appointment)
+		}
+		return nil
+	}())
+	var appointments []models.Appointment
+	results.Add("list appointments for the week", getAndEnsureStatus("/appointments", map[string]string{"date": date, "period": "week", "practitioner": practitioner}, 200, &appointments))
+	results.Add("test week lists the rescheduled appointment", func() error {
+		if len(appointments) != 1 || appointments[0].Id != appointmentId {
+			return fmt.Errorf("expected only appointment %v, but got %+v", appointmentId, appointments)
+		}
+		return nil
+	}())
+	results.Add("list appointments with invalid period", getAndEnsureStatus("/appointments", map[string]string{"date": date, "period": "month"}, 400, nil))
+
+	results.Add("cancel appointment", postAndEnsureStatus(path+"/cancel", nil, 204, nil))
+	results.Add("cancel cancelled appointment", postAndEnsureStatus(path+"/cancel", nil, 400, nil))
+	results.Add("list appointments for the day", getAndEnsureStatus("/appointments", map[string]string{"date": date, "practitioner": practitioner}, 200, &appointments))
+	results.Add("test cancelled appointment is not listed", func() error {
+		if len(appointments) != 0 {
+			return fmt.Errorf("expected no appointments, but got %+v", appointments)
+		}
+		return nil
+	}())
+
+	//left booked, to be cancelled when the patient is deleted
+	results.Add("book cancelled slot", postAndEnsureStatus(listPath, models.AppointmentRequest{SlotId: second.Id}, 200, &appointment))
+	appointmentId = appointment.Id
+	return results
+}
+
+func testFhirRead(results TestResults) TestResults {
+	var patient fhir.Patient
+	results.Add("read FHIR patient", getAndEnsureStatus(fmt.Sprintf("/fhir/R4/Patient/%v", patientId), nil, 200, &patient))
+	results.Add("test FHIR patient is mapped", func() error {
+		if patient.ResourceType != "Patient" || patient.Id != fmt.Sprint(patientId) || len(patient.Name) != 1 || len(patient.Identifier) != 1 {
+			return fmt.Errorf("expected patient %v with a name and identifier, but got %+v", patientId, patient)
+		}
+		return nil
+	}())
+	var outcome fhir.OperationOutcome
+	results.Add("read missing FHIR patient", getAndEnsureStatus("/fhir/R4/Patient/-1", nil, 404, &outcome))
+	results.Add("test missing FHIR patient returns an operation outcome", func() error {
+		if outcome.ResourceType != "OperationOutcome" || len(outcome.Issue) != 1 || outcome.Issue[0].Code != "not-found" {
+			return fmt.Errorf("expected a not-found operation outcome, but got %+v", outcome)
+		}
+		return nil
+	}())
+
+	var bundle fhir.Bundle
+	containsEntry := func(resourceType string, id int) error {
+		if bundle.ResourceType != "Bundle" || bundle.Type != "searchset" {
+			return fmt.Errorf("expected a searchset bundle, but got %+v", bundle)
+		}
+		for _, entry := range bundle.Entry {
+			if strings.HasSuffix(entry.FullUrl, fmt.Sprintf("/fhir/R4/%v/%v", resourceType, id)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected %v/%v in the bundle, but got %+v", resourceType, id, bundle.Entry)
+	}
+	if len(patient.Name) == 1 && len(patient.Identifier) == 1 {
+		results.Add("search FHIR patients by name and birthdate", getAndEnsureStatus("/fhir/R4/Patient", map[string]string{
+			"name":      strings.ToLower(patient.Name[0].Family),
+			"birthdate": patient.BirthDate,
+		}, 200, &bundle))
+		results.Add("test FHIR patient is found by name and birthdate", containsEntry("Patient", patientId))
+		results.Add("search FHIR patients by identifier", getAndEnsureStatus("/fhir/R4/Patient", map[string]string{
+			"identifier": patient.Identifier[0].Value,
+		}, 200, &bundle))
+		results.Add("test FHIR patient is found by identifier", containsEntry("Patient", patientId))
+	}
+	results.Add("search FHIR patients by unsupported parameter", getAndEnsureStatus("/fhir/R4/Patient", map[string]string{"gender": "female"}, 400, nil))
+
+	var condition fhir.Condition
+	results.Add("read FHIR condition", getAndEnsureStatus(fmt.Sprintf("/fhir/R4/Condition/%v", conditionId), nil, 200, &condition))
+	results.Add("test FHIR condition refers to its patient", func() error {
+		if condition.Subject.Reference != fmt.Sprintf("Patient/%v", patientId) || len(condition.Code.Coding) != 1 {
+			return fmt.Errorf("expected a coded condition of patient %v, but got %+v", patientId, condition)
+		}
+		return nil
+	}())
+	results.Add("search FHIR conditions by patient", getAndEnsureStatus("/fhir/R4/Condition", map[string]string{"patient": fmt.Sprint(patientId)}, 200, &bundle))
+	results.Add("test FHIR condition is found by patient", containsEntry("Condition", conditionId))
+	if len(condition.Code.Coding) == 1 {
+		results.Add("search FHIR conditions by code", getAndEnsureStatus("/fhir/R4/Condition", map[string]string{
+			"code": condition.Code.Coding[0].System + "|" + condition.Code.Coding[0].Code,
+		}, 200, &bundle))
+		results.Add("test FHIR condition is found by code", containsEntry("Condition", conditionId))
+	}
+
+	var document fhir.DocumentReference
+	results.Add("read FHIR document reference", getAndEnsureStatus(fmt.Sprintf("/fhir/R4/DocumentReference/%v", attatchmentId), nil, 200, &document))
+	results.Add("test FHIR document reference links to its binary", func() error {
+		if len(document.Content) != 1 || document.Content[0].Attachment.Url != fmt.Sprintf("Binary/%v", attatchmentId) {
+			return fmt.Errorf("expected content at Binary/%v, but got %+v", attatchmentId, document.Content)
+		}
+		return nil
+	}())
+	results.Add("search FHIR document references by patient", getAndEnsureStatus("/fhir/R4/DocumentReference", map[string]string{"patient": fmt.Sprintf("Patient/%v", patientId)}, 200, &bundle))
+	results.Add("test FHIR document reference is found by patient", containsEntry("DocumentReference", attatchmentId))
+	results.Add("read FHIR binary", getAndEnsureStatus(fmt.Sprintf("/fhir/R4/Binary/%v", attatchmentId), nil, 200, nil))
+	return results
+}
+
+func testFhirWrite(results TestResults) TestResults {
+	newPatient := func(identifier string) fhir.Patient {
+		return fhir.Patient{
+			ResourceType: "Patient",
+			Identifier:   []fhir.Identifier{{Value: identifier}},
+			Name:         []fhir.HumanName{{Family: "Garcia", Given: []string{"Maria"}}},
+			Telecom:      []fhir.ContactPoint{{System: "phone", Value: "8045550100"}},
+			BirthDate:    "1985-06-15",
+			Address:      []fhir.Address{{Line: []string{"9 elm street"}, City: "Richmond", State: "VA"}},
+		}
+	}
+	newCondition := func(subject string, code string) fhir.Condition {
+		return fhir.Condition{
+			ResourceType: "Condition",
+			Code:         fhir.CodeableConcept{Coding: []fhir.Coding{{System: fhir.CodeSystemICD10CM, Code: code}}},
+			Subject:      fhir.Reference{Reference: subject},
+			RecordedDate: "2024-03-01",
+		}
+	}
+
+	var patient fhir.Patient
+	results.Add("create FHIR patient", postAndEnsureStatus("/fhir/R4/Patient", newPatient("fhir-001"), 201, &patient))
+	results.Add("create FHIR patient with duplicate identifier", postAndEnsureStatus("/fhir/R4/Patient", newPatient("fhir-001"), 409, nil))
+	noPhone := newPatient("fhir-002")
+	noPhone.Telecom = nil
+	var outcome fhir.OperationOutcome
+	results.Add("create FHIR patient without phone number", postAndEnsureStatus("/fhir/R4/Patient", noPhone, 400, &outcome))
+	results.Add("test invalid FHIR patient returns an operation outcome", func() error {
+		if outcome.ResourceType != "OperationOutcome" || len(outcome.Issue) != 1 || outcome.Issue[0].Code != "invalid" {
+			return fmt.Errorf("expected an invalid operation outcome, but got %+v", outcome)
+		}
+		return nil
+	}())
+
+	updated := newPatient("fhir-001")
+	updated.Id = patient.Id
+	updated.Name = []fhir.HumanName{{Text: "Maria Elena Garcia"}}
+	results.Add("update FHIR patient", putAndEnsureStatus("/fhir/R4/Patient/"+patient.Id, updated, 200, nil))
+	results.Add("update FHIR patient with mismatched id", putAndEnsureStatus("/fhir/R4/Patient/-1", updated, 400, nil))
+	results.Add("read updated FHIR patient", getAndEnsureStatus("/fhir/R4/Patient/"+patient.Id, nil, 200, &patient))
+	results.Add("test FHIR patient is updated", func() error {
+		if len(patient.Name) != 1 || patient.Name[0].Text != "Maria Elena Garcia" || len(patient.Address) != 1 || patient.Address[0].Text != "9 elm street, Richmond, VA" {
+			return fmt.Errorf("expected the updated name and address, but got %+v", patient)
+		}
+		return nil
+	}())
+
+	var condition fhir.Condition
+	results.Add("create FHIR condition", postAndEnsureStatus("/fhir/R4/Condition", newCondition("Patient/"+patient.Id, "E11.9"), 201, &condition))
+	results.Add("create FHIR condition with unknown code", postAndEnsureStatus("/fhir/R4/Condition", newCondition("Patient/"+patient.Id, "ABCD"), 400, nil))
+	results.Add("update FHIR condition of another patient", putAndEnsureStatus("/fhir/R4/Condition/"+condition.Id, newCondition(fmt.Sprintf("Patient/%v", patientId), "E11.9"), 400, nil))
+	results.Add("update FHIR condition", putAndEnsureStatus("/fhir/R4/Condition/"+condition.Id, newCondition("Patient/"+patient.Id, "I10"), 200, &condition))
+	results.Add("test FHIR condition is updated", func() error {
+		if len(condition.Code.Coding) != 1 || condition.Code.Coding[0].Code != "I10" {
+			return fmt.Errorf("expected condition coded I10, but got %+v", condition.Code)
+		}
+		return nil
+	}())
+
+	transaction := func(identifier string, code string) fhir.Bundle {
+		reference := "urn:uuid:" + identifier
+		return fhir.Bundle{
+			ResourceType: "Bundle",
+			Type:         "transaction",
+			Entry: []fhir.BundleEntry{
+				{FullUrl: reference, Resource: newPatient(identifier), Request: &fhir.BundleEntryRequest{Method: http.MethodPost, Url: "Patient"}},
+				{Resource: newCondition(reference, code), Request: &fhir.BundleEntryRequest{Method: http.MethodPost, Url: "Condition"}},
+			},
+		}
+	}
+	var response fhir.Bundle
+	results.Add("post FHIR transaction", postAndEnsureStatus("/fhir/R4", transaction("fhir-003", "E11.9"), 200, &response))
+	results.Add("test FHIR transaction creates the patient and condition", func() error {
+		if response.Type != "transaction-response" || len(response.Entry) != 2 || response.Entry[0].Response == nil || response.Entry[1].Response == nil {
+			return fmt.Errorf("expected a transaction-response with 2 entries, but got %+v", response)
+		}
+		if !strings.HasPrefix(response.Entry[0].Response.Location, "Patient/") || !strings.HasPrefix(response.Entry[1].Response.Location, "Condition/") {
+			return fmt.Errorf("expected a created patient and condition, but got %+v and %+v", response.Entry[0].Response, response.Entry[1].Response)
+		}
+		return nil
+	}())
+	results.Add("post FHIR transaction with an invalid condition", postAndEnsureStatus("/fhir/R4", transaction("fhir-004", "ABCD"), 400, nil))
+	var bundle fhir.Bundle
+	results.Add("search FHIR patients from the failed transaction", getAndEnsureStatus("/fhir/R4/Patient", map[string]string{"identifier": "fhir-004"}, 200, &bundle))
+	results.Add("test failed FHIR transaction is rolled back", func() error {
+		if bundle.Total == nil || *bundle.Total != 0 {
+			return fmt.Errorf("expected no patients from the failed transaction, but got %+v", bundle.Entry)
+		}
+		return nil
+	}())
+	return results
+}
+
+func testHl7Messages(results TestResults) TestResults {
+	client, err := inboundmllp.Dial("localhost:2575")
+	results.Add("connect to MLLP server", err)
+	if err != nil {
+		return results
+	}
+	defer client.Close()
+
+	message := func(event string, segments ...string) string {
+		msh := fmt.Sprintf(`MSH|^~\&|REGADT|HOSP|MCG|MCG|20240301101500||ADT^%v|MSG-%v|P|2.5.1`, event, time.Now().UnixNano())
+		return strings.Join(append([]string{msh}, segments...), "\r")
+	}
+	sendAndEnsureAck := func(message string, code string) error {
+		ack, err := client.Send(message)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(ack, "\rMSA|"+code+"|") {
+			return fmt.Errorf("expected an %v acknowledgement, but got %q", code, ack)
+		}
+		return nil
+	}
+	var patients []models.Patient
+	findPatient := func(identifier string) error {
+		return getAndEnsureStatus("/patients", models.PatientSearch{ExternalIdentifier: identifier}, 200, &patients)
+	}
+	ensurePatient := func(name string, conditions int) error {
+		if len(patients) != 1 || patients[0].Name != name || len(patients[0].DiagnosedConditions) != conditions {
+			return fmt.Errorf("expected patient %v with %v conditions, but got %+v", name, conditions, patients)
+		}
+		return nil
+	}
+
+	results.Add("send HL7 registration", sendAndEnsureAck(message("A04",
+		"PID|1||hl7-001^^^HOSP^MR||Lee^Sam||19800704||||||8045550111",
+		"DG1|1||E11.9^Type 2 diabetes^I10||20240301"), "AA"))
+	results.Add("search HL7 registered patient", findPatient("hl7-001"))
+	results.Add("test HL7 registration creates the patient and condition", ensurePatient("Sam Lee", 1))
+
+	results.Add("send HL7 update", sendAndEnsureAck(message("A08",
+		"PID|1||hl7-001^^^HOSP^MR||Lee^Samuel",
+		"DG1|1||E119^Type 2 diabetes^I10"), "AA"))
+	results.Add("search HL7 updated patient", findPatient("hl7-001"))
+	results.Add("test HL7 update renames the patient without repeating the condition", ensurePatient("Samuel Lee", 1))
+
+	results.Add("send HL7 registration of duplicate record", sendAndEnsureAck(message("A04",
+		"PID|1||hl7-002^^^HOSP^MR||Lee^Sam||19800704||||||8045550111",
+		"DG1|1||I10^^I10"), "AA"))
+	results.Add("search HL7 duplicate record", findPatient("hl7-002"))
+	if len(patients) != 1 {
+		return results
+	}
+	priorId := patients[0].Id
+	var encounter models.Encounter
+	results.Add("add encounter to HL7 duplicate record", postAndEnsureStatus(fmt.Sprintf("/patients/%v/encounters", priorId), models.EncounterRequest{
+		Type:  models.EncounterTypeAmbulatory,
+		Start: time.Now().Add(-time.Hour),
+	}, 200, &encounter))
+	var attatchment models.Attatchment
+	results.Add("add attatchment to HL7 duplicate record", postAttatchmentToPatient(priorId, models.Attatchment{
+		Name:        "referral.txt",
+		Description: "referral letter",
+		Type:        "text/plain",
+		Data:        []byte("referred for diabetes review"),
+		EncounterId: encounter.Id,
+	}, 200, &attatchment))
+
+	results.Add("send HL7 merge", sendAndEnsureAck(message("A40", "PID|1||hl7-001^^^HOSP^MR", "MRG|hl7-002^^^HOSP^MR"), "AA"))
+	results.Add("search HL7 merged patient", findPatient("hl7-001"))
+	results.Add("test HL7 merge moves the conditions", ensurePatient("Samuel Lee", 2))
+	if len(patients) != 1 {
+		return results
+	}
+	mergedId := patients[0].Id
+	results.Add("test HL7 merge moves the encounter", getAndEnsureStatus(fmt.Sprintf("/patients/%v/encounters/%v", mergedId, encounter.Id), nil, 200, nil))
+	results.Add("test HL7 merge moves the attatchment", func() error {
+		var moved models.Attatchment
+		err := getAndEnsureStatus(fmt.Sprintf("/attatchments/%v", attatchment.Id), nil, 200, &moved)
+		if err != nil {
+			return err
+		}
+		if moved.PatientId != mergedId || moved.EncounterId != encounter.Id {
+			return fmt.Errorf("expected attatchment of patient %v at encounter %v, but got %+v", mergedId, encounter.Id, moved)
+		}
+		return nil
+	}())
+	results.Add("test HL7 merge is recorded as a link", func() error {
+		var links []models.PatientLink
+		err := getAndEnsureStatus(fmt.Sprintf("/patients/%v/links", priorId), nil, 200, &links)
+		if err != nil {
+			return err
+		}
+		if len(links) != 1 || links[0].PatientId != mergedId || links[0].MergedPatientId != priorId {
+			return fmt.Errorf("expected link from %v to %v but got %+v", priorId, mergedId, links)
+		}
+		return nil
+	}())
+	results.Add("search HL7 prior patient", findPatient("hl7-002"))
+	results.Add("test HL7 merge deletes the prior patient", func() error {
+		if len(patients) != 0 {
+			return fmt.Errorf("expected the prior patient to be deleted, but got %+v", patients)
 		}
 		return nil
 	}())
 
+	results.Add("send HL7 registration with unknown code", sendAndEnsureAck(message("A04",
+		"PID|1||hl7-003^^^HOSP^MR||Lee^Alex||19800704||||||8045550111",
+		"DG1|1||ABCD^^I10"), "AE"))
+	results.Add("search HL7 patient with unknown code", findPatient("hl7-003"))
+	results.Add("test failed HL7 message is rolled back", func() error {
+		if len(patients) != 0 {
+			return fmt.Errorf("expected no patient from the failed message, but got %+v", patients)
+		}
+		return nil
+	}())
+	results.Add("send unsupported HL7 event", sendAndEnsureAck(message("A03", "PID|1||hl7-001"), "AR"))
 	return results
 }
 
-func testDeleteAttatchment(results TestResults) TestResults {
-	path := fmt.Sprintf("/attatchments/%v", conditionId)
-	searchPath := "/patients"
-	results.Add("delete diagnosed condition", deleteAndEnsureStatus(path, 204, nil))
+func testImports(results TestResults) TestResults {
+	var job models.ImportJob
+	waitForImport := func() error {
+		for deadline := time.Now().Add(time.Second * 10); job.Status != models.ImportStatusCompleted; time.Sleep(time.Millisecond * 50) {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("expected import %v to complete, but it is %+v", job.Id, job)
+			}
+			err := getAndEnsureStatus(fmt.Sprintf("/imports/%v", job.Id), nil, 200, &job)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	ensureImported := func(patients int, conditions int, rowErrors int) error {
+		if job.ProcessedRows != job.TotalRows || job.ImportedPatients != patients || job.ImportedConditions != conditions || len(job.Errors) != rowErrors {
+			return fmt.Errorf("expected %v patients and %v conditions imported with %v errors, but got %+v", patients, conditions, rowErrors, job)
+		}
+		return nil
+	}
 	var patients []models.Patient
-	results.Add("test search patients by diagnosed condition after deletion", getAndEnsureStatus(searchPath, models.PatientSearch{
-		AttatchmentType: "MRI",
-	}, 200, &patients))
-	results.Add("test that returned patient has only 1 attatchment after delete", func() error {
-		if len(patients) != 1 {
-			return fmt.Errorf("expected 1 patient, but got %v", len(patients))
+	ensurePatients := func(identifier string, count int) error {
+		err := getAndEnsureStatus("/patients", models.PatientSearch{ExternalIdentifier: identifier}, 200, &patients)
+		if err != nil {
+			return err
 		}
-		if len(patients[0].Attatchments) != 1 {
-			return fmt.Errorf("expected 1 attatchment for patient, but got %v", len(patients[0].Attatchments))
+		if len(patients) != count {
+			return fmt.Errorf("expected %v patients with externalIdentifier %v, but got %+v", count, identifier, patients)
 		}
+		return nil
+	}
 
+	csvData := []byte("externalIdentifier,name,phoneNumber,dateOfBirth,conditionCode,conditionDate\n" +
+		"import-001,Ada Park,8045550121,1970-01-31,E11.9,2024-03-01\n" +
+		"import-001,,,,I10,2024-03-01\n" +
+		"import-002,Ben Park,8045550122,1971-02-28,,\n" +
+		"import-003,Cy,8045550123,1972-03-31,,\n" +
+		"hl7-001,Sam Lee,8045550111,1980-07-04,,\n")
+
+	results.Add("test import with unsupported column", postImport(models.ImportFormatCSV, false, []byte("externalIdentifier,ssn\n"), 400, nil))
+	results.Add("test dry run import", postImport(models.ImportFormatCSV, true, csvData, 200, &job))
+	results.Add("wait for dry run import", waitForImport())
+	results.Add("test dry run import reports what would be imported", ensureImported(2, 2, 2))
+	results.Add("test dry run import imports nothing", ensurePatients("import-001", 0))
+
+	job = models.ImportJob{}
+	results.Add("test csv import", postImport(models.ImportFormatCSV, false, csvData, 200, &job))
+	results.Add("wait for csv import", waitForImport())
+	results.Add("test csv import reports invalid and duplicate rows", func() error {
+		err := ensureImported(2, 2, 2)
+		if err != nil {
+			return err
+		}
+		if job.Errors[0].Row != 5 || job.Errors[1].Row != 6 || job.Errors[1].ExternalIdentifier != "hl7-001" {
+			return fmt.Errorf("expected errors on rows 5 and 6, but got %+v", job.Errors)
+		}
 		return nil
 	}())
-
-	return results
-}
-
-func testDeleteCondition(results TestResults) TestResults {
-	path := fmt.Sprintf("/diagnosedConditions/%v", conditionId)
-	searchPath := "/patients"
-	results.Add("delete diagnosed condition", deleteAndEnsureStatus(path, 204, nil))
-	var patients []models.Patient
-	results.Add("test search patients by diagnosed condition after deletion", getAndEnsureStatus(searchPath, models.PatientSearch{
-		Name:                   "mismatch",
-		DiagnosedConditionName: "some condition",
-	}, 200, &patients))
-	results.Add("test that patients are not returned for deleted condition", func() error {
-		if len(patients) != 0 {
-			return fmt.Errorf("expected no patients, but got %v", len(patients))
+	results.Add("test csv import creates the patient", ensurePatients("import-001", 1))
+	results.Add("test csv import adds the patient's conditions", func() error {
+		if len(patients) != 1 || len(patients[0].DiagnosedConditions) != 2 {
+			return fmt.Errorf("expected 1 patient with 2 conditions, but got %+v", patients)
 		}
 		return nil
 	}())
 
+	job = models.ImportJob{}
+	ndjsonData := []byte(`{"externalIdentifier":"import-004","name":"Dee Park","phoneNumber":"8045550124","dateOfBirth":"1973-04-30",` +
+		`"diagnosedConditions":[{"code":"44054006","codeSystem":"SNOMED-CT","date":"2024-03-01"}]}` + "\n" +
+		`{"externalIdentifier":"import-001","name":"Ada Park","phoneNumber":"8045550121","dateOfBirth":"1970-01-31"}` + "\n")
+	results.Add("test ndjson import", postImport(models.ImportFormatNDJSON, false, ndjsonData, 200, &job))
+	results.Add("wait for ndjson import", waitForImport())
+	results.Add("test ndjson import skips patients already recorded", ensureImported(1, 1, 1))
+	results.Add("test ndjson import creates the patient", ensurePatients("import-004", 1))
+	results.Add("test get import which does not exist", getAndEnsureStatus("/imports/9999", nil, 400, nil))
 	return results
 }
 
-func testSearchPatients(results TestResults) TestResults {
-	path := "/patients"
-	realAuth := authToken
-	authToken = "INVALID"
-	results.Add("test search patients without auth token", getAndEnsureStatus(path, nil, 401, nil))
-	authToken = realAuth
+func postImport(format string, dryRun bool, data []byte, status int, respBodyPntr any) error {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writeFormField(writer, "format", format)
+	writeFormField(writer, "dryRun", fmt.Sprint(dryRun))
+	part, _ := writer.CreateFormFile("data", "patients."+format)
+	io.Copy(part, bytes.NewReader(data))
+	writer.Close()
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost:8080/imports", body)
+	r.Header.Add("Content-Type", writer.FormDataContentType())
+	r.Header.Add("Authorization", "Bearer "+authToken)
+	return doAndEnsureStatus(r, status, respBodyPntr)
+}
 
-	var patients []models.Patient
-	results.Add("test search patients with no conditions", getAndEnsureStatus(path, models.PatientSearch{}, 200, &patients))
-	results.Add("test no patients are returned if no conditions are given", func() error {
-		if len(patients) > 0 {
-			return fmt.Errorf("expected no patients, but found %v", len(patients))
+func testFhirExport(results TestResults) TestResults {
+	kickOff := func(query string, prefer string, status int) (string, error) {
+		r, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/fhir/R4/$export"+query, nil)
+		r.Header.Add("Accept", "application/fhir+json")
+		r.Header.Add("Authorization", "Bearer "+authToken)
+		if prefer != "" {
+			r.Header.Add("Prefer", prefer)
 		}
-		return nil
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != status {
+			return "", fmt.Errorf("expected status %v for export kick-off, but got %v", status, resp.Status)
+		}
+		return resp.Header.Get("Content-Location"), nil
+	}
+	results.Add("test FHIR export without asking for an asynchronous response", func() error {
+		_, err := kickOff("", "", 400)
+		return err
 	}())
+	results.Add("test FHIR export of an unsupported type", func() error {
+		_, err := kickOff("?_type=Observation", "respond-async", 400)
+		return err
+	}())
+	statusUrl, err := kickOff("?_type=Patient,Condition", "respond-async", 202)
+	results.Add("start FHIR export", err)
+	if err != nil {
+		return results
+	}
 
-	results.Add("test search patients with no matching criteria", getAndEnsureStatus(path, models.PatientSearch{
-		Name:                   "Invalid name",
-		DiagnosedConditionName: "invalid name",
-	}, 200, &patients))
-	results.Add("test no patients are returned if no matching criteria", func() error {
-		if len(patients) > 0 {
-			return fmt.Errorf("expected no patients, but found %v", len(patients))
+	var manifest fhir.ExportManifest
+	results.Add("poll FHIR export until complete", func() error {
+		statusPath := strings.TrimPrefix(statusUrl, "http://localhost:8080")
+		for deadline := time.Now().Add(time.Second * 10); time.Now().Before(deadline); time.Sleep(time.Millisecond * 50) {
+			err := getAndEnsureStatus(statusPath, nil, 200, &manifest)
+			if err == nil {
+				return nil
+			}
+			if !strings.Contains(err.Error(), "202") {
+				return err
+			}
+		}
+		return fmt.Errorf("expected export at %v to complete", statusUrl)
+	}())
+	results.Add("test FHIR export manifest lists a file of each requested type", func() error {
+		if !manifest.RequiresAccessToken || len(manifest.Output) != 2 || manifest.Output[0].Type != "Patient" || manifest.Output[1].Type != "Condition" {
+			return fmt.Errorf("expected a Patient and a Condition file, but got %+v", manifest)
 		}
 		return nil
 	}())
+	if len(manifest.Output) == 0 {
+		return results
+	}
 
-	results.Add("test search patients by name", getAndEnsureStatus(path, models.PatientSearch{
-		Name: "Jane Smith",
-	}, 200, &patients))
-
-	results.Add("test that correct patients are returned by name", func() error {
-		if len(patients) != 1 {
-			return fmt.Errorf("expected 1 patient, but found %v", len(patients))
+	results.Add("test FHIR export file has one patient per line", func() error {
+		r, _ := http.NewRequest(http.MethodGet, manifest.Output[0].Url, nil)
+		r.Header.Add("Authorization", "Bearer "+authToken)
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			return err
 		}
-		if len(patients[0].Attatchments) != 2 {
-			return fmt.Errorf("expected patient to have 2 attatchments, but had %v", len(patients[0].Attatchments))
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "application/fhir+ndjson" {
+			return fmt.Errorf("expected an ndjson file, but got %v %v", resp.Status, resp.Header.Get("Content-Type"))
 		}
-		if len(patients[0].DiagnosedConditions) != 1 {
-			return fmt.Errorf("expected patient to have 1 diagnosed condition, but had %v", len(patients[0].DiagnosedConditions))
+		lines := 0
+		found := false
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var patient fhir.Patient
+			err := json.Unmarshal(scanner.Bytes(), &patient)
+			if err != nil || patient.ResourceType != "Patient" {
+				return fmt.Errorf("expected a patient on line %v, but got %v", lines+1, scanner.Text())
+			}
+			lines++
+			found = found || patient.Id == fmt.Sprint(patientId)
+		}
+		if lines != manifest.Output[0].Count || !found {
+			return fmt.Errorf("expected %v patients including patient %v, but got %v", manifest.Output[0].Count, patientId, lines)
 		}
 		return nil
 	}())
+	results.Add("test FHIR export file which was not exported", getAndEnsureStatus(strings.Replace(strings.TrimPrefix(manifest.Output[0].Url, "http://localhost:8080"), "/Patient", "/DocumentReference", 1), nil, 404, nil))
+	statusPath := strings.TrimPrefix(statusUrl, "http://localhost:8080")
+	results.Add("delete FHIR export", deleteAndEnsureStatus(statusPath, 202, nil))
+	results.Add("test FHIR export status after it was deleted", getAndEnsureStatus(statusPath, nil, 404, nil))
+	results.Add("test FHIR export file after its export was deleted", getAndEnsureStatus(strings.TrimPrefix(manifest.Output[0].Url, "http://localhost:8080"), nil, 404, nil))
+	return results
+}
 
-	patients = nil
+func testCcda(results TestResults) TestResults {
+	results.Add("test get C-CDA of patient which does not exist", getAndEnsureStatus("/patients/1000000/ccda", nil, 400, nil))
 
-	results.Add("test search patients by diagnosed condition with mismatching name", getAndEnsureStatus(path, models.PatientSearch{
-		Name:                   "mismatch",
-		DiagnosedConditionName: "some condition",
-	}, 200, &patients))
+	type id struct {
+		Extension string `xml:"extension,attr"`
+	}
+	var document struct {
+		XMLName  xml.Name
+		Sections []struct {
+			Title   string `xml:"title"`
+			Entries []id   `xml:"entry>act>id"`
+		} `xml:"component>structuredBody>component>section"`
+		PatientIds []id `xml:"recordTarget>patientRole>id"`
+	}
+	results.Add("test get C-CDA of patient", func() error {
+		path := fmt.Sprintf("/patients/%v/ccda", patientId)
+		r, _ := http.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil)
+		r.Header.Add("Authorization", "Bearer "+authToken)
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			return fmt.Errorf("error calling %v %w", path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return fmt.Errorf("expected status 200 but got %v", resp.Status)
+		}
+		if resp.Header.Get("Content-Type") != "application/xml" {
+			return fmt.Errorf("expected content type application/xml but got %v", resp.Header.Get("Content-Type"))
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&document)
+		if err != nil {
+			return fmt.Errorf("error decoding C-CDA %w", err)
+		}
+		if document.XMLName.Space != "urn:hl7-org:v3" || document.XMLName.Local != "ClinicalDocument" {
+			return fmt.Errorf("expected a ClinicalDocument but got %v", document.XMLName)
+		}
+		if len(document.PatientIds) == 0 || document.PatientIds[0].Extension != fmt.Sprint(patientId) {
+			return fmt.Errorf("expected the document to identify patient %v but got %+v", patientId, document.PatientIds)
+		}
+		return nil
+	}())
+	results.Add("test C-CDA lists the patient's problems and documents", func() error {
+		entries := map[string][]id{}
+		for _, section := range document.Sections {
+			entries[section.Title] = section.Entries
+		}
+		if !slices.Contains(entries["Problems"], id{fmt.Sprint(conditionId)}) {
+			return fmt.Errorf("expected condition %v in the problems section but got %v", conditionId, entries["Problems"])
+		}
+		if !slices.Contains(entries["Attatched Documents"], id{fmt.Sprint(attatchmentId)}) {
+			return fmt.Errorf("expected attatchment %v in the documents section but got %v", attatchmentId, entries["Attatched Documents"])
+		}
+		return nil
+	}())
+	return results
+}
 
-	results.Add("test that correct patients are returned by diagnosed condition", func() error {
-		if len(patients) != 1 {
-			return fmt.Errorf("expected 1 patient, but found %v", len(patients))
+func testPatientSummary(results TestResults) TestResults {
+	results.Add("test get summary of patient which does not exist", getAndEnsureStatus("/patients/1000000/summary.pdf", nil, 400, nil))
+	results.Add("test get summary of patient", func() error {
+		path := fmt.Sprintf("/patients/%v/summary.pdf", patientId)
+		r, _ := http.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil)
+		r.Header.Add("Authorization", "Bearer "+authToken)
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			return fmt.Errorf("error calling %v %w", path, err)
 		}
-		if len(patients[0].Attatchments) != 2 {
-			return fmt.Errorf("expected patient to have 2 attatchments, but had %v", len(patients[0].Attatchments))
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return fmt.Errorf("expected status 200 but got %v", resp.Status)
 		}
-		if len(patients[0].DiagnosedConditions) != 1 {
-			return fmt.Errorf("expected patient to have 1 diagnosed condition, but had %v", len(patients[0].DiagnosedConditions))
+		if resp.Header.Get("Content-Type") != "application/pdf" {
+			return fmt.Errorf("expected content type application/pdf but got %v", resp.Header.Get("Content-Type"))
+		}
+		summary, _ := io.ReadAll(resp.Body)
+		if !bytes.HasPrefix(summary, []byte("%PDF-")) || !bytes.Contains(summary, []byte("%%EOF")) {
+			return fmt.Errorf("expected a PDF but got %q", summary[:min(len(summary), 20)])
 		}
 		return nil
 	}())
+	return results
+}
 
-	results.Add("test search patients by attatchment type ", getAndEnsureStatus(path, models.PatientSearch{
-		AttatchmentType: "MRI",
-	}, 200, &patients))
+func testDuplicatePatients(results TestResults) TestResults {
+	dateOfBirth, _ := time.Parse(time.DateOnly, "1961-03-14")
+	patient := models.Patient{Name: "Bartholomew Quinton", PhoneNumber: "2025550143", ExternalIdentifier: "dup-1", DateOfBirth: dateOfBirth}
+	duplicate := models.Patient{Name: "Bartholomew Quinten", PhoneNumber: "(202) 555-0143", ExternalIdentifier: "dup-2", DateOfBirth: dateOfBirth}
+	results.Add("create patient to merge into", postAndEnsureStatus("/patients", patient, 200, &patient))
+	results.Add("create duplicate patient", postAndEnsureStatus("/patients", duplicate, 200, &duplicate))
+	var condition models.DiagnosedCondition
+	results.Add("add condition to duplicate patient", postAndEnsureStatus(fmt.Sprintf("/patients/%v/diagnosedConditions", duplicate.Id), models.DiagnosedCondition{
+		Name: "Essential hypertension",
+		Code: "I10",
+		Date: time.Now(),
+	}, 200, &condition))
+	var allergy models.Allergy
+	results.Add("add allergy to duplicate patient", postAndEnsureStatus(fmt.Sprintf("/patients/%v/allergies", duplicate.Id), models.AllergyRequest{
+		Allergen: "Latex",
+		Category: models.AllergyCategoryEnvironment,
+	}, 200, &allergy))
+
+	var duplicates []models.PotentialDuplicate
+	results.Add("get potential duplicates", getAndEnsureStatus(fmt.Sprintf("/patients/%v/potential-duplicates", patient.Id), nil, 200, &duplicates))
+	results.Add("test potential duplicates include the duplicate patient", func() error {
+		if len(duplicates) == 0 || duplicates[0].Patient.Id != duplicate.Id || duplicates[0].Probability < 0.9 {
+			return fmt.Errorf("expected patient %v to be the most likely duplicate but got %+v", duplicate.Id, duplicates)
+		}
+		return nil
+	}())
 
-	results.Add("test that correct patients are returned by attatchment type", func() error {
-		if len(patients) != 1 {
-			return fmt.Errorf("expected 1 patient, but found %v", len(patients))
+	mergePath := fmt.Sprintf("/patients/%v/merge", patient.Id)
+	results.Add("test merge patient into themselves", postAndEnsureStatus(mergePath, map[string]int{"duplicatePatientId": patient.Id}, 400, nil))
+	var link models.PatientLink
+	results.Add("merge duplicate patient", postAndEnsureStatus(mergePath, map[string]int{"duplicatePatientId": duplicate.Id}, 200, &link))
+	results.Add("test merged patient is deleted", func() error {
+		var found []models.Patient
+		err := getAndEnsureStatus("/patients", map[string]string{"externalIdentifier": "dup-2"}, 200, &found)
+		if err != nil {
+			return err
 		}
-		if len(patients[0].Attatchments) != 2 {
-			return fmt.Errorf("expected patient to have 2 attatchments, but had %v", len(patients[0].Attatchments))
+		if len(found) != 0 {
+			return fmt.Errorf("expected merged patient to be deleted but found %+v", found)
 		}
-		if len(patients[0].DiagnosedConditions) != 1 {
-			return fmt.Errorf("expected patient to have 1 diagnosed condition, but had %v", len(patients[0].DiagnosedConditions))
+		return nil
+	}())
+	results.Add("test merged patient's conditions are moved", func() error {
+		var moved models.DiagnosedCondition
+		err := getAndEnsureStatus(fmt.Sprintf("/diagnosedConditions/%v", condition.Id), nil, 200, &moved)
+		if err != nil {
+			return err
+		}
+		if moved.PatientId != patient.Id {
+			return fmt.Errorf("expected condition %v to belong to patient %v but got %v", condition.Id, patient.Id, moved.PatientId)
+		}
+		return nil
+	}())
+	results.Add("test merged patient's allergies are moved", getAndEnsureStatus(fmt.Sprintf("/patients/%v/allergies/%v", patient.Id, allergy.Id), nil, 200, nil))
+	results.Add("test merge is recorded as a link", func() error {
+		var links []models.PatientLink
+		err := getAndEnsureStatus(fmt.Sprintf("/patients/%v/links", duplicate.Id), nil, 200, &links)
+		if err != nil {
+			return err
+		}
+		if len(links) != 1 || links[0] != link || link.PatientId != patient.Id || link.MergedPatientId != duplicate.Id {
+			return fmt.Errorf("expected link from %v to %v but got %+v", duplicate.Id, patient.Id, links)
 		}
 		return nil
 	}())
-
 	return results
 }
 
@@ -218,10 +1498,27 @@ func testAddDiagnosedConditionToPatient(results TestResults) TestResults {
 		Name: "some condition",
 	}, 400, nil))
 	patientId = realPatientId
+	results.Add("test add condition with unknown code", postAndEnsureStatus(path, models.DiagnosedCondition{
+		Name: "some condition",
+		Code: "ABCD",
+		Date: time.Now(),
+	}, 400, nil))
+	var namedCondition models.DiagnosedCondition
+	results.Add("test add condition without name", postAndEnsureStatus(path, models.DiagnosedCondition{
+		Code: "i10",
+		Date: time.Now().Add(-time.Hour * 24 * 365),
+	}, 200, &namedCondition))
+	results.Add("test condition name defaults to code name", func() error {
+		if namedCondition.Code != "I10" || namedCondition.Name != "Essential (primary) hypertension" {
+			return fmt.Errorf("expected canonical code and name but got %+v", namedCondition)
+		}
+		return nil
+	}())
+	results.Add("test delete condition without name", deleteAndEnsureStatus(fmt.Sprintf("/diagnosedConditions/%v", namedCondition.Id), 204, nil))
 	var diagnosedCondition models.DiagnosedCondition
 	results.Add("test add condition with all fields", postAndEnsureStatus(path, models.DiagnosedCondition{
 		Name:        "some condition",
-		Code:        "ABCD",
+		Code:        "E11.9",
 		Description: "something",
 		Date:        time.Now(),
 	}, 200, &diagnosedCondition))
@@ -230,6 +1527,87 @@ func testAddDiagnosedConditionToPatient(results TestResults) TestResults {
 
 }
 
+func testSearchICD10Codes(results TestResults) TestResults {
+	var codes []models.Code
+	results.Add("test search icd10 codes by code", getAndEnsureStatus("/public/codes/icd10", map[string]string{"q": "e11.9"}, 200, &codes))
+	results.Add("test icd10 code search finds code", func() error {
+		if len(codes) != 1 || codes[0].Code != "E11.9" {
+			return fmt.Errorf("expected only E11.9 but got %+v", codes)
+		}
+		return nil
+	}())
+	results.Add("test search icd10 codes by name", getAndEnsureStatus("/public/codes/icd10", map[string]string{"q": "hypertension", "limit": "1"}, 200, &codes))
+	results.Add("test icd10 name search is limited", func() error {
+		if len(codes) != 1 {
+			return fmt.Errorf("expected 1 code but got %v", len(codes))
+		}
+		return nil
+	}())
+	results.Add("test search icd10 codes without query", getAndEnsureStatus("/public/codes/icd10", nil, 400, nil))
+	return results
+}
+
+func testUpdateDiagnosedCondition(results TestResults) TestResults {
+	path := fmt.Sprintf("/diagnosedConditions/%v", conditionId)
+	date := time.Now().Add(-time.Hour)
+
+	results.Add("test update condition with missing fields", putAndEnsureStatus(path, models.DiagnosedConditionRequest{
+		Name: "some condition",
+	}, 400, nil))
+	results.Add("test update missing condition", putAndEnsureStatus("/diagnosedConditions/-1", models.DiagnosedConditionRequest{
+		Name: "some condition",
+		Code: "E11.9",
+		Date: date,
+	}, 400, nil))
+	var condition models.DiagnosedCondition
+	results.Add("test update condition", putAndEnsureStatus(path, models.DiagnosedConditionRequest{
+		Name:        "some condition",
+		Code:        "E11.9",
+		Description: "updated",
+		Date:        date,
+	}, 200, &condition))
+	results.Add("test updated condition keeps its id", func() error {
+		if condition.Id != conditionId || condition.Description != "updated" {
+			return fmt.Errorf("expected condition %v to be updated but got %+v", conditionId, condition)
+		}
+		return nil
+	}())
+
+	description := "patched"
+	results.Add("test patch condition", patchAndEnsureStatus(path, models.DiagnosedConditionPatch{
+		Description: &description,
+	}, 200, nil))
+	condition = models.DiagnosedCondition{}
+	results.Add("test get condition", getAndEnsureStatus(path, nil, 200, &condition))
+	results.Add("test patch only changed provided fields", func() error {
+		if condition.Description != "patched" || condition.Name != "some condition" || condition.Code != "E11.9" {
+			return fmt.Errorf("unexpected condition after patch %+v", condition)
+		}
+		return nil
+	}())
+
+	listPath := fmt.Sprintf("/patients/%v/diagnosedConditions", patientId)
+	var conditions []models.DiagnosedCondition
+	results.Add("test list patient conditions by code", getAndEnsureStatus(listPath, map[string]string{"code": "E11.9"}, 200, &conditions))
+	results.Add("test listed conditions match code", func() error {
+		if len(conditions) != 1 {
+			return fmt.Errorf("expected 1 condition, but got %v", len(conditions))
+		}
+		return nil
+	}())
+	results.Add("test list patient conditions by date range", getAndEnsureStatus(listPath, map[string]string{
+		"from": time.Now().Format(time.RFC3339),
+	}, 200, &conditions))
+	results.Add("test conditions outside of date range are not listed", func() error {
+		if len(conditions) != 0 {
+			return fmt.Errorf("expected no conditions, but got %v", len(conditions))
+		}
+		return nil
+	}())
+	results.Add("test list conditions of missing patient", getAndEnsureStatus("/patients/-1/diagnosedConditions", nil, 400, nil))
+	return results
+}
+
 func testAddAttatchmentToPatient(results TestResults) TestResults {
 	attatchment := models.Attatchment{
 		Name:        "some-attatchment",
@@ -259,6 +1637,8 @@ func testAddAttatchmentToPatient(results TestResults) TestResults {
 		return nil
 	}())
 
+	attatchmentId = returnedAttatchment.Id
+
 	realPatientId := patientId
 	patientId = -1
 	results.Add("test add attatchment to patient with invalid patientId", postAttatchment(attatchment, 400, nil))
@@ -266,12 +1646,153 @@ func testAddAttatchmentToPatient(results TestResults) TestResults {
 	return results
 }
 
+func testReplaceAttatchmentContent(results TestResults) TestResults {
+	var replaced models.Attatchment
+	results.Add("test replace attatchment content", putAttatchmentContent(attatchmentId, []byte("rescanned data"), 200, &replaced))
+	results.Add("test replaced attatchment has new version and data", func() error {
+		if replaced.Version != 2 {
+			return fmt.Errorf("expected version 2 but got %v", replaced.Version)
+		}
+		if string(replaced.Data) != "rescanned data" {
+			return fmt.Errorf("expected replaced data but got %v", string(replaced.Data))
+		}
+		return nil
+	}())
+	results.Add("test replace content of missing attatchment", putAttatchmentContent(-1, []byte("rescanned data"), 400, nil))
+
+	var versions []models.AttatchmentVersion
+	results.Add("test list attatchment versions", getAndEnsureStatus(fmt.Sprintf("/attatchments/%v/versions", attatchmentId), nil, 200, &versions))
+	results.Add("test previous versions are retained", func() error {
+		if len(versions) != 2 {
+			return fmt.Errorf("expected 2 versions but got %v", len(versions))
+		}
+		return nil
+	}())
+
+	var original models.AttatchmentVersion
+	results.Add("test get original attatchment version", getAndEnsureStatus(fmt.Sprintf("/attatchments/%v/versions/1", attatchmentId), nil, 200, &original))
+	results.Add("test original version keeps its data", func() error {
+		if string(original.Data) != "some arbitrary data" {
+			return fmt.Errorf("expected original data but got %v", string(original.Data))
+		}
+		return nil
+	}())
+	results.Add("test get missing attatchment version", getAndEnsureStatus(fmt.Sprintf("/attatchments/%v/versions/3", attatchmentId), nil, 400, nil))
+	return results
+}
+
+func testAttatchmentThumbnail(results TestResults) TestResults {
+	path := fmt.Sprintf("/attatchments/%v/thumbnail", attatchmentId)
+	results.Add("test get thumbnail of non image attatchment", getAndEnsureStatus(path, nil, 400, nil))
+
+	img := image.NewRGBA(image.Rect(0, 0, 600, 300))
+	pngData := &bytes.Buffer{}
+	png.Encode(pngData, img)
+	results.Add("test replace attatchment content with image", putAttatchmentContent(attatchmentId, pngData.Bytes(), 200, nil))
+
+	results.Add("test get thumbnail with unsupported size", getAndEnsureStatus(path, map[string]string{"size": "100"}, 400, nil))
+	results.Add("test get thumbnail", func() error {
+		r, _ := http.NewRequest(http.MethodGet, "http://localhost:8080"+path+"?size=64", nil)
+		r.Header.Add("Authorization", "Bearer "+authToken)
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			return fmt.Errorf("error calling %v %w", path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return fmt.Errorf("expected status 200 but got %v", resp.Status)
+		}
+		if resp.Header.Get("Content-Type") != "image/png" {
+			return fmt.Errorf("expected content type image/png but got %v", resp.Header.Get("Content-Type"))
+		}
+		thumbnail, err := png.Decode(resp.Body)
+		if err != nil {
+			return fmt.Errorf("error decoding thumbnail %w", err)
+		}
+		if thumbnail.Bounds().Dx() != 64 || thumbnail.Bounds().Dy() != 32 {
+			return fmt.Errorf("expected 64x32 thumbnail but got %v", thumbnail.Bounds())
+		}
+		return nil
+	}())
+	return results
+}
+
+func testDicomAttatchment(results TestResults) TestResults {
+	dicom := &bytes.Buffer{}
+	dicom.Write(make([]byte, 128))
+	dicom.WriteString("DICM")
+	for _, el := range []struct {
+		group, element uint16
+		vr, value      string
+	}{
+		{0x0002, 0x0010, "UI", "1.2.840.10008.1.2.1\x00"},
+		{0x0008, 0x0020, "DA", "20240301"},
+		{0x0008, 0x0060, "CS", "CT"},
+		{0x0018, 0x0015, "CS", "CHEST "},
+		{0x0020, 0x000D, "UI", "1.2.826.0.1.3680043.2.1125.1"},
+		{0x0020, 0x000E, "UI", "1.2.826.0.1.3680043.2.1125.2"},
+	} {
+		binary.Write(dicom, binary.LittleEndian, []uint16{el.group, el.element})
+		dicom.WriteString(el.vr)
+		binary.Write(dicom, binary.LittleEndian, uint16(len(el.value)))
+		dicom.WriteString(el.value)
+	}
+	results.Add("test replace attatchment content with DICOM file", putAttatchmentContent(attatchmentId, dicom.Bytes(), 200, nil))
+
+	var attatchment models.Attatchment
+	results.Add("test get attatchment metadata", getAndEnsureStatus(fmt.Sprintf("/attatchments/%v", attatchmentId), nil, 200, &attatchment))
+	results.Add("test attatchment metadata includes DICOM metadata", func() error {
+		if len(attatchment.Data) != 0 {
+			return fmt.Errorf("expected metadata without data but got %v bytes", len(attatchment.Data))
+		}
+		if attatchment.Dicom == nil {
+			return fmt.Errorf("expected DICOM metadata but got none")
+		}
+		if attatchment.Dicom.Modality != "CT" || attatchment.Dicom.BodyPart != "CHEST" || attatchment.Dicom.StudyDate.Format(time.DateOnly) != "2024-03-01" {
+			return fmt.Errorf("unexpected DICOM metadata %+v", attatchment.Dicom)
+		}
+		return nil
+	}())
+
+	var patients []models.Patient
+	results.Add("test search patients by DICOM modality", getAndEnsureStatus("/patients", models.PatientSearch{
+		Modality: "CT",
+	}, 200, &patients))
+	results.Add("test that correct patients are returned by DICOM modality", func() error {
+		if len(patients) != 1 || patients[0].Id != patientId {
+			return fmt.Errorf("expected patient %v, but found %v patients", patientId, len(patients))
+		}
+		return nil
+	}())
+	return results
+}
+
+func putAttatchmentContent(id int, data []byte, status int, respBodyPntr any) error {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("data", "content")
+	io.Copy(part, bytes.NewReader(data))
+	writer.Close()
+	url := fmt.Sprintf("http://localhost:8080/attatchments/%v/content", id)
+	r, _ := http.NewRequest(http.MethodPut, url, body)
+	r.Header.Add("Content-Type", writer.FormDataContentType())
+	r.Header.Add("Authorization", "Bearer "+authToken)
+	return doAndEnsureStatus(r, status, respBodyPntr)
+}
+
 func postAttatchment(attatchment models.Attatchment, status int, respBodyPntr any) error {
+	return postAttatchmentToPatient(patientId, attatchment, status, respBodyPntr)
+}
+
+func postAttatchmentToPatient(patientId int, attatchment models.Attatchment, status int, respBodyPntr any) error {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 	writeFormField(writer, "name", attatchment.Name)
 	writeFormField(writer, "description", attatchment.Description)
 	writeFormField(writer, "type", attatchment.Type)
+	if attatchment.EncounterId != 0 {
+		writeFormField(writer, "encounterId", fmt.Sprint(attatchment.EncounterId))
+	}
 	dataReader := bytes.NewReader(attatchment.Data)
 	part, _ := writer.CreateFormFile("data", attatchment.Name)
 	io.Copy(part, dataReader)
@@ -295,6 +1816,75 @@ func writeFormField(writer *multipart.Writer, name string, value string) error {
 	return nil
 }
 
+func testPatientIdentifiers(results TestResults) TestResults {
+	ssn := models.PatientIdentifier{System: "http://hl7.org/fhir/sid/us-ssn", Value: "987-65-4320"}
+	mrn := models.PatientIdentifier{System: "urn:oid:2.16.840.1.113883.19.5", Value: "MRN-1001"}
+	request := models.PatientRequest{
+		Name:        "Ignatius Fairweather",
+		PhoneNumber: "3035550187",
+		DateOfBirth: time.Date(1961, 4, 2, 0, 0, 0, 0, time.UTC),
+		Identifiers: []models.PatientIdentifier{ssn, mrn},
+	}
+	var patient models.Patient
+	results.Add("create patient with identifiers and no externalIdentifier", postAndEnsureStatus("/patients", request, 200, &patient))
+	results.Add("test patient is returned with its identifiers", func() error {
+		if len(patient.Identifiers) != 2 || patient.Identifiers[0] != ssn || patient.Identifiers[1] != mrn || patient.ExternalIdentifier != "" {
+			return fmt.Errorf("expected identifiers %v and no externalIdentifier, but got %v and %q", request.Identifiers, patient.Identifiers, patient.ExternalIdentifier)
+		}
+		return nil
+	}())
+	results.Add("create patient without any identifier", postAndEnsureStatus("/patients", models.PatientRequest{
+		Name:        "Ignatius Fairweather",
+		PhoneNumber: "3035550187",
+		DateOfBirth: time.Date(1961, 4, 2, 0, 0, 0, 0, time.UTC),
+	}, 400, nil))
+	results.Add("create patient with an identifier held by another patient", postAndEnsureStatus("/patients", models.PatientRequest{
+		Name:               "Prudence Fairweather",
+		PhoneNumber:        "3035550188",
+		ExternalIdentifier: "fairweather-2",
+		DateOfBirth:        time.Date(1963, 8, 9, 0, 0, 0, 0, time.UTC),
+		Identifiers:        []models.PatientIdentifier{ssn},
+	}, 409, nil))
+	results.Add("create patient with the same value in another system", postAndEnsureStatus("/patients", models.PatientRequest{
+		Name:               "Prudence Fairweather",
+		PhoneNumber:        "3035550188",
+		ExternalIdentifier: "fairweather-2",
+		DateOfBirth:        time.Date(1963, 8, 9, 0, 0, 0, 0, time.UTC),
+		Identifiers:        []models.PatientIdentifier{{System: "http://example.com/partner", Value: ssn.Value}},
+	}, 200, nil))
+
+	searchIdentifier := func(identifier string, expected int) func() error {
+		return func() error {
+			var patients []models.Patient
+			err := getAndEnsureStatus("/patients", models.PatientSearch{Identifier: identifier}, 200, &patients)
+			if err != nil {
+				return err
+			}
+			if len(patients) != expected {
+				return fmt.Errorf("expected %v patients with identifier %v, but got %v", expected, identifier, len(patients))
+			}
+			return nil
+		}
+	}
+	results.Add("test search patients by identifier in a system", searchIdentifier(mrn.System+"|"+mrn.Value, 1)())
+	results.Add("test search patients by identifier in any system", searchIdentifier(ssn.Value, 2)())
+	results.Add("test search patients by identifier in another system", searchIdentifier("http://example.com/other|"+ssn.Value, 0)())
+
+	//clients from before identifiers only send the externalIdentifier, which leaves the patient's other identifiers alone
+	request.Identifiers = nil
+	request.ExternalIdentifier = "fairweather-1"
+	results.Add("update patient with only an externalIdentifier", putAndEnsureStatus(fmt.Sprintf("/patients/%v", patient.Id), request, 200, &patient))
+	results.Add("test update keeps the patient's other identifiers", func() error {
+		external := models.PatientIdentifier{System: models.IdentifierSystemExternal, Value: "fairweather-1"}
+		if len(patient.Identifiers) != 3 || patient.Identifiers[0] != external || patient.ExternalIdentifier != "fairweather-1" {
+			return fmt.Errorf("expected the external identifier to be added to %v, but got %v", []models.PatientIdentifier{ssn, mrn}, patient.Identifiers)
+		}
+		return nil
+	}())
+
+	return results
+}
+
 func testPatientUpdate(results TestResults) TestResults {
 	path := fmt.Sprintf("/patients/%v", patientId)
 	realAuth := authToken
@@ -313,7 +1903,9 @@ func testPatientUpdate(results TestResults) TestResults {
 		ExternalIdentifier: "123",
 		DateOfBirth:        time.Now(),
 	}
+	results.Add("test update patient to the external id of another patient", putAndEnsureStatus(path, patient, 409, nil))
 
+	patient.ExternalIdentifier = "abc"
 	results.Add("test update patient with valid fields", putAndEnsureStatus(path, patient, 200, &patient))
 	results.Add("test ensure updated patient data is returned", func() error {
 		if patient.Address == "185 main street" {
@@ -475,6 +2067,10 @@ func putAndEnsureStatus(path string, body any, status int, respObjPtr any) error
 	return buildJsonRequestAndDo(http.MethodPut, path, body, status, respObjPtr)
 }
 
+func patchAndEnsureStatus(path string, body any, status int, respObjPtr any) error {
+	return buildJsonRequestAndDo(http.MethodPatch, path, body, status, respObjPtr)
+}
+
 func postAndEnsureStatus(path string, body any, status int, respObjPtr any) error {
 	return buildJsonRequestAndDo(http.MethodPost, path, body, status, respObjPtr)
 }
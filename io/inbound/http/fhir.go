@@ -0,0 +1,238 @@
+package inboundhttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/fhir"
+	"mcg-app-backend/service/models"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const fhirBasePath = "/fhir/R4"
+
+// FHIR resources and errors have their own json formats, so are served by plain handlers rather than use case interactors
+func (server HttpServer) setupFhirRoutes() {
+	server.webService.Method(http.MethodPost, fhirBasePath, server.handleFhirTransaction())
+	server.webService.Method(http.MethodPost, fhirBasePath+"/Patient", handleFhirCreate(server.fhirService.CreatePatient, func(patient fhir.Patient) string { return "Patient/" + patient.Id }))
+	server.webService.Method(http.MethodPut, fhirBasePath+"/Patient/{id}", handleFhirUpdate(server.fhirService.UpdatePatient))
+	server.webService.Method(http.MethodPost, fhirBasePath+"/Condition", handleFhirCreate(server.fhirService.CreateCondition, func(condition fhir.Condition) string { return "Condition/" + condition.Id }))
+	server.webService.Method(http.MethodPut, fhirBasePath+"/Condition/{id}", handleFhirUpdate(server.fhirService.UpdateCondition))
+	server.webService.Method(http.MethodGet, fhirBasePath+"/Patient", server.handleFhirSearch(server.fhirService.SearchPatients))
+	server.webService.Method(http.MethodGet, fhirBasePath+"/Patient/{id}", handleFhirRead(server.fhirService.ReadPatient))
+	server.webService.Method(http.MethodGet, fhirBasePath+"/Condition", server.handleFhirSearch(server.fhirService.SearchConditions))
+	server.webService.Method(http.MethodGet, fhirBasePath+"/Condition/{id}", handleFhirRead(server.fhirService.ReadCondition))
+	server.webService.Method(http.MethodGet, fhirBasePath+"/DocumentReference", server.handleFhirSearch(server.fhirService.SearchDocumentReferences))
+	server.webService.Method(http.MethodGet, fhirBasePath+"/DocumentReference/{id}", handleFhirRead(server.fhirService.ReadDocumentReference))
+	server.webService.Method(http.MethodGet, fhirBasePath+"/Binary/{id}", server.handleFhirReadBinary())
+	server.webService.Method(http.MethodGet, fhirBasePath+"/$export", server.handleFhirExport())
+	server.webService.Method(http.MethodGet, fhirBasePath+"/Patient/$export", server.handleFhirExport())
+	server.webService.Method(http.MethodGet, fhirBasePath+"/$export-status/{id}", server.handleFhirExportStatus())
+	server.webService.Method(http.MethodDelete, fhirBasePath+"/$export-status/{id}", server.handleFhirExportDelete())
+	server.webService.Method(http.MethodGet, fhirBasePath+"/$export-file/{id}/{type}", server.handleFhirExportFile())
+}
+
+func handleFhirRead[T any](read func(ctx context.Context, id string) (T, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resource, err := read(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		writeFhirResource(w, http.StatusOK, resource)
+	})
+}
+
+// responds with the created resource, and its location relative to the FHIR base url
+func handleFhirCreate[T any](create func(ctx context.Context, resource T) (T, error), location func(T) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resource T
+		err := decodeFhirResource(r, &resource)
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		created, err := create(r.Context(), resource)
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		w.Header().Set("Location", fhirBaseUrl(r)+"/"+location(created))
+		writeFhirResource(w, http.StatusCreated, created)
+	})
+}
+
+func handleFhirUpdate[T any](update func(ctx context.Context, id string, resource T) (T, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resource T
+		err := decodeFhirResource(r, &resource)
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		updated, err := update(r.Context(), r.PathValue("id"), resource)
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		writeFhirResource(w, http.StatusOK, updated)
+	})
+}
+
+func (server HttpServer) handleFhirTransaction() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var bundle fhir.Bundle
+		err := decodeFhirResource(r, &bundle)
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		response, err := server.fhirService.ProcessTransaction(r.Context(), fhirBaseUrl(r), bundle)
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		writeFhirResource(w, http.StatusOK, response)
+	})
+}
+
+func (server HttpServer) handleFhirSearch(search func(ctx context.Context, base string, params url.Values) (fhir.Bundle, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bundle, err := search(r.Context(), fhirBaseUrl(r), r.URL.Query())
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		writeFhirResource(w, http.StatusOK, bundle)
+	})
+}
+
+// the content is returned as it is, unless a FHIR Binary resource is asked for
+func (server HttpServer) handleFhirReadBinary() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		binary, err := server.fhirService.ReadBinary(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		accept := r.Header.Get("Accept")
+		if strings.Contains(accept, "application/fhir+json") || strings.Contains(accept, "application/json") {
+			writeFhirResource(w, http.StatusOK, binary)
+			return
+		}
+		w.Header().Set("Content-Type", binary.ContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(binary.Data)
+	})
+}
+
+// Bulk Data kick-off requests must ask to be answered asynchronously, and are answered with where to poll for the
+// export's status
+func (server HttpServer) handleFhirExport() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Prefer"), "respond-async") {
+			writeFhirError(w, customerrors.NewInvalidInputError("exports run asynchronously, so the Prefer header must be respond-async"))
+			return
+		}
+		request := fhirBaseUrl(r) + strings.TrimPrefix(r.URL.RequestURI(), fhirBasePath)
+		job, err := server.exportService.StartExport(r.Context(), request, r.URL.Query())
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		w.Header().Set("Content-Location", fmt.Sprintf("%v/$export-status/%v", fhirBaseUrl(r), job.Id))
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// a running export is reported as accepted along with its progress, and a completed one with its manifest
+func (server HttpServer) handleFhirExportStatus() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job, err := server.exportService.GetExport(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		switch job.Status {
+		case models.ExportStatusRunning:
+			w.Header().Set("X-Progress", fmt.Sprintf("%v resources exported", job.ExportedResources))
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusAccepted)
+		case models.ExportStatusFailed:
+			writeFhirResource(w, http.StatusInternalServerError, fhir.OperationOutcome{
+				ResourceType: "OperationOutcome",
+				Issue:        []fhir.OperationOutcomeIssue{{Severity: "error", Code: "exception", Diagnostics: job.Error}},
+			})
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(fhir.ToExportManifest(fhirBaseUrl(r), job))
+		}
+	})
+}
+
+// deleting an export cancels it if it is still running, and removes its files
+func (server HttpServer) handleFhirExportDelete() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := server.exportService.DeleteExport(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func (server HttpServer) handleFhirExportFile() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, err := server.exportService.OpenExportFile(r.Context(), r.PathValue("id"), r.PathValue("type"))
+		if err != nil {
+			writeFhirError(w, err)
+			return
+		}
+		defer file.Close()
+		w.Header().Set("Content-Type", "application/fhir+ndjson")
+		w.WriteHeader(http.StatusOK)
+		//the status has already been sent, so a failed copy can only be logged, and the client sees a truncated file
+		_, err = io.Copy(w, file)
+		if err != nil {
+			server.logger.Error("error writing export file",
+				zap.String("exportId", r.PathValue("id")),
+				zap.String("type", r.PathValue("type")),
+				zap.Error(err))
+		}
+	})
+}
+
+func fhirBaseUrl(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + fhirBasePath
+}
+
+func decodeFhirResource(r *http.Request, resource any) error {
+	err := json.NewDecoder(r.Body).Decode(resource)
+	if err != nil {
+		return customerrors.NewInvalidInputError(fmt.Sprintf("request body is not a valid FHIR resource %v", err))
+	}
+	return nil
+}
+
+func writeFhirError(w http.ResponseWriter, err error) {
+	status, outcome := fhir.ToOperationOutcome(err)
+	writeFhirResource(w, status, outcome)
+}
+
+func writeFhirResource(w http.ResponseWriter, status int, resource any) {
+	w.Header().Set("Content-Type", "application/fhir+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resource)
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"mcg-app-backend/service/customerrors"
 	"mcg-app-backend/service/models"
+	"time"
 
 	"github.com/swaggest/usecase"
 	"github.com/swaggest/usecase/status"
@@ -100,13 +101,62 @@ func (server HttpServer) handleDeletePatient() usecase.Interactor {
 	})
 	u.SetExpectedErrors(status.Unauthenticated)
 	u.SetTitle("Delete Patient")
-	u.SetDescription("Deletes a patient along with all of their attatchments and diagnosed conditions")
+	u.SetDescription("Deletes a patient along with all of their attatchments and diagnosed conditions.  Restoring the patient also restores them")
+	return u
+}
+
+func (server HttpServer) handleRestoreDiagnosedCondition() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.RestoreByIdRequest, output *models.Empty) error {
+		err := server.diagnosedConditionService.RestoreDiagnosedCondition(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Restore Diagnosed Condition")
+	u.SetDescription("Restores a deleted diagnosed condition which has not yet been purged")
+	return u
+}
+
+func (server HttpServer) handleRestoreAttatchment() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.RestoreByIdRequest, output *models.Empty) error {
+		err := server.attatchmentService.RestoreAttatchment(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Restore Attatchment")
+	u.SetDescription("Restores a deleted attatchment which has not yet been purged")
+	return u
+}
+
+func (server HttpServer) handleRestorePatient() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.RestoreByIdRequest, output *models.Empty) error {
+		err := server.patientService.RestorePatient(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.AlreadyExists)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Restore Patient")
+	u.SetDescription("Restores a deleted patient which has not yet been purged")
 	return u
 }
 
 func (server HttpServer) handlePostPatient() usecase.Interactor {
 	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientRequest, output *models.Patient) error {
-		patient, err := server.patientService.CreatePatient(ctx, input.Name, input.Address, input.PhoneNumber, input.DateOfBirth, input.ExternalIdentifier)
+		patient, err := server.patientService.CreatePatient(ctx, input.Name, input.Address, input.PhoneNumber, input.DateOfBirth, input.ExternalIdentifier, input.Identifiers)
 		if err != nil {
 			return handleError(err)
 		}
@@ -127,7 +177,7 @@ func (server HttpServer) handlePostPatient() usecase.Interactor {
 func (server HttpServer) handlePutPatient() usecase.Interactor {
 	u := usecase.NewInteractor(func(ctx context.Context, input models.UpdatePatientRequest, output *models.Patient) error {
 
-		patient, err := server.patientService.UpdatePatient(ctx, input.Id, input.Name, input.Address, input.PhoneNumber, input.DateOfBirth, input.ExternalIdentifier)
+		patient, err := server.patientService.UpdatePatient(ctx, input.Id, input.Name, input.Address, input.PhoneNumber, input.DateOfBirth, input.ExternalIdentifier, input.Identifiers)
 		if err != nil {
 			return handleError(err)
 		}
@@ -137,6 +187,7 @@ func (server HttpServer) handlePutPatient() usecase.Interactor {
 
 	})
 	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.AlreadyExists)
 	u.SetExpectedErrors(status.Unauthenticated)
 	u.SetTitle("Update Patient")
 	u.SetDescription("Updates a patient to match the specified body")
@@ -146,7 +197,7 @@ func (server HttpServer) handlePutPatient() usecase.Interactor {
 func (server HttpServer) handlePostPatientAttatchment() usecase.Interactor {
 	u := usecase.NewInteractor(func(ctx context.Context, input models.CreateAttatchmentRequest, output *models.Attatchment) error {
 		data, _ := io.ReadAll(input.Data)
-		attatchment, err := server.attatchmentService.AddAttatchmentToPatient(ctx, input.PatientId, input.Name, input.Description, input.Type, data)
+		attatchment, err := server.attatchmentService.AddAttatchmentToPatient(ctx, input.PatientId, input.Name, input.Description, input.Type, data, input.EncounterId)
 
 		if err != nil {
 			return handleError(err)
@@ -164,9 +215,212 @@ func (server HttpServer) handlePostPatientAttatchment() usecase.Interactor {
 	return u
 }
 
+func (server HttpServer) handleGetAttatchmentMetadata() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetByIdRequest, output *models.Attatchment) error {
+		attatchment, err := server.attatchmentService.GetAttatchmentMetadata(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = attatchment
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Attatchment Metadata")
+	u.SetDescription("Gets an attatchment's metadata, including any extracted DICOM metadata, without its data")
+
+	return u
+}
+
+func (server HttpServer) handlePutAttatchmentContent() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.ReplaceAttatchmentContentRequest, output *models.Attatchment) error {
+		var data []byte
+		if input.Data != nil {
+			data, _ = io.ReadAll(input.Data)
+		}
+		attatchment, err := server.attatchmentService.ReplaceAttatchmentContent(ctx, input.Id, data)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = attatchment
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Replace Attatchment Content")
+	u.SetDescription("Uploads new data for an attatchment as a new version.  Previous versions are retained")
+
+	return u
+}
+
+func (server HttpServer) handleGetAttatchmentVersions() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetByIdRequest, output *[]models.AttatchmentVersion) error {
+		versions, err := server.attatchmentService.GetAttatchmentVersions(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = versions
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("List Attatchment Versions")
+	u.SetDescription("Lists all versions of an attatchment, without their data")
+
+	return u
+}
+
+func (server HttpServer) handleGetAttatchmentVersion() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetAttatchmentVersionRequest, output *models.AttatchmentVersion) error {
+		version, err := server.attatchmentService.GetAttatchmentVersion(ctx, input.Id, input.Version)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = version
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Attatchment Version")
+	u.SetDescription("Gets a specific version of an attatchment, including its data")
+
+	return u
+}
+
+type fileOutput struct {
+	usecase.OutputWithEmbeddedWriter
+	ContentType string `header:"Content-Type"`
+}
+
+func (server HttpServer) handleGetAttatchmentThumbnail() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetAttatchmentThumbnailRequest, output *fileOutput) error {
+		thumbnail, err := server.attatchmentService.GetAttatchmentThumbnail(ctx, input.Id, input.Size)
+		if err != nil {
+			return handleError(err)
+		}
+
+		output.ContentType = thumbnail.ContentType
+		_, err = output.Write(thumbnail.Data)
+		return err
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Attatchment Thumbnail")
+	u.SetDescription("Gets a downscaled png or jpeg preview of an image attatchment")
+
+	return u
+}
+
+func (server HttpServer) handleGetPatientCcda() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetByIdRequest, output *fileOutput) error {
+		document, err := server.ccdaService.GetContinuityOfCareDocument(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		output.ContentType = "application/xml"
+		_, err = output.Write(document)
+		return err
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetExpectedErrors(status.Unavailable)
+	u.SetTitle("Get Patient C-CDA")
+	u.SetDescription("Gets a C-CDA R2.1 Continuity of Care Document of the patient's demographics, problems and attatched documents")
+
+	return u
+}
+
+func (server HttpServer) handleGetPatientSummary() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetByIdRequest, output *fileOutput) error {
+		summary, err := server.summaryService.GetPatientSummary(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		output.ContentType = "application/pdf"
+		_, err = output.Write(summary)
+		return err
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Patient Summary")
+	u.SetDescription("Gets a printable PDF face sheet of the patient's demographics, active conditions and attatchments")
+
+	return u
+}
+
+func (server HttpServer) handleGetPotentialDuplicates() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetByIdRequest, output *[]models.PotentialDuplicate) error {
+		duplicates, err := server.duplicateService.FindPotentialDuplicates(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = duplicates
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Potential Duplicates")
+	u.SetDescription("Gets the other patients who may be the same person as this one, scored by how alike their name, date of birth, phone number and address are, most likely first")
+
+	return u
+}
+
+func (server HttpServer) handleMergePatient() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.MergePatientRequest, output *models.PatientLink) error {
+		link, err := server.duplicateService.MergePatients(ctx, input.Id, input.DuplicatePatientId)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = link
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Merge Patient")
+	u.SetDescription("Merges a duplicate patient into this one, moving their conditions and attatchments over and deleting the rest of their record")
+
+	return u
+}
+
+func (server HttpServer) handleGetPatientLinks() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetByIdRequest, output *[]models.PatientLink) error {
+		links, err := server.duplicateService.GetPatientLinks(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = links
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Patient Links")
+	u.SetDescription("Gets the merges the patient was part of, either as the surviving or the merged patient")
+
+	return u
+}
+
 func (server HttpServer) handlePostDiagnosedCondition() usecase.Interactor {
 	u := usecase.NewInteractor(func(ctx context.Context, input models.CreateDiagnosedConditionRequest, output *models.DiagnosedCondition) error {
-		cond, err := server.diagnosedConditionService.AddDiagnosedConditionToPatient(ctx, input.PatientId, input.Name, input.Code, input.Description, input.Date)
+		cond, err := server.diagnosedConditionService.AddDiagnosedConditionToPatient(ctx, input.PatientId, input.Name, input.Code, input.CodeSystem, input.Description, input.Date, input.OnsetDate, input.EncounterId)
 		if err != nil {
 			return handleError(err)
 		}
@@ -183,21 +437,739 @@ func (server HttpServer) handlePostDiagnosedCondition() usecase.Interactor {
 	return u
 }
 
-func handleError(err error) error {
-	if err == nil {
+func (server HttpServer) handleGetDiagnosedCondition() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetByIdRequest, output *models.DiagnosedCondition) error {
+		cond, err := server.diagnosedConditionService.GetDiagnosedCondition(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = cond
 		return nil
-	}
-	var inputError customerrors.InvalidInputError
-	if errors.As(err, &inputError) {
-		return inputError
-	}
-	var alreadyExistsError customerrors.AlreadyExistsError
-	if errors.As(err, &alreadyExistsError) {
-		return alreadyExistsError
-	}
-	var authError customerrors.UnauthorizedError
-	if errors.As(err, &authError) {
-		return authError
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Diagnosed Condition")
+	u.SetDescription("Gets a specific diagnosed condition")
+
+	return u
+}
+
+func (server HttpServer) handlePutDiagnosedCondition() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.UpdateDiagnosedConditionRequest, output *models.DiagnosedCondition) error {
+		cond, err := server.diagnosedConditionService.UpdateDiagnosedCondition(ctx, input.Id, input.Name, input.Code, input.CodeSystem, input.Description, input.Date, input.OnsetDate, input.EncounterId)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = cond
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Update Diagnosed Condition")
+	u.SetDescription("Updates a diagnosed condition to match the specified body")
+
+	return u
+}
+
+func (server HttpServer) handlePatchDiagnosedCondition() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatchDiagnosedConditionRequest, output *models.DiagnosedCondition) error {
+		cond, err := server.diagnosedConditionService.PatchDiagnosedCondition(ctx, input.Id, input.DiagnosedConditionPatch)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = cond
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Patch Diagnosed Condition")
+	u.SetDescription("Updates only the fields of a diagnosed condition which are present in the body")
+
+	return u
+}
+
+func (server HttpServer) handlePostDiagnosedConditionStatus() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.DiagnosedConditionStatusRequest, output *models.DiagnosedCondition) error {
+		date := time.Now()
+		if input.Date != nil {
+			date = *input.Date
+		}
+		cond, err := server.diagnosedConditionService.TransitionDiagnosedConditionStatus(ctx, input.Id, input.ClinicalStatus, input.VerificationStatus, date)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = cond
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Change Diagnosed Condition Status")
+	u.SetDescription("Moves a diagnosed condition to a new clinical and/or verification status.  Only transitions which make clinical sense are allowed, for example a resolved condition can only become a recurrence")
+
+	return u
+}
+
+func (server HttpServer) handleGetPatientDiagnosedConditions() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.DiagnosedConditionSearch, output *[]models.DiagnosedCondition) error {
+		conds, err := server.diagnosedConditionService.GetPatientDiagnosedConditions(ctx, input)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = conds
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("List Patient Diagnosed Conditions")
+	u.SetDescription("Lists a patient's diagnosed conditions, optionally filtered by code and diagnosis date range")
+
+	return u
+}
+
+func (server HttpServer) handlePostMedication() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.CreateMedicationRequest, output *models.Medication) error {
+		medication, err := server.medicationService.AddMedicationToPatient(ctx, input.PatientId, input.MedicationRequest)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = medication
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Add Medication")
+	u.SetDescription("Records a medication the patient is taking")
+
+	return u
+}
+
+func (server HttpServer) handleGetPatientMedications() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientIdRequest, output *[]models.Medication) error {
+		medications, err := server.medicationService.GetPatientMedications(ctx, input.PatientId)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = medications
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("List Patient Medications")
+	u.SetDescription("Lists a patient's medications ordered by start date")
+
+	return u
+}
+
+func (server HttpServer) handleGetMedication() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientMedicationRequest, output *models.Medication) error {
+		medication, err := server.medicationService.GetMedication(ctx, input.PatientId, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = medication
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Medication")
+	u.SetDescription("Gets a specific medication of a patient")
+
+	return u
+}
+
+func (server HttpServer) handlePutMedication() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.UpdateMedicationRequest, output *models.Medication) error {
+		medication, err := server.medicationService.UpdateMedication(ctx, input.PatientId, input.Id, input.MedicationRequest)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = medication
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Update Medication")
+	u.SetDescription("Replaces the details of a specific medication of a patient")
+
+	return u
+}
+
+func (server HttpServer) handleDeleteMedication() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientMedicationRequest, output *models.Empty) error {
+		return handleError(server.medicationService.DeleteMedication(ctx, input.PatientId, input.Id))
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Delete Medication")
+	u.SetDescription("Deletes a specific medication of a patient")
+	return u
+}
+
+func (server HttpServer) handleRestoreMedication() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientMedicationRequest, output *models.Empty) error {
+		return handleError(server.medicationService.RestoreMedication(ctx, input.PatientId, input.Id))
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Restore Medication")
+	u.SetDescription("Restores a deleted medication which has not yet been purged")
+	return u
+}
+
+func (server HttpServer) handlePostEncounter() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.CreateEncounterRequest, output *models.Encounter) error {
+		encounter, err := server.encounterService.AddEncounterToPatient(ctx, input.PatientId, input.EncounterRequest)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = encounter
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Add Encounter")
+	u.SetDescription("Records a visit or other encounter with the patient")
+
+	return u
+}
+
+func (server HttpServer) handleGetPatientEncounters() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientIdRequest, output *[]models.Encounter) error {
+		encounters, err := server.encounterService.GetPatientEncounters(ctx, input.PatientId)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = encounters
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("List Patient Encounters")
+	u.SetDescription("Lists a patient's encounters in the order they started")
+
+	return u
+}
+
+func (server HttpServer) handleGetEncounter() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientEncounterRequest, output *models.Encounter) error {
+		encounter, err := server.encounterService.GetEncounter(ctx, input.PatientId, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = encounter
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Encounter")
+	u.SetDescription("Gets a specific encounter of a patient")
+
+	return u
+}
+
+func (server HttpServer) handleGetEncounterDocumentation() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientEncounterRequest, output *models.EncounterDocumentation) error {
+		documentation, err := server.encounterService.GetEncounterDocumentation(ctx, input.PatientId, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = documentation
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Encounter Documentation")
+	u.SetDescription("Gets an encounter together with the diagnosed conditions and attatchments documented at it")
+
+	return u
+}
+
+func (server HttpServer) handlePutEncounter() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.UpdateEncounterRequest, output *models.Encounter) error {
+		encounter, err := server.encounterService.UpdateEncounter(ctx, input.PatientId, input.Id, input.EncounterRequest)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = encounter
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Update Encounter")
+	u.SetDescription("Replaces the details of a specific encounter of a patient")
+
+	return u
+}
+
+func (server HttpServer) handleDeleteEncounter() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientEncounterRequest, output *models.Empty) error {
+		return handleError(server.encounterService.DeleteEncounter(ctx, input.PatientId, input.Id))
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Delete Encounter")
+	u.SetDescription("Deletes a specific encounter of a patient")
+	return u
+}
+
+func (server HttpServer) handleRestoreEncounter() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientEncounterRequest, output *models.Empty) error {
+		return handleError(server.encounterService.RestoreEncounter(ctx, input.PatientId, input.Id))
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Restore Encounter")
+	u.SetDescription("Restores a deleted encounter which has not yet been purged")
+	return u
+}
+
+func (server HttpServer) handlePostAllergy() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.CreateAllergyRequest, output *models.Allergy) error {
+		allergy, err := server.allergyService.AddAllergyToPatient(ctx, input.PatientId, input.AllergyRequest)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = allergy
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Add Allergy")
+	u.SetDescription("Records a substance the patient is allergic or intolerant to")
+
+	return u
+}
+
+func (server HttpServer) handleGetPatientAllergies() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientIdRequest, output *[]models.Allergy) error {
+		allergies, err := server.allergyService.GetPatientAllergies(ctx, input.PatientId)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = allergies
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("List Patient Allergies")
+	u.SetDescription("Lists a patient's allergies and intolerances")
+
+	return u
+}
+
+func (server HttpServer) handleGetAllergy() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientAllergyRequest, output *models.Allergy) error {
+		allergy, err := server.allergyService.GetAllergy(ctx, input.PatientId, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = allergy
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Allergy")
+	u.SetDescription("Gets a specific allergy of a patient")
+
+	return u
+}
+
+func (server HttpServer) handlePutAllergy() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.UpdateAllergyRequest, output *models.Allergy) error {
+		allergy, err := server.allergyService.UpdateAllergy(ctx, input.PatientId, input.Id, input.AllergyRequest)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = allergy
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Update Allergy")
+	u.SetDescription("Replaces the details of a specific allergy of a patient")
+
+	return u
+}
+
+func (server HttpServer) handleDeleteAllergy() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientAllergyRequest, output *models.Empty) error {
+		return handleError(server.allergyService.DeleteAllergy(ctx, input.PatientId, input.Id))
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Delete Allergy")
+	u.SetDescription("Deletes a specific allergy of a patient")
+	return u
+}
+
+func (server HttpServer) handleRestoreAllergy() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientAllergyRequest, output *models.Empty) error {
+		return handleError(server.allergyService.RestoreAllergy(ctx, input.PatientId, input.Id))
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Restore Allergy")
+	u.SetDescription("Restores a deleted allergy which has not yet been purged")
+	return u
+}
+
+func (server HttpServer) handlePostObservation() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.CreateObservationRequest, output *models.Observation) error {
+		observation, err := server.observationService.AddObservationToPatient(ctx, input.PatientId, input.ObservationRequest)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = observation
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Add Observation")
+	u.SetDescription("Records an observation of the patient such as a vital sign or lab value")
+
+	return u
+}
+
+func (server HttpServer) handleGetPatientObservations() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.ObservationSearch, output *[]models.Observation) error {
+		observations, err := server.observationService.GetPatientObservations(ctx, input)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = observations
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("List Patient Observations")
+	u.SetDescription("Lists a patient's observations in the order they were made, optionally filtered by code and time range")
+
+	return u
+}
+
+func (server HttpServer) handleGetLatestObservations() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientIdRequest, output *[]models.Observation) error {
+		observations, err := server.observationService.GetLatestObservations(ctx, input.PatientId)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = observations
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Latest Patient Observations")
+	u.SetDescription("Summarizes a patient's observations with the most recent observation of each code")
+
+	return u
+}
+
+func (server HttpServer) handleGetObservation() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetByIdRequest, output *models.Observation) error {
+		observation, err := server.observationService.GetObservation(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = observation
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Observation")
+	u.SetDescription("Gets a specific observation")
+
+	return u
+}
+
+func (server HttpServer) handleDeleteObservation() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.DeleteByIdRequest, output *models.Empty) error {
+		return handleError(server.observationService.DeleteObservation(ctx, input.Id))
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Delete Observation")
+	u.SetDescription("Deletes a specific observation")
+	return u
+}
+
+func (server HttpServer) handleRestoreObservation() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.RestoreByIdRequest, output *models.Empty) error {
+		return handleError(server.observationService.RestoreObservation(ctx, input.Id))
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Restore Observation")
+	u.SetDescription("Restores a deleted observation which has not yet been purged")
+	return u
+}
+
+func (server HttpServer) handleGetICD10Codes() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.CodeSearch, output *[]models.Code) error {
+		codes, err := server.codeService.Search(ctx, input.Query, input.Limit)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = codes
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetTitle("Search ICD-10-CM Codes")
+	u.SetDescription("Finds ICD-10-CM codes by code prefix or by words in their name, for use in typeahead fields")
+
+	return u
+}
+
+func (server HttpServer) handlePostSlot() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.SlotRequest, output *models.Slot) error {
+		slot, err := server.appointmentService.AddSlot(ctx, input)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = slot
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.AlreadyExists)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Add Slot")
+	u.SetDescription("Makes a practitioner available for booking.  Slots of the same practitioner may not overlap")
+
+	return u
+}
+
+func (server HttpServer) handleGetSlots() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.ScheduleSearch, output *[]models.Slot) error {
+		slots, err := server.appointmentService.GetSlots(ctx, input)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = slots
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("List Slots")
+	u.SetDescription("Lists the slots starting on a day or in a week, in the order they start")
+
+	return u
+}
+
+func (server HttpServer) handleGetSchedule() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.ScheduleSearch, output *[]models.Appointment) error {
+		appointments, err := server.appointmentService.GetSchedule(ctx, input)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = appointments
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("List Appointments")
+	u.SetDescription("Lists the booked appointments starting on a day or in a week, in the order they start")
+
+	return u
+}
+
+func (server HttpServer) handlePostAppointment() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.BookAppointmentRequest, output *models.Appointment) error {
+		appointment, err := server.appointmentService.BookAppointment(ctx, input.PatientId, input.AppointmentRequest)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = appointment
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.AlreadyExists)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Book Appointment")
+	u.SetDescription("Books the patient into a slot which has not already been booked")
+
+	return u
+}
+
+func (server HttpServer) handleGetPatientAppointments() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientIdRequest, output *[]models.Appointment) error {
+		appointments, err := server.appointmentService.GetPatientAppointments(ctx, input.PatientId)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = appointments
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("List Patient Appointments")
+	u.SetDescription("Lists a patient's booked and cancelled appointments in the order they start")
+
+	return u
+}
+
+func (server HttpServer) handleGetAppointment() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientAppointmentRequest, output *models.Appointment) error {
+		appointment, err := server.appointmentService.GetAppointment(ctx, input.PatientId, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = appointment
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Appointment")
+	u.SetDescription("Gets a specific appointment of a patient")
+
+	return u
+}
+
+func (server HttpServer) handleCancelAppointment() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.PatientAppointmentRequest, output *models.Empty) error {
+		return handleError(server.appointmentService.CancelAppointment(ctx, input.PatientId, input.Id))
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Cancel Appointment")
+	u.SetDescription("Cancels an appointment, freeing its slot to be booked again")
+	return u
+}
+
+func (server HttpServer) handleRescheduleAppointment() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.RescheduleAppointmentRequest, output *models.Appointment) error {
+		appointment, err := server.appointmentService.RescheduleAppointment(ctx, input.PatientId, input.Id, input.SlotId)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = appointment
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.AlreadyExists)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Reschedule Appointment")
+	u.SetDescription("Moves an appointment to another slot which has not already been booked, freeing its previous slot")
+
+	return u
+}
+
+func (server HttpServer) handlePostImport() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.ImportRequest, output *models.ImportJob) error {
+		data, _ := io.ReadAll(input.Data)
+		job, err := server.importService.StartImport(ctx, input.Format, input.DryRun, data)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = job
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Import Patients")
+	u.SetDescription("Starts importing patients and their diagnosed conditions from a csv or ndjson file in the background, returning the import to poll for its progress")
+
+	return u
+}
+
+func (server HttpServer) handleGetImport() usecase.Interactor {
+	u := usecase.NewInteractor(func(ctx context.Context, input models.GetByIdRequest, output *models.ImportJob) error {
+		job, err := server.importService.GetImport(ctx, input.Id)
+		if err != nil {
+			return handleError(err)
+		}
+
+		*output = job
+		return nil
+
+	})
+	u.SetExpectedErrors(status.InvalidArgument)
+	u.SetExpectedErrors(status.Unauthenticated)
+	u.SetTitle("Get Import")
+	u.SetDescription("Gets the progress of an import, along with the rows which could not be imported")
+
+	return u
+}
+
+func handleError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var inputError customerrors.InvalidInputError
+	if errors.As(err, &inputError) {
+		return inputError
+	}
+	var alreadyExistsError customerrors.AlreadyExistsError
+	if errors.As(err, &alreadyExistsError) {
+		return alreadyExistsError
+	}
+	var authError customerrors.UnauthorizedError
+	if errors.As(err, &authError) {
+		return authError
+	}
+	var unavailableError customerrors.UnavailableError
+	if errors.As(err, &unavailableError) {
+		return unavailableError
 	}
 	return errors.New("internal server error")
 }
@@ -2,7 +2,10 @@ package inboundhttp
 
 import (
 	"context"
+	"io"
+	"mcg-app-backend/service/fhir"
 	"mcg-app-backend/service/models"
+	"net/url"
 	"time"
 )
 
@@ -16,18 +19,124 @@ type AuthService interface {
 }
 
 type PatientService interface {
-	CreatePatient(ctx context.Context, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string) (models.Patient, error)
+	CreatePatient(ctx context.Context, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error)
 	SearchPatients(ctx context.Context, search models.PatientSearch) ([]models.Patient, error)
 	DeletePatient(ctx context.Context, patientId int) error
-	UpdatePatient(ctx context.Context, id int, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string) (models.Patient, error)
+	RestorePatient(ctx context.Context, patientId int) error
+	UpdatePatient(ctx context.Context, id int, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error)
 }
 
 type AttatchmentService interface {
-	AddAttatchmentToPatient(ctx context.Context, patientId int, name string, description string, typ string, data []byte) (models.Attatchment, error)
+	AddAttatchmentToPatient(ctx context.Context, patientId int, name string, description string, typ string, data []byte, encounterId int) (models.Attatchment, error)
 	DeleteAttatchment(ctx context.Context, attatchmentId int) error
+	RestoreAttatchment(ctx context.Context, attatchmentId int) error
+	GetAttatchmentMetadata(ctx context.Context, attatchmentId int) (models.Attatchment, error)
+	ReplaceAttatchmentContent(ctx context.Context, attatchmentId int, data []byte) (models.Attatchment, error)
+	GetAttatchmentVersions(ctx context.Context, attatchmentId int) ([]models.AttatchmentVersion, error)
+	GetAttatchmentVersion(ctx context.Context, attatchmentId int, version int) (models.AttatchmentVersion, error)
+	GetAttatchmentThumbnail(ctx context.Context, attatchmentId int, size int) (models.AttatchmentThumbnail, error)
 }
 
 type DiagnosedConditionsService interface {
-	AddDiagnosedConditionToPatient(ctx context.Context, patientId int, name string, code string, description string, date time.Time) (models.DiagnosedCondition, error)
+	AddDiagnosedConditionToPatient(ctx context.Context, patientId int, name string, code string, codeSystem string, description string, date time.Time, onsetDate *time.Time, encounterId int) (models.DiagnosedCondition, error)
 	DeleteDiagnosedCondition(ctx context.Context, conditionId int) error
+	RestoreDiagnosedCondition(ctx context.Context, conditionId int) error
+	GetDiagnosedCondition(ctx context.Context, conditionId int) (models.DiagnosedCondition, error)
+	UpdateDiagnosedCondition(ctx context.Context, conditionId int, name string, code string, codeSystem string, description string, date time.Time, onsetDate *time.Time, encounterId int) (models.DiagnosedCondition, error)
+	PatchDiagnosedCondition(ctx context.Context, conditionId int, patch models.DiagnosedConditionPatch) (models.DiagnosedCondition, error)
+	TransitionDiagnosedConditionStatus(ctx context.Context, conditionId int, clinicalStatus string, verificationStatus string, date time.Time) (models.DiagnosedCondition, error)
+	GetPatientDiagnosedConditions(ctx context.Context, search models.DiagnosedConditionSearch) ([]models.DiagnosedCondition, error)
+}
+
+type MedicationService interface {
+	AddMedicationToPatient(ctx context.Context, patientId int, details models.MedicationRequest) (models.Medication, error)
+	GetMedication(ctx context.Context, patientId int, medicationId int) (models.Medication, error)
+	GetPatientMedications(ctx context.Context, patientId int) ([]models.Medication, error)
+	UpdateMedication(ctx context.Context, patientId int, medicationId int, details models.MedicationRequest) (models.Medication, error)
+	DeleteMedication(ctx context.Context, patientId int, medicationId int) error
+	RestoreMedication(ctx context.Context, patientId int, medicationId int) error
+}
+
+type AllergyService interface {
+	AddAllergyToPatient(ctx context.Context, patientId int, details models.AllergyRequest) (models.Allergy, error)
+	GetAllergy(ctx context.Context, patientId int, allergyId int) (models.Allergy, error)
+	GetPatientAllergies(ctx context.Context, patientId int) ([]models.Allergy, error)
+	UpdateAllergy(ctx context.Context, patientId int, allergyId int, details models.AllergyRequest) (models.Allergy, error)
+	DeleteAllergy(ctx context.Context, patientId int, allergyId int) error
+	RestoreAllergy(ctx context.Context, patientId int, allergyId int) error
+}
+
+type ObservationService interface {
+	AddObservationToPatient(ctx context.Context, patientId int, details models.ObservationRequest) (models.Observation, error)
+	GetObservation(ctx context.Context, observationId int) (models.Observation, error)
+	GetPatientObservations(ctx context.Context, search models.ObservationSearch) ([]models.Observation, error)
+	GetLatestObservations(ctx context.Context, patientId int) ([]models.Observation, error)
+	DeleteObservation(ctx context.Context, observationId int) error
+	RestoreObservation(ctx context.Context, observationId int) error
+}
+
+type EncounterService interface {
+	AddEncounterToPatient(ctx context.Context, patientId int, details models.EncounterRequest) (models.Encounter, error)
+	GetEncounter(ctx context.Context, patientId int, encounterId int) (models.Encounter, error)
+	GetEncounterDocumentation(ctx context.Context, patientId int, encounterId int) (models.EncounterDocumentation, error)
+	GetPatientEncounters(ctx context.Context, patientId int) ([]models.Encounter, error)
+	UpdateEncounter(ctx context.Context, patientId int, encounterId int, details models.EncounterRequest) (models.Encounter, error)
+	DeleteEncounter(ctx context.Context, patientId int, encounterId int) error
+	RestoreEncounter(ctx context.Context, patientId int, encounterId int) error
+}
+
+type AppointmentService interface {
+	AddSlot(ctx context.Context, details models.SlotRequest) (models.Slot, error)
+	GetSlots(ctx context.Context, search models.ScheduleSearch) ([]models.Slot, error)
+	GetSchedule(ctx context.Context, search models.ScheduleSearch) ([]models.Appointment, error)
+	BookAppointment(ctx context.Context, patientId int, details models.AppointmentRequest) (models.Appointment, error)
+	GetAppointment(ctx context.Context, patientId int, appointmentId int) (models.Appointment, error)
+	GetPatientAppointments(ctx context.Context, patientId int) ([]models.Appointment, error)
+	CancelAppointment(ctx context.Context, patientId int, appointmentId int) error
+	RescheduleAppointment(ctx context.Context, patientId int, appointmentId int, slotId int) (models.Appointment, error)
+}
+
+type ImportService interface {
+	StartImport(ctx context.Context, format string, dryRun bool, data []byte) (models.ImportJob, error)
+	GetImport(ctx context.Context, id int) (models.ImportJob, error)
+}
+
+type FhirService interface {
+	ProcessTransaction(ctx context.Context, base string, bundle fhir.Bundle) (fhir.Bundle, error)
+	CreatePatient(ctx context.Context, resource fhir.Patient) (fhir.Patient, error)
+	UpdatePatient(ctx context.Context, id string, resource fhir.Patient) (fhir.Patient, error)
+	ReadPatient(ctx context.Context, id string) (fhir.Patient, error)
+	SearchPatients(ctx context.Context, base string, params url.Values) (fhir.Bundle, error)
+	CreateCondition(ctx context.Context, resource fhir.Condition) (fhir.Condition, error)
+	UpdateCondition(ctx context.Context, id string, resource fhir.Condition) (fhir.Condition, error)
+	ReadCondition(ctx context.Context, id string) (fhir.Condition, error)
+	SearchConditions(ctx context.Context, base string, params url.Values) (fhir.Bundle, error)
+	ReadDocumentReference(ctx context.Context, id string) (fhir.DocumentReference, error)
+	SearchDocumentReferences(ctx context.Context, base string, params url.Values) (fhir.Bundle, error)
+	ReadBinary(ctx context.Context, id string) (fhir.Binary, error)
+}
+
+type ExportService interface {
+	StartExport(ctx context.Context, request string, params url.Values) (models.ExportJob, error)
+	GetExport(ctx context.Context, id string) (models.ExportJob, error)
+	OpenExportFile(ctx context.Context, id string, resourceType string) (io.ReadCloser, error)
+	DeleteExport(ctx context.Context, id string) error
+}
+
+type CcdaService interface {
+	GetContinuityOfCareDocument(ctx context.Context, patientId int) ([]byte, error)
+}
+
+type SummaryService interface {
+	GetPatientSummary(ctx context.Context, patientId int) ([]byte, error)
+}
+
+type DuplicateService interface {
+	FindPotentialDuplicates(ctx context.Context, patientId int) ([]models.PotentialDuplicate, error)
+	MergePatients(ctx context.Context, patientId int, duplicatePatientId int) (models.PatientLink, error)
+	GetPatientLinks(ctx context.Context, patientId int) ([]models.PatientLink, error)
+}
+
+type CodeService interface {
+	Search(ctx context.Context, query string, limit int) ([]models.Code, error)
 }
@@ -10,15 +10,71 @@ func (server HttpServer) setupRoutes() {
 	server.webService.Use(server.RequireValidToken)
 	server.webService.Post("/public/users", server.handlePostUser())
 	server.webService.Post("/public/users/login", server.handleLogin())
+	server.webService.Get("/public/codes/icd10", server.handleGetICD10Codes())
 	server.webService.Post("/patients", server.handlePostPatient())
 	server.webService.Put("/patients/{id}", server.handlePutPatient())
+	server.webService.Get("/patients/{id}/ccda", server.handleGetPatientCcda())
+	server.webService.Get("/patients/{id}/summary.pdf", server.handleGetPatientSummary())
+	server.webService.Get("/patients/{id}/potential-duplicates", server.handleGetPotentialDuplicates())
+	server.webService.Post("/patients/{id}/merge", server.handleMergePatient())
+	server.webService.Get("/patients/{id}/links", server.handleGetPatientLinks())
 	server.webService.Post("/patients/{patientId}/attatchments", server.handlePostPatientAttatchment())
 	server.webService.Post("/patients/{patientId}/diagnosedConditions", server.handlePostDiagnosedCondition())
+	server.webService.Get("/patients/{patientId}/diagnosedConditions", server.handleGetPatientDiagnosedConditions())
+	server.webService.Get("/diagnosedConditions/{id}", server.handleGetDiagnosedCondition())
+	server.webService.Put("/diagnosedConditions/{id}", server.handlePutDiagnosedCondition())
+	server.webService.Patch("/diagnosedConditions/{id}", server.handlePatchDiagnosedCondition())
+	server.webService.Post("/diagnosedConditions/{id}/status", server.handlePostDiagnosedConditionStatus())
+	server.webService.Post("/patients/{patientId}/medications", server.handlePostMedication())
+	server.webService.Get("/patients/{patientId}/medications", server.handleGetPatientMedications())
+	server.webService.Get("/patients/{patientId}/medications/{id}", server.handleGetMedication())
+	server.webService.Put("/patients/{patientId}/medications/{id}", server.handlePutMedication())
+	server.webService.Delete("/patients/{patientId}/medications/{id}", server.handleDeleteMedication())
+	server.webService.Post("/patients/{patientId}/medications/{id}/restore", server.handleRestoreMedication())
+	server.webService.Post("/patients/{patientId}/allergies", server.handlePostAllergy())
+	server.webService.Get("/patients/{patientId}/allergies", server.handleGetPatientAllergies())
+	server.webService.Get("/patients/{patientId}/allergies/{id}", server.handleGetAllergy())
+	server.webService.Put("/patients/{patientId}/allergies/{id}", server.handlePutAllergy())
+	server.webService.Delete("/patients/{patientId}/allergies/{id}", server.handleDeleteAllergy())
+	server.webService.Post("/patients/{patientId}/allergies/{id}/restore", server.handleRestoreAllergy())
+	server.webService.Post("/patients/{patientId}/observations", server.handlePostObservation())
+	server.webService.Get("/patients/{patientId}/observations", server.handleGetPatientObservations())
+	server.webService.Get("/patients/{patientId}/observations/latest", server.handleGetLatestObservations())
+	server.webService.Get("/observations/{id}", server.handleGetObservation())
+	server.webService.Post("/patients/{patientId}/encounters", server.handlePostEncounter())
+	server.webService.Get("/patients/{patientId}/encounters", server.handleGetPatientEncounters())
+	server.webService.Get("/patients/{patientId}/encounters/{id}", server.handleGetEncounter())
+	server.webService.Get("/patients/{patientId}/encounters/{id}/documentation", server.handleGetEncounterDocumentation())
+	server.webService.Put("/patients/{patientId}/encounters/{id}", server.handlePutEncounter())
+	server.webService.Delete("/patients/{patientId}/encounters/{id}", server.handleDeleteEncounter())
+	server.webService.Post("/patients/{patientId}/encounters/{id}/restore", server.handleRestoreEncounter())
+	server.webService.Post("/slots", server.handlePostSlot())
+	server.webService.Get("/slots", server.handleGetSlots())
+	server.webService.Get("/appointments", server.handleGetSchedule())
+	server.webService.Post("/patients/{patientId}/appointments", server.handlePostAppointment())
+	server.webService.Get("/patients/{patientId}/appointments", server.handleGetPatientAppointments())
+	server.webService.Get("/patients/{patientId}/appointments/{id}", server.handleGetAppointment())
+	server.webService.Post("/patients/{patientId}/appointments/{id}/cancel", server.handleCancelAppointment())
+	server.webService.Post("/patients/{patientId}/appointments/{id}/reschedule", server.handleRescheduleAppointment())
+	server.webService.Post("/imports", server.handlePostImport())
+	server.webService.Get("/imports/{id}", server.handleGetImport())
 
 	server.webService.Get("/patients", server.handleGetPatients())
 	server.webService.Delete("/diagnosedConditions/{id}", server.handleDeleteDiagnosedCondition())
+	server.webService.Post("/diagnosedConditions/{id}/restore", server.handleRestoreDiagnosedCondition())
+	server.webService.Delete("/observations/{id}", server.handleDeleteObservation())
+	server.webService.Post("/observations/{id}/restore", server.handleRestoreObservation())
 
 	server.webService.Delete("/patients/{id}", server.handleDeletePatient())
+	server.webService.Post("/patients/{id}/restore", server.handleRestorePatient())
 	server.webService.Delete("/attatchments/{id}", server.handleDeleteAttatchment())
+	server.webService.Post("/attatchments/{id}/restore", server.handleRestoreAttatchment())
+	server.webService.Get("/attatchments/{id}", server.handleGetAttatchmentMetadata())
+	server.webService.Put("/attatchments/{id}/content", server.handlePutAttatchmentContent())
+	server.webService.Get("/attatchments/{id}/versions", server.handleGetAttatchmentVersions())
+	server.webService.Get("/attatchments/{id}/versions/{version}", server.handleGetAttatchmentVersion())
+	server.webService.Get("/attatchments/{id}/thumbnail", server.handleGetAttatchmentThumbnail())
+
+	server.setupFhirRoutes()
 
 }
@@ -15,17 +15,41 @@ type HttpServer struct {
 	patientService            PatientService
 	attatchmentService        AttatchmentService
 	diagnosedConditionService DiagnosedConditionsService
+	medicationService         MedicationService
+	allergyService            AllergyService
+	observationService        ObservationService
+	encounterService          EncounterService
+	appointmentService        AppointmentService
+	importService             ImportService
+	fhirService               FhirService
+	exportService             ExportService
+	ccdaService               CcdaService
+	summaryService            SummaryService
+	duplicateService          DuplicateService
+	codeService               CodeService
 	logger                    *zap.Logger
 	webService                *web.Service
 }
 
-func NewServer(authService AuthService, userService UserService, patientService PatientService, attatchmentService AttatchmentService, diagnosedConditionService DiagnosedConditionsService, logger *zap.Logger) *HttpServer {
+func NewServer(authService AuthService, userService UserService, patientService PatientService, attatchmentService AttatchmentService, diagnosedConditionService DiagnosedConditionsService, medicationService MedicationService, allergyService AllergyService, observationService ObservationService, encounterService EncounterService, appointmentService AppointmentService, importService ImportService, fhirService FhirService, exportService ExportService, ccdaService CcdaService, summaryService SummaryService, duplicateService DuplicateService, codeService CodeService, logger *zap.Logger) *HttpServer {
 	return &HttpServer{
 		authService:               authService,
 		userService:               userService,
 		patientService:            patientService,
 		attatchmentService:        attatchmentService,
 		diagnosedConditionService: diagnosedConditionService,
+		medicationService:         medicationService,
+		allergyService:            allergyService,
+		observationService:        observationService,
+		encounterService:          encounterService,
+		appointmentService:        appointmentService,
+		importService:             importService,
+		fhirService:               fhirService,
+		exportService:             exportService,
+		ccdaService:               ccdaService,
+		summaryService:            summaryService,
+		duplicateService:          duplicateService,
+		codeService:               codeService,
 		logger:                    logger,
 	}
 }
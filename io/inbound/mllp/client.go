@@ -0,0 +1,35 @@
+package inboundmllp
+
+import (
+	"bufio"
+	"net"
+	"time"
+)
+
+// sends messages to an MLLP server one at a time, such as to test the server or replay messages into it
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func Dial(address string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, time.Second*5)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// sends the message and waits for its acknowledgement
+func (c *Client) Send(message string) (string, error) {
+	err := writeFrame(c.conn, message)
+	if err != nil {
+		return "", err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(time.Second * 30))
+	return readFrame(c.reader)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,136 @@
+package inboundmllp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MLLP wraps each message in a start block and an end block followed by a carriage return
+const (
+	startBlock     = 0x0b
+	endBlock       = 0x1c
+	carriageReturn = 0x0d
+)
+
+const (
+	maxMessageSize = 1 << 20
+	idleTimeout    = time.Minute * 5
+)
+
+type HL7Service interface {
+	HandleMessage(ctx context.Context, raw string) string
+}
+
+type MllpServer struct {
+	hl7Service HL7Service
+	logger     *zap.Logger
+}
+
+func NewServer(hl7Service HL7Service, logger *zap.Logger) *MllpServer {
+	return &MllpServer{
+		hl7Service: hl7Service,
+		logger:     logger,
+	}
+}
+
+// listens at the address, such as localhost:2575.  Messages are not authenticated, so the address should only be
+// reachable by the systems which send them
+func (server *MllpServer) Start(address string) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		server.logger.Error("error listening for MLLP", zap.Error(err))
+		return
+	}
+	server.logger.Info("listening for MLLP at", zap.String("adress", address))
+	err = server.Serve(listener)
+	if err != nil {
+		server.logger.Error("error accepting MLLP connection, no longer listening", zap.Error(err))
+	}
+}
+
+// accepts connections until the listener is closed.  Each connection may send any number of messages, which are acknowledged in order
+func (server *MllpServer) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go server.serve(conn)
+	}
+}
+
+func (server *MllpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		message, err := readFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				server.logger.Info("closing MLLP connection", zap.String("remote", conn.RemoteAddr().String()), zap.Error(err))
+			}
+			return
+		}
+		ack := server.hl7Service.HandleMessage(context.Background(), message)
+		err = writeFrame(conn, ack)
+		if err != nil {
+			server.logger.Info("error writing MLLP acknowledgement", zap.String("remote", conn.RemoteAddr().String()), zap.Error(err))
+			return
+		}
+	}
+}
+
+// anything sent before the start block is skipped
+func readFrame(reader *bufio.Reader) (string, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == startBlock {
+			break
+		}
+	}
+
+	var message []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == endBlock {
+			break
+		}
+		if len(message) >= maxMessageSize {
+			return "", fmt.Errorf("message is larger than %v bytes", maxMessageSize)
+		}
+		message = append(message, b)
+	}
+	b, err := reader.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if b != carriageReturn {
+		return "", fmt.Errorf("expected a carriage return after the end block, but got %#x", b)
+	}
+	return string(message), nil
+}
+
+func writeFrame(w io.Writer, message string) error {
+	frame := make([]byte, 0, len(message)+3)
+	frame = append(frame, startBlock)
+	frame = append(frame, message...)
+	frame = append(frame, endBlock, carriageReturn)
+	_, err := w.Write(frame)
+	return err
+}
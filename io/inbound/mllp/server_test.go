@@ -0,0 +1,66 @@
+package inboundmllp
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type echoHL7Service struct{}
+
+func (s echoHL7Service) HandleMessage(ctx context.Context, raw string) string {
+	return "ACK " + raw
+}
+
+func startServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go NewServer(echoHL7Service{}, zap.NewNop()).Serve(listener)
+	return listener.Addr().String()
+}
+
+func TestServe(t *testing.T) {
+	t.Run("Serve_AcknowledgesEachMessageInOrder", func(t *testing.T) {
+		client, err := Dial(startServer(t))
+		assert.Nil(t, err)
+		defer client.Close()
+
+		ack, err := client.Send("MSH|first\rPID|1")
+		assert.Nil(t, err)
+		assert.Equal(t, "ACK MSH|first\rPID|1", ack)
+
+		ack, err = client.Send("MSH|second")
+		assert.Nil(t, err)
+		assert.Equal(t, "ACK MSH|second", ack)
+	})
+
+	t.Run("Serve_SkipsBytesBeforeTheStartBlock", func(t *testing.T) {
+		conn, err := net.Dial("tcp", startServer(t))
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("noise\r\n\x0bMSH|framed\x1c\r"))
+		assert.Nil(t, err)
+
+		ack, err := readFrame(bufio.NewReader(conn))
+		assert.Nil(t, err)
+		assert.Equal(t, "ACK MSH|framed", ack)
+	})
+
+	t.Run("Serve_ClosesConnectionOnInvalidFrame", func(t *testing.T) {
+		conn, err := net.Dial("tcp", startServer(t))
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("\x0bMSH|unterminated\x1cX"))
+		assert.Nil(t, err)
+
+		_, err = readFrame(bufio.NewReader(conn))
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,191 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// encrypts fields with a new data key per envelope, which is itself wrapped by the active key encryption key
+type Encrypter struct {
+	mutex       sync.RWMutex
+	keys        map[string]cipher.AEAD
+	activeKeyId string
+	indexKey    []byte
+}
+
+// fields sealed under one data key.  The data key is only kept wrapped by the key encryption key with the id
+type Envelope struct {
+	KeyId      string
+	WrappedKey []byte
+	Fields     map[string][]byte
+}
+
+func newEncrypter(keys keyfile) (*Encrypter, error) {
+	e := &Encrypter{}
+	err := e.setKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// rereads the keyfile, such as after RotateKeyfile has added a key to it
+func (e *Encrypter) Reload(path string) error {
+	keys, err := readKeyfile(path)
+	if err != nil {
+		return err
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if !bytes.Equal(keys.IndexKey, e.indexKey) {
+		return fmt.Errorf("index key in keyfile %v has changed, existing blind indexes would no longer match", path)
+	}
+	return e.setKeys(keys)
+}
+
+func (e *Encrypter) setKeys(keys keyfile) error {
+	aeads := map[string]cipher.AEAD{}
+	for _, key := range keys.Keys {
+		aead, err := newAEAD(key.Key)
+		if err != nil {
+			return fmt.Errorf("error using key %v %w", key.Id, err)
+		}
+		aeads[key.Id] = aead
+	}
+	e.keys = aeads
+	e.activeKeyId = keys.Keys[len(keys.Keys)-1].Id
+	e.indexKey = keys.IndexKey
+	return nil
+}
+
+// the id of the key encryption key which wraps new data keys
+func (e *Encrypter) ActiveKeyId() string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.activeKeyId
+}
+
+// seals each field under a new data key.  The field name is authenticated with its value, so sealed values can't be
+// swapped between fields
+func (e *Encrypter) Seal(fields map[string]string) (Envelope, error) {
+	dataKey, err := randomBytes(keySize)
+	if err != nil {
+		return Envelope{}, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return Envelope{}, err
+	}
+	sealed := make(map[string][]byte, len(fields))
+	for field, value := range fields {
+		sealed[field], err = seal(aead, []byte(value), []byte(field))
+		if err != nil {
+			return Envelope{}, err
+		}
+	}
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	wrappedKey, err := seal(e.keys[e.activeKeyId], dataKey, []byte(e.activeKeyId))
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{KeyId: e.activeKeyId, WrappedKey: wrappedKey, Fields: sealed}, nil
+}
+
+// opens every field in the envelope
+func (e *Encrypter) Open(envelope Envelope) (map[string]string, error) {
+	dataKey, err := e.unwrap(envelope)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string, len(envelope.Fields))
+	for field, sealed := range envelope.Fields {
+		value, err := open(aead, sealed, []byte(field))
+		if err != nil {
+			return nil, fmt.Errorf("error opening field %v %w", field, err)
+		}
+		fields[field] = string(value)
+	}
+	return fields, nil
+}
+
+// wraps the envelope's data key with the active key encryption key, if an earlier one wrapped it.  The fields are
+// left as they are, as the data key is unchanged
+func (e *Encrypter) Rewrap(envelope Envelope) (Envelope, bool, error) {
+	activeKeyId := e.ActiveKeyId()
+	if envelope.KeyId == activeKeyId {
+		return envelope, false, nil
+	}
+	dataKey, err := e.unwrap(envelope)
+	if err != nil {
+		return Envelope{}, false, err
+	}
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	wrappedKey, err := seal(e.keys[e.activeKeyId], dataKey, []byte(e.activeKeyId))
+	if err != nil {
+		return Envelope{}, false, err
+	}
+	return Envelope{KeyId: e.activeKeyId, WrappedKey: wrappedKey, Fields: envelope.Fields}, true, nil
+}
+
+// a keyed hash of the value, so equal values can be found without opening them.  The field name is hashed with the
+// value, so values in different fields don't share an index
+func (e *Encrypter) BlindIndex(field string, value string) string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	mac := hmac.New(sha256.New, e.indexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (e *Encrypter) unwrap(envelope Envelope) ([]byte, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	kek, ok := e.keys[envelope.KeyId]
+	if !ok {
+		return nil, fmt.Errorf("key encryption key %v is not in the keyfile", envelope.KeyId)
+	}
+	dataKey, err := open(kek, envelope.WrappedKey, []byte(envelope.KeyId))
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key with key %v %w", envelope.KeyId, err)
+	}
+	return dataKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// the nonce is prepended to the ciphertext
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed value is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
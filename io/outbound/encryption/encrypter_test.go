@@ -0,0 +1,147 @@
+package encryption
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncrypter(t *testing.T) {
+	fields := map[string]string{"address": "1 Example Street", "phoneNumber": "555 555 0100"}
+
+	t.Run("LoadEncrypter_CreatesKeyfile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		e, err := LoadEncrypter(path)
+		assert.Nil(t, err)
+		info, err := os.Stat(path)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		//loading it again uses the same keys
+		again, err := LoadEncrypter(path)
+		assert.Nil(t, err)
+		assert.Equal(t, e.ActiveKeyId(), again.ActiveKeyId())
+		assert.Equal(t, e.BlindIndex("identifier", "123"), again.BlindIndex("identifier", "123"))
+		envelope, _ := e.Seal(fields)
+		opened, err := again.Open(envelope)
+		assert.Nil(t, err)
+		assert.Equal(t, fields, opened)
+	})
+
+	t.Run("LoadEncrypter_InvalidKeyfile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		os.WriteFile(path, []byte(`{"keys":[{"id":"a","key":"c2hvcnQ="}]}`), 0o600)
+		_, err := LoadEncrypter(path)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Seal_Open", func(t *testing.T) {
+		e, _ := LoadEncrypter(filepath.Join(t.TempDir(), "keys.json"))
+		envelope, err := e.Seal(fields)
+		assert.Nil(t, err)
+		assert.Equal(t, e.ActiveKeyId(), envelope.KeyId)
+		assert.NotContains(t, string(envelope.Fields["address"]), fields["address"])
+
+		opened, err := e.Open(envelope)
+		assert.Nil(t, err)
+		assert.Equal(t, fields, opened)
+	})
+
+	t.Run("Open_SwappedFields", func(t *testing.T) {
+		e, _ := LoadEncrypter(filepath.Join(t.TempDir(), "keys.json"))
+		envelope, _ := e.Seal(fields)
+		envelope.Fields["address"], envelope.Fields["phoneNumber"] = envelope.Fields["phoneNumber"], envelope.Fields["address"]
+		_, err := e.Open(envelope)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Open_UnknownKey", func(t *testing.T) {
+		e, _ := LoadEncrypter(filepath.Join(t.TempDir(), "keys.json"))
+		other, _ := LoadEncrypter(filepath.Join(t.TempDir(), "keys.json"))
+		envelope, _ := other.Seal(fields)
+		_, err := e.Open(envelope)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Rewrap_AfterRotation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		e, _ := LoadEncrypter(path)
+		envelope, _ := e.Seal(fields)
+		index := e.BlindIndex("identifier", "123")
+
+		keyId, err := RotateKeyfile(path)
+		assert.Nil(t, err)
+		assert.Nil(t, e.Reload(path))
+		assert.Equal(t, keyId, e.ActiveKeyId())
+		assert.Equal(t, index, e.BlindIndex("identifier", "123"))
+
+		//envelopes wrapped by the earlier key can still be opened
+		opened, err := e.Open(envelope)
+		assert.Nil(t, err)
+		assert.Equal(t, fields, opened)
+
+		rewrapped, changed, err := e.Rewrap(envelope)
+		assert.Nil(t, err)
+		assert.True(t, changed)
+		assert.Equal(t, keyId, rewrapped.KeyId)
+		opened, err = e.Open(rewrapped)
+		assert.Nil(t, err)
+		assert.Equal(t, fields, opened)
+
+		_, changed, _ = e.Rewrap(rewrapped)
+		assert.False(t, changed)
+	})
+
+	t.Run("RetireKeyfileKeys", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		e, _ := LoadEncrypter(path)
+		firstKeyId := e.ActiveKeyId()
+		envelope, _ := e.Seal(fields)
+		secondKeyId, _ := RotateKeyfile(path)
+		thirdKeyId, _ := RotateKeyfile(path)
+
+		//the first key still wraps a data key and the newest key is kept, so only the second is retired
+		retired, err := RetireKeyfileKeys(path, map[string]bool{firstKeyId: true})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{secondKeyId}, retired)
+		assert.Nil(t, e.Reload(path))
+		assert.Equal(t, thirdKeyId, e.ActiveKeyId())
+		opened, err := e.Open(envelope)
+		assert.Nil(t, err)
+		assert.Equal(t, fields, opened)
+
+		//once rewrapped, the first key is no longer in use either
+		rewrapped, _, _ := e.Rewrap(envelope)
+		retired, err = RetireKeyfileKeys(path, map[string]bool{rewrapped.KeyId: true})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{firstKeyId}, retired)
+		assert.Nil(t, e.Reload(path))
+		_, err = e.Open(envelope)
+		assert.NotNil(t, err)
+		opened, err = e.Open(rewrapped)
+		assert.Nil(t, err)
+		assert.Equal(t, fields, opened)
+
+		retired, err = RetireKeyfileKeys(path, map[string]bool{})
+		assert.Nil(t, err)
+		assert.Empty(t, retired)
+	})
+
+	t.Run("Reload_ChangedIndexKey", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		e, _ := LoadEncrypter(path)
+		os.Remove(path)
+		LoadEncrypter(path)
+		assert.NotNil(t, e.Reload(path))
+	})
+
+	t.Run("BlindIndex", func(t *testing.T) {
+		e, _ := LoadEncrypter(filepath.Join(t.TempDir(), "keys.json"))
+		assert.Equal(t, e.BlindIndex("identifier", "123"), e.BlindIndex("identifier", "123"))
+		assert.NotEqual(t, e.BlindIndex("identifier", "123"), e.BlindIndex("identifier", "124"))
+		assert.NotEqual(t, e.BlindIndex("identifier", "123"), e.BlindIndex("phoneNumber", "123"))
+		assert.NotContains(t, e.BlindIndex("identifier", "123"), "123")
+	})
+}
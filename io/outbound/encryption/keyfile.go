@@ -0,0 +1,181 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const keySize = 32
+
+// the keys held on local disk.  The last key encryption key wraps new data keys, while earlier ones are kept to unwrap
+// the data keys they wrapped until those are rewrapped.  The blind index key never changes, as every index would have
+// to be recomputed from the plaintext if it did
+type keyfile struct {
+	Keys     []keyfileKey `json:"keys"`
+	IndexKey []byte       `json:"indexKey"`
+}
+
+type keyfileKey struct {
+	Id        string    `json:"id"`
+	Key       []byte    `json:"key"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// loads the keys from the keyfile at the path, first creating it with new keys if there is none
+func LoadEncrypter(path string) (*Encrypter, error) {
+	keys, err := readKeyfile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		keys, err = newKeyfile()
+		if err != nil {
+			return nil, err
+		}
+		err = writeKeyfile(path, keys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newEncrypter(keys)
+}
+
+// adds a new key encryption key to the keyfile, which wraps data keys from then on, returning its id
+func RotateKeyfile(path string) (string, error) {
+	keys, err := readKeyfile(path)
+	if err != nil {
+		return "", err
+	}
+	key, err := newKeyfileKey()
+	if err != nil {
+		return "", err
+	}
+	keys.Keys = append(keys.Keys, key)
+	err = writeKeyfile(path, keys)
+	if err != nil {
+		return "", err
+	}
+	return key.Id, nil
+}
+
+// removes the key encryption keys which are not in use from the keyfile, returning their ids.  The newest key is always
+// kept, as it wraps new data keys
+func RetireKeyfileKeys(path string, inUse map[string]bool) ([]string, error) {
+	keys, err := readKeyfile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []keyfileKey
+	var retired []string
+	for i, key := range keys.Keys {
+		if inUse[key.Id] || i == len(keys.Keys)-1 {
+			kept = append(kept, key)
+		} else {
+			retired = append(retired, key.Id)
+		}
+	}
+	if len(retired) == 0 {
+		return nil, nil
+	}
+
+	keys.Keys = kept
+	err = writeKeyfile(path, keys)
+	if err != nil {
+		return nil, err
+	}
+	return retired, nil
+}
+
+func newKeyfile() (keyfile, error) {
+	key, err := newKeyfileKey()
+	if err != nil {
+		return keyfile{}, err
+	}
+	indexKey, err := randomBytes(keySize)
+	if err != nil {
+		return keyfile{}, err
+	}
+	return keyfile{Keys: []keyfileKey{key}, IndexKey: indexKey}, nil
+}
+
+func newKeyfileKey() (keyfileKey, error) {
+	id, err := randomBytes(8)
+	if err != nil {
+		return keyfileKey{}, err
+	}
+	key, err := randomBytes(keySize)
+	if err != nil {
+		return keyfileKey{}, err
+	}
+	return keyfileKey{Id: hex.EncodeToString(id), Key: key, CreatedAt: time.Now().UTC()}, nil
+}
+
+func readKeyfile(path string) (keyfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return keyfile{}, err
+	}
+	var keys keyfile
+	err = json.Unmarshal(data, &keys)
+	if err != nil {
+		return keyfile{}, fmt.Errorf("keyfile %v is not valid json %w", path, err)
+	}
+	if len(keys.Keys) == 0 {
+		return keyfile{}, fmt.Errorf("keyfile %v has no key encryption keys", path)
+	}
+	ids := map[string]bool{}
+	for _, key := range keys.Keys {
+		if key.Id == "" || ids[key.Id] {
+			return keyfile{}, fmt.Errorf("keyfile %v has a key without an id, or with the id of another key", path)
+		}
+		ids[key.Id] = true
+		if len(key.Key) != keySize {
+			return keyfile{}, fmt.Errorf("key %v in keyfile %v is %v bytes, expected %v", key.Id, path, len(key.Key), keySize)
+		}
+	}
+	if len(keys.IndexKey) != keySize {
+		return keyfile{}, fmt.Errorf("index key in keyfile %v is %v bytes, expected %v", path, len(keys.IndexKey), keySize)
+	}
+	return keys, nil
+}
+
+// the keyfile is replaced whole, so a failed write never leaves it half written.  Only its owner may read it
+func writeKeyfile(path string, keys keyfile) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".keyfile-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,79 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// stores files beneath a directory on local disk, named by slash separated paths relative to it
+type FileStore struct {
+	directory string
+}
+
+func NewFileStore(directory string) FileStore {
+	return FileStore{directory: directory}
+}
+
+// creates the file along with any directories it is in.  Files and directories are only readable by their owner, and
+// a file which already exists is never replaced
+func (s FileStore) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+}
+
+func (s FileStore) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// removes the file, or the directory and everything in it.  Removing a name which does not exist is not an error
+func (s FileStore) Remove(ctx context.Context, name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if path == filepath.Clean(s.directory) {
+		return fmt.Errorf("%q is not a valid file name", name)
+	}
+	return os.RemoveAll(path)
+}
+
+// the names of the files and directories directly beneath the directory
+func (s FileStore) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.directory)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// names may not reach outside of the directory
+func (s FileStore) path(name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("%q is not a valid file name", name)
+	}
+	return filepath.Join(s.directory, local), nil
+}
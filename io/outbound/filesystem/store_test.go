@@ -0,0 +1,73 @@
+package filesystem
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir())
+
+	t.Run("FileStore_CreateAndOpen", func(t *testing.T) {
+		file, err := store.Create(ctx, "1/Patient.ndjson")
+		assert.Nil(t, err)
+		_, err = file.Write([]byte("{}\n"))
+		assert.Nil(t, err)
+		assert.Nil(t, file.Close())
+
+		opened, err := store.Open(ctx, "1/Patient.ndjson")
+		assert.Nil(t, err)
+		defer opened.Close()
+		data, err := io.ReadAll(opened)
+		assert.Nil(t, err)
+		assert.Equal(t, "{}\n", string(data))
+	})
+
+	t.Run("FileStore_CreateOnlyReadableByOwnerAndNeverReplaced", func(t *testing.T) {
+		file, err := store.Create(ctx, "2/Patient.ndjson")
+		assert.Nil(t, err)
+		assert.Nil(t, file.Close())
+
+		info, err := os.Stat(filepath.Join(store.directory, "2", "Patient.ndjson"))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		_, err = store.Create(ctx, "2/Patient.ndjson")
+		assert.ErrorIs(t, err, fs.ErrExist)
+	})
+
+	t.Run("FileStore_ListAndRemove", func(t *testing.T) {
+		listStore := NewFileStore(filepath.Join(t.TempDir(), "exports"))
+		names, err := listStore.List(ctx)
+		assert.Nil(t, err)
+		assert.Empty(t, names)
+
+		file, err := listStore.Create(ctx, "3/Patient.ndjson")
+		assert.Nil(t, err)
+		assert.Nil(t, file.Close())
+		names, err = listStore.List(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"3"}, names)
+
+		assert.Nil(t, listStore.Remove(ctx, "3"))
+		names, err = listStore.List(ctx)
+		assert.Nil(t, err)
+		assert.Empty(t, names)
+		assert.Nil(t, listStore.Remove(ctx, "3"))
+		assert.NotNil(t, listStore.Remove(ctx, "."))
+	})
+
+	t.Run("FileStore_NameOutsideDirectory", func(t *testing.T) {
+		_, err := store.Create(ctx, "../Patient.ndjson")
+		assert.NotNil(t, err)
+		_, err = store.Open(ctx, "/etc/passwd")
+		assert.NotNil(t, err)
+	})
+}
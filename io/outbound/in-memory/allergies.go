@@ -0,0 +1,142 @@
+package inmemory
+
+import (
+	"context"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"sort"
+	"time"
+)
+
+func (r *InMemoryRepo) InsertAllergy(ctx context.Context, allergy models.Allergy) (int, error) {
+	defer r.lock(ctx)()
+
+	id := r.nextAllergyId
+	r.nextAllergyId++
+
+	allergy.Id = id
+	recordUndo(ctx, r.allergies, id)
+	r.allergies[id] = allergy
+
+	return id, nil
+}
+
+func (r *InMemoryRepo) GetAllergy(ctx context.Context, allergyId int) (models.Allergy, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	allergy, exists := r.activeAllergy(allergyId)
+	if !exists {
+		return models.Allergy{}, customerrors.NewInvalidInputError("allergy not found")
+	}
+	return allergy, nil
+}
+
+func (r *InMemoryRepo) UpdateAllergy(ctx context.Context, allergy models.Allergy) error {
+	defer r.lock(ctx)()
+
+	if _, exists := r.activeAllergy(allergy.Id); !exists {
+		return customerrors.NewInvalidInputError("allergy not found")
+	}
+
+	recordUndo(ctx, r.allergies, allergy.Id)
+	r.allergies[allergy.Id] = allergy
+	return nil
+}
+
+func (r *InMemoryRepo) DeleteAllergy(ctx context.Context, allergyId int) error {
+	defer r.lock(ctx)()
+
+	allergy, exists := r.activeAllergy(allergyId)
+	if !exists {
+		return customerrors.NewInvalidInputError("allergy not found")
+	}
+
+	allergy.DeletedAt = r.now(ctx)
+	recordUndo(ctx, r.allergies, allergyId)
+	r.allergies[allergyId] = allergy
+	return nil
+}
+
+func (r *InMemoryRepo) RestoreAllergy(ctx context.Context, patientId int, allergyId int) error {
+	defer r.lock(ctx)()
+
+	allergy, exists := r.allergies[allergyId]
+	if !exists || allergy.PatientId != patientId {
+		return customerrors.NewInvalidInputError("allergy not found")
+	}
+	if allergy.DeletedAt.IsZero() {
+		return customerrors.NewInvalidInputError("allergy is not deleted")
+	}
+	if _, exists := r.activePatient(allergy.PatientId); !exists {
+		return customerrors.NewInvalidInputError("patient id not found")
+	}
+
+	allergy.DeletedAt = time.Time{}
+	recordUndo(ctx, r.allergies, allergyId)
+	r.allergies[allergyId] = allergy
+	return nil
+}
+
+func (r *InMemoryRepo) GetAllergiesByPatientId(ctx context.Context, patientId int) ([]models.Allergy, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	allergies := []models.Allergy{}
+	for _, allergy := range r.allergies {
+		if allergy.PatientId == patientId && allergy.DeletedAt.IsZero() {
+			allergies = append(allergies, allergy)
+		}
+	}
+
+	sort.Slice(allergies, func(i, j int) bool {
+		return allergies[i].Id < allergies[j].Id
+	})
+	return allergies, nil
+}
+
+func (r *InMemoryRepo) DeleteAllergiesByPatientId(ctx context.Context, patientId int) error {
+	defer r.lock(ctx)()
+
+	for id, allergy := range r.allergies {
+		if allergy.PatientId == patientId && allergy.DeletedAt.IsZero() {
+			allergy.DeletedAt = r.now(ctx)
+			recordUndo(ctx, r.allergies, id)
+			r.allergies[id] = allergy
+		}
+	}
+
+	return nil
+}
+
+func (r *InMemoryRepo) ReassignAllergies(ctx context.Context, fromPatientId int, toPatientId int) error {
+	defer r.lock(ctx)()
+
+	for id, allergy := range r.allergies {
+		if allergy.PatientId == fromPatientId && allergy.DeletedAt.IsZero() {
+			allergy.PatientId = toPatientId
+			recordUndo(ctx, r.allergies, id)
+			r.allergies[id] = allergy
+		}
+	}
+
+	return nil
+}
+
+func (r *InMemoryRepo) PurgeDeletedAllergies(ctx context.Context, deletedBefore time.Time) (int, error) {
+	defer r.lock(ctx)()
+
+	purged := 0
+	for id, allergy := range r.allergies {
+		if !allergy.DeletedAt.IsZero() && allergy.DeletedAt.Before(deletedBefore) {
+			delete(r.allergies, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *InMemoryRepo) activeAllergy(id int) (models.Allergy, bool) {
+	allergy, exists := r.allergies[id]
+	return allergy, exists && allergy.DeletedAt.IsZero()
+}
@@ -0,0 +1,255 @@
+package inmemory
+
+import (
+	"context"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// slots of the same practitioner may not overlap
+func (r *InMemoryRepo) InsertSlot(ctx context.Context, slot models.Slot) (int, error) {
+	defer r.lock(ctx)()
+
+	for _, existing := range r.slots {
+		if strings.EqualFold(existing.Practitioner, slot.Practitioner) && overlaps(existing.Start, existing.End, slot.Start, slot.End) {
+			return 0, customerrors.NewAlreadyExistsError("slot overlaps another slot of the practitioner")
+		}
+	}
+
+	id := r.nextSlotId
+	r.nextSlotId++
+
+	slot.Id = id
+	recordUndo(ctx, r.slots, id)
+	r.slots[id] = slot
+
+	return id, nil
+}
+
+func (r *InMemoryRepo) GetSlot(ctx context.Context, slotId int) (models.Slot, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	slot, exists := r.slots[slotId]
+	if !exists {
+		return models.Slot{}, customerrors.NewInvalidInputError("slot not found")
+	}
+	return slot, nil
+}
+
+func (r *InMemoryRepo) GetSlots(ctx context.Context, practitioner string, from time.Time, to time.Time, available bool) ([]models.Slot, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	slots := []models.Slot{}
+	for _, slot := range r.slots {
+		if practitioner != "" && !strings.EqualFold(slot.Practitioner, practitioner) {
+			continue
+		}
+		if slot.Start.Before(from) || !slot.Start.Before(to) {
+			continue
+		}
+		if available && slot.AppointmentId != 0 {
+			continue
+		}
+		slots = append(slots, slot)
+	}
+
+	sort.Slice(slots, func(i, j int) bool {
+		if slots[i].Start.Equal(slots[j].Start) {
+			return slots[i].Practitioner < slots[j].Practitioner
+		}
+		return slots[i].Start.Before(slots[j].Start)
+	})
+	return slots, nil
+}
+
+// the slot is checked and claimed under the same lock, so concurrent bookings of one slot cannot both succeed
+func (r *InMemoryRepo) BookAppointment(ctx context.Context, appointment models.Appointment) (models.Appointment, error) {
+	defer r.lock(ctx)()
+
+	slot, err := r.claimableSlot(appointment, appointment.SlotId)
+	if err != nil {
+		return models.Appointment{}, err
+	}
+
+	appointment.Id = r.nextAppointmentId
+	r.nextAppointmentId++
+	appointment.Practitioner = slot.Practitioner
+	appointment.Start = slot.Start
+	appointment.End = slot.End
+
+	recordUndo(ctx, r.appointments, appointment.Id)
+	r.appointments[appointment.Id] = appointment
+	slot.AppointmentId = appointment.Id
+	recordUndo(ctx, r.slots, slot.Id)
+	r.slots[slot.Id] = slot
+
+	return appointment, nil
+}
+
+func (r *InMemoryRepo) GetAppointment(ctx context.Context, appointmentId int) (models.Appointment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	appointment, exists := r.appointments[appointmentId]
+	if !exists {
+		return models.Appointment{}, customerrors.NewInvalidInputError("appointment not found")
+	}
+	return appointment, nil
+}
+
+func (r *InMemoryRepo) CancelAppointment(ctx context.Context, appointmentId int) error {
+	defer r.lock(ctx)()
+
+	appointment, exists := r.appointments[appointmentId]
+	if !exists {
+		return customerrors.NewInvalidInputError("appointment not found")
+	}
+	if appointment.Status == models.AppointmentStatusCancelled {
+		return customerrors.NewInvalidInputError("appointment is already cancelled")
+	}
+
+	r.cancelAppointment(ctx, appointment)
+	return nil
+}
+
+// the appointment moves to the new slot and releases its old one in a single step
+func (r *InMemoryRepo) RescheduleAppointment(ctx context.Context, appointmentId int, slotId int) (models.Appointment, error) {
+	defer r.lock(ctx)()
+
+	appointment, exists := r.appointments[appointmentId]
+	if !exists {
+		return models.Appointment{}, customerrors.NewInvalidInputError("appointment not found")
+	}
+	if appointment.Status == models.AppointmentStatusCancelled {
+		return models.Appointment{}, customerrors.NewInvalidInputError("cancelled appointments cannot be rescheduled")
+	}
+	if appointment.SlotId == slotId {
+		return appointment, nil
+	}
+
+	slot, err := r.claimableSlot(appointment, slotId)
+	if err != nil {
+		return models.Appointment{}, err
+	}
+
+	recordUndo(ctx, r.slots, appointment.SlotId)
+	r.releaseSlot(appointment)
+
+	appointment.SlotId = slot.Id
+	appointment.Practitioner = slot.Practitioner
+	appointment.Start = slot.Start
+	appointment.End = slot.End
+	recordUndo(ctx, r.appointments, appointmentId)
+	r.appointments[appointmentId] = appointment
+	slot.AppointmentId = appointmentId
+	recordUndo(ctx, r.slots, slot.Id)
+	r.slots[slot.Id] = slot
+
+	return appointment, nil
+}
+
+func (r *InMemoryRepo) GetAppointmentsByPatientId(ctx context.Context, patientId int) ([]models.Appointment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	appointments := []models.Appointment{}
+	for _, appointment := range r.appointments {
+		if appointment.PatientId == patientId {
+			appointments = append(appointments, appointment)
+		}
+	}
+
+	sortAppointments(appointments)
+	return appointments, nil
+}
+
+// booked appointments starting within the range
+func (r *InMemoryRepo) GetAppointments(ctx context.Context, practitioner string, from time.Time, to time.Time) ([]models.Appointment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	appointments := []models.Appointment{}
+	for _, appointment := range r.appointments {
+		if appointment.Status != models.AppointmentStatusBooked {
+			continue
+		}
+		if practitioner != "" && !strings.EqualFold(appointment.Practitioner, practitioner) {
+			continue
+		}
+		if appointment.Start.Before(from) || !appointment.Start.Before(to) {
+			continue
+		}
+		appointments = append(appointments, appointment)
+	}
+
+	sortAppointments(appointments)
+	return appointments, nil
+}
+
+func (r *InMemoryRepo) CancelAppointmentsByPatientId(ctx context.Context, patientId int) error {
+	defer r.lock(ctx)()
+
+	for _, appointment := range r.appointments {
+		if appointment.PatientId == patientId && appointment.Status == models.AppointmentStatusBooked {
+			r.cancelAppointment(ctx, appointment)
+		}
+	}
+
+	return nil
+}
+
+// must be called with the repo locked.  A patient cannot be booked into two appointments which overlap
+func (r *InMemoryRepo) claimableSlot(appointment models.Appointment, slotId int) (models.Slot, error) {
+	slot, exists := r.slots[slotId]
+	if !exists {
+		return models.Slot{}, customerrors.NewInvalidInputError("slot not found")
+	}
+	if slot.AppointmentId != 0 {
+		return models.Slot{}, customerrors.NewAlreadyExistsError("slot is already booked")
+	}
+	for _, other := range r.appointments {
+		if other.Id == appointment.Id || other.PatientId != appointment.PatientId || other.Status != models.AppointmentStatusBooked {
+			continue
+		}
+		if overlaps(other.Start, other.End, slot.Start, slot.End) {
+			return models.Slot{}, customerrors.NewAlreadyExistsError("patient already has an appointment at that time")
+		}
+	}
+	return slot, nil
+}
+
+// must be called with the repo locked
+func (r *InMemoryRepo) cancelAppointment(ctx context.Context, appointment models.Appointment) {
+	recordUndo(ctx, r.slots, appointment.SlotId)
+	r.releaseSlot(appointment)
+	appointment.Status = models.AppointmentStatusCancelled
+	recordUndo(ctx, r.appointments, appointment.Id)
+	r.appointments[appointment.Id] = appointment
+}
+
+// must be called with the repo locked
+func (r *InMemoryRepo) releaseSlot(appointment models.Appointment) {
+	slot, exists := r.slots[appointment.SlotId]
+	if exists && slot.AppointmentId == appointment.Id {
+		slot.AppointmentId = 0
+		r.slots[slot.Id] = slot
+	}
+}
+
+func overlaps(start time.Time, end time.Time, otherStart time.Time, otherEnd time.Time) bool {
+	return start.Before(otherEnd) && otherStart.Before(end)
+}
+
+func sortAppointments(appointments []models.Appointment) {
+	sort.Slice(appointments, func(i, j int) bool {
+		if appointments[i].Start.Equal(appointments[j].Start) {
+			return appointments[i].Id < appointments[j].Id
+		}
+		return appointments[i].Start.Before(appointments[j].Start)
+	})
+}
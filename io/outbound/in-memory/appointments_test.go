@@ -0,0 +1,134 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func insertSlot(ctx context.Context, repo *InMemoryRepo, practitioner string, start time.Time) int {
+	id, _ := repo.InsertSlot(ctx, models.Slot{SlotRequest: models.SlotRequest{
+		Practitioner: practitioner,
+		Start:        start,
+		End:          start.Add(30 * time.Minute),
+	}})
+	return id
+}
+
+func TestBookAppointment(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2030, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	t.Run("BookAppointment_ConcurrentBookingsOfOneSlot", func(t *testing.T) {
+		repo := newTestRepo(t)
+		slotId := insertSlot(ctx, repo, "Dr. Jones", start)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 50)
+		for patientId := 1; patientId <= 50; patientId++ {
+			wg.Add(1)
+			go func(patientId int) {
+				defer wg.Done()
+				_, err := repo.BookAppointment(ctx, models.Appointment{PatientId: patientId, SlotId: slotId, Status: models.AppointmentStatusBooked})
+				errs <- err
+			}(patientId)
+		}
+		wg.Wait()
+		close(errs)
+
+		booked := 0
+		for err := range errs {
+			var alreadyExists customerrors.AlreadyExistsError
+			if err == nil {
+				booked++
+			} else {
+				assert.True(t, errors.As(err, &alreadyExists))
+			}
+		}
+		assert.Equal(t, 1, booked)
+	})
+
+	t.Run("BookAppointment_PatientAlreadyBookedAtThatTime", func(t *testing.T) {
+		repo := newTestRepo(t)
+		first := insertSlot(ctx, repo, "Dr. Jones", start)
+		overlapping := insertSlot(ctx, repo, "Dr. Smith", start.Add(15*time.Minute))
+		_, err := repo.BookAppointment(ctx, models.Appointment{PatientId: 1, SlotId: first, Status: models.AppointmentStatusBooked})
+		assert.Nil(t, err)
+
+		_, err = repo.BookAppointment(ctx, models.Appointment{PatientId: 1, SlotId: overlapping, Status: models.AppointmentStatusBooked})
+		assert.Equal(t, "patient already has an appointment at that time", err.Error())
+	})
+
+	t.Run("BookAppointment_CancelledSlotCanBeRebooked", func(t *testing.T) {
+		repo := newTestRepo(t)
+		slotId := insertSlot(ctx, repo, "Dr. Jones", start)
+		appointment, _ := repo.BookAppointment(ctx, models.Appointment{PatientId: 1, SlotId: slotId, Status: models.AppointmentStatusBooked})
+		assert.Nil(t, repo.CancelAppointment(ctx, appointment.Id))
+
+		_, err := repo.BookAppointment(ctx, models.Appointment{PatientId: 2, SlotId: slotId, Status: models.AppointmentStatusBooked})
+		assert.Nil(t, err)
+	})
+}
+
+func TestRescheduleAppointment(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2030, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	t.Run("RescheduleAppointment_ReleasesOldSlot", func(t *testing.T) {
+		repo := newTestRepo(t)
+		oldSlot := insertSlot(ctx, repo, "Dr. Jones", start)
+		newSlot := insertSlot(ctx, repo, "Dr. Jones", start.Add(time.Hour))
+		appointment, _ := repo.BookAppointment(ctx, models.Appointment{PatientId: 1, SlotId: oldSlot, Status: models.AppointmentStatusBooked})
+
+		rescheduled, err := repo.RescheduleAppointment(ctx, appointment.Id, newSlot)
+		assert.Nil(t, err)
+		assert.Equal(t, start.Add(time.Hour), rescheduled.Start)
+
+		available, _ := repo.GetSlots(ctx, "", start, start.AddDate(0, 0, 1), true)
+		assert.Equal(t, 1, len(available))
+		assert.Equal(t, oldSlot, available[0].Id)
+	})
+
+	t.Run("RescheduleAppointment_NewSlotAlreadyBooked", func(t *testing.T) {
+		repo := newTestRepo(t)
+		oldSlot := insertSlot(ctx, repo, "Dr. Jones", start)
+		newSlot := insertSlot(ctx, repo, "Dr. Jones", start.Add(time.Hour))
+		appointment, _ := repo.BookAppointment(ctx, models.Appointment{PatientId: 1, SlotId: oldSlot, Status: models.AppointmentStatusBooked})
+		repo.BookAppointment(ctx, models.Appointment{PatientId: 2, SlotId: newSlot, Status: models.AppointmentStatusBooked})
+
+		_, err := repo.RescheduleAppointment(ctx, appointment.Id, newSlot)
+		assert.Equal(t, "slot is already booked", err.Error())
+
+		unchanged, _ := repo.GetAppointment(ctx, appointment.Id)
+		assert.Equal(t, oldSlot, unchanged.SlotId)
+	})
+}
+
+func TestInsertSlot(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2030, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	t.Run("InsertSlot_OverlapsPractitionersSlot", func(t *testing.T) {
+		repo := newTestRepo(t)
+		insertSlot(ctx, repo, "Dr. Jones", start)
+
+		_, err := repo.InsertSlot(ctx, models.Slot{SlotRequest: models.SlotRequest{Practitioner: "dr. jones", Start: start.Add(10 * time.Minute), End: start.Add(time.Hour)}})
+		assert.Equal(t, "slot overlaps another slot of the practitioner", err.Error())
+	})
+
+	t.Run("InsertSlot_AdjacentAndOtherPractitioners", func(t *testing.T) {
+		repo := newTestRepo(t)
+		insertSlot(ctx, repo, "Dr. Jones", start)
+
+		_, err := repo.InsertSlot(ctx, models.Slot{SlotRequest: models.SlotRequest{Practitioner: "Dr. Jones", Start: start.Add(30 * time.Minute), End: start.Add(time.Hour)}})
+		assert.Nil(t, err)
+		_, err = repo.InsertSlot(ctx, models.Slot{SlotRequest: models.SlotRequest{Practitioner: "Dr. Smith", Start: start, End: start.Add(time.Hour)}})
+		assert.Nil(t, err)
+	})
+}
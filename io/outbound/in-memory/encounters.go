@@ -0,0 +1,176 @@
+package inmemory
+
+import (
+	"context"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"sort"
+	"time"
+)
+
+func (r *InMemoryRepo) InsertEncounter(ctx context.Context, encounter models.Encounter) (int, error) {
+	defer r.lock(ctx)()
+
+	id := r.nextEncounterId
+	r.nextEncounterId++
+
+	encounter.Id = id
+	recordUndo(ctx, r.encounters, id)
+	r.encounters[id] = encounter
+
+	return id, nil
+}
+
+func (r *InMemoryRepo) GetEncounter(ctx context.Context, encounterId int) (models.Encounter, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	encounter, exists := r.activeEncounter(encounterId)
+	if !exists {
+		return models.Encounter{}, customerrors.NewInvalidInputError("encounter not found")
+	}
+	return encounter, nil
+}
+
+func (r *InMemoryRepo) UpdateEncounter(ctx context.Context, encounter models.Encounter) error {
+	defer r.lock(ctx)()
+
+	if _, exists := r.activeEncounter(encounter.Id); !exists {
+		return customerrors.NewInvalidInputError("encounter not found")
+	}
+
+	recordUndo(ctx, r.encounters, encounter.Id)
+	r.encounters[encounter.Id] = encounter
+	return nil
+}
+
+func (r *InMemoryRepo) DeleteEncounter(ctx context.Context, encounterId int) error {
+	defer r.lock(ctx)()
+
+	encounter, exists := r.activeEncounter(encounterId)
+	if !exists {
+		return customerrors.NewInvalidInputError("encounter not found")
+	}
+
+	encounter.DeletedAt = r.now(ctx)
+	recordUndo(ctx, r.encounters, encounterId)
+	r.encounters[encounterId] = encounter
+	return nil
+}
+
+func (r *InMemoryRepo) RestoreEncounter(ctx context.Context, patientId int, encounterId int) error {
+	defer r.lock(ctx)()
+
+	encounter, exists := r.encounters[encounterId]
+	if !exists || encounter.PatientId != patientId {
+		return customerrors.NewInvalidInputError("encounter not found")
+	}
+	if encounter.DeletedAt.IsZero() {
+		return customerrors.NewInvalidInputError("encounter is not deleted")
+	}
+	if _, exists := r.activePatient(encounter.PatientId); !exists {
+		return customerrors.NewInvalidInputError("patient id not found")
+	}
+
+	encounter.DeletedAt = time.Time{}
+	recordUndo(ctx, r.encounters, encounterId)
+	r.encounters[encounterId] = encounter
+	return nil
+}
+
+func (r *InMemoryRepo) GetEncountersByPatientId(ctx context.Context, patientId int) ([]models.Encounter, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	encounters := []models.Encounter{}
+	for _, encounter := range r.encounters {
+		if encounter.PatientId == patientId && encounter.DeletedAt.IsZero() {
+			encounters = append(encounters, encounter)
+		}
+	}
+
+	sort.Slice(encounters, func(i, j int) bool {
+		return encounters[i].Start.Before(encounters[j].Start)
+	})
+	return encounters, nil
+}
+
+func (r *InMemoryRepo) DeleteEncountersByPatientId(ctx context.Context, patientId int) error {
+	defer r.lock(ctx)()
+
+	for id, encounter := range r.encounters {
+		if encounter.PatientId == patientId && encounter.DeletedAt.IsZero() {
+			encounter.DeletedAt = r.now(ctx)
+			recordUndo(ctx, r.encounters, id)
+			r.encounters[id] = encounter
+		}
+	}
+
+	return nil
+}
+
+func (r *InMemoryRepo) ReassignEncounters(ctx context.Context, fromPatientId int, toPatientId int) error {
+	defer r.lock(ctx)()
+
+	for id, encounter := range r.encounters {
+		if encounter.PatientId == fromPatientId && encounter.DeletedAt.IsZero() {
+			encounter.PatientId = toPatientId
+			recordUndo(ctx, r.encounters, id)
+			r.encounters[id] = encounter
+		}
+	}
+
+	return nil
+}
+
+func (r *InMemoryRepo) PurgeDeletedEncounters(ctx context.Context, deletedBefore time.Time) (int, error) {
+	defer r.lock(ctx)()
+
+	purged := 0
+	for id, encounter := range r.encounters {
+		if !encounter.DeletedAt.IsZero() && encounter.DeletedAt.Before(deletedBefore) {
+			delete(r.encounters, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *InMemoryRepo) GetDiagnosedConditionsByEncounterId(ctx context.Context, encounterId int) ([]models.DiagnosedCondition, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	conditions := []models.DiagnosedCondition{}
+	for _, condition := range r.diagnosedConditions {
+		if condition.EncounterId == encounterId && condition.DeletedAt.IsZero() {
+			conditions = append(conditions, condition)
+		}
+	}
+
+	sort.Slice(conditions, func(i, j int) bool {
+		return conditions[i].Date.Before(conditions[j].Date)
+	})
+	return conditions, nil
+}
+
+func (r *InMemoryRepo) GetAttatchmentsByEncounterId(ctx context.Context, encounterId int) ([]models.Attatchment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	attatchments := []models.Attatchment{}
+	for _, attatchment := range r.attatchments {
+		if attatchment.EncounterId == encounterId && attatchment.DeletedAt.IsZero() {
+			attatchments = append(attatchments, attatchment)
+		}
+	}
+
+	sort.Slice(attatchments, func(i, j int) bool {
+		return attatchments[i].Id < attatchments[j].Id
+	})
+	return attatchments, nil
+}
+
+func (r *InMemoryRepo) activeEncounter(id int) (models.Encounter, bool) {
+	encounter, exists := r.encounters[id]
+	return encounter, exists && encounter.DeletedAt.IsZero()
+}
@@ -0,0 +1,118 @@
+package inmemory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mcg-app-backend/io/outbound/encryption"
+	"mcg-app-backend/service/models"
+)
+
+const (
+	sealedAddress            = "address"
+	sealedPhoneNumber        = "phoneNumber"
+	sealedExternalIdentifier = "externalIdentifier"
+	sealedIdentifiers        = "identifiers"
+	//identifier values share one blind index, so an external identifier is found whether searched by externalIdentifier
+	//or as an identifier in the external system
+	identifierIndex = "identifier"
+)
+
+// a patient as held in memory.  Their address, phone number and identifiers are only held sealed, alongside blind
+// indexes of their identifiers so patients can still be found by them
+type storedPatient struct {
+	models.Patient
+	sealed                  encryption.Envelope
+	externalIdentifierIndex string
+	identifierIndexes       []models.PatientIdentifier
+}
+
+func (r *InMemoryRepo) sealPatient(patient models.Patient) (storedPatient, error) {
+	identifiers, err := json.Marshal(patient.Identifiers)
+	if err != nil {
+		return storedPatient{}, err
+	}
+	sealed, err := r.encrypter.Seal(map[string]string{
+		sealedAddress:            patient.Address,
+		sealedPhoneNumber:        patient.PhoneNumber,
+		sealedExternalIdentifier: patient.ExternalIdentifier,
+		sealedIdentifiers:        string(identifiers),
+	})
+	if err != nil {
+		return storedPatient{}, fmt.Errorf("error sealing patient %v %w", patient.Id, err)
+	}
+
+	stored := storedPatient{Patient: patient, sealed: sealed}
+	if patient.ExternalIdentifier != "" {
+		stored.externalIdentifierIndex = r.encrypter.BlindIndex(identifierIndex, patient.ExternalIdentifier)
+	}
+	for _, identifier := range patient.Identifiers {
+		stored.identifierIndexes = append(stored.identifierIndexes, models.PatientIdentifier{
+			System: identifier.System,
+			Value:  r.encrypter.BlindIndex(identifierIndex, identifier.Value),
+		})
+	}
+	stored.Address = ""
+	stored.PhoneNumber = ""
+	stored.ExternalIdentifier = ""
+	stored.Identifiers = nil
+	return stored, nil
+}
+
+func (r *InMemoryRepo) openPatient(stored storedPatient) (models.Patient, error) {
+	fields, err := r.encrypter.Open(stored.sealed)
+	if err != nil {
+		return models.Patient{}, fmt.Errorf("error opening patient %v %w", stored.Id, err)
+	}
+	patient := stored.Patient
+	patient.Address = fields[sealedAddress]
+	patient.PhoneNumber = fields[sealedPhoneNumber]
+	patient.ExternalIdentifier = fields[sealedExternalIdentifier]
+	err = json.Unmarshal([]byte(fields[sealedIdentifiers]), &patient.Identifiers)
+	if err != nil {
+		return models.Patient{}, fmt.Errorf("error opening identifiers of patient %v %w", stored.Id, err)
+	}
+	return patient, nil
+}
+
+// whether the patient holds an identifier with the blind index.  An empty system matches identifiers in any system
+func (stored storedPatient) hasIdentifier(system string, index string) bool {
+	for _, identifier := range stored.identifierIndexes {
+		if identifier.Value == index && (system == "" || identifier.System == system) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewraps the data keys of patients, deleted or not, which an earlier key encryption key wrapped, so that key can be
+// retired.  Returns how many were rewrapped
+func (r *InMemoryRepo) RewrapDataKeys(ctx context.Context) (int, error) {
+	defer r.lock(ctx)()
+
+	rewrapped := 0
+	for id, stored := range r.patients {
+		sealed, changed, err := r.encrypter.Rewrap(stored.sealed)
+		if err != nil {
+			return rewrapped, fmt.Errorf("error rewrapping patient %v %w", id, err)
+		}
+		if changed {
+			stored.sealed = sealed
+			r.patients[id] = stored
+			rewrapped++
+		}
+	}
+	return rewrapped, nil
+}
+
+// the ids of the key encryption keys which wrap the data key of any patient, deleted or not.  Other keys can be retired
+func (r *InMemoryRepo) KeyEncryptionKeysInUse(ctx context.Context) (map[string]bool, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keyIds := make(map[string]bool)
+	for _, stored := range r.patients {
+		keyIds[stored.sealed.KeyId] = true
+	}
+	return keyIds, nil
+}
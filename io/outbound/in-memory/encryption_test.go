@@ -0,0 +1,105 @@
+package inmemory
+
+import (
+	"bytes"
+	"context"
+	"mcg-app-backend/io/outbound/encryption"
+	"mcg-app-backend/service/models"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRepo(t *testing.T) *InMemoryRepo {
+	encrypter, err := encryption.LoadEncrypter(filepath.Join(t.TempDir(), "keys.json"))
+	assert.Nil(t, err)
+	return NewInMemoryRepo(encrypter)
+}
+
+func TestPatientEncryption(t *testing.T) {
+	ctx := context.Background()
+	patient := models.Patient{
+		Name:               "John Smith",
+		ExternalIdentifier: "123-45-6789",
+		Identifiers: []models.PatientIdentifier{
+			{System: models.IdentifierSystemExternal, Value: "123-45-6789"},
+			{System: "urn:oid:2.16.840.1.113883.4.1", Value: "987-65-4321"},
+		},
+		Address:     "1 Example Street",
+		PhoneNumber: "555 555 0100",
+	}
+
+	t.Run("InsertPatient_StoresSensitiveFieldsSealed", func(t *testing.T) {
+		repo := newTestRepo(t)
+		id, err := repo.InsertPatient(ctx, patient)
+		assert.Nil(t, err)
+
+		stored := repo.patients[id]
+		assert.Empty(t, stored.Address)
+		assert.Empty(t, stored.PhoneNumber)
+		assert.Empty(t, stored.ExternalIdentifier)
+		assert.Nil(t, stored.Identifiers)
+		for _, sealed := range stored.sealed.Fields {
+			for _, plaintext := range []string{"123-45-6789", "987-65-4321", "1 Example Street", "555 555 0100"} {
+				assert.False(t, bytes.Contains(sealed, []byte(plaintext)))
+			}
+		}
+
+		got, err := repo.GetPatient(ctx, id)
+		assert.Nil(t, err)
+		assert.Equal(t, patient.Address, got.Address)
+		assert.Equal(t, patient.PhoneNumber, got.PhoneNumber)
+		assert.Equal(t, patient.ExternalIdentifier, got.ExternalIdentifier)
+		assert.Equal(t, patient.Identifiers, got.Identifiers)
+	})
+
+	t.Run("SearchPatients_MatchesSealedFields", func(t *testing.T) {
+		repo := newTestRepo(t)
+		id, _ := repo.InsertPatient(ctx, patient)
+		repo.InsertPatient(ctx, models.Patient{Name: "Jane Smith", ExternalIdentifier: "abc", Address: "2 Example Street"})
+
+		for _, search := range []models.PatientSearch{
+			{ExternalIdentifier: "123-45-6789"},
+			{IdentifierValue: "987-65-4321"},
+			{IdentifierSystem: "urn:oid:2.16.840.1.113883.4.1", IdentifierValue: "987-65-4321"},
+			{Phone: "555 555 0100"},
+			{Address: "1 Example Street"},
+		} {
+			patients, err := repo.SearchPatients(ctx, search)
+			assert.Nil(t, err)
+			assert.Len(t, patients, 1)
+			assert.Equal(t, id, patients[0].Id)
+			assert.Equal(t, patient.PhoneNumber, patients[0].PhoneNumber)
+		}
+
+		patients, _ := repo.SearchPatients(ctx, models.PatientSearch{IdentifierSystem: models.IdentifierSystemExternal, IdentifierValue: "987-65-4321"})
+		assert.Empty(t, patients)
+		ids, _ := repo.GetPatientIdsWithIdentifier(ctx, models.PatientIdentifier{System: models.IdentifierSystemExternal, Value: "123-45-6789"})
+		assert.Equal(t, []int{id}, ids)
+	})
+
+	t.Run("RewrapDataKeys_AfterRotation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		encrypter, _ := encryption.LoadEncrypter(path)
+		repo := NewInMemoryRepo(encrypter)
+		id, _ := repo.InsertPatient(ctx, patient)
+		deletedId, _ := repo.InsertPatient(ctx, models.Patient{Name: "Jane Smith", ExternalIdentifier: "abc"})
+		assert.Nil(t, repo.DeletePatient(ctx, deletedId))
+
+		keyId, err := encryption.RotateKeyfile(path)
+		assert.Nil(t, err)
+		assert.Nil(t, encrypter.Reload(path))
+		rewrapped, err := repo.RewrapDataKeys(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, rewrapped)
+		assert.Equal(t, keyId, repo.patients[id].sealed.KeyId)
+		assert.Equal(t, keyId, repo.patients[deletedId].sealed.KeyId)
+
+		got, err := repo.GetPatient(ctx, id)
+		assert.Nil(t, err)
+		assert.Equal(t, patient.Address, got.Address)
+		rewrapped, _ = repo.RewrapDataKeys(ctx)
+		assert.Equal(t, 0, rewrapped)
+	})
+}
@@ -0,0 +1,133 @@
+package inmemory
+
+import (
+	"context"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"slices"
+	"sort"
+	"time"
+)
+
+// export jobs only record progress, so like import jobs they are not part of any transaction
+
+func (r *InMemoryRepo) InsertExportJob(ctx context.Context, job models.ExportJob) (int, error) {
+	defer r.lock(ctx)()
+
+	id := r.nextExportJobId
+	r.nextExportJobId++
+
+	job.Id = id
+	r.exportJobs[id] = cloneExportJob(job)
+
+	return id, nil
+}
+
+func (r *InMemoryRepo) UpdateExportJob(ctx context.Context, job models.ExportJob) error {
+	defer r.lock(ctx)()
+
+	if _, exists := r.exportJobs[job.Id]; !exists {
+		return customerrors.NewInvalidInputError("export not found")
+	}
+
+	r.exportJobs[job.Id] = cloneExportJob(job)
+	return nil
+}
+
+func (r *InMemoryRepo) GetExportJob(ctx context.Context, id int) (models.ExportJob, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	job, exists := r.exportJobs[id]
+	if !exists {
+		return models.ExportJob{}, customerrors.NewInvalidInputError("export not found")
+	}
+	return cloneExportJob(job), nil
+}
+
+func (r *InMemoryRepo) DeleteExportJob(ctx context.Context, id int) error {
+	defer r.lock(ctx)()
+
+	if _, exists := r.exportJobs[id]; !exists {
+		return customerrors.NewInvalidInputError("export not found")
+	}
+
+	delete(r.exportJobs, id)
+	return nil
+}
+
+// exports which completed or failed before the cutoff, in order of id
+func (r *InMemoryRepo) GetExportJobsCompletedBefore(ctx context.Context, cutoff time.Time) ([]models.ExportJob, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	jobs := []models.ExportJob{}
+	for _, job := range r.exportJobs {
+		if job.CompletedAt != nil && job.CompletedAt.Before(cutoff) {
+			jobs = append(jobs, cloneExportJob(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Id < jobs[j].Id })
+	return jobs, nil
+}
+
+func cloneExportJob(job models.ExportJob) models.ExportJob {
+	job.Types = slices.Clone(job.Types)
+	job.Output = slices.Clone(job.Output)
+	return job
+}
+
+// the pages below list records in order of id, starting after afterId, so a caller can work through every record
+// without holding them all at once
+
+func (r *InMemoryRepo) GetPatientsPage(ctx context.Context, afterId int, limit int) ([]models.Patient, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	stored := page(r.patients, afterId, r.nextPatientId, limit, func(patient storedPatient) bool {
+		return patient.DeletedAt.IsZero()
+	})
+	patients := make([]models.Patient, 0, len(stored))
+	for _, patient := range stored {
+		opened, err := r.openPatient(patient)
+		if err != nil {
+			return nil, err
+		}
+		patients = append(patients, opened)
+	}
+	return patients, nil
+}
+
+func (r *InMemoryRepo) GetDiagnosedConditionsPage(ctx context.Context, afterId int, limit int) ([]models.DiagnosedCondition, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return page(r.diagnosedConditions, afterId, r.nextConditionId, limit, func(condition models.DiagnosedCondition) bool {
+		_, patientExists := r.activePatient(condition.PatientId)
+		return condition.DeletedAt.IsZero() && patientExists
+	}), nil
+}
+
+func (r *InMemoryRepo) GetAttatchmentsPage(ctx context.Context, afterId int, limit int) ([]models.Attatchment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return page(r.attatchments, afterId, r.nextAttatchmentId, limit, func(attatchment models.Attatchment) bool {
+		_, patientExists := r.activePatient(attatchment.PatientId)
+		return attatchment.DeletedAt.IsZero() && patientExists
+	}), nil
+}
+
+// ids are handed out in increasing order and never reused, so the page is found by counting up from afterId rather
+// than sorting every id, and reading every page costs no more than reading them all at once.  Must be called with the
+// repo locked
+func page[T any](records map[int]T, afterId int, nextId int, limit int, include func(T) bool) []T {
+	result := []T{}
+	for id := afterId + 1; id < nextId && len(result) < limit; id++ {
+		record, exists := records[id]
+		if exists && include(record) {
+			result = append(result, record)
+		}
+	}
+	return result
+}
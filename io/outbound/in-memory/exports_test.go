@@ -0,0 +1,74 @@
+package inmemory
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPatientsPage(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	for _, name := range []string{"one", "two", "three", "four", "five"} {
+		repo.InsertPatient(ctx, models.Patient{Name: name})
+	}
+	repo.DeletePatient(ctx, 2)
+
+	ids := func(patients []models.Patient) []int {
+		var ids []int
+		for _, patient := range patients {
+			ids = append(ids, patient.Id)
+		}
+		return ids
+	}
+
+	first, err := repo.GetPatientsPage(ctx, 0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 3}, ids(first))
+
+	second, err := repo.GetPatientsPage(ctx, 3, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{4, 5}, ids(second))
+
+	last, err := repo.GetPatientsPage(ctx, 5, 2)
+	assert.Nil(t, err)
+	assert.Empty(t, last)
+}
+
+func TestGetDiagnosedConditionsPage(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	patientId, _ := repo.InsertPatient(ctx, models.Patient{Name: "kept"})
+	deletedPatientId, _ := repo.InsertPatient(ctx, models.Patient{Name: "deleted"})
+	repo.InsertDiagnosedCondition(ctx, models.DiagnosedCondition{PatientId: patientId})
+	repo.InsertDiagnosedCondition(ctx, models.DiagnosedCondition{PatientId: deletedPatientId})
+	repo.DeletePatient(ctx, deletedPatientId)
+
+	conditions, err := repo.GetDiagnosedConditionsPage(ctx, 0, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conditions))
+	assert.Equal(t, patientId, conditions[0].PatientId)
+}
+
+func TestGetExportJobsCompletedBefore(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+	repo.InsertExportJob(ctx, models.ExportJob{Status: models.ExportStatusCompleted, CompletedAt: &earlier})
+	repo.InsertExportJob(ctx, models.ExportJob{Status: models.ExportStatusCompleted, CompletedAt: &now})
+	repo.InsertExportJob(ctx, models.ExportJob{Status: models.ExportStatusRunning})
+
+	jobs, err := repo.GetExportJobsCompletedBefore(ctx, now.Add(-time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobs))
+	assert.Equal(t, 1, jobs[0].Id)
+
+	assert.Nil(t, repo.DeleteExportJob(ctx, 1))
+	_, err = repo.GetExportJob(ctx, 1)
+	assert.NotNil(t, err)
+	assert.NotNil(t, repo.DeleteExportJob(ctx, 1))
+}
@@ -0,0 +1,47 @@
+package inmemory
+
+import (
+	"context"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"slices"
+)
+
+// import jobs only record progress, so they are not part of any transaction and are never undone
+
+func (r *InMemoryRepo) InsertImportJob(ctx context.Context, job models.ImportJob) (int, error) {
+	defer r.lock(ctx)()
+
+	id := r.nextImportJobId
+	r.nextImportJobId++
+
+	job.Id = id
+	job.Errors = slices.Clone(job.Errors)
+	r.importJobs[id] = job
+
+	return id, nil
+}
+
+func (r *InMemoryRepo) UpdateImportJob(ctx context.Context, job models.ImportJob) error {
+	defer r.lock(ctx)()
+
+	if _, exists := r.importJobs[job.Id]; !exists {
+		return customerrors.NewInvalidInputError("import not found")
+	}
+
+	job.Errors = slices.Clone(job.Errors)
+	r.importJobs[job.Id] = job
+	return nil
+}
+
+func (r *InMemoryRepo) GetImportJob(ctx context.Context, id int) (models.ImportJob, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	job, exists := r.importJobs[id]
+	if !exists {
+		return models.ImportJob{}, customerrors.NewInvalidInputError("import not found")
+	}
+	job.Errors = slices.Clone(job.Errors)
+	return job, nil
+}
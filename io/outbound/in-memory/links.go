@@ -0,0 +1,38 @@
+package inmemory
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+	"sort"
+)
+
+func (r *InMemoryRepo) InsertPatientLink(ctx context.Context, link models.PatientLink) (int, error) {
+	defer r.lock(ctx)()
+
+	id := r.nextPatientLinkId
+	r.nextPatientLinkId++
+
+	link.Id = id
+	recordUndo(ctx, r.patientLinks, id)
+	r.patientLinks[id] = link
+
+	return id, nil
+}
+
+// links are kept when either patient is deleted, as they record what happened to the merged patient's records.  They are
+// removed when either patient is purged
+func (r *InMemoryRepo) GetPatientLinks(ctx context.Context, patientId int) ([]models.PatientLink, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	links := []models.PatientLink{}
+	for _, link := range r.patientLinks {
+		if link.PatientId == patientId || link.MergedPatientId == patientId {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Id < links[j].Id
+	})
+	return links, nil
+}
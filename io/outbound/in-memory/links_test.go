@@ -0,0 +1,29 @@
+package inmemory
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeDeletedPatientLinks(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	patientId, _ := repo.InsertPatient(ctx, models.Patient{Name: "John Smith", ExternalIdentifier: "123"})
+	mergedId, _ := repo.InsertPatient(ctx, models.Patient{Name: "Jon Smith", ExternalIdentifier: "456"})
+	_, err := repo.InsertPatientLink(ctx, models.PatientLink{PatientId: patientId, MergedPatientId: mergedId, MergedAt: time.Now()})
+	assert.Nil(t, err)
+	assert.Nil(t, repo.DeletePatient(ctx, mergedId))
+
+	links, _ := repo.GetPatientLinks(ctx, patientId)
+	assert.Len(t, links, 1)
+
+	purged, err := repo.PurgeDeletedPatients(ctx, time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, purged)
+	links, _ = repo.GetPatientLinks(ctx, patientId)
+	assert.Empty(t, links)
+}
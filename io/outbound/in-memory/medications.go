@@ -0,0 +1,142 @@
+package inmemory
+
+import (
+	"context"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"sort"
+	"time"
+)
+
+func (r *InMemoryRepo) InsertMedication(ctx context.Context, medication models.Medication) (int, error) {
+	defer r.lock(ctx)()
+
+	id := r.nextMedicationId
+	r.nextMedicationId++
+
+	medication.Id = id
+	recordUndo(ctx, r.medications, id)
+	r.medications[id] = medication
+
+	return id, nil
+}
+
+func (r *InMemoryRepo) GetMedication(ctx context.Context, medicationId int) (models.Medication, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	medication, exists := r.activeMedication(medicationId)
+	if !exists {
+		return models.Medication{}, customerrors.NewInvalidInputError("medication not found")
+	}
+	return medication, nil
+}
+
+func (r *InMemoryRepo) UpdateMedication(ctx context.Context, medication models.Medication) error {
+	defer r.lock(ctx)()
+
+	if _, exists := r.activeMedication(medication.Id); !exists {
+		return customerrors.NewInvalidInputError("medication not found")
+	}
+
+	recordUndo(ctx, r.medications, medication.Id)
+	r.medications[medication.Id] = medication
+	return nil
+}
+
+func (r *InMemoryRepo) DeleteMedication(ctx context.Context, medicationId int) error {
+	defer r.lock(ctx)()
+
+	medication, exists := r.activeMedication(medicationId)
+	if !exists {
+		return customerrors.NewInvalidInputError("medication not found")
+	}
+
+	medication.DeletedAt = r.now(ctx)
+	recordUndo(ctx, r.medications, medicationId)
+	r.medications[medicationId] = medication
+	return nil
+}
+
+func (r *InMemoryRepo) RestoreMedication(ctx context.Context, patientId int, medicationId int) error {
+	defer r.lock(ctx)()
+
+	medication, exists := r.medications[medicationId]
+	if !exists || medication.PatientId != patientId {
+		return customerrors.NewInvalidInputError("medication not found")
+	}
+	if medication.DeletedAt.IsZero() {
+		return customerrors.NewInvalidInputError("medication is not deleted")
+	}
+	if _, exists := r.activePatient(medication.PatientId); !exists {
+		return customerrors.NewInvalidInputError("patient id not found")
+	}
+
+	medication.DeletedAt = time.Time{}
+	recordUndo(ctx, r.medications, medicationId)
+	r.medications[medicationId] = medication
+	return nil
+}
+
+func (r *InMemoryRepo) GetMedicationsByPatientId(ctx context.Context, patientId int) ([]models.Medication, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	medications := []models.Medication{}
+	for _, medication := range r.medications {
+		if medication.PatientId == patientId && medication.DeletedAt.IsZero() {
+			medications = append(medications, medication)
+		}
+	}
+
+	sort.Slice(medications, func(i, j int) bool {
+		return medications[i].StartDate.Before(medications[j].StartDate)
+	})
+	return medications, nil
+}
+
+func (r *InMemoryRepo) DeleteMedicationsByPatientId(ctx context.Context, patientId int) error {
+	defer r.lock(ctx)()
+
+	for id, medication := range r.medications {
+		if medication.PatientId == patientId && medication.DeletedAt.IsZero() {
+			medication.DeletedAt = r.now(ctx)
+			recordUndo(ctx, r.medications, id)
+			r.medications[id] = medication
+		}
+	}
+
+	return nil
+}
+
+func (r *InMemoryRepo) ReassignMedications(ctx context.Context, fromPatientId int, toPatientId int) error {
+	defer r.lock(ctx)()
+
+	for id, medication := range r.medications {
+		if medication.PatientId == fromPatientId && medication.DeletedAt.IsZero() {
+			medication.PatientId = toPatientId
+			recordUndo(ctx, r.medications, id)
+			r.medications[id] = medication
+		}
+	}
+
+	return nil
+}
+
+func (r *InMemoryRepo) PurgeDeletedMedications(ctx context.Context, deletedBefore time.Time) (int, error) {
+	defer r.lock(ctx)()
+
+	purged := 0
+	for id, medication := range r.medications {
+		if !medication.DeletedAt.IsZero() && medication.DeletedAt.Before(deletedBefore) {
+			delete(r.medications, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *InMemoryRepo) activeMedication(id int) (models.Medication, bool) {
+	medication, exists := r.medications[id]
+	return medication, exists && medication.DeletedAt.IsZero()
+}
@@ -0,0 +1,198 @@
+package inmemory
+
+import (
+	"context"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"slices"
+	"sort"
+	"time"
+)
+
+// observations are indexed per patient in order of when they were made, so time range queries
+// only visit the observations within the range
+func (r *InMemoryRepo) InsertObservation(ctx context.Context, observation models.Observation) (int, error) {
+	defer r.lock(ctx)()
+
+	id := r.nextObservationId
+	r.nextObservationId++
+
+	observation.Id = id
+	recordUndo(ctx, r.observations, id)
+	r.observations[id] = observation
+
+	//the timeline is copied rather than changed in place so an undo restores the original
+	timeline := slices.Clone(r.patientObservations[observation.PatientId])
+	position := sort.Search(len(timeline), func(i int) bool {
+		return r.observations[timeline[i]].EffectiveDate.After(observation.EffectiveDate)
+	})
+	recordUndo(ctx, r.patientObservations, observation.PatientId)
+	r.patientObservations[observation.PatientId] = slices.Insert(timeline, position, id)
+
+	return id, nil
+}
+
+func (r *InMemoryRepo) GetObservation(ctx context.Context, observationId int) (models.Observation, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	observation, exists := r.activeObservation(observationId)
+	if !exists {
+		return models.Observation{}, customerrors.NewInvalidInputError("observation not found")
+	}
+	return observation, nil
+}
+
+func (r *InMemoryRepo) DeleteObservation(ctx context.Context, observationId int) error {
+	defer r.lock(ctx)()
+
+	observation, exists := r.activeObservation(observationId)
+	if !exists {
+		return customerrors.NewInvalidInputError("observation not found")
+	}
+
+	observation.DeletedAt = r.now(ctx)
+	recordUndo(ctx, r.observations, observationId)
+	r.observations[observationId] = observation
+	return nil
+}
+
+func (r *InMemoryRepo) RestoreObservation(ctx context.Context, observationId int) error {
+	defer r.lock(ctx)()
+
+	observation, exists := r.observations[observationId]
+	if !exists {
+		return customerrors.NewInvalidInputError("observation not found")
+	}
+	if observation.DeletedAt.IsZero() {
+		return customerrors.NewInvalidInputError("observation is not deleted")
+	}
+	if _, exists := r.activePatient(observation.PatientId); !exists {
+		return customerrors.NewInvalidInputError("patient id not found")
+	}
+
+	observation.DeletedAt = time.Time{}
+	recordUndo(ctx, r.observations, observationId)
+	r.observations[observationId] = observation
+	return nil
+}
+
+func (r *InMemoryRepo) DeleteObservationsByPatientId(ctx context.Context, patientId int) error {
+	defer r.lock(ctx)()
+
+	for _, id := range r.patientObservations[patientId] {
+		observation := r.observations[id]
+		if observation.DeletedAt.IsZero() {
+			observation.DeletedAt = r.now(ctx)
+			recordUndo(ctx, r.observations, id)
+			r.observations[id] = observation
+		}
+	}
+
+	return nil
+}
+
+// the moved observations are merged into the other patient's timeline, keeping it in order of when they were made.
+// Deleted observations stay with the patient they were deleted from
+func (r *InMemoryRepo) ReassignObservations(ctx context.Context, fromPatientId int, toPatientId int) error {
+	defer r.lock(ctx)()
+
+	var remaining []int
+	timeline := slices.Clone(r.patientObservations[toPatientId])
+	for _, id := range r.patientObservations[fromPatientId] {
+		observation := r.observations[id]
+		if !observation.DeletedAt.IsZero() {
+			remaining = append(remaining, id)
+			continue
+		}
+		observation.PatientId = toPatientId
+		recordUndo(ctx, r.observations, id)
+		r.observations[id] = observation
+
+		position := sort.Search(len(timeline), func(i int) bool {
+			return r.observations[timeline[i]].EffectiveDate.After(observation.EffectiveDate)
+		})
+		timeline = slices.Insert(timeline, position, id)
+	}
+
+	recordUndo(ctx, r.patientObservations, fromPatientId)
+	r.patientObservations[fromPatientId] = remaining
+	recordUndo(ctx, r.patientObservations, toPatientId)
+	r.patientObservations[toPatientId] = timeline
+	return nil
+}
+
+func (r *InMemoryRepo) GetObservationsByPatientId(ctx context.Context, search models.ObservationSearch) ([]models.Observation, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	timeline := r.patientObservations[search.PatientId]
+	start, end := 0, len(timeline)
+	if !search.From.IsZero() {
+		start = sort.Search(len(timeline), func(i int) bool {
+			return !r.observations[timeline[i]].EffectiveDate.Before(search.From)
+		})
+	}
+	if !search.To.IsZero() {
+		end = sort.Search(len(timeline), func(i int) bool {
+			return r.observations[timeline[i]].EffectiveDate.After(search.To)
+		})
+	}
+
+	observations := []models.Observation{}
+	for i := start; i < end; i++ {
+		observation := r.observations[timeline[i]]
+		if !observation.DeletedAt.IsZero() || (search.Code != "" && observation.Code != search.Code) {
+			continue
+		}
+		observations = append(observations, observation)
+	}
+	return observations, nil
+}
+
+func (r *InMemoryRepo) GetLatestObservationsByPatientId(ctx context.Context, patientId int) ([]models.Observation, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	timeline := r.patientObservations[patientId]
+	seenCodes := make(map[string]bool)
+	observations := []models.Observation{}
+	for i := len(timeline) - 1; i >= 0; i-- {
+		observation := r.observations[timeline[i]]
+		if !observation.DeletedAt.IsZero() || seenCodes[observation.Code] {
+			continue
+		}
+		seenCodes[observation.Code] = true
+		observations = append(observations, observation)
+	}
+
+	sort.Slice(observations, func(i, j int) bool {
+		return observations[i].Code < observations[j].Code
+	})
+	return observations, nil
+}
+
+func (r *InMemoryRepo) PurgeDeletedObservations(ctx context.Context, deletedBefore time.Time) (int, error) {
+	defer r.lock(ctx)()
+
+	purged := 0
+	for patientId, timeline := range r.patientObservations {
+		remaining := make([]int, 0, len(timeline))
+		for _, id := range timeline {
+			observation := r.observations[id]
+			if !observation.DeletedAt.IsZero() && observation.DeletedAt.Before(deletedBefore) {
+				delete(r.observations, id)
+				purged++
+				continue
+			}
+			remaining = append(remaining, id)
+		}
+		r.patientObservations[patientId] = remaining
+	}
+	return purged, nil
+}
+
+func (r *InMemoryRepo) activeObservation(id int) (models.Observation, bool) {
+	observation, exists := r.observations[id]
+	return observation, exists && observation.DeletedAt.IsZero()
+}
@@ -0,0 +1,118 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func insertObservation(ctx context.Context, repo *InMemoryRepo, patientId int, code string, value float64, effectiveDate time.Time) int {
+	id, _ := repo.InsertObservation(ctx, models.Observation{
+		PatientId: patientId,
+		ObservationRequest: models.ObservationRequest{
+			Code:          code,
+			Value:         &value,
+			EffectiveDate: effectiveDate,
+		},
+	})
+	return id
+}
+
+func TestGetObservationsByPatientId(t *testing.T) {
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	repo := newTestRepo(t)
+	//inserted out of order to check the timeline stays ordered by effective date
+	third := insertObservation(ctx, repo, 1, "8867-4", 70, day(3))
+	first := insertObservation(ctx, repo, 1, "8867-4", 80, day(1))
+	weight := insertObservation(ctx, repo, 1, "29463-7", 82, day(2))
+	fourth := insertObservation(ctx, repo, 1, "8867-4", 65, day(4))
+	insertObservation(ctx, repo, 2, "8867-4", 90, day(2))
+
+	ids := func(observations []models.Observation) []int {
+		result := []int{}
+		for _, observation := range observations {
+			result = append(result, observation.Id)
+		}
+		return result
+	}
+
+	t.Run("GetObservationsByPatientId_All", func(t *testing.T) {
+		observations, err := repo.GetObservationsByPatientId(ctx, models.ObservationSearch{PatientId: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{first, weight, third, fourth}, ids(observations))
+	})
+
+	t.Run("GetObservationsByPatientId_TimeRangeIsInclusive", func(t *testing.T) {
+		observations, err := repo.GetObservationsByPatientId(ctx, models.ObservationSearch{PatientId: 1, From: day(2), To: day(3)})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{weight, third}, ids(observations))
+	})
+
+	t.Run("GetObservationsByPatientId_Code", func(t *testing.T) {
+		observations, err := repo.GetObservationsByPatientId(ctx, models.ObservationSearch{PatientId: 1, Code: "8867-4", From: day(2)})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{third, fourth}, ids(observations))
+	})
+
+	t.Run("GetLatestObservationsByPatientId", func(t *testing.T) {
+		assert.Nil(t, repo.DeleteObservation(ctx, fourth))
+
+		observations, err := repo.GetLatestObservationsByPatientId(ctx, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, []int{weight, third}, ids(observations))
+	})
+
+	t.Run("InsertObservation_RolledBack", func(t *testing.T) {
+		err := repo.RunInTransaction(ctx, func(ctx context.Context) error {
+			insertObservation(ctx, repo, 1, "8867-4", 100, day(5))
+			return fmt.Errorf("failed")
+		})
+		assert.NotNil(t, err)
+
+		observations, _ := repo.GetObservationsByPatientId(ctx, models.ObservationSearch{PatientId: 1})
+		assert.Equal(t, []int{first, weight, third}, ids(observations))
+	})
+
+	t.Run("PurgeDeletedObservations", func(t *testing.T) {
+		purged, err := repo.PurgeDeletedObservations(ctx, time.Now().Add(time.Minute))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, purged)
+		assert.Equal(t, []int{first, weight, third}, repo.patientObservations[1])
+	})
+}
+
+func TestReassignObservations(t *testing.T) {
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	repo := newTestRepo(t)
+	first := insertObservation(ctx, repo, 1, "8867-4", 80, day(1))
+	third := insertObservation(ctx, repo, 1, "8867-4", 70, day(3))
+	second := insertObservation(ctx, repo, 2, "8867-4", 75, day(2))
+	deleted := insertObservation(ctx, repo, 2, "8867-4", 60, day(4))
+	assert.Nil(t, repo.DeleteObservation(ctx, deleted))
+
+	t.Run("ReassignObservations_RolledBack", func(t *testing.T) {
+		err := repo.RunInTransaction(ctx, func(ctx context.Context) error {
+			assert.Nil(t, repo.ReassignObservations(ctx, 2, 1))
+			return fmt.Errorf("failed")
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, []int{first, third}, repo.patientObservations[1])
+		assert.Equal(t, []int{second, deleted}, repo.patientObservations[2])
+		assert.Equal(t, 2, repo.observations[second].PatientId)
+	})
+
+	t.Run("ReassignObservations_KeepsTimelineOrdered", func(t *testing.T) {
+		assert.Nil(t, repo.ReassignObservations(ctx, 2, 1))
+		assert.Equal(t, []int{first, second, third}, repo.patientObservations[1])
+		assert.Equal(t, []int{deleted}, repo.patientObservations[2])
+		assert.Equal(t, 1, repo.observations[second].PatientId)
+	})
+}
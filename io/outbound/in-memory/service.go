@@ -2,68 +2,129 @@ package inmemory
 
 import (
 	"context"
+	"fmt"
+	"mcg-app-backend/io/outbound/encryption"
 	"mcg-app-backend/service/customerrors"
 	"mcg-app-backend/service/models"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 type InMemoryRepo struct {
 	mutex               sync.RWMutex
-	patients            map[int]models.Patient
+	transactionMutex    sync.Mutex
+	encrypter           *encryption.Encrypter
+	patients            map[int]storedPatient
 	attatchments        map[int]models.Attatchment
+	attatchmentVersions map[int][]models.AttatchmentVersion
+	thumbnails          map[int][]models.AttatchmentThumbnail
 	diagnosedConditions map[int]models.DiagnosedCondition
+	medications         map[int]models.Medication
+	allergies           map[int]models.Allergy
+	observations        map[int]models.Observation
+	//observation ids of each patient, ordered by when they were made
+	patientObservations map[int][]int
+	encounters          map[int]models.Encounter
+	slots               map[int]models.Slot
+	appointments        map[int]models.Appointment
 	users               map[string]models.User
+	importJobs          map[int]models.ImportJob
+	exportJobs          map[int]models.ExportJob
+	patientLinks        map[int]models.PatientLink
 	nextPatientId       int
 	nextAttatchmentId   int
 	nextConditionId     int
+	nextMedicationId    int
+	nextAllergyId       int
+	nextObservationId   int
+	nextEncounterId     int
+	nextSlotId          int
+	nextAppointmentId   int
+	nextImportJobId     int
+	nextExportJobId     int
+	nextPatientLinkId   int
 }
 
-func NewInMemoryRepo() *InMemoryRepo {
+func NewInMemoryRepo(encrypter *encryption.Encrypter) *InMemoryRepo {
 	return &InMemoryRepo{
-		patients:            make(map[int]models.Patient),
+		encrypter:           encrypter,
+		patients:            make(map[int]storedPatient),
 		attatchments:        make(map[int]models.Attatchment),
+		attatchmentVersions: make(map[int][]models.AttatchmentVersion),
+		thumbnails:          make(map[int][]models.AttatchmentThumbnail),
 		diagnosedConditions: make(map[int]models.DiagnosedCondition),
+		medications:         make(map[int]models.Medication),
+		allergies:           make(map[int]models.Allergy),
+		observations:        make(map[int]models.Observation),
+		patientObservations: make(map[int][]int),
+		encounters:          make(map[int]models.Encounter),
+		slots:               make(map[int]models.Slot),
+		appointments:        make(map[int]models.Appointment),
 		users:               make(map[string]models.User),
+		importJobs:          make(map[int]models.ImportJob),
+		exportJobs:          make(map[int]models.ExportJob),
+		patientLinks:        make(map[int]models.PatientLink),
 		nextPatientId:       1,
 		nextAttatchmentId:   1,
 		nextConditionId:     1,
+		nextMedicationId:    1,
+		nextAllergyId:       1,
+		nextObservationId:   1,
+		nextEncounterId:     1,
+		nextSlotId:          1,
+		nextAppointmentId:   1,
+		nextImportJobId:     1,
+		nextExportJobId:     1,
+		nextPatientLinkId:   1,
 	}
 }
 
 func (r *InMemoryRepo) InsertPatient(ctx context.Context, patient models.Patient) (int, error) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer r.lock(ctx)()
 
 	id := r.nextPatientId
 	r.nextPatientId++
 
 	patient.Id = id
-	r.patients[id] = patient
+	stored, err := r.sealPatient(patient)
+	if err != nil {
+		return 0, err
+	}
+	recordUndo(ctx, r.patients, id)
+	r.patients[id] = stored
 
 	return id, nil
 }
 
 func (r *InMemoryRepo) UpdatePatient(ctx context.Context, patient models.Patient) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer r.lock(ctx)()
 
-	if _, exists := r.patients[patient.Id]; !exists {
+	if _, exists := r.activePatient(patient.Id); !exists {
 		return customerrors.NewInvalidInputError("patient not found")
 	}
 
-	r.patients[patient.Id] = patient
+	stored, err := r.sealPatient(patient)
+	if err != nil {
+		return err
+	}
+	recordUndo(ctx, r.patients, patient.Id)
+	r.patients[patient.Id] = stored
 	return nil
 }
 
 func (r *InMemoryRepo) DeletePatient(ctx context.Context, id int) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer r.lock(ctx)()
 
-	if _, exists := r.patients[id]; !exists {
+	patient, exists := r.activePatient(id)
+	if !exists {
 		return customerrors.NewInvalidInputError("patient not found")
 	}
 
-	delete(r.patients, id)
+	patient.DeletedAt = r.now(ctx)
+	recordUndo(ctx, r.patients, id)
+	r.patients[id] = patient
 	return nil
 }
 
@@ -71,58 +132,233 @@ func (r *InMemoryRepo) GetCountOfPatientId(ctx context.Context, id int) (int, er
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	if _, exists := r.patients[id]; exists {
+	if _, exists := r.activePatient(id); exists {
 		return 1, nil
 	}
 	return 0, nil
 }
 
-func (r *InMemoryRepo) GetCountOfExternalIdentifier(ctx context.Context, externalIdentifier string) (int, error) {
+func (r *InMemoryRepo) GetPatientIdsWithIdentifier(ctx context.Context, identifier models.PatientIdentifier) ([]int, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	count := 0
+	index := r.encrypter.BlindIndex(identifierIndex, identifier.Value)
+	ids := []int{}
 	for _, patient := range r.patients {
-		if patient.ExternalIdentifier == externalIdentifier {
-			count++
+		if patient.DeletedAt.IsZero() && patient.hasIdentifier(identifier.System, index) {
+			ids = append(ids, patient.Id)
 		}
 	}
-	return count, nil
+	sort.Ints(ids)
+	return ids, nil
 }
 
 func (r *InMemoryRepo) InsertAttatchment(ctx context.Context, attatchment models.Attatchment) (int, error) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer r.lock(ctx)()
 
 	id := r.nextAttatchmentId
 	r.nextAttatchmentId++
 
 	attatchment.Id = id
+	recordUndo(ctx, r.attatchments, id)
+	recordUndo(ctx, r.attatchmentVersions, id)
 	r.attatchments[id] = attatchment
+	r.attatchmentVersions[id] = []models.AttatchmentVersion{{
+		AttatchmentId: id,
+		Version:       1,
+		Data:          attatchment.Data,
+		Dicom:         attatchment.Dicom,
+		CreatedAt:     r.now(ctx),
+	}}
 
 	return id, nil
 }
 
+func (r *InMemoryRepo) GetAttatchment(ctx context.Context, attatchmentId int) (models.Attatchment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	attatchment, exists := r.activeAttatchment(attatchmentId)
+	if !exists {
+		return models.Attatchment{}, customerrors.NewInvalidInputError("attatchment not found")
+	}
+	return attatchment, nil
+}
+
+func (r *InMemoryRepo) InsertAttatchmentVersion(ctx context.Context, version models.AttatchmentVersion) (int, error) {
+	defer r.lock(ctx)()
+
+	attatchment, exists := r.activeAttatchment(version.AttatchmentId)
+	if !exists {
+		return 0, customerrors.NewInvalidInputError("attatchment not found")
+	}
+
+	versions := r.attatchmentVersions[version.AttatchmentId]
+	version.Version = len(versions) + 1
+	recordUndo(ctx, r.attatchmentVersions, version.AttatchmentId)
+	recordUndo(ctx, r.attatchments, attatchment.Id)
+	r.attatchmentVersions[version.AttatchmentId] = append(versions, version)
+
+	attatchment.Data = version.Data
+	attatchment.Version = version.Version
+	attatchment.Dicom = version.Dicom
+	r.attatchments[attatchment.Id] = attatchment
+
+	return version.Version, nil
+}
+
+func (r *InMemoryRepo) GetAttatchmentVersions(ctx context.Context, attatchmentId int) ([]models.AttatchmentVersion, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if _, exists := r.activeAttatchment(attatchmentId); !exists {
+		return nil, customerrors.NewInvalidInputError("attatchment not found")
+	}
+
+	versions := make([]models.AttatchmentVersion, len(r.attatchmentVersions[attatchmentId]))
+	copy(versions, r.attatchmentVersions[attatchmentId])
+	return versions, nil
+}
+
+func (r *InMemoryRepo) GetAttatchmentVersion(ctx context.Context, attatchmentId int, version int) (models.AttatchmentVersion, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if _, exists := r.activeAttatchment(attatchmentId); !exists {
+		return models.AttatchmentVersion{}, customerrors.NewInvalidInputError("attatchment not found")
+	}
+
+	versions := r.attatchmentVersions[attatchmentId]
+	if version < 1 || version > len(versions) {
+		return models.AttatchmentVersion{}, customerrors.NewInvalidInputError("attatchment version not found")
+	}
+	return versions[version-1], nil
+}
+
+func (r *InMemoryRepo) SaveAttatchmentThumbnails(ctx context.Context, attatchmentId int, thumbnails []models.AttatchmentThumbnail) error {
+	defer r.lock(ctx)()
+
+	if _, exists := r.activeAttatchment(attatchmentId); !exists {
+		return customerrors.NewInvalidInputError("attatchment not found")
+	}
+
+	saved := make([]models.AttatchmentThumbnail, len(thumbnails))
+	for i, thumbnail := range thumbnails {
+		thumbnail.AttatchmentId = attatchmentId
+		saved[i] = thumbnail
+	}
+	recordUndo(ctx, r.thumbnails, attatchmentId)
+	r.thumbnails[attatchmentId] = saved
+	return nil
+}
+
+func (r *InMemoryRepo) GetAttatchmentThumbnail(ctx context.Context, attatchmentId int, size int) (models.AttatchmentThumbnail, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if _, exists := r.activeAttatchment(attatchmentId); !exists {
+		return models.AttatchmentThumbnail{}, customerrors.NewInvalidInputError("attatchment not found")
+	}
+
+	for _, thumbnail := range r.thumbnails[attatchmentId] {
+		if thumbnail.Size == size {
+			return thumbnail, nil
+		}
+	}
+	return models.AttatchmentThumbnail{}, customerrors.NewInvalidInputError("attatchment has no thumbnail")
+}
+
 func (r *InMemoryRepo) InsertDiagnosedCondition(ctx context.Context, condition models.DiagnosedCondition) (int, error) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer r.lock(ctx)()
 
 	id := r.nextConditionId
 	r.nextConditionId++
 
 	condition.Id = id
+	recordUndo(ctx, r.diagnosedConditions, id)
 	r.diagnosedConditions[id] = condition
 
 	return id, nil
 }
 
+func (r *InMemoryRepo) GetDiagnosedCondition(ctx context.Context, conditionId int) (models.DiagnosedCondition, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	condition, exists := r.activeCondition(conditionId)
+	if !exists {
+		return models.DiagnosedCondition{}, customerrors.NewInvalidInputError("diagnosed condition not found")
+	}
+	return condition, nil
+}
+
+func (r *InMemoryRepo) UpdateDiagnosedCondition(ctx context.Context, condition models.DiagnosedCondition) error {
+	defer r.lock(ctx)()
+
+	if _, exists := r.activeCondition(condition.Id); !exists {
+		return customerrors.NewInvalidInputError("diagnosed condition not found")
+	}
+
+	recordUndo(ctx, r.diagnosedConditions, condition.Id)
+	r.diagnosedConditions[condition.Id] = condition
+	return nil
+}
+
+func (r *InMemoryRepo) GetDiagnosedConditionsByPatientId(ctx context.Context, search models.DiagnosedConditionSearch) ([]models.DiagnosedCondition, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	searchCodings := make(map[models.Coding]bool)
+	for _, coding := range search.Codings {
+		searchCodings[coding] = true
+	}
+
+	conditions := []models.DiagnosedCondition{}
+	for _, condition := range r.diagnosedConditions {
+		if condition.PatientId != search.PatientId || !condition.DeletedAt.IsZero() {
+			continue
+		}
+		if (search.Code != "" && !searchCodings[models.Coding{System: condition.CodeSystem, Code: condition.Code}]) ||
+			(search.Active && !condition.IsActive()) ||
+			(!search.From.IsZero() && condition.Date.Before(search.From)) ||
+			(!search.To.IsZero() && condition.Date.After(search.To)) {
+			continue
+		}
+		conditions = append(conditions, condition)
+	}
+
+	sort.Slice(conditions, func(i, j int) bool {
+		return conditions[i].Date.Before(conditions[j].Date)
+	})
+	return conditions, nil
+}
+
 func (r *InMemoryRepo) DeleteAttatchmentsByPatientId(ctx context.Context, patientId int) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer r.lock(ctx)()
+
+	for id, attatchment := range r.attatchments {
+		if attatchment.PatientId == patientId && attatchment.DeletedAt.IsZero() {
+			attatchment.DeletedAt = r.now(ctx)
+			recordUndo(ctx, r.attatchments, id)
+			r.attatchments[id] = attatchment
+		}
+	}
+
+	return nil
+}
+
+// attatchments stay linked to their encounter only if it was moved to the same patient first
+func (r *InMemoryRepo) ReassignAttatchments(ctx context.Context, fromPatientId int, toPatientId int) error {
+	defer r.lock(ctx)()
 
 	for id, attatchment := range r.attatchments {
-		if attatchment.PatientId == patientId {
-			delete(r.attatchments, id)
+		if attatchment.PatientId == fromPatientId && attatchment.DeletedAt.IsZero() {
+			attatchment.PatientId = toPatientId
+			if r.encounters[attatchment.EncounterId].PatientId != toPatientId {
+				attatchment.EncounterId = 0
+			}
+			recordUndo(ctx, r.attatchments, id)
+			r.attatchments[id] = attatchment
 		}
 	}
 
@@ -130,12 +366,32 @@ func (r *InMemoryRepo) DeleteAttatchmentsByPatientId(ctx context.Context, patien
 }
 
 func (r *InMemoryRepo) DeleteDiagnosedConditionsByPatientId(ctx context.Context, patientId int) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer r.lock(ctx)()
 
 	for id, condition := range r.diagnosedConditions {
-		if condition.PatientId == patientId {
-			delete(r.diagnosedConditions, id)
+		if condition.PatientId == patientId && condition.DeletedAt.IsZero() {
+			condition.DeletedAt = r.now(ctx)
+			recordUndo(ctx, r.diagnosedConditions, id)
+			r.diagnosedConditions[id] = condition
+		}
+	}
+
+	return nil
+}
+
+// conditions stay linked to their encounter only if it was moved to the same patient first.  Otherwise it still belongs
+// to the patient the conditions are moved from, so the conditions are no longer linked to it
+func (r *InMemoryRepo) ReassignDiagnosedConditions(ctx context.Context, fromPatientId int, toPatientId int) error {
+	defer r.lock(ctx)()
+
+	for id, condition := range r.diagnosedConditions {
+		if condition.PatientId == fromPatientId && condition.DeletedAt.IsZero() {
+			condition.PatientId = toPatientId
+			if r.encounters[condition.EncounterId].PatientId != toPatientId {
+				condition.EncounterId = 0
+			}
+			recordUndo(ctx, r.diagnosedConditions, id)
+			r.diagnosedConditions[id] = condition
 		}
 	}
 
@@ -143,70 +399,411 @@ func (r *InMemoryRepo) DeleteDiagnosedConditionsByPatientId(ctx context.Context,
 }
 
 func (r *InMemoryRepo) DeleteDiagnosedCondition(ctx context.Context, conditionId int) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer r.lock(ctx)()
 
-	if _, exists := r.diagnosedConditions[conditionId]; !exists {
+	condition, exists := r.activeCondition(conditionId)
+	if !exists {
 		return customerrors.NewInvalidInputError("diagnosed condition not found")
 	}
 
-	delete(r.diagnosedConditions, conditionId)
+	condition.DeletedAt = r.now(ctx)
+	recordUndo(ctx, r.diagnosedConditions, conditionId)
+	r.diagnosedConditions[conditionId] = condition
 	return nil
 }
 
 func (r *InMemoryRepo) DeleteAttatchment(ctx context.Context, attatchmentId int) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer r.lock(ctx)()
+
+	attatchment, exists := r.activeAttatchment(attatchmentId)
+	if !exists {
+		return customerrors.NewInvalidInputError("attatchment not found")
+	}
+
+	attatchment.DeletedAt = r.now(ctx)
+	recordUndo(ctx, r.attatchments, attatchmentId)
+	r.attatchments[attatchmentId] = attatchment
+	return nil
+}
+
+func (r *InMemoryRepo) RestorePatient(ctx context.Context, id int) error {
+	defer r.lock(ctx)()
+
+	patient, exists := r.patients[id]
+	if !exists {
+		return customerrors.NewInvalidInputError("patient not found")
+	}
+	if patient.DeletedAt.IsZero() {
+		return customerrors.NewInvalidInputError("patient is not deleted")
+	}
+	opened, err := r.openPatient(patient)
+	if err != nil {
+		return err
+	}
+	//the patient's identifiers may have been given to someone else while they were deleted
+	for _, other := range r.patients {
+		if !other.DeletedAt.IsZero() {
+			continue
+		}
+		for i, identifier := range opened.Identifiers {
+			if !other.hasIdentifier(identifier.System, patient.identifierIndexes[i].Value) {
+				continue
+			}
+			if identifier.System == models.IdentifierSystemExternal {
+				return customerrors.NewAlreadyExistsError("patient with matching externalIdentifier already exists")
+			}
+			return customerrors.NewAlreadyExistsError(fmt.Sprintf("patient with matching identifier %v|%v already exists", identifier.System, identifier.Value))
+		}
+	}
+
+	//records deleted along with the patient are restored with it
+	for attatchmentId, attatchment := range r.attatchments {
+		if attatchment.PatientId == id && attatchment.DeletedAt.Equal(patient.DeletedAt) {
+			attatchment.DeletedAt = time.Time{}
+			recordUndo(ctx, r.attatchments, attatchmentId)
+			r.attatchments[attatchmentId] = attatchment
+		}
+	}
+	for conditionId, condition := range r.diagnosedConditions {
+		if condition.PatientId == id && condition.DeletedAt.Equal(patient.DeletedAt) {
+			condition.DeletedAt = time.Time{}
+			recordUndo(ctx, r.diagnosedConditions, conditionId)
+			r.diagnosedConditions[conditionId] = condition
+		}
+	}
+	for medicationId, medication := range r.medications {
+		if medication.PatientId == id && medication.DeletedAt.Equal(patient.DeletedAt) {
+			medication.DeletedAt = time.Time{}
+			recordUndo(ctx, r.medications, medicationId)
+			r.medications[medicationId] = medication
+		}
+	}
+	for allergyId, allergy := range r.allergies {
+		if allergy.PatientId == id && allergy.DeletedAt.Equal(patient.DeletedAt) {
+			allergy.DeletedAt = time.Time{}
+			recordUndo(ctx, r.allergies, allergyId)
+			r.allergies[allergyId] = allergy
+		}
+	}
+	for _, observationId := range r.patientObservations[id] {
+		observation := r.observations[observationId]
+		if observation.DeletedAt.Equal(patient.DeletedAt) {
+			observation.DeletedAt = time.Time{}
+			recordUndo(ctx, r.observations, observationId)
+			r.observations[observationId] = observation
+		}
+	}
+	for encounterId, encounter := range r.encounters {
+		if encounter.PatientId == id && encounter.DeletedAt.Equal(patient.DeletedAt) {
+			encounter.DeletedAt = time.Time{}
+			recordUndo(ctx, r.encounters, encounterId)
+			r.encounters[encounterId] = encounter
+		}
+	}
 
-	if _, exists := r.attatchments[attatchmentId]; !exists {
+	patient.DeletedAt = time.Time{}
+	recordUndo(ctx, r.patients, id)
+	r.patients[id] = patient
+	return nil
+}
+
+func (r *InMemoryRepo) RestoreAttatchment(ctx context.Context, attatchmentId int) error {
+	defer r.lock(ctx)()
+
+	attatchment, exists := r.attatchments[attatchmentId]
+	if !exists {
 		return customerrors.NewInvalidInputError("attatchment not found")
 	}
+	if attatchment.DeletedAt.IsZero() {
+		return customerrors.NewInvalidInputError("attatchment is not deleted")
+	}
+	if _, exists := r.activePatient(attatchment.PatientId); !exists {
+		return customerrors.NewInvalidInputError("patient id not found")
+	}
 
-	delete(r.attatchments, attatchmentId)
+	attatchment.DeletedAt = time.Time{}
+	recordUndo(ctx, r.attatchments, attatchmentId)
+	r.attatchments[attatchmentId] = attatchment
 	return nil
 }
 
+func (r *InMemoryRepo) RestoreDiagnosedCondition(ctx context.Context, conditionId int) error {
+	defer r.lock(ctx)()
+
+	condition, exists := r.diagnosedConditions[conditionId]
+	if !exists {
+		return customerrors.NewInvalidInputError("diagnosed condition not found")
+	}
+	if condition.DeletedAt.IsZero() {
+		return customerrors.NewInvalidInputError("diagnosed condition is not deleted")
+	}
+	if _, exists := r.activePatient(condition.PatientId); !exists {
+		return customerrors.NewInvalidInputError("patient id not found")
+	}
+
+	condition.DeletedAt = time.Time{}
+	recordUndo(ctx, r.diagnosedConditions, conditionId)
+	r.diagnosedConditions[conditionId] = condition
+	return nil
+}
+
+// permanently removes patients deleted before the cutoff, along with all of their records and the links of their merges
+func (r *InMemoryRepo) PurgeDeletedPatients(ctx context.Context, deletedBefore time.Time) (int, error) {
+	defer r.lock(ctx)()
+
+	purged := 0
+	for id, patient := range r.patients {
+		if patient.DeletedAt.IsZero() || !patient.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		for attatchmentId, attatchment := range r.attatchments {
+			if attatchment.PatientId == id {
+				r.removeAttatchment(attatchmentId)
+			}
+		}
+		for conditionId, condition := range r.diagnosedConditions {
+			if condition.PatientId == id {
+				delete(r.diagnosedConditions, conditionId)
+			}
+		}
+		for medicationId, medication := range r.medications {
+			if medication.PatientId == id {
+				delete(r.medications, medicationId)
+			}
+		}
+		for allergyId, allergy := range r.allergies {
+			if allergy.PatientId == id {
+				delete(r.allergies, allergyId)
+			}
+		}
+		for _, observationId := range r.patientObservations[id] {
+			delete(r.observations, observationId)
+		}
+		delete(r.patientObservations, id)
+		for encounterId, encounter := range r.encounters {
+			if encounter.PatientId == id {
+				delete(r.encounters, encounterId)
+			}
+		}
+		for appointmentId, appointment := range r.appointments {
+			if appointment.PatientId == id {
+				r.releaseSlot(appointment)
+				delete(r.appointments, appointmentId)
+			}
+		}
+		//links only record where a merged patient's records went, so they are no use once either patient is purged
+		for linkId, link := range r.patientLinks {
+			if link.PatientId == id || link.MergedPatientId == id {
+				delete(r.patientLinks, linkId)
+			}
+		}
+		delete(r.patients, id)
+		purged++
+	}
+	return purged, nil
+}
+
+func (r *InMemoryRepo) PurgeDeletedAttatchments(ctx context.Context, deletedBefore time.Time) (int, error) {
+	defer r.lock(ctx)()
+
+	purged := 0
+	for id, attatchment := range r.attatchments {
+		if !attatchment.DeletedAt.IsZero() && attatchment.DeletedAt.Before(deletedBefore) {
+			r.removeAttatchment(id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *InMemoryRepo) PurgeDeletedDiagnosedConditions(ctx context.Context, deletedBefore time.Time) (int, error) {
+	defer r.lock(ctx)()
+
+	purged := 0
+	for id, condition := range r.diagnosedConditions {
+		if !condition.DeletedAt.IsZero() && condition.DeletedAt.Before(deletedBefore) {
+			delete(r.diagnosedConditions, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *InMemoryRepo) removeAttatchment(id int) {
+	delete(r.attatchments, id)
+	delete(r.attatchmentVersions, id)
+	delete(r.thumbnails, id)
+}
+
+func (r *InMemoryRepo) activePatient(id int) (storedPatient, bool) {
+	patient, exists := r.patients[id]
+	return patient, exists && patient.DeletedAt.IsZero()
+}
+
+func (r *InMemoryRepo) activeAttatchment(id int) (models.Attatchment, bool) {
+	attatchment, exists := r.attatchments[id]
+	return attatchment, exists && attatchment.DeletedAt.IsZero()
+}
+
+func (r *InMemoryRepo) activeCondition(id int) (models.DiagnosedCondition, bool) {
+	condition, exists := r.diagnosedConditions[id]
+	return condition, exists && condition.DeletedAt.IsZero()
+}
+
 func (r *InMemoryRepo) SearchPatients(ctx context.Context, search models.PatientSearch) ([]models.Patient, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
 	var patients []models.Patient
 
 	matchedPatientIds := make(map[int]bool)
 
 	conditionsByPatientId := make(map[int][]models.DiagnosedCondition)
 	attatchmentsByPatientId := make(map[int][]models.Attatchment)
+	allergiesByPatientId := make(map[int][]models.Allergy)
+
+	searchCodings := make(map[models.Coding]bool)
+	for _, coding := range search.DiagnosedConditionCodings {
+		searchCodings[coding] = true
+	}
 
 	for _, condition := range r.diagnosedConditions {
+		if !condition.DeletedAt.IsZero() {
+			continue
+		}
 		conditionsByPatientId[condition.PatientId] = append(conditionsByPatientId[condition.PatientId], condition)
-		if (search.DiagnosedConditionCode != "" && condition.Code == search.DiagnosedConditionCode) ||
-			(search.DiagnosedConditionName != "" && condition.Name == search.DiagnosedConditionName) {
+		if search.DiagnosedConditionActive && !condition.IsActive() {
+			continue
+		}
+		if (search.DiagnosedConditionName != "" && condition.Name == search.DiagnosedConditionName) ||
+			searchCodings[models.Coding{System: condition.CodeSystem, Code: condition.Code}] {
 			matchedPatientIds[condition.PatientId] = true
 		}
 	}
 
 	for _, attatchment := range r.attatchments {
+		if !attatchment.DeletedAt.IsZero() {
+			continue
+		}
 		attatchmentsByPatientId[attatchment.PatientId] = append(attatchmentsByPatientId[attatchment.PatientId], attatchment)
 		if (search.AttatchmentName != "" && attatchment.Name == search.AttatchmentName) ||
-			(search.AttatchmentType != "" && attatchment.Type == search.AttatchmentType) {
+			(search.AttatchmentType != "" && attatchment.Type == search.AttatchmentType) ||
+			matchesDicomSearch(attatchment.Dicom, search) {
 			matchedPatientIds[attatchment.PatientId] = true
 		}
 	}
 
-	for _, patient := range r.patients {
-		if (search.Name != "" && patient.Name == search.Name) ||
-			(search.Phone != "" && patient.PhoneNumber == search.Phone) ||
-			(search.Address != "" && patient.Address == search.Address) ||
-			(search.ExternalIdentifier != "" && patient.ExternalIdentifier == search.ExternalIdentifier) {
-			matchedPatientIds[patient.Id] = true
+	for _, allergy := range r.allergies {
+		if !allergy.DeletedAt.IsZero() {
+			continue
 		}
+		allergiesByPatientId[allergy.PatientId] = append(allergiesByPatientId[allergy.PatientId], allergy)
+		if search.Allergen != "" && strings.EqualFold(allergy.Allergen, search.Allergen) {
+			matchedPatientIds[allergy.PatientId] = true
+		}
+	}
+
+	var externalIdentifierIndex, identifierValueIndex string
+	if search.ExternalIdentifier != "" {
+		externalIdentifierIndex = r.encrypter.BlindIndex(identifierIndex, search.ExternalIdentifier)
+	}
+	if search.IdentifierValue != "" {
+		identifierValueIndex = r.encrypter.BlindIndex(identifierIndex, search.IdentifierValue)
+	}
 
-		if matchedPatientIds[patient.Id] {
-			patient.Attatchments = attatchmentsByPatientId[patient.Id]
-			patient.DiagnosedConditions = conditionsByPatientId[patient.Id]
-			patients = append(patients, patient)
+	for _, stored := range r.patients {
+		if !stored.DeletedAt.IsZero() {
+			continue
+		}
+		matched := matchedPatientIds[stored.Id] ||
+			(search.Name != "" && stored.Name == search.Name) ||
+			(search.NamePart != "" && matchesNamePart(stored.Name, search.NamePart)) ||
+			(search.BirthDate != "" && stored.DateOfBirth.Format(time.DateOnly) == search.BirthDate) ||
+			(externalIdentifierIndex != "" && stored.externalIdentifierIndex == externalIdentifierIndex) ||
+			(identifierValueIndex != "" && stored.hasIdentifier(search.IdentifierSystem, identifierValueIndex))
+		//phone numbers and addresses have no blind index, so the patient is opened to compare them
+		if !matched && search.Phone == "" && search.Address == "" {
+			continue
 		}
+		patient, err := r.openPatient(stored)
+		if err != nil {
+			return nil, err
+		}
+		if !matched &&
+			!(search.Phone != "" && patient.PhoneNumber == search.Phone) &&
+			!(search.Address != "" && patient.Address == search.Address) {
+			continue
+		}
+
+		patient.Attatchments = attatchmentsByPatientId[patient.Id]
+		patient.DiagnosedConditions = conditionsByPatientId[patient.Id]
+		patient.Allergies = allergiesByPatientId[patient.Id]
+		patients = append(patients, patient)
 	}
 	return patients, nil
 }
 
+func (r *InMemoryRepo) GetPatient(ctx context.Context, patientId int) (models.Patient, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	stored, exists := r.activePatient(patientId)
+	if !exists {
+		return models.Patient{}, customerrors.NewInvalidInputError("patient id not found")
+	}
+	patient, err := r.openPatient(stored)
+	if err != nil {
+		return models.Patient{}, err
+	}
+
+	for _, condition := range r.diagnosedConditions {
+		if condition.PatientId == patientId && condition.DeletedAt.IsZero() {
+			patient.DiagnosedConditions = append(patient.DiagnosedConditions, condition)
+		}
+	}
+	for _, attatchment := range r.attatchments {
+		if attatchment.PatientId == patientId && attatchment.DeletedAt.IsZero() {
+			patient.Attatchments = append(patient.Attatchments, attatchment)
+		}
+	}
+	for _, allergy := range r.allergies {
+		if allergy.PatientId == patientId && allergy.DeletedAt.IsZero() {
+			patient.Allergies = append(patient.Allergies, allergy)
+		}
+	}
+	sort.Slice(patient.DiagnosedConditions, func(i, j int) bool {
+		return patient.DiagnosedConditions[i].Id < patient.DiagnosedConditions[j].Id
+	})
+	sort.Slice(patient.Attatchments, func(i, j int) bool {
+		return patient.Attatchments[i].Id < patient.Attatchments[j].Id
+	})
+	sort.Slice(patient.Allergies, func(i, j int) bool {
+		return patient.Allergies[i].Id < patient.Allergies[j].Id
+	})
+	return patient, nil
+}
+
+// whether any word of the name starts with the part, ignoring case
+func matchesNamePart(name string, part string) bool {
+	for _, word := range strings.Fields(name) {
+		if strings.HasPrefix(strings.ToLower(word), strings.ToLower(part)) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesDicomSearch(dicom *models.DicomMetadata, search models.PatientSearch) bool {
+	if dicom == nil {
+		return false
+	}
+	return (search.StudyDate != "" && !dicom.StudyDate.IsZero() && dicom.StudyDate.Format(time.DateOnly) == search.StudyDate) ||
+		(search.Modality != "" && dicom.Modality == search.Modality) ||
+		(search.BodyPart != "" && dicom.BodyPart == search.BodyPart) ||
+		(search.StudyInstanceUid != "" && dicom.StudyInstanceUid == search.StudyInstanceUid) ||
+		(search.SeriesInstanceUid != "" && dicom.SeriesInstanceUid == search.SeriesInstanceUid)
+}
+
 func (r *InMemoryRepo) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
 	return r.users[username], nil
 }
@@ -0,0 +1,75 @@
+package inmemory
+
+import (
+	"context"
+	"time"
+)
+
+type transactionKey struct{}
+
+type transaction struct {
+	now  time.Time
+	undo []func()
+}
+
+// runs fn as a single unit of work.  Every change made through ctx is undone if fn returns an error.
+// Calls made while already inside a transaction join the outer one.  Changes made outside of the transaction wait
+// until it has finished
+func (r *InMemoryRepo) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(transactionKey{}).(*transaction); ok {
+		return fn(ctx)
+	}
+
+	r.transactionMutex.Lock()
+	defer r.transactionMutex.Unlock()
+
+	tx := &transaction{now: time.Now()}
+	err := fn(context.WithValue(ctx, transactionKey{}, tx))
+	if err != nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		for i := len(tx.undo) - 1; i >= 0; i-- {
+			tx.undo[i]()
+		}
+	}
+	return err
+}
+
+// locks the repo for a change, returning the function which unlocks it.  Changes made outside of a transaction first
+// wait for any running transaction to finish, so undoing a failed transaction cannot overwrite them
+func (r *InMemoryRepo) lock(ctx context.Context) func() {
+	if _, ok := ctx.Value(transactionKey{}).(*transaction); ok {
+		r.mutex.Lock()
+		return r.mutex.Unlock
+	}
+	r.transactionMutex.Lock()
+	r.mutex.Lock()
+	return func() {
+		r.mutex.Unlock()
+		r.transactionMutex.Unlock()
+	}
+}
+
+// all changes within a transaction share the same timestamp, so records deleted together can be restored together
+func (r *InMemoryRepo) now(ctx context.Context) time.Time {
+	if tx, ok := ctx.Value(transactionKey{}).(*transaction); ok {
+		return tx.now
+	}
+	return time.Now()
+}
+
+// must be called with the repo locked, before m[key] is changed
+func recordUndo[K comparable, V any](ctx context.Context, m map[K]V, key K) {
+	tx, ok := ctx.Value(transactionKey{}).(*transaction)
+	if !ok {
+		return
+	}
+	previous, existed := m[key]
+	tx.undo = append(tx.undo, func() {
+		if existed {
+			m[key] = previous
+		} else {
+			delete(m, key)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunInTransaction(t *testing.T) {
+	t.Run("RunInTransaction_RollsBackOnError", func(t *testing.T) {
+		repo := newTestRepo(t)
+		ctx := context.Background()
+		patientId, _ := repo.InsertPatient(ctx, models.Patient{Name: "John Smith", ExternalIdentifier: "123"})
+		attatchmentId, _ := repo.InsertAttatchment(ctx, models.Attatchment{PatientId: patientId, Data: []byte("data")})
+		conditionId, _ := repo.InsertDiagnosedCondition(ctx, models.DiagnosedCondition{PatientId: patientId, Name: "condition"})
+
+		err := repo.RunInTransaction(ctx, func(ctx context.Context) error {
+			assert.Nil(t, repo.DeleteAttatchmentsByPatientId(ctx, patientId))
+			assert.Nil(t, repo.DeleteDiagnosedConditionsByPatientId(ctx, patientId))
+			_, err := repo.InsertPatient(ctx, models.Patient{Name: "Jane Smith", ExternalIdentifier: "abc", Identifiers: []models.PatientIdentifier{{System: models.IdentifierSystemExternal, Value: "abc"}}})
+			assert.Nil(t, err)
+			return fmt.Errorf("failed")
+		})
+		assert.NotNil(t, err)
+
+		_, err = repo.GetAttatchment(ctx, attatchmentId)
+		assert.Nil(t, err)
+		_, exists := repo.activeCondition(conditionId)
+		assert.True(t, exists)
+		ids, _ := repo.GetPatientIdsWithIdentifier(ctx, models.PatientIdentifier{System: models.IdentifierSystemExternal, Value: "abc"})
+		assert.Empty(t, ids)
+	})
+
+	t.Run("RunInTransaction_CommitsOnSuccess", func(t *testing.T) {
+		repo := newTestRepo(t)
+		ctx := context.Background()
+		patientId, _ := repo.InsertPatient(ctx, models.Patient{Name: "John Smith", ExternalIdentifier: "123"})
+		attatchmentId, _ := repo.InsertAttatchment(ctx, models.Attatchment{PatientId: patientId, Data: []byte("data")})
+
+		err := repo.RunInTransaction(ctx, func(ctx context.Context) error {
+			assert.Nil(t, repo.DeleteAttatchmentsByPatientId(ctx, patientId))
+			return repo.DeletePatient(ctx, patientId)
+		})
+		assert.Nil(t, err)
+
+		_, err = repo.GetAttatchment(ctx, attatchmentId)
+		assert.NotNil(t, err)
+		assert.Equal(t, repo.patients[patientId].DeletedAt, repo.attatchments[attatchmentId].DeletedAt)
+	})
+	t.Run("RunInTransaction_RollbackKeepsOutsideChanges", func(t *testing.T) {
+		repo := newTestRepo(t)
+		ctx := context.Background()
+		patientId, _ := repo.InsertPatient(ctx, models.Patient{Name: "John Smith", ExternalIdentifier: "123"})
+
+		inTransaction := make(chan struct{})
+		rollBack := make(chan struct{})
+		transactionDone := make(chan error)
+		go func() {
+			transactionDone <- repo.RunInTransaction(ctx, func(ctx context.Context) error {
+				err := repo.UpdatePatient(ctx, models.Patient{Id: patientId, Name: "Jon Smith", ExternalIdentifier: "123"})
+				assert.Nil(t, err)
+				close(inTransaction)
+				<-rollBack
+				return fmt.Errorf("failed")
+			})
+		}()
+		<-inTransaction
+
+		updateDone := make(chan error)
+		go func() {
+			updateDone <- repo.UpdatePatient(ctx, models.Patient{Id: patientId, Name: "Johnny Smith", ExternalIdentifier: "123"})
+		}()
+		close(rollBack)
+		assert.NotNil(t, <-transactionDone)
+		assert.Nil(t, <-updateDone)
+
+		patient, err := repo.GetPatient(ctx, patientId)
+		assert.Nil(t, err)
+		assert.Equal(t, "Johnny Smith", patient.Name)
+	})
+}
@@ -1,14 +1,37 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	inboundhttp "mcg-app-backend/io/inbound/http"
+	inboundmllp "mcg-app-backend/io/inbound/mllp"
+	"mcg-app-backend/io/outbound/encryption"
+	"mcg-app-backend/io/outbound/filesystem"
 	inmemory "mcg-app-backend/io/outbound/in-memory"
+	"mcg-app-backend/service/allergies"
+	"mcg-app-backend/service/appointments"
 	"mcg-app-backend/service/attatchments"
 	"mcg-app-backend/service/auth"
+	"mcg-app-backend/service/ccda"
+	"mcg-app-backend/service/codes"
 	diagnosedconditions "mcg-app-backend/service/diagnosedConditions"
+	"mcg-app-backend/service/duplicates"
+	"mcg-app-backend/service/encounters"
+	"mcg-app-backend/service/exports"
+	"mcg-app-backend/service/fhir"
+	"mcg-app-backend/service/hl7"
+	"mcg-app-backend/service/imports"
+	"mcg-app-backend/service/medications"
+	"mcg-app-backend/service/observations"
 	"mcg-app-backend/service/patients"
+	"mcg-app-backend/service/retention"
+	"mcg-app-backend/service/summaries"
 	"mcg-app-backend/service/tracing"
 	"mcg-app-backend/service/users"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 
 	"go.uber.org/zap"
@@ -16,16 +39,184 @@ import (
 
 func main() {
 	logger, _ := zap.NewProduction()
-	repo := inmemory.NewInMemoryRepo()
+	//patients' addresses, phone numbers and identifiers are encrypted with keys from this keyfile, which is created on
+	//first start.  It should live outside the temp directory, and be backed up
+	keyfilePath := os.Getenv("MCG_KEYFILE_PATH")
+	if keyfilePath == "" {
+		keyfilePath = filepath.Join(os.TempDir(), "mcg-app-keys.json")
+		logger.Warn("MCG_KEYFILE_PATH is not set, keeping the keyfile in the temp directory", zap.String("path", keyfilePath))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rotate-key" {
+		keyId, err := encryption.RotateKeyfile(keyfilePath)
+		if err != nil {
+			logger.Fatal("error rotating key encryption key", zap.Error(err))
+		}
+		logger.Info("added key encryption key, send SIGHUP to the running server to rewrap data keys with it", zap.String("keyId", keyId))
+		return
+	}
+	encrypter, err := encryption.LoadEncrypter(keyfilePath)
+	if err != nil {
+		logger.Fatal("error loading keyfile", zap.Error(err))
+	}
+	repo := inmemory.NewInMemoryRepo(encrypter)
+	//on SIGHUP the keyfile is reloaded, and data keys wrapped by earlier keys are rewrapped with the newest one.  Once
+	//they all are, the earlier keys are retired from the keyfile
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			err := encrypter.Reload(keyfilePath)
+			if err != nil {
+				logger.Error("error reloading keyfile", zap.Error(err))
+				continue
+			}
+			rewrapped, err := repo.RewrapDataKeys(context.Background())
+			if err != nil {
+				logger.Error("error rewrapping data keys, no keys were retired", zap.Int("rewrapped", rewrapped), zap.Error(err))
+				continue
+			}
+			logger.Info("rewrapped data keys", zap.String("keyId", encrypter.ActiveKeyId()), zap.Int("rewrapped", rewrapped))
+			inUse, err := repo.KeyEncryptionKeysInUse(context.Background())
+			if err != nil {
+				logger.Error("error finding key encryption keys in use", zap.Error(err))
+				continue
+			}
+			retired, err := encryption.RetireKeyfileKeys(keyfilePath, inUse)
+			if err != nil {
+				logger.Error("error retiring key encryption keys", zap.Error(err))
+				continue
+			}
+			err = encrypter.Reload(keyfilePath)
+			if err != nil {
+				logger.Error("error reloading keyfile", zap.Error(err))
+				continue
+			}
+			logger.Info("retired key encryption keys", zap.Strings("keyIds", retired))
+		}
+	}()
 	tracer := tracing.NewService(logger)
-	patientSrv := patients.NewPatientService(repo, tracer)
-	attatchmentSrv := attatchments.NewAttachmentService(repo, patientSrv, tracer)
-	diagnosedConditionSrv := diagnosedconditions.NewDiagnosedConditionService(repo, patientSrv, tracer)
+	//the full ICD-10-CM code table, such as the codes file of the CMS release, is loaded from this path.  Without it a
+	//bundled sample table is used, and other codes are only checked to be well formed
+	icd10TablePath := os.Getenv("MCG_ICD10CM_PATH")
+	icd10Srv, err := codes.NewICD10Service(icd10TablePath, tracer)
+	if err != nil {
+		logger.Fatal("error loading ICD-10-CM codes", zap.Error(err))
+	}
+	//the full SNOMED CT to ICD-10-CM map is loaded from this path.  Without it a bundled sample map is used
+	snomedMapPath := os.Getenv("MCG_SNOMED_MAP_PATH")
+	snomedMapping, err := codes.NewSNOMEDMapping(snomedMapPath)
+	if err != nil {
+		logger.Fatal("error loading SNOMED CT map", zap.Error(err))
+	}
+	codeSrv := codes.NewTerminology(icd10Srv, snomedMapping)
+	patientSrv := patients.NewPatientService(repo, codeSrv, tracer)
+	encounterSrv := encounters.NewEncounterService(repo, patientSrv, tracer)
+	attatchmentSrv := attatchments.NewAttachmentService(repo, patientSrv, encounterSrv, tracer)
+	diagnosedConditionSrv := diagnosedconditions.NewDiagnosedConditionService(repo, patientSrv, encounterSrv, codeSrv, tracer)
+	medicationSrv := medications.NewMedicationService(repo, patientSrv, tracer)
+	allergySrv := allergies.NewAllergyService(repo, patientSrv, tracer)
+	observationSrv := observations.NewObservationService(repo, patientSrv, tracer)
+	appointmentSrv := appointments.NewAppointmentService(repo, patientSrv, tracer)
+	patientSrv = patientSrv.WithDependentServices(
+		attatchmentSrv.DeletePatientAttachments,
+		diagnosedConditionSrv.DeletePatientDiagnosedConditions,
+		medicationSrv.DeletePatientMedications,
+		allergySrv.DeletePatientAllergies,
+		observationSrv.DeletePatientObservations,
+		encounterSrv.DeletePatientEncounters,
+		appointmentSrv.CancelPatientAppointments)
+	importSrv := imports.NewImportService(repo, patientSrv, diagnosedConditionSrv, codeSrv, tracer)
+	fhirSrv := fhir.NewService(repo, patientSrv, diagnosedConditionSrv, attatchmentSrv, tracer)
+	//C-CDA documents name this organization as their custodian and identify our records beneath its OID, which must be
+	//registered to the organization.  Until it is configured, no documents are produced
+	ccdaFacility := ccda.Facility{
+		Oid:         os.Getenv("MCG_CUSTODIAN_OID"),
+		Name:        os.Getenv("MCG_CUSTODIAN_NAME"),
+		PhoneNumber: os.Getenv("MCG_CUSTODIAN_PHONE"),
+		Address:     os.Getenv("MCG_CUSTODIAN_ADDRESS"),
+	}
+	if ccdaFacility == (ccda.Facility{}) {
+		logger.Warn("MCG_CUSTODIAN_OID, MCG_CUSTODIAN_NAME, MCG_CUSTODIAN_PHONE and MCG_CUSTODIAN_ADDRESS are not set, C-CDA documents will not be produced")
+	} else {
+		err = ccdaFacility.Validate()
+		if err != nil {
+			logger.Fatal("error reading C-CDA custodian", zap.Error(err))
+		}
+	}
+	ccdaSrv := ccda.NewCcdaService(patientSrv, ccdaFacility, tracer)
+	//printed at the top of every page of patient summaries, naming the custodian organization unless set otherwise.  A
+	//png or jpeg logo is shown beside it when its path is set
+	summaryTitle := os.Getenv("MCG_SUMMARY_TITLE")
+	if summaryTitle == "" {
+		summaryTitle = ccdaFacility.Name
+	}
+	summarySubtitle := os.Getenv("MCG_SUMMARY_SUBTITLE")
+	if summarySubtitle == "" {
+		summarySubtitle = ccdaFacility.Address
+	}
+	summaryLogoPath := os.Getenv("MCG_SUMMARY_LOGO_PATH")
+	summaryHeader, err := summaries.NewHeader(summaryTitle, summarySubtitle, summaryLogoPath)
+	if err != nil {
+		logger.Fatal("error loading patient summary header", zap.Error(err))
+	}
+	summarySrv := summaries.NewSummaryService(patientSrv, summaryHeader, tracer)
+	duplicateSrv := duplicates.NewDuplicateService(repo, patientSrv, diagnosedConditionSrv, attatchmentSrv, tracer)
+	hl7Srv := hl7.NewService(repo, patientSrv, diagnosedConditionSrv, duplicateSrv, tracer)
 	userService := users.NewService(repo, tracer)
 	//in a real application, these would be fed by environment variables
 	expirationTime := time.Minute * 10
 	issuer := "localhost"
 	tokenSecret := "asdf"
 	authService := auth.NewService(userService, tracer, expirationTime, issuer, tokenSecret)
-	inboundhttp.NewServer(authService, userService, patientSrv, attatchmentSrv, diagnosedConditionSrv, logger).Start()
+	//deleted records are kept for the retention period before being permanently purged, checking every purge interval
+	retentionPeriod, err := durationFromEnv("MCG_RETENTION_PERIOD", time.Hour*24*365*7)
+	if err != nil {
+		logger.Fatal("error reading retention period", zap.Error(err))
+	}
+	purgeInterval, err := durationFromEnv("MCG_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		logger.Fatal("error reading purge interval", zap.Error(err))
+	}
+	retention.NewService(repo, tracer, retentionPeriod).Start(context.Background(), purgeInterval, func(err error) {
+		logger.Error("error purging deleted records", zap.Error(err))
+	})
+	//FHIR bulk exports hold every patient unencrypted, so are written beneath a directory only readable by the server, and
+	//deleted once they have been kept for the export expiry, checking every purge interval
+	exportDirectory := os.Getenv("MCG_EXPORT_DIRECTORY")
+	if exportDirectory == "" {
+		exportDirectory = filepath.Join(os.TempDir(), "mcg-app-exports")
+		logger.Warn("MCG_EXPORT_DIRECTORY is not set, writing exports to the temp directory", zap.String("path", exportDirectory))
+	}
+	exportExpiry, err := durationFromEnv("MCG_EXPORT_EXPIRY", time.Hour*24)
+	if err != nil {
+		logger.Fatal("error reading export expiry", zap.Error(err))
+	}
+	exportSrv := exports.NewExportService(repo, filesystem.NewFileStore(exportDirectory), tracer, exportExpiry)
+	exportSrv.Start(context.Background(), purgeInterval, func(err error) {
+		logger.Error("error expiring exports", zap.Error(err))
+	})
+	//HL7 v2 messages from the registration system are received over MLLP, alongside the http api.  They are not
+	//authenticated, so only localhost can send them unless another address is configured
+	mllpAddress := os.Getenv("MCG_MLLP_ADDRESS")
+	if mllpAddress == "" {
+		mllpAddress = "localhost:2575"
+	}
+	go inboundmllp.NewServer(hl7Srv, logger).Start(mllpAddress)
+	inboundhttp.NewServer(authService, userService, patientSrv, attatchmentSrv, diagnosedConditionSrv, medicationSrv, allergySrv, observationSrv, encounterSrv, appointmentSrv, importSrv, fhirSrv, exportSrv, ccdaSrv, summarySrv, duplicateSrv, codeSrv, logger).Start()
+}
+
+// reads a duration such as 720h from the environment variable, or returns the fallback when it is not set
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%v is not a duration %w", name, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("%v must be positive, but was %v", name, value)
+	}
+	return duration, nil
 }
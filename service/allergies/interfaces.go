@@ -0,0 +1,29 @@
+package allergies
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type AllergyRepo interface {
+	InsertAllergy(ctx context.Context, allergy models.Allergy) (int, error)
+	GetAllergy(ctx context.Context, allergyId int) (models.Allergy, error)
+	UpdateAllergy(ctx context.Context, allergy models.Allergy) error
+	DeleteAllergy(ctx context.Context, allergyId int) error
+	RestoreAllergy(ctx context.Context, patientId int, allergyId int) error
+	GetAllergiesByPatientId(ctx context.Context, patientId int) ([]models.Allergy, error)
+	DeleteAllergiesByPatientId(ctx context.Context, patientId int) error
+}
+
+type PatientService interface {
+	ValidatePatientId(ctx context.Context, patientId int) error
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
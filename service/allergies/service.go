@@ -0,0 +1,225 @@
+package allergies
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var categories = map[string]bool{
+	models.AllergyCategoryFood:        true,
+	models.AllergyCategoryMedication:  true,
+	models.AllergyCategoryEnvironment: true,
+	models.AllergyCategoryBiologic:    true,
+}
+
+var criticalities = map[string]bool{
+	models.AllergyCriticalityLow:            true,
+	models.AllergyCriticalityHigh:           true,
+	models.AllergyCriticalityUnableToAssess: true,
+}
+
+var severities = map[string]bool{
+	models.AllergySeverityMild:     true,
+	models.AllergySeverityModerate: true,
+	models.AllergySeveritySevere:   true,
+}
+
+type AllergyService struct {
+	repo       AllergyRepo
+	patientSvc PatientService
+	tracer     Tracer
+}
+
+func NewAllergyService(repo AllergyRepo, patientSvc PatientService, tracer Tracer) AllergyService {
+	return AllergyService{
+		repo:       repo,
+		patientSvc: patientSvc,
+		tracer:     tracer,
+	}
+}
+
+func (s AllergyService) AddAllergyToPatient(ctx context.Context, patientId int, details models.AllergyRequest) (models.Allergy, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "AddAllergyToPatient")
+	defer span.End()
+	s.setDetailAttributes(ctx, details)
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	details, err := s.validateDetails(ctx, details)
+	if err != nil {
+		return models.Allergy{}, err
+	}
+
+	err = s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return models.Allergy{}, err
+	}
+
+	allergy := models.Allergy{
+		PatientId:      patientId,
+		AllergyRequest: details,
+	}
+
+	id, err := s.repo.InsertAllergy(ctx, allergy)
+	if err != nil {
+		return models.Allergy{}, s.tracer.RecordError(ctx, fmt.Errorf("error inserting allergy %w", err))
+	}
+
+	allergy.Id = id
+	return allergy, nil
+}
+
+func (s AllergyService) GetAllergy(ctx context.Context, patientId int, allergyId int) (models.Allergy, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetAllergy")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("allergyId", allergyId))
+
+	return s.getPatientAllergy(ctx, patientId, allergyId)
+}
+
+func (s AllergyService) GetPatientAllergies(ctx context.Context, patientId int) ([]models.Allergy, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetPatientAllergies")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return nil, err
+	}
+
+	allergies, err := s.repo.GetAllergiesByPatientId(ctx, patientId)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting allergies for patient %w", err))
+	}
+
+	return allergies, nil
+}
+
+func (s AllergyService) UpdateAllergy(ctx context.Context, patientId int, allergyId int, details models.AllergyRequest) (models.Allergy, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "UpdateAllergy")
+	defer span.End()
+	s.setDetailAttributes(ctx, details)
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("allergyId", allergyId))
+
+	details, err := s.validateDetails(ctx, details)
+	if err != nil {
+		return models.Allergy{}, err
+	}
+
+	allergy, err := s.getPatientAllergy(ctx, patientId, allergyId)
+	if err != nil {
+		return models.Allergy{}, err
+	}
+
+	allergy.AllergyRequest = details
+	err = s.repo.UpdateAllergy(ctx, allergy)
+	if err != nil {
+		return models.Allergy{}, s.tracer.RecordError(ctx, fmt.Errorf("error updating allergy %w", err))
+	}
+
+	return allergy, nil
+}
+
+func (s AllergyService) DeleteAllergy(ctx context.Context, patientId int, allergyId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "DeleteAllergy")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("allergyId", allergyId))
+
+	_, err := s.getPatientAllergy(ctx, patientId, allergyId)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteAllergy(ctx, allergyId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error deleting allergy %w", err))
+	}
+
+	return nil
+}
+
+func (s AllergyService) RestoreAllergy(ctx context.Context, patientId int, allergyId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "RestoreAllergy")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("allergyId", allergyId))
+
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.RestoreAllergy(ctx, patientId, allergyId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error restoring allergy %w", err))
+	}
+
+	return nil
+}
+
+func (s AllergyService) DeletePatientAllergies(ctx context.Context, patientId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "DeletePatientAllergies")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.repo.DeleteAllergiesByPatientId(ctx, patientId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error deleting allergies for patient %w", err))
+	}
+
+	return nil
+}
+
+// allergies are addressed through their patient, so one belonging to another patient is treated as missing
+func (s AllergyService) getPatientAllergy(ctx context.Context, patientId int, allergyId int) (models.Allergy, error) {
+	allergy, err := s.repo.GetAllergy(ctx, allergyId)
+	if err != nil {
+		return models.Allergy{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting allergy %w", err))
+	}
+	if allergy.PatientId != patientId {
+		return models.Allergy{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("allergy not found"))
+	}
+	return allergy, nil
+}
+
+// coded fields are compared ignoring case and stored in lower case
+func (s AllergyService) validateDetails(ctx context.Context, details models.AllergyRequest) (models.AllergyRequest, error) {
+	details.Allergen = strings.TrimSpace(details.Allergen)
+	details.Category = strings.ToLower(details.Category)
+	details.Criticality = strings.ToLower(details.Criticality)
+	details.Severity = strings.ToLower(details.Severity)
+
+	if details.Allergen == "" {
+		return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("allergen is required"))
+	}
+	if !categories[details.Category] {
+		return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a valid allergy category, expected food, medication, environment or biologic", details.Category)))
+	}
+	if details.Criticality != "" && !criticalities[details.Criticality] {
+		return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%v is not a valid criticality, expected low, high or unable-to-assess", details.Criticality)))
+	}
+	if details.Severity != "" && !severities[details.Severity] {
+		return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%v is not a valid severity, expected mild, moderate or severe", details.Severity)))
+	}
+	return details, nil
+}
+
+func (s AllergyService) setDetailAttributes(ctx context.Context, details models.AllergyRequest) {
+	s.tracer.SetAttributes(ctx,
+		attribute.String("allergen", details.Allergen),
+		attribute.String("category", details.Category),
+		attribute.String("criticality", details.Criticality),
+		attribute.String("reaction", details.Reaction),
+		attribute.String("severity", details.Severity))
+}
@@ -0,0 +1,373 @@
+package allergies
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockAllergyRepo struct {
+	mock.Mock
+}
+
+func (m *MockAllergyRepo) InsertAllergy(ctx context.Context, allergy models.Allergy) (int, error) {
+	args := m.Called(ctx, allergy)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockAllergyRepo) GetAllergy(ctx context.Context, allergyId int) (models.Allergy, error) {
+	args := m.Called(ctx, allergyId)
+	return args.Get(0).(models.Allergy), args.Error(1)
+}
+
+func (m *MockAllergyRepo) UpdateAllergy(ctx context.Context, allergy models.Allergy) error {
+	args := m.Called(ctx, allergy)
+	return args.Error(0)
+}
+
+func (m *MockAllergyRepo) DeleteAllergy(ctx context.Context, allergyId int) error {
+	args := m.Called(ctx, allergyId)
+	return args.Error(0)
+}
+
+func (m *MockAllergyRepo) RestoreAllergy(ctx context.Context, patientId int, allergyId int) error {
+	args := m.Called(ctx, patientId, allergyId)
+	return args.Error(0)
+}
+
+func (m *MockAllergyRepo) GetAllergiesByPatientId(ctx context.Context, patientId int) ([]models.Allergy, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).([]models.Allergy), args.Error(1)
+}
+
+func (m *MockAllergyRepo) DeleteAllergiesByPatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) ValidatePatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService() (*MockAllergyRepo, *MockPatientService, AllergyService) {
+	mockRepo := new(MockAllergyRepo)
+	mockPatientSvc := new(MockPatientService)
+	service := NewAllergyService(mockRepo, mockPatientSvc, new(MockTracer))
+	return mockRepo, mockPatientSvc, service
+}
+
+func getDetails() models.AllergyRequest {
+	return models.AllergyRequest{
+		Allergen:    "Penicillin",
+		Category:    models.AllergyCategoryMedication,
+		Criticality: models.AllergyCriticalityHigh,
+		Reaction:    "hives",
+		Severity:    models.AllergySeverityModerate,
+	}
+}
+
+func TestAddAllergyToPatient(t *testing.T) {
+	patientId := 1
+
+	t.Run("AddAllergy_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := getDetails()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertAllergy", mock.Anything, models.Allergy{PatientId: patientId, AllergyRequest: details}).Return(4, nil)
+
+		allergy, err := service.AddAllergyToPatient(context.Background(), patientId, details)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, allergy.Id)
+		assert.Equal(t, patientId, allergy.PatientId)
+		assert.Equal(t, details, allergy.AllergyRequest)
+
+		mockRepo.AssertExpectations(t)
+		mockPatientSvc.AssertExpectations(t)
+	})
+
+	t.Run("AddAllergy_InvalidPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.AddAllergyToPatient(context.Background(), patientId, getDetails())
+		assert.NotNil(t, err)
+		assert.Equal(t, "patient id not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertAllergy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddAllergy_NormalizesCodedFields", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := getDetails()
+		details.Allergen = " Penicillin "
+		details.Category = "Medication"
+		details.Severity = "MODERATE"
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertAllergy", mock.Anything, models.Allergy{PatientId: patientId, AllergyRequest: getDetails()}).Return(1, nil)
+
+		allergy, err := service.AddAllergyToPatient(context.Background(), patientId, details)
+		assert.Nil(t, err)
+		assert.Equal(t, getDetails(), allergy.AllergyRequest)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("AddAllergy_MissingAllergen", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := getDetails()
+		details.Allergen = "  "
+
+		_, err := service.AddAllergyToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "allergen is required", err.Error())
+
+		mockPatientSvc.AssertNotCalled(t, "ValidatePatientId", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "InsertAllergy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddAllergy_InvalidCategory", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.Category = "drug"
+
+		_, err := service.AddAllergyToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, `"drug" is not a valid allergy category, expected food, medication, environment or biologic`, err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertAllergy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddAllergy_InvalidCriticality", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.Criticality = "extreme"
+
+		_, err := service.AddAllergyToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "extreme is not a valid criticality, expected low, high or unable-to-assess", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertAllergy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddAllergy_InvalidSeverity", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.Severity = "fatal"
+
+		_, err := service.AddAllergyToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "fatal is not a valid severity, expected mild, moderate or severe", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertAllergy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddAllergy_OptionalFieldsOmitted", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := models.AllergyRequest{Allergen: "Peanut", Category: models.AllergyCategoryFood}
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertAllergy", mock.Anything, models.Allergy{PatientId: patientId, AllergyRequest: details}).Return(2, nil)
+
+		_, err := service.AddAllergyToPatient(context.Background(), patientId, details)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("AddAllergy_RepoError", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertAllergy", mock.Anything, mock.Anything).Return(0, fmt.Errorf("db error"))
+
+		_, err := service.AddAllergyToPatient(context.Background(), patientId, getDetails())
+		assert.NotNil(t, err)
+		assert.Equal(t, "error inserting allergy db error", err.Error())
+	})
+}
+
+func TestGetAllergy(t *testing.T) {
+	allergy := models.Allergy{Id: 3, PatientId: 1, AllergyRequest: getDetails()}
+
+	t.Run("GetAllergy_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetAllergy", mock.Anything, 3).Return(allergy, nil)
+
+		result, err := service.GetAllergy(context.Background(), 1, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, allergy, result)
+	})
+
+	t.Run("GetAllergy_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetAllergy", mock.Anything, 3).Return(allergy, nil)
+
+		_, err := service.GetAllergy(context.Background(), 2, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "allergy not found", err.Error())
+	})
+
+	t.Run("GetAllergy_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetAllergy", mock.Anything, 3).Return(models.Allergy{}, fmt.Errorf("db error"))
+
+		_, err := service.GetAllergy(context.Background(), 1, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting allergy db error", err.Error())
+	})
+}
+
+func TestGetPatientAllergies(t *testing.T) {
+	t.Run("GetPatientAllergies_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		allergies := []models.Allergy{{Id: 1, PatientId: 1, AllergyRequest: getDetails()}}
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("GetAllergiesByPatientId", mock.Anything, 1).Return(allergies, nil)
+
+		result, err := service.GetPatientAllergies(context.Background(), 1)
+		assert.Nil(t, err)
+		assert.Equal(t, allergies, result)
+	})
+
+	t.Run("GetPatientAllergies_InvalidPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.GetPatientAllergies(context.Background(), 1)
+		assert.NotNil(t, err)
+
+		mockRepo.AssertNotCalled(t, "GetAllergiesByPatientId", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUpdateAllergy(t *testing.T) {
+	existing := models.Allergy{Id: 3, PatientId: 1, AllergyRequest: getDetails()}
+
+	t.Run("UpdateAllergy_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.Severity = models.AllergySeveritySevere
+		mockRepo.On("GetAllergy", mock.Anything, 3).Return(existing, nil)
+		mockRepo.On("UpdateAllergy", mock.Anything, models.Allergy{Id: 3, PatientId: 1, AllergyRequest: details}).Return(nil)
+
+		allergy, err := service.UpdateAllergy(context.Background(), 1, 3, details)
+		assert.Nil(t, err)
+		assert.Equal(t, models.AllergySeveritySevere, allergy.Severity)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("UpdateAllergy_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetAllergy", mock.Anything, 3).Return(existing, nil)
+
+		_, err := service.UpdateAllergy(context.Background(), 2, 3, getDetails())
+		assert.NotNil(t, err)
+		assert.Equal(t, "allergy not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "UpdateAllergy", mock.Anything, mock.Anything)
+	})
+
+	t.Run("UpdateAllergy_InvalidDetails", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.Allergen = ""
+
+		_, err := service.UpdateAllergy(context.Background(), 1, 3, details)
+		assert.NotNil(t, err)
+
+		mockRepo.AssertNotCalled(t, "GetAllergy", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeleteAllergy(t *testing.T) {
+	existing := models.Allergy{Id: 3, PatientId: 1, AllergyRequest: getDetails()}
+
+	t.Run("DeleteAllergy_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetAllergy", mock.Anything, 3).Return(existing, nil)
+		mockRepo.On("DeleteAllergy", mock.Anything, 3).Return(nil)
+
+		err := service.DeleteAllergy(context.Background(), 1, 3)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("DeleteAllergy_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetAllergy", mock.Anything, 3).Return(existing, nil)
+
+		err := service.DeleteAllergy(context.Background(), 2, 3)
+		assert.NotNil(t, err)
+
+		mockRepo.AssertNotCalled(t, "DeleteAllergy", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRestoreAllergy(t *testing.T) {
+	t.Run("RestoreAllergy_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("RestoreAllergy", mock.Anything, 1, 3).Return(nil)
+
+		err := service.RestoreAllergy(context.Background(), 1, 3)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("RestoreAllergy_RepoError", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("RestoreAllergy", mock.Anything, 1, 3).Return(fmt.Errorf("db error"))
+
+		err := service.RestoreAllergy(context.Background(), 1, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error restoring allergy db error", err.Error())
+	})
+}
+
+func TestDeletePatientAllergies(t *testing.T) {
+	t.Run("DeletePatientAllergies_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("DeleteAllergiesByPatientId", mock.Anything, 1).Return(nil)
+
+		err := service.DeletePatientAllergies(context.Background(), 1)
+		assert.Nil(t, err)
+	})
+
+	t.Run("DeletePatientAllergies_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("DeleteAllergiesByPatientId", mock.Anything, 1).Return(fmt.Errorf("db error"))
+
+		err := service.DeletePatientAllergies(context.Background(), 1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error deleting allergies for patient db error", err.Error())
+	})
+}
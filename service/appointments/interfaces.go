@@ -0,0 +1,34 @@
+package appointments
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// booking and rescheduling check for conflicts and save as a single step, so two concurrent requests can never both claim the same time
+type AppointmentRepo interface {
+	InsertSlot(ctx context.Context, slot models.Slot) (int, error)
+	GetSlot(ctx context.Context, slotId int) (models.Slot, error)
+	GetSlots(ctx context.Context, practitioner string, from time.Time, to time.Time, available bool) ([]models.Slot, error)
+	BookAppointment(ctx context.Context, appointment models.Appointment) (models.Appointment, error)
+	GetAppointment(ctx context.Context, appointmentId int) (models.Appointment, error)
+	CancelAppointment(ctx context.Context, appointmentId int) error
+	RescheduleAppointment(ctx context.Context, appointmentId int, slotId int) (models.Appointment, error)
+	GetAppointmentsByPatientId(ctx context.Context, patientId int) ([]models.Appointment, error)
+	GetAppointments(ctx context.Context, practitioner string, from time.Time, to time.Time) ([]models.Appointment, error)
+	CancelAppointmentsByPatientId(ctx context.Context, patientId int) error
+}
+
+type PatientService interface {
+	ValidatePatientId(ctx context.Context, patientId int) error
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
@@ -0,0 +1,270 @@
+package appointments
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	PeriodDay  = "day"
+	PeriodWeek = "week"
+)
+
+type AppointmentService struct {
+	repo       AppointmentRepo
+	patientSvc PatientService
+	tracer     Tracer
+}
+
+func NewAppointmentService(repo AppointmentRepo, patientSvc PatientService, tracer Tracer) AppointmentService {
+	return AppointmentService{
+		repo:       repo,
+		patientSvc: patientSvc,
+		tracer:     tracer,
+	}
+}
+
+func (s AppointmentService) AddSlot(ctx context.Context, details models.SlotRequest) (models.Slot, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "AddSlot")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.String("practitioner", details.Practitioner),
+		attribute.String("start", fmt.Sprintf("%v", details.Start)),
+		attribute.String("end", fmt.Sprintf("%v", details.End)))
+
+	details.Practitioner = strings.TrimSpace(details.Practitioner)
+	if details.Practitioner == "" {
+		return models.Slot{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("practitioner is required"))
+	}
+	if details.Start.IsZero() {
+		return models.Slot{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("start is required"))
+	}
+	if !details.End.After(details.Start) {
+		return models.Slot{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("end must be after the start"))
+	}
+
+	slot := models.Slot{SlotRequest: details}
+	id, err := s.repo.InsertSlot(ctx, slot)
+	if err != nil {
+		return models.Slot{}, s.tracer.RecordError(ctx, fmt.Errorf("error inserting slot %w", err))
+	}
+
+	slot.Id = id
+	return slot, nil
+}
+
+func (s AppointmentService) GetSlots(ctx context.Context, search models.ScheduleSearch) ([]models.Slot, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetSlots")
+	defer span.End()
+	s.setSearchAttributes(ctx, search)
+
+	from, to, err := s.scheduleRange(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	slots, err := s.repo.GetSlots(ctx, search.Practitioner, from, to, search.Available)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting slots %w", err))
+	}
+
+	return slots, nil
+}
+
+func (s AppointmentService) GetSchedule(ctx context.Context, search models.ScheduleSearch) ([]models.Appointment, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetSchedule")
+	defer span.End()
+	s.setSearchAttributes(ctx, search)
+
+	from, to, err := s.scheduleRange(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	appointments, err := s.repo.GetAppointments(ctx, search.Practitioner, from, to)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting appointments %w", err))
+	}
+
+	return appointments, nil
+}
+
+func (s AppointmentService) BookAppointment(ctx context.Context, patientId int, details models.AppointmentRequest) (models.Appointment, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "BookAppointment")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("slotId", details.SlotId),
+		attribute.String("reason", details.Reason))
+
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return models.Appointment{}, err
+	}
+
+	err = s.validateSlot(ctx, details.SlotId)
+	if err != nil {
+		return models.Appointment{}, err
+	}
+
+	appointment, err := s.repo.BookAppointment(ctx, models.Appointment{
+		PatientId: patientId,
+		SlotId:    details.SlotId,
+		Reason:    details.Reason,
+		Status:    models.AppointmentStatusBooked,
+	})
+	if err != nil {
+		return models.Appointment{}, s.tracer.RecordError(ctx, fmt.Errorf("error booking appointment %w", err))
+	}
+
+	return appointment, nil
+}
+
+func (s AppointmentService) GetAppointment(ctx context.Context, patientId int, appointmentId int) (models.Appointment, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetAppointment")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("appointmentId", appointmentId))
+
+	return s.getPatientAppointment(ctx, patientId, appointmentId)
+}
+
+func (s AppointmentService) GetPatientAppointments(ctx context.Context, patientId int) ([]models.Appointment, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetPatientAppointments")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return nil, err
+	}
+
+	appointments, err := s.repo.GetAppointmentsByPatientId(ctx, patientId)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting appointments for patient %w", err))
+	}
+
+	return appointments, nil
+}
+
+func (s AppointmentService) CancelAppointment(ctx context.Context, patientId int, appointmentId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "CancelAppointment")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("appointmentId", appointmentId))
+
+	_, err := s.getPatientAppointment(ctx, patientId, appointmentId)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.CancelAppointment(ctx, appointmentId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error cancelling appointment %w", err))
+	}
+
+	return nil
+}
+
+func (s AppointmentService) RescheduleAppointment(ctx context.Context, patientId int, appointmentId int, slotId int) (models.Appointment, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "RescheduleAppointment")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("appointmentId", appointmentId),
+		attribute.Int("slotId", slotId))
+
+	_, err := s.getPatientAppointment(ctx, patientId, appointmentId)
+	if err != nil {
+		return models.Appointment{}, err
+	}
+
+	err = s.validateSlot(ctx, slotId)
+	if err != nil {
+		return models.Appointment{}, err
+	}
+
+	appointment, err := s.repo.RescheduleAppointment(ctx, appointmentId, slotId)
+	if err != nil {
+		return models.Appointment{}, s.tracer.RecordError(ctx, fmt.Errorf("error rescheduling appointment %w", err))
+	}
+
+	return appointment, nil
+}
+
+// appointments are not deleted with their patient, but cancelled so their slots can be booked by others
+func (s AppointmentService) CancelPatientAppointments(ctx context.Context, patientId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "CancelPatientAppointments")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.repo.CancelAppointmentsByPatientId(ctx, patientId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error cancelling appointments for patient %w", err))
+	}
+
+	return nil
+}
+
+// appointments are addressed through their patient, so one belonging to another patient is treated as missing
+func (s AppointmentService) getPatientAppointment(ctx context.Context, patientId int, appointmentId int) (models.Appointment, error) {
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return models.Appointment{}, err
+	}
+
+	appointment, err := s.repo.GetAppointment(ctx, appointmentId)
+	if err != nil {
+		return models.Appointment{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting appointment %w", err))
+	}
+	if appointment.PatientId != patientId {
+		return models.Appointment{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("appointment not found"))
+	}
+	return appointment, nil
+}
+
+// whether the slot is free is left to the repo, which checks it at the moment of booking
+func (s AppointmentService) validateSlot(ctx context.Context, slotId int) error {
+	slot, err := s.repo.GetSlot(ctx, slotId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error getting slot %w", err))
+	}
+	if !slot.Start.After(time.Now()) {
+		return s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("slot has already started"))
+	}
+	return nil
+}
+
+// the day, or monday to sunday week, containing the searched date
+func (s AppointmentService) scheduleRange(ctx context.Context, search models.ScheduleSearch) (time.Time, time.Time, error) {
+	day, err := time.Parse(time.DateOnly, search.Date)
+	if err != nil {
+		return time.Time{}, time.Time{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a valid date, expected the format 2006-01-02", search.Date)))
+	}
+
+	switch strings.ToLower(search.Period) {
+	case "", PeriodDay:
+		return day, day.AddDate(0, 0, 1), nil
+	case PeriodWeek:
+		monday := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return monday, monday.AddDate(0, 0, 7), nil
+	default:
+		return time.Time{}, time.Time{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a valid period, expected day or week", search.Period)))
+	}
+}
+
+func (s AppointmentService) setSearchAttributes(ctx context.Context, search models.ScheduleSearch) {
+	s.tracer.SetAttributes(ctx,
+		attribute.String("search.practitioner", search.Practitioner),
+		attribute.String("search.date", search.Date),
+		attribute.String("search.period", search.Period),
+		attribute.Bool("search.available", search.Available))
+}
@@ -0,0 +1,328 @@
+package appointments
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockAppointmentRepo struct {
+	mock.Mock
+}
+
+func (m *MockAppointmentRepo) InsertSlot(ctx context.Context, slot models.Slot) (int, error) {
+	args := m.Called(ctx, slot)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockAppointmentRepo) GetSlot(ctx context.Context, slotId int) (models.Slot, error) {
+	args := m.Called(ctx, slotId)
+	return args.Get(0).(models.Slot), args.Error(1)
+}
+
+func (m *MockAppointmentRepo) GetSlots(ctx context.Context, practitioner string, from time.Time, to time.Time, available bool) ([]models.Slot, error) {
+	args := m.Called(ctx, practitioner, from, to, available)
+	return args.Get(0).([]models.Slot), args.Error(1)
+}
+
+func (m *MockAppointmentRepo) BookAppointment(ctx context.Context, appointment models.Appointment) (models.Appointment, error) {
+	args := m.Called(ctx, appointment)
+	return args.Get(0).(models.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentRepo) GetAppointment(ctx context.Context, appointmentId int) (models.Appointment, error) {
+	args := m.Called(ctx, appointmentId)
+	return args.Get(0).(models.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentRepo) CancelAppointment(ctx context.Context, appointmentId int) error {
+	args := m.Called(ctx, appointmentId)
+	return args.Error(0)
+}
+
+func (m *MockAppointmentRepo) RescheduleAppointment(ctx context.Context, appointmentId int, slotId int) (models.Appointment, error) {
+	args := m.Called(ctx, appointmentId, slotId)
+	return args.Get(0).(models.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentRepo) GetAppointmentsByPatientId(ctx context.Context, patientId int) ([]models.Appointment, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).([]models.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentRepo) GetAppointments(ctx context.Context, practitioner string, from time.Time, to time.Time) ([]models.Appointment, error) {
+	args := m.Called(ctx, practitioner, from, to)
+	return args.Get(0).([]models.Appointment), args.Error(1)
+}
+
+func (m *MockAppointmentRepo) CancelAppointmentsByPatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) ValidatePatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService() (*MockAppointmentRepo, *MockPatientService, AppointmentService) {
+	mockRepo := new(MockAppointmentRepo)
+	mockPatientSvc := new(MockPatientService)
+	service := NewAppointmentService(mockRepo, mockPatientSvc, new(MockTracer))
+	return mockRepo, mockPatientSvc, service
+}
+
+func getFutureSlot() models.Slot {
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	return models.Slot{Id: 5, SlotRequest: models.SlotRequest{
+		Practitioner: "Dr. Jones",
+		Start:        start,
+		End:          start.Add(30 * time.Minute),
+	}}
+}
+
+func TestAddSlot(t *testing.T) {
+	t.Run("AddSlot_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getFutureSlot().SlotRequest
+		mockRepo.On("InsertSlot", mock.Anything, models.Slot{SlotRequest: details}).Return(5, nil)
+
+		slot, err := service.AddSlot(context.Background(), details)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, slot.Id)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("AddSlot_MissingPractitioner", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getFutureSlot().SlotRequest
+		details.Practitioner = " "
+
+		_, err := service.AddSlot(context.Background(), details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "practitioner is required", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertSlot", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddSlot_EndNotAfterStart", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getFutureSlot().SlotRequest
+		details.End = details.Start
+
+		_, err := service.AddSlot(context.Background(), details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "end must be after the start", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertSlot", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddSlot_Overlapping", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("InsertSlot", mock.Anything, mock.Anything).Return(0, customerrors.NewAlreadyExistsError("slot overlaps another slot of the practitioner"))
+
+		_, err := service.AddSlot(context.Background(), getFutureSlot().SlotRequest)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error inserting slot slot overlaps another slot of the practitioner", err.Error())
+	})
+}
+
+func TestGetSchedule(t *testing.T) {
+	t.Run("GetSchedule_Day", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		from := time.Date(2030, 3, 6, 0, 0, 0, 0, time.UTC)
+		mockRepo.On("GetAppointments", mock.Anything, "Dr. Jones", from, from.AddDate(0, 0, 1)).Return([]models.Appointment{}, nil)
+
+		_, err := service.GetSchedule(context.Background(), models.ScheduleSearch{Practitioner: "Dr. Jones", Date: "2030-03-06"})
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("GetSchedule_WeekStartsOnMonday", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		monday := time.Date(2030, 3, 4, 0, 0, 0, 0, time.UTC)
+		mockRepo.On("GetAppointments", mock.Anything, "", monday, monday.AddDate(0, 0, 7)).Return([]models.Appointment{}, nil)
+
+		_, err := service.GetSchedule(context.Background(), models.ScheduleSearch{Date: "2030-03-10", Period: "week"})
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("GetSchedule_InvalidDate", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+
+		_, err := service.GetSchedule(context.Background(), models.ScheduleSearch{Date: "03/06/2030"})
+		assert.NotNil(t, err)
+		assert.Equal(t, `"03/06/2030" is not a valid date, expected the format 2006-01-02`, err.Error())
+
+		mockRepo.AssertNotCalled(t, "GetAppointments", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("GetSlots_InvalidPeriod", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+
+		_, err := service.GetSlots(context.Background(), models.ScheduleSearch{Date: "2030-03-06", Period: "month"})
+		assert.NotNil(t, err)
+		assert.Equal(t, `"month" is not a valid period, expected day or week`, err.Error())
+
+		mockRepo.AssertNotCalled(t, "GetSlots", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestBookAppointment(t *testing.T) {
+	patientId := 1
+	details := models.AppointmentRequest{SlotId: 5, Reason: "check up"}
+
+	t.Run("BookAppointment_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		slot := getFutureSlot()
+		booked := models.Appointment{Id: 2, PatientId: patientId, SlotId: 5, Reason: "check up", Status: models.AppointmentStatusBooked, Practitioner: slot.Practitioner, Start: slot.Start, End: slot.End}
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("GetSlot", mock.Anything, 5).Return(slot, nil)
+		mockRepo.On("BookAppointment", mock.Anything, models.Appointment{PatientId: patientId, SlotId: 5, Reason: "check up", Status: models.AppointmentStatusBooked}).Return(booked, nil)
+
+		appointment, err := service.BookAppointment(context.Background(), patientId, details)
+		assert.Nil(t, err)
+		assert.Equal(t, booked, appointment)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("BookAppointment_InvalidPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.BookAppointment(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "patient id not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "BookAppointment", mock.Anything, mock.Anything)
+	})
+
+	t.Run("BookAppointment_SlotInThePast", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		slot := getFutureSlot()
+		slot.Start = time.Now().Add(-time.Hour)
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("GetSlot", mock.Anything, 5).Return(slot, nil)
+
+		_, err := service.BookAppointment(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "slot has already started", err.Error())
+
+		mockRepo.AssertNotCalled(t, "BookAppointment", mock.Anything, mock.Anything)
+	})
+
+	t.Run("BookAppointment_SlotAlreadyBooked", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("GetSlot", mock.Anything, 5).Return(getFutureSlot(), nil)
+		mockRepo.On("BookAppointment", mock.Anything, mock.Anything).Return(models.Appointment{}, customerrors.NewAlreadyExistsError("slot is already booked"))
+
+		_, err := service.BookAppointment(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error booking appointment slot is already booked", err.Error())
+	})
+}
+
+func TestCancelAppointment(t *testing.T) {
+	existing := models.Appointment{Id: 2, PatientId: 1, SlotId: 5, Status: models.AppointmentStatusBooked}
+
+	t.Run("CancelAppointment_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("GetAppointment", mock.Anything, 2).Return(existing, nil)
+		mockRepo.On("CancelAppointment", mock.Anything, 2).Return(nil)
+
+		err := service.CancelAppointment(context.Background(), 1, 2)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("CancelAppointment_OtherPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 3).Return(nil)
+		mockRepo.On("GetAppointment", mock.Anything, 2).Return(existing, nil)
+
+		err := service.CancelAppointment(context.Background(), 3, 2)
+		assert.NotNil(t, err)
+		assert.Equal(t, "appointment not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "CancelAppointment", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRescheduleAppointment(t *testing.T) {
+	existing := models.Appointment{Id: 2, PatientId: 1, SlotId: 4, Status: models.AppointmentStatusBooked}
+
+	t.Run("RescheduleAppointment_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		rescheduled := existing
+		rescheduled.SlotId = 5
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("GetAppointment", mock.Anything, 2).Return(existing, nil)
+		mockRepo.On("GetSlot", mock.Anything, 5).Return(getFutureSlot(), nil)
+		mockRepo.On("RescheduleAppointment", mock.Anything, 2, 5).Return(rescheduled, nil)
+
+		appointment, err := service.RescheduleAppointment(context.Background(), 1, 2, 5)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, appointment.SlotId)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("RescheduleAppointment_MissingSlot", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("GetAppointment", mock.Anything, 2).Return(existing, nil)
+		mockRepo.On("GetSlot", mock.Anything, 5).Return(models.Slot{}, customerrors.NewInvalidInputError("slot not found"))
+
+		_, err := service.RescheduleAppointment(context.Background(), 1, 2, 5)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting slot slot not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "RescheduleAppointment", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestCancelPatientAppointments(t *testing.T) {
+	t.Run("CancelPatientAppointments_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("CancelAppointmentsByPatientId", mock.Anything, 1).Return(fmt.Errorf("db error"))
+
+		err := service.CancelPatientAppointments(context.Background(), 1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error cancelling appointments for patient db error", err.Error())
+	})
+}
@@ -0,0 +1,240 @@
+package attatchments
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"mcg-app-backend/service/models"
+	"strings"
+	"time"
+)
+
+const (
+	implicitVRLittleEndian = "1.2.840.10008.1.2"
+	explicitVRBigEndian    = "1.2.840.10008.1.2.2"
+	deflatedLittleEndian   = "1.2.840.10008.1.2.1.99"
+	undefinedLength        = 0xFFFFFFFF
+	//sequences and items of undefined length are skipped recursively, so their nesting is limited to keep a crafted
+	//file from exhausting the stack
+	maxNestingDepth = 64
+)
+
+type dicomTag struct {
+	group   uint16
+	element uint16
+}
+
+var (
+	transferSyntaxTag    = dicomTag{0x0002, 0x0010}
+	studyDateTag         = dicomTag{0x0008, 0x0020}
+	modalityTag          = dicomTag{0x0008, 0x0060}
+	bodyPartTag          = dicomTag{0x0018, 0x0015}
+	studyInstanceUidTag  = dicomTag{0x0020, 0x000D}
+	seriesInstanceUidTag = dicomTag{0x0020, 0x000E}
+	itemTag              = dicomTag{0xFFFE, 0xE000}
+	itemDelimiterTag     = dicomTag{0xFFFE, 0xE00D}
+	sequenceDelimiterTag = dicomTag{0xFFFE, 0xE0DD}
+)
+
+// vrs which are followed by two reserved bytes and a four byte length when the vr is explicit
+var longLengthVRs = map[string]bool{
+	"OB": true, "OD": true, "OF": true, "OL": true, "OV": true, "OW": true,
+	"SQ": true, "SV": true, "UC": true, "UN": true, "UR": true, "UT": true, "UV": true,
+}
+
+type dicomReader struct {
+	data     []byte
+	pos      int
+	order    binary.ByteOrder
+	implicit bool
+	//how many sequences and items of undefined length are being skipped
+	depth int
+}
+
+func isDicom(data []byte) bool {
+	return len(data) >= 132 && string(data[128:132]) == "DICM"
+}
+
+// returns nil when data is not a DICOM part 10 file, or its data set is deflated
+func parseDicomMetadata(data []byte) (*models.DicomMetadata, error) {
+	if !isDicom(data) {
+		return nil, nil
+	}
+
+	//the file meta information group is always explicit vr little endian
+	reader := &dicomReader{data: data, pos: 132, order: binary.LittleEndian}
+	transferSyntax := ""
+	for reader.pos < len(data) {
+		if reader.peekTag().group != 0x0002 {
+			break
+		}
+		tag, value, err := reader.readElement()
+		if err != nil {
+			return nil, err
+		}
+		if tag == transferSyntaxTag {
+			transferSyntax = trimDicomString(value)
+		}
+	}
+
+	switch transferSyntax {
+	case implicitVRLittleEndian:
+		reader.implicit = true
+	case explicitVRBigEndian:
+		reader.order = binary.BigEndian
+	case deflatedLittleEndian:
+		//the data set is compressed, so the file is stored without its metadata
+		return nil, nil
+	}
+
+	metadata := &models.DicomMetadata{}
+	for reader.pos < len(data) {
+		//elements are stored in ascending tag order, so nothing of interest follows group 0020
+		if reader.peekTag().group > 0x0020 {
+			break
+		}
+		tag, value, err := reader.readElement()
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case studyDateTag:
+			studyDate, err := time.Parse("20060102", trimDicomString(value))
+			if err == nil {
+				metadata.StudyDate = studyDate
+			}
+		case modalityTag:
+			metadata.Modality = trimDicomString(value)
+		case bodyPartTag:
+			metadata.BodyPart = trimDicomString(value)
+		case studyInstanceUidTag:
+			metadata.StudyInstanceUid = trimDicomString(value)
+		case seriesInstanceUidTag:
+			metadata.SeriesInstanceUid = trimDicomString(value)
+		}
+	}
+	return metadata, nil
+}
+
+func (r *dicomReader) peekTag() dicomTag {
+	if r.pos+4 > len(r.data) {
+		return dicomTag{0xFFFF, 0xFFFF}
+	}
+	return dicomTag{r.order.Uint16(r.data[r.pos:]), r.order.Uint16(r.data[r.pos+2:])}
+}
+
+func (r *dicomReader) next(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, errors.New("dicom data is truncated")
+	}
+	bts := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return bts, nil
+}
+
+func (r *dicomReader) readTag() (dicomTag, error) {
+	bts, err := r.next(4)
+	if err != nil {
+		return dicomTag{}, err
+	}
+	return dicomTag{r.order.Uint16(bts), r.order.Uint16(bts[2:])}, nil
+}
+
+func (r *dicomReader) readUint32() (uint32, error) {
+	bts, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return r.order.Uint32(bts), nil
+}
+
+// reads the next data element, skipping over the contents of sequences
+func (r *dicomReader) readElement() (dicomTag, []byte, error) {
+	tag, err := r.readTag()
+	if err != nil {
+		return tag, nil, err
+	}
+
+	var length uint32
+	vr := ""
+	if r.implicit || tag.group == 0xFFFE {
+		length, err = r.readUint32()
+	} else {
+		var vrBytes []byte
+		vrBytes, err = r.next(2)
+		if err != nil {
+			return tag, nil, err
+		}
+		vr = string(vrBytes)
+		if longLengthVRs[vr] {
+			_, err = r.next(2)
+			if err == nil {
+				length, err = r.readUint32()
+			}
+		} else {
+			var lengthBytes []byte
+			lengthBytes, err = r.next(2)
+			if err == nil {
+				length = uint32(r.order.Uint16(lengthBytes))
+			}
+		}
+	}
+	if err != nil {
+		return tag, nil, err
+	}
+
+	if length == undefinedLength {
+		return tag, nil, r.skipUndefinedLength()
+	}
+	if vr == "SQ" {
+		_, err = r.next(int(length))
+		return tag, nil, err
+	}
+	value, err := r.next(int(length))
+	return tag, value, err
+}
+
+// skips a sequence or item of undefined length up to and including its delimiter
+func (r *dicomReader) skipUndefinedLength() error {
+	if r.depth == maxNestingDepth {
+		return errors.New("dicom sequences are nested too deeply")
+	}
+	r.depth++
+	defer func() { r.depth-- }()
+
+	for {
+		if r.peekTag() == sequenceDelimiterTag || r.peekTag() == itemDelimiterTag {
+			_, err := r.next(8)
+			return err
+		}
+		tag, err := r.readTag()
+		if err != nil {
+			return err
+		}
+		if tag != itemTag {
+			//not an item, so this is the contents of an item being read as a flat list of elements
+			r.pos -= 4
+			_, _, err = r.readElement()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		length, err := r.readUint32()
+		if err != nil {
+			return err
+		}
+		if length == undefinedLength {
+			err = r.skipUndefinedLength()
+		} else {
+			_, err = r.next(int(length))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func trimDicomString(value []byte) string {
+	return strings.TrimRight(string(bytes.TrimRight(value, "\x00")), " ")
+}
@@ -0,0 +1,128 @@
+package attatchments
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testDicomElement struct {
+	group   uint16
+	element uint16
+	vr      string
+	value   []byte
+}
+
+func writeTestDicomElement(buf *bytes.Buffer, implicit bool, el testDicomElement) {
+	value := el.value
+	if len(value)%2 == 1 {
+		value = append(value, ' ')
+	}
+	binary.Write(buf, binary.LittleEndian, el.group)
+	binary.Write(buf, binary.LittleEndian, el.element)
+	if implicit {
+		binary.Write(buf, binary.LittleEndian, uint32(len(value)))
+	} else if longLengthVRs[el.vr] {
+		buf.WriteString(el.vr)
+		buf.Write([]byte{0, 0})
+		binary.Write(buf, binary.LittleEndian, uint32(len(value)))
+	} else {
+		buf.WriteString(el.vr)
+		binary.Write(buf, binary.LittleEndian, uint16(len(value)))
+	}
+	buf.Write(value)
+}
+
+// builds a DICOM part 10 file containing a referenced image sequence of undefined length
+// followed by the elements extracted on upload
+func buildTestDicom(implicit bool) []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(make([]byte, 128))
+	buf.WriteString("DICM")
+	transferSyntax := "1.2.840.10008.1.2.1"
+	if implicit {
+		transferSyntax = implicitVRLittleEndian
+	}
+	writeTestDicomElement(buf, false, testDicomElement{0x0002, 0x0010, "UI", []byte(transferSyntax)})
+
+	writeTestDicomElement(buf, implicit, testDicomElement{0x0008, 0x0020, "DA", []byte("20240115")})
+	writeTestDicomElement(buf, implicit, testDicomElement{0x0008, 0x0060, "CS", []byte("MR")})
+
+	binary.Write(buf, binary.LittleEndian, []uint16{0x0008, 0x1140})
+	if !implicit {
+		buf.WriteString("SQ")
+		buf.Write([]byte{0, 0})
+	}
+	binary.Write(buf, binary.LittleEndian, uint32(undefinedLength))
+	binary.Write(buf, binary.LittleEndian, []uint16{0xFFFE, 0xE000})
+	binary.Write(buf, binary.LittleEndian, uint32(undefinedLength))
+	writeTestDicomElement(buf, implicit, testDicomElement{0x0008, 0x1150, "UI", []byte("1.2.840.10008.5.1.4.1.1.4")})
+	binary.Write(buf, binary.LittleEndian, []uint16{0xFFFE, 0xE00D})
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+	binary.Write(buf, binary.LittleEndian, []uint16{0xFFFE, 0xE0DD})
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+
+	writeTestDicomElement(buf, implicit, testDicomElement{0x0018, 0x0015, "CS", []byte("KNEE")})
+	writeTestDicomElement(buf, implicit, testDicomElement{0x0020, 0x000D, "UI", []byte("1.2.3.4")})
+	writeTestDicomElement(buf, implicit, testDicomElement{0x0020, 0x000E, "UI", []byte("1.2.3.4.5")})
+	writeTestDicomElement(buf, implicit, testDicomElement{0x7FE0, 0x0010, "OB", []byte{1, 2, 3, 4}})
+	return buf.Bytes()
+}
+
+func TestParseDicomMetadata(t *testing.T) {
+	for _, implicit := range []bool{false, true} {
+		metadata, err := parseDicomMetadata(buildTestDicom(implicit))
+		assert.Nil(t, err)
+		assert.NotNil(t, metadata)
+		assert.Equal(t, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), metadata.StudyDate)
+		assert.Equal(t, "MR", metadata.Modality)
+		assert.Equal(t, "KNEE", metadata.BodyPart)
+		assert.Equal(t, "1.2.3.4", metadata.StudyInstanceUid)
+		assert.Equal(t, "1.2.3.4.5", metadata.SeriesInstanceUid)
+	}
+
+	t.Run("ParseDicom_NotDicom", func(t *testing.T) {
+		metadata, err := parseDicomMetadata([]byte("sample data"))
+		assert.Nil(t, err)
+		assert.Nil(t, metadata)
+	})
+
+	t.Run("ParseDicom_Truncated", func(t *testing.T) {
+		data := buildTestDicom(false)
+		metadata, err := parseDicomMetadata(data[:len(data)-30])
+		assert.NotNil(t, err)
+		assert.Nil(t, metadata)
+	})
+	t.Run("ParseDicom_Deflated", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		buf.Write(make([]byte, 128))
+		buf.WriteString("DICM")
+		writeTestDicomElement(buf, false, testDicomElement{0x0002, 0x0010, "UI", []byte(deflatedLittleEndian)})
+		buf.Write([]byte{0x78, 0x9c, 0x01, 0x02})
+
+		metadata, err := parseDicomMetadata(buf.Bytes())
+		assert.Nil(t, err)
+		assert.Nil(t, metadata)
+	})
+
+	t.Run("ParseDicom_NestedTooDeeply", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		buf.Write(make([]byte, 128))
+		buf.WriteString("DICM")
+		writeTestDicomElement(buf, false, testDicomElement{0x0002, 0x0010, "UI", []byte(implicitVRLittleEndian)})
+		binary.Write(buf, binary.LittleEndian, []uint16{0x0008, 0x1140})
+		binary.Write(buf, binary.LittleEndian, uint32(undefinedLength))
+		for i := 0; i < 100000; i++ {
+			binary.Write(buf, binary.LittleEndian, []uint16{0xFFFE, 0xE000})
+			binary.Write(buf, binary.LittleEndian, uint32(undefinedLength))
+		}
+
+		metadata, err := parseDicomMetadata(buf.Bytes())
+		assert.NotNil(t, err)
+		assert.Equal(t, "dicom sequences are nested too deeply", err.Error())
+		assert.Nil(t, metadata)
+	})
+}
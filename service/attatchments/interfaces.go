@@ -12,12 +12,25 @@ type AttachmentRepo interface {
 	InsertAttatchment(ctx context.Context, attachment models.Attatchment) (int, error)
 	DeleteAttatchment(ctx context.Context, attachmentId int) error
 	DeleteAttatchmentsByPatientId(ctx context.Context, patientId int) error
+	ReassignAttatchments(ctx context.Context, fromPatientId int, toPatientId int) error
+	RestoreAttatchment(ctx context.Context, attachmentId int) error
+	GetAttatchment(ctx context.Context, attachmentId int) (models.Attatchment, error)
+	InsertAttatchmentVersion(ctx context.Context, version models.AttatchmentVersion) (int, error)
+	GetAttatchmentVersions(ctx context.Context, attachmentId int) ([]models.AttatchmentVersion, error)
+	GetAttatchmentVersion(ctx context.Context, attachmentId int, version int) (models.AttatchmentVersion, error)
+	SaveAttatchmentThumbnails(ctx context.Context, attachmentId int, thumbnails []models.AttatchmentThumbnail) error
+	GetAttatchmentThumbnail(ctx context.Context, attachmentId int, size int) (models.AttatchmentThumbnail, error)
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type PatientService interface {
 	ValidatePatientId(ctx context.Context, patientId int) error
 }
 
+type EncounterService interface {
+	ValidateEncounterId(ctx context.Context, patientId int, encounterId int) error
+}
+
 type Tracer interface {
 	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
 	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
@@ -2,35 +2,41 @@ package attatchments
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mcg-app-backend/service/customerrors"
 	"mcg-app-backend/service/models"
+	"slices"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 )
 
 type AttachmentService struct {
-	repo       AttachmentRepo
-	patientSvc PatientService
-	tracer     Tracer
+	repo         AttachmentRepo
+	patientSvc   PatientService
+	encounterSvc EncounterService
+	tracer       Tracer
 }
 
-func NewAttachmentService(repo AttachmentRepo, patientSvc PatientService, tracer Tracer) AttachmentService {
+func NewAttachmentService(repo AttachmentRepo, patientSvc PatientService, encounterSvc EncounterService, tracer Tracer) AttachmentService {
 	return AttachmentService{
-		repo:       repo,
-		patientSvc: patientSvc,
-		tracer:     tracer,
+		repo:         repo,
+		patientSvc:   patientSvc,
+		encounterSvc: encounterSvc,
+		tracer:       tracer,
 	}
 }
 
-func (s AttachmentService) AddAttatchmentToPatient(ctx context.Context, patientId int, name string, description string, typ string, data []byte) (models.Attatchment, error) {
+func (s AttachmentService) AddAttatchmentToPatient(ctx context.Context, patientId int, name string, description string, typ string, data []byte, encounterId int) (models.Attatchment, error) {
 	ctx, span := s.tracer.NewSpan(ctx, "AddAttachmentToPatient")
 	defer span.End()
 	s.tracer.SetAttributes(ctx,
 		attribute.Int("patientId", patientId),
 		attribute.String("name", name),
 		attribute.String("description", description),
-		attribute.String("type", typ))
+		attribute.String("type", typ),
+		attribute.Int("encounterId", encounterId))
 
 	if len(data) == 0 {
 		return models.Attatchment{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("data was empty"))
@@ -40,6 +46,18 @@ func (s AttachmentService) AddAttatchmentToPatient(ctx context.Context, patientI
 	if err != nil {
 		return models.Attatchment{}, err
 	}
+	//an encounter id of 0 means the attatchment was not produced at an encounter
+	if encounterId != 0 {
+		err = s.encounterSvc.ValidateEncounterId(ctx, patientId, encounterId)
+		if err != nil {
+			return models.Attatchment{}, err
+		}
+	}
+
+	dicom, err := parseDicomMetadata(data)
+	if err != nil {
+		return models.Attatchment{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("invalid DICOM file: %v", err)))
+	}
 
 	attachment := models.Attatchment{
 		PatientId:   patientId,
@@ -47,14 +65,34 @@ func (s AttachmentService) AddAttatchmentToPatient(ctx context.Context, patientI
 		Description: description,
 		Type:        typ,
 		Data:        data,
+		Version:     1,
+		Dicom:       dicom,
+		EncounterId: encounterId,
+	}
+
+	thumbnails, err := s.generateThumbnails(ctx, data)
+	if err != nil {
+		return models.Attatchment{}, err
 	}
 
-	id, err := s.repo.InsertAttatchment(ctx, attachment)
+	err = s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		id, err := s.repo.InsertAttatchment(ctx, attachment)
+		if err != nil {
+			return fmt.Errorf("error inserting attachment %w", err)
+		}
+		attachment.Id = id
+		if len(thumbnails) > 0 {
+			err = s.repo.SaveAttatchmentThumbnails(ctx, id, thumbnails)
+			if err != nil {
+				return fmt.Errorf("error saving thumbnails %w", err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return models.Attatchment{}, s.tracer.RecordError(ctx, fmt.Errorf("error inserting attachment %w", err))
+		return models.Attatchment{}, s.tracer.RecordError(ctx, err)
 	}
 
-	attachment.Id = id
 	return attachment, nil
 }
 
@@ -71,6 +109,19 @@ func (s AttachmentService) DeleteAttatchment(ctx context.Context, attachmentId i
 	return nil
 }
 
+func (s AttachmentService) RestoreAttatchment(ctx context.Context, attachmentId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "RestoreAttachment")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("attachmentId", attachmentId))
+
+	err := s.repo.RestoreAttatchment(ctx, attachmentId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error restoring attachment %w", err))
+	}
+
+	return nil
+}
+
 func (s AttachmentService) DeletePatientAttachments(ctx context.Context, patientId int) error {
 	ctx, span := s.tracer.NewSpan(ctx, "DeletePatientAttachments")
 	defer span.End()
@@ -83,3 +134,159 @@ func (s AttachmentService) DeletePatientAttachments(ctx context.Context, patient
 
 	return nil
 }
+
+// moves the attatchments of one patient to another, such as when duplicate records of a patient are merged
+func (s AttachmentService) MoveAttatchmentsToPatient(ctx context.Context, fromPatientId int, toPatientId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "MoveAttatchmentsToPatient")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("fromPatientId", fromPatientId),
+		attribute.Int("toPatientId", toPatientId))
+
+	err := s.patientSvc.ValidatePatientId(ctx, toPatientId)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.ReassignAttatchments(ctx, fromPatientId, toPatientId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error moving attachments %w", err))
+	}
+
+	return nil
+}
+
+func (s AttachmentService) ReplaceAttatchmentContent(ctx context.Context, attachmentId int, data []byte) (models.Attatchment, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "ReplaceAttachmentContent")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("attachmentId", attachmentId))
+
+	if len(data) == 0 {
+		return models.Attatchment{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("data was empty"))
+	}
+
+	attachment, err := s.repo.GetAttatchment(ctx, attachmentId)
+	if err != nil {
+		return models.Attatchment{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting attachment %w", err))
+	}
+
+	dicom, err := parseDicomMetadata(data)
+	if err != nil {
+		return models.Attatchment{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("invalid DICOM file: %v", err)))
+	}
+
+	thumbnails, err := s.generateThumbnails(ctx, data)
+	if err != nil {
+		return models.Attatchment{}, err
+	}
+
+	version := models.AttatchmentVersion{
+		AttatchmentId: attachmentId,
+		Data:          data,
+		Dicom:         dicom,
+		CreatedAt:     time.Now(),
+	}
+
+	var versionNumber int
+	err = s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		number, err := s.repo.InsertAttatchmentVersion(ctx, version)
+		if err != nil {
+			return fmt.Errorf("error inserting attachment version %w", err)
+		}
+		versionNumber = number
+		//thumbnails always reflect the current version, so any from the previous version are replaced
+		err = s.repo.SaveAttatchmentThumbnails(ctx, attachmentId, thumbnails)
+		if err != nil {
+			return fmt.Errorf("error saving thumbnails %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Attatchment{}, s.tracer.RecordError(ctx, err)
+	}
+
+	attachment.Data = data
+	attachment.Version = versionNumber
+	attachment.Dicom = dicom
+	return attachment, nil
+}
+
+func (s AttachmentService) GetAttatchmentMetadata(ctx context.Context, attachmentId int) (models.Attatchment, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetAttachmentMetadata")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("attachmentId", attachmentId))
+
+	attachment, err := s.repo.GetAttatchment(ctx, attachmentId)
+	if err != nil {
+		return models.Attatchment{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting attachment %w", err))
+	}
+
+	attachment.Data = nil
+	return attachment, nil
+}
+
+func (s AttachmentService) GetAttatchmentVersions(ctx context.Context, attachmentId int) ([]models.AttatchmentVersion, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetAttachmentVersions")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("attachmentId", attachmentId))
+
+	versions, err := s.repo.GetAttatchmentVersions(ctx, attachmentId)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting attachment versions %w", err))
+	}
+
+	//data is left off of the listing, individual versions can be fetched to retrieve it
+	for i := range versions {
+		versions[i].Data = nil
+	}
+	return versions, nil
+}
+
+func (s AttachmentService) GetAttatchmentVersion(ctx context.Context, attachmentId int, version int) (models.AttatchmentVersion, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetAttachmentVersion")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("attachmentId", attachmentId),
+		attribute.Int("version", version))
+
+	attachmentVersion, err := s.repo.GetAttatchmentVersion(ctx, attachmentId, version)
+	if err != nil {
+		return models.AttatchmentVersion{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting attachment version %w", err))
+	}
+
+	return attachmentVersion, nil
+}
+
+func (s AttachmentService) GetAttatchmentThumbnail(ctx context.Context, attachmentId int, size int) (models.AttatchmentThumbnail, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetAttachmentThumbnail")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("attachmentId", attachmentId),
+		attribute.Int("size", size))
+
+	if size == 0 {
+		size = DefaultThumbnailSize
+	}
+	if !slices.Contains(ThumbnailSizes, size) {
+		return models.AttatchmentThumbnail{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("size must be one of %v", ThumbnailSizes)))
+	}
+
+	thumbnail, err := s.repo.GetAttatchmentThumbnail(ctx, attachmentId, size)
+	if err != nil {
+		return models.AttatchmentThumbnail{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting attachment thumbnail %w", err))
+	}
+
+	return thumbnail, nil
+}
+
+// images which cannot be decoded are refused, as their thumbnails could not be shown
+func (s AttachmentService) generateThumbnails(ctx context.Context, data []byte) ([]models.AttatchmentThumbnail, error) {
+	thumbnails, err := generateThumbnails(data)
+	if errors.Is(err, errInvalidImage) {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(err.Error()))
+	}
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error generating thumbnails %w", err))
+	}
+	return thumbnails, nil
+}
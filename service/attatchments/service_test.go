@@ -1,8 +1,14 @@
 package attatchments
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mcg-app-backend/service/customerrors"
 	"mcg-app-backend/service/models"
 	"testing"
 
@@ -32,6 +38,50 @@ func (m *MockAttachmentRepo) DeleteAttatchmentsByPatientId(ctx context.Context,
 	return args.Error(0)
 }
 
+func (m *MockAttachmentRepo) ReassignAttatchments(ctx context.Context, fromPatientId int, toPatientId int) error {
+	args := m.Called(ctx, fromPatientId, toPatientId)
+	return args.Error(0)
+}
+
+func (m *MockAttachmentRepo) GetAttatchment(ctx context.Context, attachmentId int) (models.Attatchment, error) {
+	args := m.Called(ctx, attachmentId)
+	return args.Get(0).(models.Attatchment), args.Error(1)
+}
+
+func (m *MockAttachmentRepo) InsertAttatchmentVersion(ctx context.Context, version models.AttatchmentVersion) (int, error) {
+	args := m.Called(ctx, version)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockAttachmentRepo) GetAttatchmentVersions(ctx context.Context, attachmentId int) ([]models.AttatchmentVersion, error) {
+	args := m.Called(ctx, attachmentId)
+	return args.Get(0).([]models.AttatchmentVersion), args.Error(1)
+}
+
+func (m *MockAttachmentRepo) GetAttatchmentVersion(ctx context.Context, attachmentId int, version int) (models.AttatchmentVersion, error) {
+	args := m.Called(ctx, attachmentId, version)
+	return args.Get(0).(models.AttatchmentVersion), args.Error(1)
+}
+
+func (m *MockAttachmentRepo) SaveAttatchmentThumbnails(ctx context.Context, attachmentId int, thumbnails []models.AttatchmentThumbnail) error {
+	args := m.Called(ctx, attachmentId, thumbnails)
+	return args.Error(0)
+}
+
+func (m *MockAttachmentRepo) GetAttatchmentThumbnail(ctx context.Context, attachmentId int, size int) (models.AttatchmentThumbnail, error) {
+	args := m.Called(ctx, attachmentId, size)
+	return args.Get(0).(models.AttatchmentThumbnail), args.Error(1)
+}
+
+func (m *MockAttachmentRepo) RestoreAttatchment(ctx context.Context, attachmentId int) error {
+	args := m.Called(ctx, attachmentId)
+	return args.Error(0)
+}
+
+func (m *MockAttachmentRepo) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
 type MockPatientService struct {
 	mock.Mock
 }
@@ -41,6 +91,15 @@ func (m *MockPatientService) ValidatePatientId(ctx context.Context, patientId in
 	return args.Error(0)
 }
 
+type MockEncounterService struct {
+	mock.Mock
+}
+
+func (m *MockEncounterService) ValidateEncounterId(ctx context.Context, patientId int, encounterId int) error {
+	args := m.Called(ctx, patientId, encounterId)
+	return args.Error(0)
+}
+
 type MockTracer struct {
 	mock.Mock
 }
@@ -55,11 +114,17 @@ func (m *MockTracer) RecordError(ctx context.Context, err error) error {
 	return err
 }
 func getMocksAndService() (*MockAttachmentRepo, *MockPatientService, *MockTracer, AttachmentService) {
+	mockAttachmentRepo, mockPatientService, _, mockTracer, service := getMocksAndServiceWithEncounters()
+	return mockAttachmentRepo, mockPatientService, mockTracer, service
+}
+
+func getMocksAndServiceWithEncounters() (*MockAttachmentRepo, *MockPatientService, *MockEncounterService, *MockTracer, AttachmentService) {
 	mockAttachmentRepo := new(MockAttachmentRepo)
 	mockPatientService := new(MockPatientService)
+	mockEncounterService := new(MockEncounterService)
 	mockTracer := new(MockTracer)
-	service := NewAttachmentService(mockAttachmentRepo, mockPatientService, mockTracer)
-	return mockAttachmentRepo, mockPatientService, mockTracer, service
+	service := NewAttachmentService(mockAttachmentRepo, mockPatientService, mockEncounterService, mockTracer)
+	return mockAttachmentRepo, mockPatientService, mockEncounterService, mockTracer, service
 }
 
 func TestAddAttatchmentToPatient(t *testing.T) {
@@ -74,7 +139,7 @@ func TestAddAttatchmentToPatient(t *testing.T) {
 		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
 		mockAttachmentRepo.On("InsertAttatchment", mock.Anything, mock.Anything).Return(1, nil)
 
-		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, name, description, typ, data)
+		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, name, description, typ, data, 0)
 		assert.Nil(t, err)
 		assert.NotNil(t, attachment)
 		assert.Equal(t, patientId, attachment.PatientId)
@@ -82,16 +147,42 @@ func TestAddAttatchmentToPatient(t *testing.T) {
 		assert.Equal(t, description, attachment.Description)
 		assert.Equal(t, typ, attachment.Type)
 		assert.Equal(t, data, attachment.Data)
+		assert.Equal(t, 1, attachment.Version)
 
 		mockPatientService.AssertExpectations(t)
 		mockAttachmentRepo.AssertExpectations(t)
 		mockTracer.AssertExpectations(t)
 	})
 
+	t.Run("AddAttachment_WithEncounter", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, mockEncounterService, _, service := getMocksAndServiceWithEncounters()
+		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockEncounterService.On("ValidateEncounterId", mock.Anything, patientId, 4).Return(nil)
+		mockAttachmentRepo.On("InsertAttatchment", mock.Anything, mock.Anything).Return(1, nil)
+
+		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, name, description, typ, data, 4)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, attachment.EncounterId)
+
+		mockEncounterService.AssertExpectations(t)
+	})
+
+	t.Run("AddAttachment_InvalidEncounter", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, mockEncounterService, _, service := getMocksAndServiceWithEncounters()
+		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockEncounterService.On("ValidateEncounterId", mock.Anything, patientId, 4).Return(fmt.Errorf("encounter not found"))
+
+		_, err := service.AddAttatchmentToPatient(context.Background(), patientId, name, description, typ, data, 4)
+		assert.NotNil(t, err)
+		assert.Equal(t, "encounter not found", err.Error())
+
+		mockAttachmentRepo.AssertNotCalled(t, "InsertAttatchment", mock.Anything, mock.Anything)
+	})
+
 	t.Run("AddAttachment_EmptyData", func(t *testing.T) {
 		mockAttachmentRepo, mockPatientService, mockTracer, service := getMocksAndService()
 
-		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, name, description, typ, []byte{})
+		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, name, description, typ, []byte{}, 0)
 		assert.NotNil(t, err)
 		assert.Equal(t, "data was empty", err.Error())
 		assert.Equal(t, models.Attatchment{}, attachment)
@@ -105,7 +196,7 @@ func TestAddAttatchmentToPatient(t *testing.T) {
 		mockAttachmentRepo, mockPatientService, mockTracer, service := getMocksAndService()
 		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(fmt.Errorf("invalid patient"))
 
-		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, name, description, typ, data)
+		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, name, description, typ, data, 0)
 		assert.NotNil(t, err)
 		assert.Equal(t, "invalid patient", err.Error())
 		assert.Equal(t, models.Attatchment{}, attachment)
@@ -120,7 +211,7 @@ func TestAddAttatchmentToPatient(t *testing.T) {
 		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
 		mockAttachmentRepo.On("InsertAttatchment", mock.Anything, mock.Anything).Return(0, fmt.Errorf("insert error"))
 
-		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, name, description, typ, data)
+		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, name, description, typ, data, 0)
 		assert.NotNil(t, err)
 		assert.Equal(t, "error inserting attachment insert error", err.Error())
 		assert.Equal(t, models.Attatchment{}, attachment)
@@ -184,3 +275,388 @@ func TestDeletePatientAttachments(t *testing.T) {
 		mockTracer.AssertExpectations(t)
 	})
 }
+
+func TestMoveAttatchmentsToPatient(t *testing.T) {
+	t.Run("MoveAttatchmentsToPatient_Success", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, _, service := getMocksAndService()
+		mockPatientService.On("ValidatePatientId", mock.Anything, 2).Return(nil)
+		mockAttachmentRepo.On("ReassignAttatchments", mock.Anything, 1, 2).Return(nil)
+
+		err := service.MoveAttatchmentsToPatient(context.Background(), 1, 2)
+		assert.Nil(t, err)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("MoveAttatchmentsToPatient_InvalidPatientId", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, _, service := getMocksAndService()
+		mockPatientService.On("ValidatePatientId", mock.Anything, 2).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		err := service.MoveAttatchmentsToPatient(context.Background(), 1, 2)
+		assert.Equal(t, customerrors.NewInvalidInputError("patient id not found"), err)
+
+		mockAttachmentRepo.AssertNotCalled(t, "ReassignAttatchments", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestReplaceAttatchmentContent(t *testing.T) {
+	attachmentId := 1
+	data := []byte("rescanned data")
+	existing := models.Attatchment{
+		Id:        attachmentId,
+		PatientId: 2,
+		Name:      "Attachment 1",
+		Type:      "pdf",
+		Data:      []byte("original data"),
+		Version:   1,
+	}
+
+	t.Run("ReplaceContent_Success", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("GetAttatchment", mock.Anything, attachmentId).Return(existing, nil)
+		mockAttachmentRepo.On("InsertAttatchmentVersion", mock.Anything, mock.MatchedBy(func(v models.AttatchmentVersion) bool {
+			return v.AttatchmentId == attachmentId && string(v.Data) == string(data)
+		})).Return(2, nil)
+		mockAttachmentRepo.On("SaveAttatchmentThumbnails", mock.Anything, attachmentId, []models.AttatchmentThumbnail(nil)).Return(nil)
+
+		attachment, err := service.ReplaceAttatchmentContent(context.Background(), attachmentId, data)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, attachment.Version)
+		assert.Equal(t, data, attachment.Data)
+		assert.Equal(t, existing.Name, attachment.Name)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("ReplaceContent_EmptyData", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+
+		attachment, err := service.ReplaceAttatchmentContent(context.Background(), attachmentId, nil)
+		assert.NotNil(t, err)
+		assert.Equal(t, "data was empty", err.Error())
+		assert.Equal(t, models.Attatchment{}, attachment)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("ReplaceContent_NotFound", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("GetAttatchment", mock.Anything, attachmentId).Return(models.Attatchment{}, fmt.Errorf("not found"))
+
+		attachment, err := service.ReplaceAttatchmentContent(context.Background(), attachmentId, data)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting attachment not found", err.Error())
+		assert.Equal(t, models.Attatchment{}, attachment)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("ReplaceContent_InsertError", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("GetAttatchment", mock.Anything, attachmentId).Return(existing, nil)
+		mockAttachmentRepo.On("InsertAttatchmentVersion", mock.Anything, mock.Anything).Return(0, fmt.Errorf("insert error"))
+
+		attachment, err := service.ReplaceAttatchmentContent(context.Background(), attachmentId, data)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error inserting attachment version insert error", err.Error())
+		assert.Equal(t, models.Attatchment{}, attachment)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+}
+
+func TestGetAttatchmentVersions(t *testing.T) {
+	attachmentId := 1
+
+	t.Run("GetVersions_OmitsData", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("GetAttatchmentVersions", mock.Anything, attachmentId).Return([]models.AttatchmentVersion{
+			{AttatchmentId: attachmentId, Version: 1, Data: []byte("v1")},
+			{AttatchmentId: attachmentId, Version: 2, Data: []byte("v2")},
+		}, nil)
+
+		versions, err := service.GetAttatchmentVersions(context.Background(), attachmentId)
+		assert.Nil(t, err)
+		assert.Len(t, versions, 2)
+		for _, version := range versions {
+			assert.Nil(t, version.Data)
+		}
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("GetVersions_Error", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("GetAttatchmentVersions", mock.Anything, attachmentId).Return([]models.AttatchmentVersion(nil), fmt.Errorf("not found"))
+
+		versions, err := service.GetAttatchmentVersions(context.Background(), attachmentId)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting attachment versions not found", err.Error())
+		assert.Nil(t, versions)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+}
+
+func TestGetAttatchmentVersion(t *testing.T) {
+	attachmentId := 1
+
+	t.Run("GetVersion_Success", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		expected := models.AttatchmentVersion{AttatchmentId: attachmentId, Version: 1, Data: []byte("v1")}
+		mockAttachmentRepo.On("GetAttatchmentVersion", mock.Anything, attachmentId, 1).Return(expected, nil)
+
+		version, err := service.GetAttatchmentVersion(context.Background(), attachmentId, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, version)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("GetVersion_Error", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("GetAttatchmentVersion", mock.Anything, attachmentId, 5).Return(models.AttatchmentVersion{}, fmt.Errorf("not found"))
+
+		version, err := service.GetAttatchmentVersion(context.Background(), attachmentId, 5)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting attachment version not found", err.Error())
+		assert.Equal(t, models.AttatchmentVersion{}, version)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+}
+
+func encodeTestImage(t *testing.T, width int, height int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.Nil(t, encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestAddAttatchmentThumbnails(t *testing.T) {
+	patientId := 1
+
+	t.Run("AddAttachment_PngThumbnails", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, _, service := getMocksAndService()
+		data := encodeTestImage(t, 400, 200, func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) })
+		var saved []models.AttatchmentThumbnail
+		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockAttachmentRepo.On("InsertAttatchment", mock.Anything, mock.Anything).Return(3, nil)
+		mockAttachmentRepo.On("SaveAttatchmentThumbnails", mock.Anything, 3, mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(2).([]models.AttatchmentThumbnail)
+		}).Return(nil)
+
+		_, err := service.AddAttatchmentToPatient(context.Background(), patientId, "scan.png", "", "image", data, 0)
+		assert.Nil(t, err)
+		assert.Len(t, saved, len(ThumbnailSizes))
+		for i, thumbnail := range saved {
+			assert.Equal(t, "image/png", thumbnail.ContentType)
+			decoded, err := png.Decode(bytes.NewReader(thumbnail.Data))
+			assert.Nil(t, err)
+			assert.Equal(t, ThumbnailSizes[i], decoded.Bounds().Dx())
+			assert.Equal(t, ThumbnailSizes[i]/2, decoded.Bounds().Dy())
+		}
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("AddAttachment_JpegThumbnailsAreNotEnlarged", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, _, service := getMocksAndService()
+		data := encodeTestImage(t, 50, 100, func(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) })
+		var saved []models.AttatchmentThumbnail
+		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockAttachmentRepo.On("InsertAttatchment", mock.Anything, mock.Anything).Return(3, nil)
+		mockAttachmentRepo.On("SaveAttatchmentThumbnails", mock.Anything, 3, mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(2).([]models.AttatchmentThumbnail)
+		}).Return(nil)
+
+		_, err := service.AddAttatchmentToPatient(context.Background(), patientId, "scan.jpg", "", "image", data, 0)
+		assert.Nil(t, err)
+		assert.Len(t, saved, len(ThumbnailSizes))
+		smallest, err := jpeg.Decode(bytes.NewReader(saved[0].Data))
+		assert.Nil(t, err)
+		assert.Equal(t, "image/jpeg", saved[0].ContentType)
+		assert.Equal(t, image.Rect(0, 0, 32, 64), smallest.Bounds())
+		largest, err := jpeg.Decode(bytes.NewReader(saved[len(saved)-1].Data))
+		assert.Nil(t, err)
+		assert.Equal(t, image.Rect(0, 0, 50, 100), largest.Bounds())
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("AddAttachment_SaveThumbnailError", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, _, service := getMocksAndService()
+		data := encodeTestImage(t, 10, 10, func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) })
+		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockAttachmentRepo.On("InsertAttatchment", mock.Anything, mock.Anything).Return(3, nil)
+		mockAttachmentRepo.On("SaveAttatchmentThumbnails", mock.Anything, 3, mock.Anything).Return(fmt.Errorf("save error"))
+
+		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, "scan.png", "", "image", data, 0)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error saving thumbnails save error", err.Error())
+		assert.Equal(t, models.Attatchment{}, attachment)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("AddAttachment_ImageTooLargeForThumbnails", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, _, service := getMocksAndService()
+		//a gif header declaring a 65535 x 65535 screen, which would take gigabytes to decode
+		data := []byte("GIF89a\xff\xff\xff\xff\x00\x00\x00")
+		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockAttachmentRepo.On("InsertAttatchment", mock.Anything, mock.Anything).Return(3, nil)
+
+		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, "scan.gif", "", "image", data, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, attachment.Id)
+
+		mockAttachmentRepo.AssertNotCalled(t, "SaveAttatchmentThumbnails", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddAttachment_CorruptImage", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, _, service := getMocksAndService()
+		data := encodeTestImage(t, 100, 100, func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) })
+		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+
+		_, err := service.AddAttatchmentToPatient(context.Background(), patientId, "scan.png", "", "image", data[:len(data)/2], 0)
+		var inputError customerrors.InvalidInputError
+		assert.ErrorAs(t, err, &inputError)
+		assert.Contains(t, err.Error(), "invalid image")
+
+		mockAttachmentRepo.AssertNotCalled(t, "InsertAttatchment", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetAttatchmentThumbnail(t *testing.T) {
+	attachmentId := 1
+
+	t.Run("GetThumbnail_DefaultSize", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		expected := models.AttatchmentThumbnail{AttatchmentId: attachmentId, Size: DefaultThumbnailSize, ContentType: "image/png"}
+		mockAttachmentRepo.On("GetAttatchmentThumbnail", mock.Anything, attachmentId, DefaultThumbnailSize).Return(expected, nil)
+
+		thumbnail, err := service.GetAttatchmentThumbnail(context.Background(), attachmentId, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, thumbnail)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("GetThumbnail_UnsupportedSize", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+
+		thumbnail, err := service.GetAttatchmentThumbnail(context.Background(), attachmentId, 100)
+		assert.NotNil(t, err)
+		assert.Equal(t, "size must be one of [64 128 256]", err.Error())
+		assert.Equal(t, models.AttatchmentThumbnail{}, thumbnail)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("GetThumbnail_Error", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("GetAttatchmentThumbnail", mock.Anything, attachmentId, 64).Return(models.AttatchmentThumbnail{}, fmt.Errorf("not found"))
+
+		thumbnail, err := service.GetAttatchmentThumbnail(context.Background(), attachmentId, 64)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting attachment thumbnail not found", err.Error())
+		assert.Equal(t, models.AttatchmentThumbnail{}, thumbnail)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+}
+
+func TestAddDicomAttatchment(t *testing.T) {
+	patientId := 1
+
+	t.Run("AddAttachment_ExtractsDicomMetadata", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, _, service := getMocksAndService()
+		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockAttachmentRepo.On("InsertAttatchment", mock.Anything, mock.MatchedBy(func(a models.Attatchment) bool {
+			return a.Dicom != nil && a.Dicom.Modality == "MR"
+		})).Return(1, nil)
+
+		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, "knee scan", "", "MRI", buildTestDicom(false), 0)
+		assert.Nil(t, err)
+		assert.NotNil(t, attachment.Dicom)
+		assert.Equal(t, "KNEE", attachment.Dicom.BodyPart)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("AddAttachment_InvalidDicom", func(t *testing.T) {
+		mockAttachmentRepo, mockPatientService, _, service := getMocksAndService()
+		mockPatientService.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		data := buildTestDicom(false)
+
+		attachment, err := service.AddAttatchmentToPatient(context.Background(), patientId, "knee scan", "", "MRI", data[:140], 0)
+		assert.NotNil(t, err)
+		assert.Equal(t, "invalid DICOM file: dicom data is truncated", err.Error())
+		assert.Equal(t, models.Attatchment{}, attachment)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+}
+
+func TestGetAttatchmentMetadata(t *testing.T) {
+	attachmentId := 1
+
+	t.Run("GetMetadata_OmitsData", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("GetAttatchment", mock.Anything, attachmentId).Return(models.Attatchment{
+			Id:    attachmentId,
+			Name:  "knee scan",
+			Data:  []byte("data"),
+			Dicom: &models.DicomMetadata{Modality: "MR"},
+		}, nil)
+
+		attachment, err := service.GetAttatchmentMetadata(context.Background(), attachmentId)
+		assert.Nil(t, err)
+		assert.Nil(t, attachment.Data)
+		assert.Equal(t, "MR", attachment.Dicom.Modality)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("GetMetadata_Error", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("GetAttatchment", mock.Anything, attachmentId).Return(models.Attatchment{}, fmt.Errorf("not found"))
+
+		attachment, err := service.GetAttatchmentMetadata(context.Background(), attachmentId)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting attachment not found", err.Error())
+		assert.Equal(t, models.Attatchment{}, attachment)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+}
+
+func TestRestoreAttatchment(t *testing.T) {
+	attachmentId := 1
+
+	t.Run("RestoreAttachment_Success", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("RestoreAttatchment", mock.Anything, attachmentId).Return(nil)
+
+		err := service.RestoreAttatchment(context.Background(), attachmentId)
+		assert.Nil(t, err)
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("RestoreAttachment_Error", func(t *testing.T) {
+		mockAttachmentRepo, _, _, service := getMocksAndService()
+		mockAttachmentRepo.On("RestoreAttatchment", mock.Anything, attachmentId).Return(fmt.Errorf("not deleted"))
+
+		err := service.RestoreAttatchment(context.Background(), attachmentId)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error restoring attachment not deleted", err.Error())
+
+		mockAttachmentRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,111 @@
+package attatchments
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"mcg-app-backend/service/models"
+)
+
+var ThumbnailSizes = []int{64, 128, 256}
+
+const DefaultThumbnailSize = 128
+
+// images with more pixels than this produce no thumbnails.  Their header is read before they are decoded, as a small
+// file can declare dimensions which would take gigabytes to decode
+const maxThumbnailPixels = 50_000_000
+
+var errInvalidImage = errors.New("invalid image")
+
+// data that is not an image, or is an image too large to decode, produces no thumbnails.  An image which cannot be
+// decoded is reported as invalid
+func generateThumbnails(data []byte) ([]models.AttatchmentThumbnail, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidImage, err)
+	}
+	if int64(config.Width)*int64(config.Height) > maxThumbnailPixels {
+		return nil, nil
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidImage, err)
+	}
+
+	var thumbnails []models.AttatchmentThumbnail
+	for _, size := range ThumbnailSizes {
+		var buf bytes.Buffer
+		scaled := scaleToFit(img, size)
+		contentType := "image/png"
+		if format == "jpeg" {
+			contentType = "image/jpeg"
+			err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85})
+		} else {
+			err = png.Encode(&buf, scaled)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error encoding %vpx thumbnail %w", size, err)
+		}
+
+		thumbnails = append(thumbnails, models.AttatchmentThumbnail{
+			Size:        size,
+			ContentType: contentType,
+			Data:        buf.Bytes(),
+		})
+	}
+	return thumbnails, nil
+}
+
+// shrinks img to fit within a size x size box, averaging the source pixels covered by each destination pixel
+func scaleToFit(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	width, height := srcWidth, srcHeight
+	if width > size || height > size {
+		if width >= height {
+			height = max(1, height*size/width)
+			width = size
+		} else {
+			width = max(1, width*size/height)
+			height = size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcHeight/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcWidth/width)
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					count++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(b / count),
+				A: uint16(a / count),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,240 @@
+package ccda
+
+import "encoding/xml"
+
+// the subset of the CDA R2 schema used by a C-CDA R2.1 Continuity of Care Document.  Fields are declared in the order
+// the schema requires their elements to appear
+
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+type ClinicalDocument struct {
+	XMLName             xml.Name        `xml:"urn:hl7-org:v3 ClinicalDocument"`
+	XsiNamespace        string          `xml:"xmlns:xsi,attr"`
+	RealmCode           Code            `xml:"realmCode"`
+	TypeId              Id              `xml:"typeId"`
+	TemplateIds         []Id            `xml:"templateId"`
+	Id                  Id              `xml:"id"`
+	Code                Code            `xml:"code"`
+	Title               string          `xml:"title"`
+	EffectiveTime       Time            `xml:"effectiveTime"`
+	ConfidentialityCode Code            `xml:"confidentialityCode"`
+	LanguageCode        Code            `xml:"languageCode"`
+	RecordTarget        RecordTarget    `xml:"recordTarget"`
+	Author              Author          `xml:"author"`
+	Custodian           Custodian       `xml:"custodian"`
+	DocumentationOf     DocumentationOf `xml:"documentationOf"`
+	Component           BodyComponent   `xml:"component"`
+}
+
+type Id struct {
+	Root       string `xml:"root,attr,omitempty"`
+	Extension  string `xml:"extension,attr,omitempty"`
+	NullFlavor string `xml:"nullFlavor,attr,omitempty"`
+}
+
+// coded values, which are also used for status codes and, with a type, for observation values
+type Code struct {
+	Type           string `xml:"xsi:type,attr,omitempty"`
+	Code           string `xml:"code,attr,omitempty"`
+	CodeSystem     string `xml:"codeSystem,attr,omitempty"`
+	CodeSystemName string `xml:"codeSystemName,attr,omitempty"`
+	DisplayName    string `xml:"displayName,attr,omitempty"`
+	NullFlavor     string `xml:"nullFlavor,attr,omitempty"`
+	Translation    []Code `xml:"translation"`
+}
+
+// a point in time, or an interval when low or high are given
+type Time struct {
+	Value      string `xml:"value,attr,omitempty"`
+	NullFlavor string `xml:"nullFlavor,attr,omitempty"`
+	Low        *Time  `xml:"low"`
+	High       *Time  `xml:"high"`
+}
+
+type Addr struct {
+	Use               string `xml:"use,attr,omitempty"`
+	NullFlavor        string `xml:"nullFlavor,attr,omitempty"`
+	StreetAddressLine string `xml:"streetAddressLine,omitempty"`
+}
+
+type Telecom struct {
+	Use        string `xml:"use,attr,omitempty"`
+	Value      string `xml:"value,attr,omitempty"`
+	NullFlavor string `xml:"nullFlavor,attr,omitempty"`
+}
+
+type Name struct {
+	Use    string   `xml:"use,attr,omitempty"`
+	Given  []string `xml:"given"`
+	Family string   `xml:"family"`
+}
+
+type RecordTarget struct {
+	PatientRole PatientRole `xml:"patientRole"`
+}
+
+type PatientRole struct {
+	Ids     []Id    `xml:"id"`
+	Addr    Addr    `xml:"addr"`
+	Telecom Telecom `xml:"telecom"`
+	Patient Person  `xml:"patient"`
+}
+
+type Person struct {
+	Name                     Name `xml:"name"`
+	AdministrativeGenderCode Code `xml:"administrativeGenderCode"`
+	BirthTime                Time `xml:"birthTime"`
+}
+
+type Author struct {
+	Time           Time           `xml:"time"`
+	AssignedAuthor AssignedAuthor `xml:"assignedAuthor"`
+}
+
+type AssignedAuthor struct {
+	Id                      Id            `xml:"id"`
+	Addr                    Addr          `xml:"addr"`
+	Telecom                 Telecom       `xml:"telecom"`
+	AssignedAuthoringDevice Device        `xml:"assignedAuthoringDevice"`
+	RepresentedOrganization *Organization `xml:"representedOrganization"`
+}
+
+type Device struct {
+	ManufacturerModelName string `xml:"manufacturerModelName"`
+	SoftwareName          string `xml:"softwareName"`
+}
+
+type Organization struct {
+	Id      Id      `xml:"id"`
+	Name    string  `xml:"name"`
+	Telecom Telecom `xml:"telecom"`
+	Addr    Addr    `xml:"addr"`
+}
+
+type Custodian struct {
+	AssignedCustodian AssignedCustodian `xml:"assignedCustodian"`
+}
+
+type AssignedCustodian struct {
+	RepresentedCustodianOrganization Organization `xml:"representedCustodianOrganization"`
+}
+
+type DocumentationOf struct {
+	ServiceEvent ServiceEvent `xml:"serviceEvent"`
+}
+
+type ServiceEvent struct {
+	ClassCode     string `xml:"classCode,attr"`
+	EffectiveTime Time   `xml:"effectiveTime"`
+}
+
+type BodyComponent struct {
+	StructuredBody StructuredBody `xml:"structuredBody"`
+}
+
+type StructuredBody struct {
+	Components []SectionComponent `xml:"component"`
+}
+
+type SectionComponent struct {
+	Section Section `xml:"section"`
+}
+
+type Section struct {
+	NullFlavor  string    `xml:"nullFlavor,attr,omitempty"`
+	TemplateIds []Id      `xml:"templateId"`
+	Code        Code      `xml:"code"`
+	Title       string    `xml:"title"`
+	Text        Narrative `xml:"text"`
+	Entries     []Entry   `xml:"entry"`
+}
+
+// the human readable part of a section, which entries refer to by the ID of a cell
+type Narrative struct {
+	Paragraph string `xml:"paragraph,omitempty"`
+	Table     *Table `xml:"table"`
+}
+
+type Table struct {
+	Head TableHead `xml:"thead"`
+	Body TableBody `xml:"tbody"`
+}
+
+type TableHead struct {
+	Row TableHeadRow `xml:"tr"`
+}
+
+type TableHeadRow struct {
+	Cells []string `xml:"th"`
+}
+
+type TableBody struct {
+	Rows []TableRow `xml:"tr"`
+}
+
+type TableRow struct {
+	Cells []TableCell `xml:"td"`
+}
+
+type TableCell struct {
+	Id    string `xml:"ID,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type Entry struct {
+	Act Act `xml:"act"`
+}
+
+type Act struct {
+	ClassCode          string              `xml:"classCode,attr"`
+	MoodCode           string              `xml:"moodCode,attr"`
+	TemplateIds        []Id                `xml:"templateId"`
+	Ids                []Id                `xml:"id"`
+	Code               Code                `xml:"code"`
+	Text               *Text               `xml:"text"`
+	StatusCode         Code                `xml:"statusCode"`
+	EffectiveTime      Time                `xml:"effectiveTime"`
+	Author             *Author             `xml:"author"`
+	EntryRelationships []EntryRelationship `xml:"entryRelationship"`
+	Reference          *Reference          `xml:"reference"`
+}
+
+type EntryRelationship struct {
+	TypeCode    string      `xml:"typeCode,attr"`
+	Observation Observation `xml:"observation"`
+}
+
+type Observation struct {
+	ClassCode     string `xml:"classCode,attr"`
+	MoodCode      string `xml:"moodCode,attr"`
+	TemplateIds   []Id   `xml:"templateId"`
+	Ids           []Id   `xml:"id"`
+	Code          Code   `xml:"code"`
+	Text          *Text  `xml:"text"`
+	StatusCode    Code   `xml:"statusCode"`
+	EffectiveTime Time   `xml:"effectiveTime"`
+	Value         Code   `xml:"value"`
+}
+
+// refers to the narrative, or to content outside of the document
+type Text struct {
+	MediaType string           `xml:"mediaType,attr,omitempty"`
+	Reference ContentReference `xml:"reference"`
+}
+
+type ContentReference struct {
+	Value string `xml:"value,attr"`
+}
+
+type Reference struct {
+	TypeCode         string           `xml:"typeCode,attr"`
+	ExternalDocument ExternalDocument `xml:"externalDocument"`
+}
+
+type ExternalDocument struct {
+	ClassCode string `xml:"classCode,attr"`
+	MoodCode  string `xml:"moodCode,attr"`
+	Id        Id     `xml:"id"`
+	Code      Code   `xml:"code"`
+	Text      *Text  `xml:"text"`
+}
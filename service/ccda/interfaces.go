@@ -0,0 +1,19 @@
+package ccda
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type PatientService interface {
+	GetPatient(ctx context.Context, patientId int) (models.Patient, error)
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
@@ -0,0 +1,339 @@
+package ccda
+
+import (
+	"errors"
+	"fmt"
+	"mcg-app-backend/service/fhir"
+	"mcg-app-backend/service/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	codeSystemLOINC           = "2.16.840.1.113883.6.1"
+	codeSystemSNOMEDCT        = "2.16.840.1.113883.6.96"
+	codeSystemICD10CM         = "2.16.840.1.113883.6.90"
+	codeSystemActClass        = "2.16.840.1.113883.5.6"
+	codeSystemConfidentiality = "2.16.840.1.113883.5.25"
+)
+
+// C-CDA R2.1 templates, with the version each is used at
+const (
+	templateUSRealmHeader      = "2.16.840.1.113883.10.20.22.1.1"
+	templateCCD                = "2.16.840.1.113883.10.20.22.1.2"
+	templateAllergiesSection   = "2.16.840.1.113883.10.20.22.2.6.1"
+	templateMedicationsSection = "2.16.840.1.113883.10.20.22.2.1.1"
+	templateProblemSection     = "2.16.840.1.113883.10.20.22.2.5.1"
+	templateProblemConcernAct  = "2.16.840.1.113883.10.20.22.4.3"
+	templateProblemObservation = "2.16.840.1.113883.10.20.22.4.4"
+	templateNotesSection       = "2.16.840.1.113883.10.20.22.2.65"
+	templateNoteActivity       = "2.16.840.1.113883.10.20.22.4.202"
+
+	versionR21           = "2015-08-01"
+	versionMedications   = "2014-06-09"
+	versionNotes         = "2016-11-01"
+	softwareName         = "mcg-app-backend"
+	dateFormat           = "20060102"
+	timestampFormat      = "20060102150405-0700"
+	notIncludedNarrative = "%v are not included in this document."
+)
+
+// the organization responsible for the documents, whose OID is also the root under which our records are identified
+type Facility struct {
+	Oid         string
+	Name        string
+	PhoneNumber string
+	Address     string
+}
+
+var oidPattern = regexp.MustCompile(`^[0-2](\.(0|[1-9][0-9]*))+$`)
+
+// every field is required, so documents cannot name a custodian which is missing any of them
+func (f Facility) Validate() error {
+	switch {
+	case !oidPattern.MatchString(f.Oid):
+		return fmt.Errorf("custodian OID %q is not a valid OID", f.Oid)
+	case f.Name == "":
+		return errors.New("custodian name is required")
+	case f.PhoneNumber == "":
+		return errors.New("custodian phone number is required")
+	case f.Address == "":
+		return errors.New("custodian address is required")
+	}
+	return nil
+}
+
+// ids of each kind of record are rooted at their own branch of the facility's OID
+func (f Facility) patientRoot() string            { return f.Oid + ".1" }
+func (f Facility) externalIdentifierRoot() string { return f.Oid + ".2" }
+func (f Facility) conditionRoot() string          { return f.Oid + ".3" }
+func (f Facility) concernRoot() string            { return f.Oid + ".4" }
+func (f Facility) attatchmentRoot() string        { return f.Oid + ".5" }
+func (f Facility) noteRoot() string               { return f.Oid + ".6" }
+
+// a Continuity of Care Document of the patient's demographics, problems and attatched documents.  A CCD must also have
+// allergy and medication sections, which are included without entries and say they are not part of the document
+func ToContinuityOfCareDocument(patient models.Patient, facility Facility, documentId string, now time.Time) ClinicalDocument {
+	author := toAuthor(facility, now)
+	return ClinicalDocument{
+		XsiNamespace: xsiNamespace,
+		RealmCode:    Code{Code: "US"},
+		TypeId:       Id{Root: "2.16.840.1.113883.1.3", Extension: "POCD_HD000040"},
+		TemplateIds: []Id{
+			{Root: templateUSRealmHeader},
+			{Root: templateUSRealmHeader, Extension: versionR21},
+			{Root: templateCCD},
+			{Root: templateCCD, Extension: versionR21},
+		},
+		Id:                  Id{Root: documentId},
+		Code:                Code{Code: "34133-9", CodeSystem: codeSystemLOINC, CodeSystemName: "LOINC", DisplayName: "Summarization of Episode Note"},
+		Title:               "Continuity of Care Document",
+		EffectiveTime:       Time{Value: now.Format(timestampFormat)},
+		ConfidentialityCode: Code{Code: "N", CodeSystem: codeSystemConfidentiality, DisplayName: "normal"},
+		LanguageCode:        Code{Code: "en-US"},
+		RecordTarget:        RecordTarget{PatientRole: toPatientRole(patient, facility)},
+		Author:              author,
+		Custodian:           Custodian{AssignedCustodian: AssignedCustodian{RepresentedCustodianOrganization: toOrganization(facility)}},
+		DocumentationOf: DocumentationOf{ServiceEvent: ServiceEvent{
+			ClassCode: "PCPR",
+			EffectiveTime: Time{
+				Low:  &Time{Value: patient.DateOfBirth.Format(dateFormat)},
+				High: &Time{Value: now.Format(timestampFormat)},
+			},
+		}},
+		Component: BodyComponent{StructuredBody: StructuredBody{Components: []SectionComponent{
+			{Section: notIncludedSection(templateAllergiesSection, versionR21,
+				Code{Code: "48765-2", CodeSystem: codeSystemLOINC, CodeSystemName: "LOINC", DisplayName: "Allergies and adverse reactions Document"},
+				"Allergies and Adverse Reactions", "Allergies")},
+			{Section: notIncludedSection(templateMedicationsSection, versionMedications,
+				Code{Code: "10160-0", CodeSystem: codeSystemLOINC, CodeSystemName: "LOINC", DisplayName: "History of Medication use Narrative"},
+				"Medications", "Medications")},
+			{Section: toProblemSection(patient.DiagnosedConditions, facility)},
+			{Section: toNotesSection(patient.Attatchments, facility, author)},
+		}}},
+	}
+}
+
+func toPatientRole(patient models.Patient, facility Facility) PatientRole {
+	ids := []Id{{Root: facility.patientRoot(), Extension: strconv.Itoa(patient.Id)}}
+	if patient.ExternalIdentifier != "" {
+		ids = append(ids, Id{Root: facility.externalIdentifierRoot(), Extension: patient.ExternalIdentifier})
+	}
+	//ids are rooted in OIDs, so identifiers in other systems cannot be included
+	for _, identifier := range patient.Identifiers {
+		if oid, found := strings.CutPrefix(identifier.System, "urn:oid:"); found {
+			ids = append(ids, Id{Root: oid, Extension: identifier.Value})
+		}
+	}
+	return PatientRole{
+		Ids:     ids,
+		Addr:    toAddr(patient.Address, "HP"),
+		Telecom: toTelecom(patient.PhoneNumber, "HP"),
+		Patient: Person{
+			Name: toName(patient.Name),
+			// gender is not recorded
+			AdministrativeGenderCode: Code{NullFlavor: "UNK"},
+			BirthTime:                Time{Value: patient.DateOfBirth.Format(dateFormat)},
+		},
+	}
+}
+
+// names are recorded whole, so the last word is taken to be the family name
+func toName(name string) Name {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return Name{Use: "L"}
+	}
+	return Name{Use: "L", Given: words[:len(words)-1], Family: words[len(words)-1]}
+}
+
+// addresses are recorded as a single line of text
+func toAddr(address string, use string) Addr {
+	if address == "" {
+		return Addr{NullFlavor: "UNK"}
+	}
+	return Addr{Use: use, StreetAddressLine: address}
+}
+
+func toTelecom(phoneNumber string, use string) Telecom {
+	if phoneNumber == "" {
+		return Telecom{NullFlavor: "UNK"}
+	}
+	return Telecom{Use: use, Value: "tel:" + strings.Join(strings.Fields(phoneNumber), "")}
+}
+
+func toOrganization(facility Facility) Organization {
+	return Organization{
+		Id:      Id{Root: facility.Oid},
+		Name:    facility.Name,
+		Telecom: toTelecom(facility.PhoneNumber, "WP"),
+		Addr:    toAddr(facility.Address, "WP"),
+	}
+}
+
+// documents are generated by this application rather than written by a person
+func toAuthor(facility Facility, now time.Time) Author {
+	organization := toOrganization(facility)
+	return Author{
+		Time: Time{Value: now.Format(timestampFormat)},
+		AssignedAuthor: AssignedAuthor{
+			Id:                      Id{Root: facility.Oid, Extension: softwareName},
+			Addr:                    organization.Addr,
+			Telecom:                 organization.Telecom,
+			AssignedAuthoringDevice: Device{ManufacturerModelName: softwareName, SoftwareName: softwareName},
+			RepresentedOrganization: &organization,
+		},
+	}
+}
+
+func notIncludedSection(template string, version string, code Code, title string, contents string) Section {
+	return Section{
+		NullFlavor:  "NI",
+		TemplateIds: []Id{{Root: template}, {Root: template, Extension: version}},
+		Code:        code,
+		Title:       title,
+		Text:        Narrative{Paragraph: fmt.Sprintf(notIncludedNarrative, contents)},
+	}
+}
+
+// conditions which were refuted or entered in error are left out, as they are not problems the patient has had
+func toProblemSection(conditions []models.DiagnosedCondition, facility Facility) Section {
+	section := Section{
+		TemplateIds: []Id{{Root: templateProblemSection}, {Root: templateProblemSection, Extension: versionR21}},
+		Code:        Code{Code: "11450-4", CodeSystem: codeSystemLOINC, CodeSystemName: "LOINC", DisplayName: "Problem list - Reported"},
+		Title:       "Problems",
+	}
+	table := &Table{Head: TableHead{Row: TableHeadRow{Cells: []string{"Problem", "Code", "Status", "Onset", "Diagnosed"}}}}
+	for _, condition := range conditions {
+		if condition.VerificationStatus == models.VerificationStatusRefuted || condition.VerificationStatus == models.VerificationStatusEnteredInError {
+			continue
+		}
+		narrativeId := fmt.Sprintf("problem-%v", condition.Id)
+		onset := ""
+		if condition.OnsetDate != nil {
+			onset = condition.OnsetDate.Format(time.DateOnly)
+		}
+		table.Body.Rows = append(table.Body.Rows, TableRow{Cells: []TableCell{
+			{Id: narrativeId, Value: condition.Name},
+			{Value: fmt.Sprintf("%v %v", condition.CodeSystem, condition.Code)},
+			{Value: condition.ClinicalStatus},
+			{Value: onset},
+			{Value: condition.Date.Format(time.DateOnly)},
+		}})
+		section.Entries = append(section.Entries, Entry{Act: toProblemConcern(condition, facility, narrativeId)})
+	}
+	if len(section.Entries) == 0 {
+		section.NullFlavor = "NI"
+		section.Text = Narrative{Paragraph: "No problems recorded."}
+		return section
+	}
+	section.Text = Narrative{Table: table}
+	return section
+}
+
+// each problem is wrapped in a concern, which is completed once the problem is no longer active
+func toProblemConcern(condition models.DiagnosedCondition, facility Facility, narrativeId string) Act {
+	active := condition.IsActive()
+	concernTime := Time{Low: &Time{Value: condition.Date.Format(dateFormat)}}
+	concernStatus := "active"
+	if !active {
+		concernStatus = "completed"
+		concernTime.High = toDate(condition.AbatementDate)
+	}
+
+	observationTime := Time{Low: toDate(condition.OnsetDate)}
+	if condition.AbatementDate != nil {
+		observationTime.High = toDate(condition.AbatementDate)
+	}
+
+	return Act{
+		ClassCode:     "ACT",
+		MoodCode:      "EVN",
+		TemplateIds:   []Id{{Root: templateProblemConcernAct}, {Root: templateProblemConcernAct, Extension: versionR21}},
+		Ids:           []Id{{Root: facility.concernRoot(), Extension: strconv.Itoa(condition.Id)}},
+		Code:          Code{Code: "CONC", CodeSystem: codeSystemActClass, DisplayName: "Concern"},
+		StatusCode:    Code{Code: concernStatus},
+		EffectiveTime: concernTime,
+		EntryRelationships: []EntryRelationship{{TypeCode: "SUBJ", Observation: Observation{
+			ClassCode:   "OBS",
+			MoodCode:    "EVN",
+			TemplateIds: []Id{{Root: templateProblemObservation}, {Root: templateProblemObservation, Extension: versionR21}},
+			Ids:         []Id{{Root: facility.conditionRoot(), Extension: strconv.Itoa(condition.Id)}},
+			Code: Code{Code: "55607006", CodeSystem: codeSystemSNOMEDCT, CodeSystemName: "SNOMED CT", DisplayName: "Problem",
+				Translation: []Code{{Code: "75326-9", CodeSystem: codeSystemLOINC, CodeSystemName: "LOINC", DisplayName: "Problem"}}},
+			Text:          &Text{Reference: ContentReference{Value: "#" + narrativeId}},
+			StatusCode:    Code{Code: "completed"},
+			EffectiveTime: observationTime,
+			Value:         toProblemCode(condition),
+		}}},
+	}
+}
+
+func toProblemCode(condition models.DiagnosedCondition) Code {
+	code := Code{Type: "CD", Code: condition.Code, CodeSystem: codeSystemICD10CM, CodeSystemName: "ICD-10-CM", DisplayName: condition.Name}
+	if condition.CodeSystem == models.CodeSystemSNOMEDCT {
+		code.CodeSystem, code.CodeSystemName = codeSystemSNOMEDCT, "SNOMED CT"
+	}
+	return code
+}
+
+// dates which are not known are reported as such, as the templates require them to be present
+func toDate(date *time.Time) *Time {
+	if date == nil {
+		return &Time{NullFlavor: "UNK"}
+	}
+	return &Time{Value: date.Format(dateFormat)}
+}
+
+// attatchments are listed as notes which refer to the attatchment, rather than including its content
+func toNotesSection(attatchments []models.Attatchment, facility Facility, author Author) Section {
+	section := Section{
+		TemplateIds: []Id{{Root: templateNotesSection, Extension: versionNotes}},
+		Code:        Code{Code: "34109-9", CodeSystem: codeSystemLOINC, CodeSystemName: "LOINC", DisplayName: "Note"},
+		Title:       "Attatched Documents",
+	}
+	table := &Table{Head: TableHead{Row: TableHeadRow{Cells: []string{"Name", "Type", "Description", "Content Type", "Date"}}}}
+	for _, attatchment := range attatchments {
+		narrativeId := fmt.Sprintf("document-%v", attatchment.Id)
+		date := (*time.Time)(nil)
+		dateText := ""
+		if attatchment.Dicom != nil && !attatchment.Dicom.StudyDate.IsZero() {
+			date = &attatchment.Dicom.StudyDate
+			dateText = date.Format(time.DateOnly)
+		}
+		table.Body.Rows = append(table.Body.Rows, TableRow{Cells: []TableCell{
+			{Id: narrativeId, Value: attatchment.Name},
+			{Value: attatchment.Type},
+			{Value: attatchment.Description},
+			{Value: fhir.ContentType(attatchment)},
+			{Value: dateText},
+		}})
+		noteAuthor := author
+		section.Entries = append(section.Entries, Entry{Act: Act{
+			ClassCode:     "ACT",
+			MoodCode:      "EVN",
+			TemplateIds:   []Id{{Root: templateNoteActivity, Extension: versionNotes}},
+			Ids:           []Id{{Root: facility.noteRoot(), Extension: strconv.Itoa(attatchment.Id)}},
+			Code:          Code{Code: "34109-9", CodeSystem: codeSystemLOINC, CodeSystemName: "LOINC", DisplayName: "Note"},
+			Text:          &Text{Reference: ContentReference{Value: "#" + narrativeId}},
+			StatusCode:    Code{Code: "completed"},
+			EffectiveTime: *toDate(date),
+			Author:        &noteAuthor,
+			Reference: &Reference{TypeCode: "REFR", ExternalDocument: ExternalDocument{
+				ClassCode: "DOC",
+				MoodCode:  "EVN",
+				Id:        Id{Root: facility.attatchmentRoot(), Extension: strconv.Itoa(attatchment.Id)},
+				Code:      Code{Code: "34109-9", CodeSystem: codeSystemLOINC, CodeSystemName: "LOINC", DisplayName: "Note"},
+			}},
+		}})
+	}
+	if len(section.Entries) == 0 {
+		section.NullFlavor = "NI"
+		section.Text = Narrative{Paragraph: "No documents attatched."}
+		return section
+	}
+	section.Text = Narrative{Table: table}
+	return section
+}
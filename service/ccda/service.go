@@ -0,0 +1,58 @@
+package ccda
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type CcdaService struct {
+	patientSvc PatientService
+	facility   Facility
+	tracer     Tracer
+}
+
+func NewCcdaService(patientSvc PatientService, facility Facility, tracer Tracer) CcdaService {
+	return CcdaService{
+		patientSvc: patientSvc,
+		facility:   facility,
+		tracer:     tracer,
+	}
+}
+
+// the patient's Continuity of Care Document as XML.  Each document is given a new id, as it is a new document rather
+// than a version of an earlier one
+func (s CcdaService) GetContinuityOfCareDocument(ctx context.Context, patientId int) ([]byte, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetContinuityOfCareDocument")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	if s.facility.Validate() != nil {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewUnavailableError("C-CDA documents are unavailable until their custodian organization is configured"))
+	}
+	patient, err := s.patientSvc.GetPatient(ctx, patientId)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting patient %w", err))
+	}
+
+	document := ToContinuityOfCareDocument(patient, s.facility, newUUID(), time.Now())
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error encoding document %w", err))
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// a random (version 4) UUID
+func newUUID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
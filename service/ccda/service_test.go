@@ -0,0 +1,340 @@
+package ccda
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) GetPatient(ctx context.Context, patientId int) (models.Patient, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).(models.Patient), args.Error(1)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+var facility = Facility{Oid: "2.16.840.1.113883.19.5", Name: "Example Clinic", PhoneNumber: "555 123 4567", Address: "1 Main St"}
+
+func getMocksAndService() (*MockPatientService, CcdaService) {
+	mockPatientSvc := new(MockPatientService)
+	service := NewCcdaService(mockPatientSvc, facility, new(MockTracer))
+	return mockPatientSvc, service
+}
+
+// an element of a parsed document, so the tests check the XML that is produced rather than the structs it came from
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []node     `xml:",any"`
+}
+
+func (n node) attr(name string) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// the descendants of n reached by following the element names in path
+func (n node) find(path ...string) []node {
+	if len(path) == 0 {
+		return []node{n}
+	}
+	var found []node
+	for _, child := range n.Children {
+		if child.XMLName.Local == path[0] {
+			found = append(found, child.find(path[1:]...)...)
+		}
+	}
+	return found
+}
+
+func (n node) hasTemplate(root string, extension string) bool {
+	for _, template := range n.find("templateId") {
+		if template.attr("root") == root && template.attr("extension") == extension {
+			return true
+		}
+	}
+	return false
+}
+
+func (n node) ids() []string {
+	var ids []string
+	var collect func(node)
+	collect = func(n node) {
+		if id := n.attr("ID"); id != "" {
+			ids = append(ids, id)
+		}
+		for _, child := range n.Children {
+			collect(child)
+		}
+	}
+	collect(n)
+	return ids
+}
+
+func parse(t *testing.T, data []byte) node {
+	var document node
+	err := xml.NewDecoder(bytes.NewReader(data)).Decode(&document)
+	assert.Nil(t, err)
+	return document
+}
+
+func sectionWithTemplate(document node, root string) (node, bool) {
+	for _, section := range document.find("component", "structuredBody", "component", "section") {
+		for _, template := range section.find("templateId") {
+			if template.attr("root") == root {
+				return section, true
+			}
+		}
+	}
+	return node{}, false
+}
+
+func date(value string) time.Time {
+	parsed, _ := time.Parse(time.DateOnly, value)
+	return parsed
+}
+
+func TestFacilityValidate(t *testing.T) {
+	assert.Nil(t, facility.Validate())
+
+	invalid := facility
+	invalid.Oid = "2.16.840.01"
+	assert.Equal(t, `custodian OID "2.16.840.01" is not a valid OID`, invalid.Validate().Error())
+	invalid.Oid = "clinic"
+	assert.NotNil(t, invalid.Validate())
+
+	missing := facility
+	missing.Address = ""
+	assert.Equal(t, "custodian address is required", missing.Validate().Error())
+}
+
+func TestGetContinuityOfCareDocument(t *testing.T) {
+	onset := date("2019-03-01")
+	abatement := date("2023-06-30")
+	patient := models.Patient{
+		Id:                 7,
+		Name:               "Mary Ann Smith",
+		Address:            "12 High St",
+		PhoneNumber:        "555 987 6543",
+		DateOfBirth:        date("1980-05-17"),
+		ExternalIdentifier: "MRN-7",
+		Identifiers: []models.PatientIdentifier{
+			{System: models.IdentifierSystemExternal, Value: "MRN-7"},
+			{System: "urn:oid:2.16.840.1.113883.4.1", Value: "123-45-6789"},
+			{System: "http://example.com/partner", Value: "P-1"},
+		},
+		DiagnosedConditions: []models.DiagnosedCondition{
+			{Id: 1, Name: "Type 2 diabetes mellitus", Code: "E11.9", CodeSystem: models.CodeSystemICD10CM, Date: date("2020-01-02"), OnsetDate: &onset,
+				ClinicalStatus: models.ClinicalStatusActive, VerificationStatus: models.VerificationStatusConfirmed},
+			{Id: 2, Name: "Asthma", Code: "195967001", CodeSystem: models.CodeSystemSNOMEDCT, Date: date("2021-02-03"), AbatementDate: &abatement,
+				ClinicalStatus: models.ClinicalStatusResolved, VerificationStatus: models.VerificationStatusConfirmed},
+			{Id: 3, Name: "Hypertension", Code: "I10", CodeSystem: models.CodeSystemICD10CM, Date: date("2021-02-03"),
+				ClinicalStatus: models.ClinicalStatusActive, VerificationStatus: models.VerificationStatusEnteredInError},
+		},
+		Attatchments: []models.Attatchment{
+			{Id: 4, Name: "referral.txt", Type: "referral", Description: "referral letter", Data: []byte("Dear Dr Jones")},
+		},
+	}
+
+	t.Run("GetContinuityOfCareDocument_Header", func(t *testing.T) {
+		mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 7).Return(patient, nil)
+
+		data, err := service.GetContinuityOfCareDocument(context.Background(), 7)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(string(data), xml.Header))
+		document := parse(t, data)
+
+		assert.Equal(t, xml.Name{Space: "urn:hl7-org:v3", Local: "ClinicalDocument"}, document.XMLName)
+		assert.Equal(t, "US", document.find("realmCode")[0].attr("code"))
+		assert.Equal(t, "POCD_HD000040", document.find("typeId")[0].attr("extension"))
+		assert.True(t, document.hasTemplate(templateUSRealmHeader, "2015-08-01"))
+		assert.True(t, document.hasTemplate(templateCCD, "2015-08-01"))
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), document.find("id")[0].attr("root"))
+		assert.Equal(t, "34133-9", document.find("code")[0].attr("code"))
+		assert.Equal(t, "N", document.find("confidentialityCode")[0].attr("code"))
+		assert.Len(t, document.find("recordTarget"), 1)
+		assert.Len(t, document.find("author", "assignedAuthor", "assignedAuthoringDevice"), 1)
+		assert.Equal(t, facility.Oid, document.find("custodian", "assignedCustodian", "representedCustodianOrganization", "id")[0].attr("root"))
+		assert.Equal(t, "19800517", document.find("documentationOf", "serviceEvent", "effectiveTime", "low")[0].attr("value"))
+	})
+
+	t.Run("GetContinuityOfCareDocument_Demographics", func(t *testing.T) {
+		mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 7).Return(patient, nil)
+
+		data, _ := service.GetContinuityOfCareDocument(context.Background(), 7)
+		patientRole := parse(t, data).find("recordTarget", "patientRole")[0]
+
+		ids := patientRole.find("id")
+		assert.Equal(t, facility.Oid+".1", ids[0].attr("root"))
+		assert.Equal(t, "7", ids[0].attr("extension"))
+		assert.Equal(t, "MRN-7", ids[1].attr("extension"))
+		assert.Len(t, ids, 3)
+		assert.Equal(t, "2.16.840.1.113883.4.1", ids[2].attr("root"))
+		assert.Equal(t, "123-45-6789", ids[2].attr("extension"))
+		assert.Equal(t, "12 High St", patientRole.find("addr", "streetAddressLine")[0].Text)
+		assert.Equal(t, "tel:5559876543", patientRole.find("telecom")[0].attr("value"))
+		assert.Equal(t, []string{"Mary", "Ann"}, []string{patientRole.find("patient", "name", "given")[0].Text, patientRole.find("patient", "name", "given")[1].Text})
+		assert.Equal(t, "Smith", patientRole.find("patient", "name", "family")[0].Text)
+		assert.Equal(t, "UNK", patientRole.find("patient", "administrativeGenderCode")[0].attr("nullFlavor"))
+		assert.Equal(t, "19800517", patientRole.find("patient", "birthTime")[0].attr("value"))
+	})
+
+	t.Run("GetContinuityOfCareDocument_RequiredSections", func(t *testing.T) {
+		mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 7).Return(patient, nil)
+
+		data, _ := service.GetContinuityOfCareDocument(context.Background(), 7)
+		document := parse(t, data)
+
+		for _, template := range []string{templateAllergiesSection, templateMedicationsSection, templateProblemSection} {
+			section, found := sectionWithTemplate(document, template)
+			assert.True(t, found, template)
+			assert.Len(t, section.find("code"), 1)
+			assert.Len(t, section.find("title"), 1)
+			assert.Len(t, section.find("text"), 1)
+		}
+		allergies, _ := sectionWithTemplate(document, templateAllergiesSection)
+		assert.Equal(t, "NI", allergies.attr("nullFlavor"))
+		assert.Empty(t, allergies.find("entry"))
+	})
+
+	t.Run("GetContinuityOfCareDocument_Problems", func(t *testing.T) {
+		mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 7).Return(patient, nil)
+
+		data, _ := service.GetContinuityOfCareDocument(context.Background(), 7)
+		section, _ := sectionWithTemplate(parse(t, data), templateProblemSection)
+
+		assert.True(t, section.hasTemplate(templateProblemSection, "2015-08-01"))
+		assert.Equal(t, "11450-4", section.find("code")[0].attr("code"))
+		assert.Empty(t, section.attr("nullFlavor"))
+		narrativeIds := section.find("text")[0].ids()
+
+		concerns := section.find("entry", "act")
+		assert.Len(t, concerns, 2)
+		for _, concern := range concerns {
+			assert.True(t, concern.hasTemplate(templateProblemConcernAct, "2015-08-01"))
+			assert.Equal(t, "CONC", concern.find("code")[0].attr("code"))
+			assert.Len(t, concern.find("effectiveTime", "low"), 1)
+
+			observation := concern.find("entryRelationship", "observation")[0]
+			assert.Equal(t, "SUBJ", concern.find("entryRelationship")[0].attr("typeCode"))
+			assert.True(t, observation.hasTemplate(templateProblemObservation, "2015-08-01"))
+			assert.Equal(t, "55607006", observation.find("code")[0].attr("code"))
+			assert.Equal(t, "completed", observation.find("statusCode")[0].attr("code"))
+			assert.Len(t, observation.find("effectiveTime", "low"), 1)
+			assert.Equal(t, "CD", observation.find("value")[0].attr("type"))
+
+			reference := strings.TrimPrefix(observation.find("text", "reference")[0].attr("value"), "#")
+			assert.Contains(t, narrativeIds, reference)
+		}
+
+		diabetes := concerns[0]
+		assert.Equal(t, "active", diabetes.find("statusCode")[0].attr("code"))
+		assert.Empty(t, diabetes.find("effectiveTime", "high"))
+		diabetesObservation := diabetes.find("entryRelationship", "observation")[0]
+		assert.Equal(t, "20190301", diabetesObservation.find("effectiveTime", "low")[0].attr("value"))
+		assert.Equal(t, "E11.9", diabetesObservation.find("value")[0].attr("code"))
+		assert.Equal(t, codeSystemICD10CM, diabetesObservation.find("value")[0].attr("codeSystem"))
+
+		asthma := concerns[1]
+		assert.Equal(t, "completed", asthma.find("statusCode")[0].attr("code"))
+		assert.Equal(t, "20230630", asthma.find("effectiveTime", "high")[0].attr("value"))
+		asthmaObservation := asthma.find("entryRelationship", "observation")[0]
+		assert.Equal(t, "UNK", asthmaObservation.find("effectiveTime", "low")[0].attr("nullFlavor"))
+		assert.Equal(t, codeSystemSNOMEDCT, asthmaObservation.find("value")[0].attr("codeSystem"))
+	})
+
+	t.Run("GetContinuityOfCareDocument_Documents", func(t *testing.T) {
+		mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 7).Return(patient, nil)
+
+		data, _ := service.GetContinuityOfCareDocument(context.Background(), 7)
+		section, found := sectionWithTemplate(parse(t, data), templateNotesSection)
+		assert.True(t, found)
+		assert.True(t, section.hasTemplate(templateNotesSection, "2016-11-01"))
+
+		notes := section.find("entry", "act")
+		assert.Len(t, notes, 1)
+		assert.True(t, notes[0].hasTemplate(templateNoteActivity, "2016-11-01"))
+		assert.Len(t, notes[0].find("author"), 1)
+		reference := strings.TrimPrefix(notes[0].find("text", "reference")[0].attr("value"), "#")
+		assert.Contains(t, section.find("text")[0].ids(), reference)
+		externalDocument := notes[0].find("reference", "externalDocument")[0]
+		assert.Equal(t, facility.Oid+".5", externalDocument.find("id")[0].attr("root"))
+		assert.Equal(t, "4", externalDocument.find("id")[0].attr("extension"))
+		assert.Contains(t, string(data), "referral.txt")
+	})
+
+	t.Run("GetContinuityOfCareDocument_NothingRecorded", func(t *testing.T) {
+		mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 8).Return(models.Patient{Id: 8, Name: "Sam", DateOfBirth: date("2000-01-01")}, nil)
+
+		data, err := service.GetContinuityOfCareDocument(context.Background(), 8)
+		assert.Nil(t, err)
+		document := parse(t, data)
+
+		assert.Equal(t, "UNK", document.find("recordTarget", "patientRole", "addr")[0].attr("nullFlavor"))
+		assert.Equal(t, "Sam", document.find("recordTarget", "patientRole", "patient", "name", "family")[0].Text)
+		for _, template := range []string{templateProblemSection, templateNotesSection} {
+			section, _ := sectionWithTemplate(document, template)
+			assert.Equal(t, "NI", section.attr("nullFlavor"))
+			assert.Empty(t, section.find("entry"))
+		}
+	})
+
+	t.Run("GetContinuityOfCareDocument_CustodianNotConfigured", func(t *testing.T) {
+		mockPatientSvc := new(MockPatientService)
+		service := NewCcdaService(mockPatientSvc, Facility{}, new(MockTracer))
+
+		_, err := service.GetContinuityOfCareDocument(context.Background(), 9)
+		var unavailableError customerrors.UnavailableError
+		assert.True(t, errors.As(err, &unavailableError))
+		mockPatientSvc.AssertNotCalled(t, "GetPatient", mock.Anything, mock.Anything)
+	})
+
+	t.Run("GetContinuityOfCareDocument_PatientNotFound", func(t *testing.T) {
+		mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 9).Return(models.Patient{}, customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.GetContinuityOfCareDocument(context.Background(), 9)
+		var inputError customerrors.InvalidInputError
+		assert.True(t, errors.As(err, &inputError))
+	})
+}
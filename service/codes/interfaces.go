@@ -0,0 +1,14 @@
+package codes
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
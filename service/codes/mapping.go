@@ -0,0 +1,104 @@
+package codes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// a map between SNOMED CT concepts and ICD-10-CM codes.  A concept may map to several codes and a code to several concepts
+type Mapping struct {
+	snomedNames map[string]string
+	snomedToICD map[string][]string
+	icdToSNOMED map[string][]string
+}
+
+// loads the SNOMED CT to ICD-10-CM map from path, or the map bundled with the application when path is empty
+func NewSNOMEDMapping(path string) (Mapping, error) {
+	var file io.ReadCloser
+	var err error
+	if path == "" {
+		file, err = data.Open("data/snomed_icd10cm.tsv")
+	} else {
+		file, err = os.Open(path)
+	}
+	if err != nil {
+		return Mapping{}, fmt.Errorf("error opening SNOMED CT map %w", err)
+	}
+	defer file.Close()
+	return NewMapping(file)
+}
+
+// reads a map of tab separated SNOMED CT concept id, concept name and ICD-10-CM code, one mapping per line
+func NewMapping(table io.Reader) (Mapping, error) {
+	m := Mapping{
+		snomedNames: make(map[string]string),
+		snomedToICD: make(map[string][]string),
+		icdToSNOMED: make(map[string][]string),
+	}
+
+	scanner := bufio.NewScanner(table)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 3 || !isValidSNOMEDId(strings.TrimSpace(fields[0])) {
+			return Mapping{}, fmt.Errorf("invalid SNOMED CT map entry on line %v", line)
+		}
+		concept := strings.TrimSpace(fields[0])
+		code := NormalizeCode(fields[2])
+		m.snomedNames[concept] = strings.TrimSpace(fields[1])
+		m.snomedToICD[concept] = append(m.snomedToICD[concept], code)
+		m.icdToSNOMED[code] = append(m.icdToSNOMED[code], concept)
+	}
+	if err := scanner.Err(); err != nil {
+		return Mapping{}, fmt.Errorf("error reading SNOMED CT map %w", err)
+	}
+	return m, nil
+}
+
+var verhoeffMultiplication = [10][10]int{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+	{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+	{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+	{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+	{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+	{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+	{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+	{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+	{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+}
+
+var verhoeffPermutation = [8][10]int{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+	{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+	{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+	{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+	{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+	{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+	{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+}
+
+// SNOMED CT identifiers are 6 to 18 digits, the last of which is a Verhoeff check digit
+func isValidSNOMEDId(id string) bool {
+	if len(id) < 6 || len(id) > 18 {
+		return false
+	}
+	check := 0
+	for i := 0; i < len(id); i++ {
+		digit := id[len(id)-1-i]
+		if digit < '0' || digit > '9' {
+			return false
+		}
+		check = verhoeffMultiplication[check][verhoeffPermutation[i%8][digit-'0']]
+	}
+	return check == 0
+}
@@ -0,0 +1,225 @@
+package codes
+
+import (
+	"bufio"
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+//go:embed data
+var data embed.FS
+
+const defaultSearchLimit = 20
+
+type Service struct {
+	codes  map[string]models.Code
+	sorted []models.Code
+	//whether the table holds every code.  When it doesn't, well formed codes missing from it are accepted without a name
+	complete bool
+	tracer   Tracer
+}
+
+// the canonical form of an ICD-10-CM code: a letter, a digit and a letter or digit, followed by up to four more after a dot
+var icd10Format = regexp.MustCompile(`^[A-Z][0-9][0-9A-Z](\.[0-9A-Z]{1,4})?$`)
+
+// loads the full ICD-10-CM code table from path, such as the codes file of the CMS release.  When path is empty the
+// sample table bundled with the application is used, and codes missing from it are only checked to be well formed
+func NewICD10Service(path string, tracer Tracer) (Service, error) {
+	if path == "" {
+		file, err := data.Open("data/icd10cm.tsv")
+		if err != nil {
+			return Service{}, fmt.Errorf("error opening icd10cm code table %w", err)
+		}
+		defer file.Close()
+		s, err := NewService(file, tracer)
+		s.complete = false
+		return s, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Service{}, fmt.Errorf("error opening icd10cm code table %w", err)
+	}
+	defer file.Close()
+	return NewService(file, tracer)
+}
+
+// reads a complete code table of code and name pairs, one per line.  The code is separated from its name by a tab, or
+// by spaces as in the codes file of the CMS release
+func NewService(table io.Reader, tracer Tracer) (Service, error) {
+	s := Service{
+		codes:    make(map[string]models.Code),
+		complete: true,
+		tracer:   tracer,
+	}
+
+	scanner := bufio.NewScanner(table)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		code, name, found := strings.Cut(text, "\t")
+		if !found {
+			code, name, found = strings.Cut(text, " ")
+		}
+		if !found {
+			return Service{}, fmt.Errorf("invalid code table entry on line %v", line)
+		}
+		entry := models.Code{
+			Code: NormalizeCode(code),
+			Name: strings.TrimSpace(name),
+		}
+		s.codes[entry.Code] = entry
+		s.sorted = append(s.sorted, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return Service{}, fmt.Errorf("error reading code table %w", err)
+	}
+
+	sort.Slice(s.sorted, func(i, j int) bool {
+		return s.sorted[i].Code < s.sorted[j].Code
+	})
+	return s, nil
+}
+
+// converts a code to its canonical upper case, dotted form, for example e119 to E11.9
+func NormalizeCode(code string) string {
+	code = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), ".", ""))
+	if len(code) > 3 {
+		code = code[:3] + "." + code[3:]
+	}
+	return code
+}
+
+func (s Service) Lookup(ctx context.Context, code string) (models.Code, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "LookupCode")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("code", code))
+
+	normalized := NormalizeCode(code)
+	entry, exists := s.codes[normalized]
+	if exists {
+		return entry, nil
+	}
+	if !s.complete && icd10Format.MatchString(normalized) {
+		return models.Code{Code: normalized}, nil
+	}
+	return models.Code{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%v is not a valid ICD-10-CM code", code)))
+}
+
+// finds codes starting with the query, followed by codes whose name contains every word of the query
+func (s Service) Search(ctx context.Context, query string, limit int) ([]models.Code, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "SearchCodes")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.String("query", query),
+		attribute.Int("limit", limit))
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("query must not be empty"))
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	results := []models.Code{}
+	codePrefix := NormalizeCode(query)
+	for _, entry := range s.sorted {
+		if len(results) == limit {
+			return results, nil
+		}
+		if strings.HasPrefix(entry.Code, codePrefix) {
+			results = append(results, entry)
+		}
+	}
+
+	words := strings.Fields(strings.ToLower(query))
+	for _, entry := range s.sorted {
+		if len(results) == limit {
+			break
+		}
+		if strings.HasPrefix(entry.Code, codePrefix) {
+			continue
+		}
+		name := strings.ToLower(entry.Name)
+		matches := true
+		for _, word := range words {
+			if !strings.Contains(name, word) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			results = append(results, entry)
+		}
+	}
+	return results, nil
+}
+
+// returns the code and every code below it in the hierarchy.  A category such as E11 matches E11, E11.9, E11.65 and so on
+func (s Service) Descendants(ctx context.Context, prefix string) ([]string, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "CodeDescendants")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("prefix", prefix))
+
+	prefix = NormalizeCode(prefix)
+	if prefix == "" {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("code prefix must not be empty"))
+	}
+
+	var codes []string
+	for i := sort.Search(len(s.sorted), func(i int) bool { return s.sorted[i].Code >= prefix }); i < len(s.sorted); i++ {
+		if !strings.HasPrefix(s.sorted[i].Code, prefix) {
+			break
+		}
+		codes = append(codes, s.sorted[i].Code)
+	}
+	if len(codes) == 0 {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("no ICD-10-CM codes start with %v", prefix)))
+	}
+	return codes, nil
+}
+
+// returns every code from the start of the range up to and including the end of the range and its descendants,
+// so E08-E13 covers all of the diabetes mellitus categories
+func (s Service) Range(ctx context.Context, codeRange string) ([]string, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "CodeRange")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("range", codeRange))
+
+	from, to, found := strings.Cut(codeRange, "-")
+	from, to = NormalizeCode(from), NormalizeCode(to)
+	if !found || from == "" || to == "" {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%v is not a code range, expected a range such as E08-E13", codeRange)))
+	}
+	if to < from {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("range %v ends before it starts", codeRange)))
+	}
+
+	var codes []string
+	for i := sort.Search(len(s.sorted), func(i int) bool { return s.sorted[i].Code >= from }); i < len(s.sorted); i++ {
+		code := s.sorted[i].Code
+		if code > to && !strings.HasPrefix(code, to) {
+			break
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("no ICD-10-CM codes are in the range %v", codeRange)))
+	}
+	return codes, nil
+}
@@ -0,0 +1,277 @@
+package codes
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func TestNewICD10Service(t *testing.T) {
+	t.Run("NewICD10Service_Bundled", func(t *testing.T) {
+		service, err := NewICD10Service("", new(MockTracer))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, service.codes)
+		assert.False(t, service.complete)
+		for code := range service.codes {
+			assert.Equal(t, NormalizeCode(code), code)
+		}
+	})
+
+	t.Run("NewICD10Service_Path", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "icd10cm_codes.txt")
+		assert.Nil(t, os.WriteFile(path, []byte("E11649  Type 2 diabetes mellitus with hypoglycemia without coma\n"), 0600))
+
+		service, err := NewICD10Service(path, new(MockTracer))
+		assert.Nil(t, err)
+		assert.True(t, service.complete)
+		assert.Equal(t, map[string]models.Code{
+			"E11.649": {Code: "E11.649", Name: "Type 2 diabetes mellitus with hypoglycemia without coma"},
+		}, service.codes)
+	})
+
+	t.Run("NewICD10Service_MissingPath", func(t *testing.T) {
+		_, err := NewICD10Service(filepath.Join(t.TempDir(), "missing.txt"), new(MockTracer))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestNewService(t *testing.T) {
+	t.Run("NewService_InvalidEntry", func(t *testing.T) {
+		_, err := NewService(strings.NewReader("E11.9\n"), new(MockTracer))
+		assert.NotNil(t, err)
+		assert.Equal(t, "invalid code table entry on line 1", err.Error())
+	})
+}
+
+func TestNormalizeCode(t *testing.T) {
+	assert.Equal(t, "E11.9", NormalizeCode("e119"))
+	assert.Equal(t, "E11.9", NormalizeCode(" E11.9 "))
+	assert.Equal(t, "E11", NormalizeCode("E11"))
+	assert.Equal(t, "J45.909", NormalizeCode("J45909"))
+}
+
+func TestLookup(t *testing.T) {
+	service, err := NewService(strings.NewReader("E11\tType 2 diabetes mellitus\nE11.9\tType 2 diabetes mellitus without complications\n"), new(MockTracer))
+	assert.Nil(t, err)
+
+	t.Run("Lookup_Success", func(t *testing.T) {
+		code, err := service.Lookup(context.Background(), "e119")
+		assert.Nil(t, err)
+		assert.Equal(t, "E11.9", code.Code)
+		assert.Equal(t, "Type 2 diabetes mellitus without complications", code.Name)
+	})
+
+	t.Run("Lookup_UnknownCode", func(t *testing.T) {
+		code, err := service.Lookup(context.Background(), "ABCD")
+		assert.NotNil(t, err)
+		assert.Equal(t, "ABCD is not a valid ICD-10-CM code", err.Error())
+		assert.Empty(t, code)
+	})
+
+	t.Run("Lookup_UnknownCodeInCompleteTable", func(t *testing.T) {
+		code, err := service.Lookup(context.Background(), "E11.649")
+		assert.NotNil(t, err)
+		assert.Equal(t, "E11.649 is not a valid ICD-10-CM code", err.Error())
+		assert.Empty(t, code)
+	})
+
+	t.Run("Lookup_UnknownCodeInSampleTable", func(t *testing.T) {
+		sample := service
+		sample.complete = false
+
+		code, err := sample.Lookup(context.Background(), "e11649")
+		assert.Nil(t, err)
+		assert.Equal(t, models.Code{Code: "E11.649"}, code)
+
+		_, err = sample.Lookup(context.Background(), "ABCD")
+		assert.NotNil(t, err)
+		assert.Equal(t, "ABCD is not a valid ICD-10-CM code", err.Error())
+	})
+}
+
+func TestSearch(t *testing.T) {
+	table := "E11\tType 2 diabetes mellitus\n" +
+		"E11.9\tType 2 diabetes mellitus without complications\n" +
+		"E10.9\tType 1 diabetes mellitus without complications\n" +
+		"I10\tEssential (primary) hypertension\n"
+	service, err := NewService(strings.NewReader(table), new(MockTracer))
+	assert.Nil(t, err)
+
+	t.Run("Search_CodePrefix", func(t *testing.T) {
+		codes, err := service.Search(context.Background(), "e11", 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(codes))
+		assert.Equal(t, "E11", codes[0].Code)
+		assert.Equal(t, "E11.9", codes[1].Code)
+	})
+
+	t.Run("Search_Name", func(t *testing.T) {
+		codes, err := service.Search(context.Background(), "diabetes without", 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(codes))
+		assert.Equal(t, "E10.9", codes[0].Code)
+		assert.Equal(t, "E11.9", codes[1].Code)
+	})
+
+	t.Run("Search_Limit", func(t *testing.T) {
+		codes, err := service.Search(context.Background(), "diabetes", 1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(codes))
+	})
+
+	t.Run("Search_EmptyQuery", func(t *testing.T) {
+		codes, err := service.Search(context.Background(), " ", 0)
+		assert.NotNil(t, err)
+		assert.Equal(t, "query must not be empty", err.Error())
+		assert.Nil(t, codes)
+	})
+}
+
+func TestHierarchy(t *testing.T) {
+	table := "E08.9\tDiabetes mellitus due to underlying condition without complications\n" +
+		"E10.9\tType 1 diabetes mellitus without complications\n" +
+		"E11\tType 2 diabetes mellitus\n" +
+		"E11.65\tType 2 diabetes mellitus with hyperglycemia\n" +
+		"E11.9\tType 2 diabetes mellitus without complications\n" +
+		"E13.9\tOther specified diabetes mellitus without complications\n" +
+		"E15\tNondiabetic hypoglycemic coma\n"
+	service, err := NewService(strings.NewReader(table), new(MockTracer))
+	assert.Nil(t, err)
+
+	t.Run("Descendants_Category", func(t *testing.T) {
+		codes, err := service.Descendants(context.Background(), "e11")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"E11", "E11.65", "E11.9"}, codes)
+	})
+
+	t.Run("Descendants_Unknown", func(t *testing.T) {
+		codes, err := service.Descendants(context.Background(), "Z99")
+		assert.NotNil(t, err)
+		assert.Equal(t, "no ICD-10-CM codes start with Z99", err.Error())
+		assert.Nil(t, codes)
+	})
+
+	t.Run("Range_IncludesDescendantsOfEnd", func(t *testing.T) {
+		codes, err := service.Range(context.Background(), "E08-E13")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"E08.9", "E10.9", "E11", "E11.65", "E11.9", "E13.9"}, codes)
+	})
+
+	t.Run("Range_Reversed", func(t *testing.T) {
+		codes, err := service.Range(context.Background(), "E13-E08")
+		assert.NotNil(t, err)
+		assert.Equal(t, "range E13-E08 ends before it starts", err.Error())
+		assert.Nil(t, codes)
+	})
+
+	t.Run("Range_Malformed", func(t *testing.T) {
+		codes, err := service.Range(context.Background(), "E08")
+		assert.NotNil(t, err)
+		assert.Equal(t, "E08 is not a code range, expected a range such as E08-E13", err.Error())
+		assert.Nil(t, codes)
+	})
+}
+
+func TestNewSNOMEDMapping(t *testing.T) {
+	mapping, err := NewSNOMEDMapping("")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, mapping.snomedToICD)
+
+	icd10, err := NewICD10Service("", new(MockTracer))
+	assert.Nil(t, err)
+	for _, codes := range mapping.snomedToICD {
+		for _, code := range codes {
+			_, exists := icd10.codes[code]
+			assert.True(t, exists, "%v is mapped to but not in the ICD-10-CM table", code)
+		}
+	}
+
+	t.Run("NewSNOMEDMapping_MissingFile", func(t *testing.T) {
+		_, err := NewSNOMEDMapping("missing.tsv")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("NewMapping_InvalidConceptId", func(t *testing.T) {
+		_, err := NewMapping(strings.NewReader("44054007\tDiabetes mellitus type 2\tE11.9\n"))
+		assert.NotNil(t, err)
+		assert.Equal(t, "invalid SNOMED CT map entry on line 1", err.Error())
+	})
+}
+
+func TestTerminology(t *testing.T) {
+	icd10, err := NewService(strings.NewReader("E11.9\tType 2 diabetes mellitus without complications\nI10\tEssential (primary) hypertension\n"), new(MockTracer))
+	assert.Nil(t, err)
+	mapping, err := NewMapping(strings.NewReader("44054006\tDiabetes mellitus type 2\tE11.9\n38341003\tHypertensive disorder, systemic arterial\tI10\n59621000\tEssential hypertension\tI10\n"))
+	assert.Nil(t, err)
+	terminology := NewTerminology(icd10, mapping)
+
+	t.Run("LookupCoding_DefaultsToICD10", func(t *testing.T) {
+		code, err := terminology.LookupCoding(context.Background(), "", "e119")
+		assert.Nil(t, err)
+		assert.Equal(t, "E11.9", code.Code)
+	})
+
+	t.Run("LookupCoding_MappedSNOMED", func(t *testing.T) {
+		code, err := terminology.LookupCoding(context.Background(), models.CodeSystemSNOMEDCT, "44054006")
+		assert.Nil(t, err)
+		assert.Equal(t, models.Code{Code: "44054006", Name: "Diabetes mellitus type 2"}, code)
+	})
+
+	t.Run("LookupCoding_UnmappedSNOMED", func(t *testing.T) {
+		code, err := terminology.LookupCoding(context.Background(), models.CodeSystemSNOMEDCT, "195967001")
+		assert.Nil(t, err)
+		assert.Equal(t, models.Code{Code: "195967001"}, code)
+	})
+
+	t.Run("LookupCoding_InvalidCheckDigit", func(t *testing.T) {
+		code, err := terminology.LookupCoding(context.Background(), models.CodeSystemSNOMEDCT, "44054007")
+		assert.NotNil(t, err)
+		assert.Equal(t, "44054007 is not a valid SNOMED CT concept id", err.Error())
+		assert.Empty(t, code)
+	})
+
+	t.Run("LookupCoding_UnsupportedSystem", func(t *testing.T) {
+		code, err := terminology.LookupCoding(context.Background(), "LOINC", "1234-5")
+		assert.NotNil(t, err)
+		assert.Equal(t, "unsupported code system LOINC", err.Error())
+		assert.Empty(t, code)
+	})
+
+	t.Run("Expand", func(t *testing.T) {
+		codings := terminology.Expand(context.Background(), []models.Coding{
+			{System: models.CodeSystemICD10CM, Code: "i10"},
+			{System: models.CodeSystemSNOMEDCT, Code: "44054006"},
+			{System: models.CodeSystemSNOMEDCT, Code: "38341003"},
+		})
+		assert.Equal(t, []models.Coding{
+			{System: models.CodeSystemICD10CM, Code: "I10"},
+			{System: models.CodeSystemSNOMEDCT, Code: "38341003"},
+			{System: models.CodeSystemSNOMEDCT, Code: "59621000"},
+			{System: models.CodeSystemSNOMEDCT, Code: "44054006"},
+			{System: models.CodeSystemICD10CM, Code: "E11.9"},
+		}, codings)
+	})
+}
@@ -0,0 +1,79 @@
+package codes
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// the code systems a condition can be recorded in, along with the map between them
+type Terminology struct {
+	Service
+	snomed Mapping
+}
+
+func NewTerminology(icd10 Service, snomed Mapping) Terminology {
+	return Terminology{
+		Service: icd10,
+		snomed:  snomed,
+	}
+}
+
+// validates a code in the given system, defaulting to ICD-10-CM, and returns it in its canonical form.
+// The name is empty for SNOMED CT concepts which are not in the map
+func (t Terminology) LookupCoding(ctx context.Context, system string, code string) (models.Code, error) {
+	switch system {
+	case "", models.CodeSystemICD10CM:
+		return t.Lookup(ctx, code)
+	case models.CodeSystemSNOMEDCT:
+	default:
+		return models.Code{}, customerrors.NewInvalidInputError(fmt.Sprintf("unsupported code system %v", system))
+	}
+
+	ctx, span := t.tracer.NewSpan(ctx, "LookupSNOMEDCode")
+	defer span.End()
+	t.tracer.SetAttributes(ctx, attribute.String("code", code))
+
+	code = strings.TrimSpace(code)
+	if !isValidSNOMEDId(code) {
+		return models.Code{}, t.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%v is not a valid SNOMED CT concept id", code)))
+	}
+	return models.Code{Code: code, Name: t.snomed.snomedNames[code]}, nil
+}
+
+// adds the codes which the given codes map to in the other code system
+func (t Terminology) Expand(ctx context.Context, codings []models.Coding) []models.Coding {
+	ctx, span := t.tracer.NewSpan(ctx, "ExpandCodings")
+	defer span.End()
+	t.tracer.SetAttributes(ctx, attribute.Int("codings", len(codings)))
+
+	seen := make(map[models.Coding]bool)
+	var expanded []models.Coding
+	add := func(coding models.Coding) {
+		if !seen[coding] {
+			seen[coding] = true
+			expanded = append(expanded, coding)
+		}
+	}
+	for _, coding := range codings {
+		switch coding.System {
+		case models.CodeSystemSNOMEDCT:
+			concept := strings.TrimSpace(coding.Code)
+			add(models.Coding{System: models.CodeSystemSNOMEDCT, Code: concept})
+			for _, code := range t.snomed.snomedToICD[concept] {
+				add(models.Coding{System: models.CodeSystemICD10CM, Code: code})
+			}
+		default:
+			code := NormalizeCode(coding.Code)
+			add(models.Coding{System: models.CodeSystemICD10CM, Code: code})
+			for _, concept := range t.snomed.icdToSNOMED[code] {
+				add(models.Coding{System: models.CodeSystemSNOMEDCT, Code: concept})
+			}
+		}
+	}
+	return expanded
+}
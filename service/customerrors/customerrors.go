@@ -38,6 +38,24 @@ func NewInvalidInputError(message string) InvalidInputError {
 	}
 }
 
+type NotFoundError struct {
+	message string
+}
+
+func (r NotFoundError) Error() string {
+	return r.message
+}
+
+func (r NotFoundError) HTTPStatus() int {
+	return http.StatusNotFound
+}
+
+func NewNotFoundError(message string) NotFoundError {
+	return NotFoundError{
+		message: message,
+	}
+}
+
 type UnauthorizedError struct {
 	message string
 }
@@ -55,3 +73,21 @@ func NewUnauthorizedError(message string) UnauthorizedError {
 		message: message,
 	}
 }
+
+type UnavailableError struct {
+	message string
+}
+
+func (r UnavailableError) Error() string {
+	return r.message
+}
+
+func (r UnavailableError) HTTPStatus() int {
+	return http.StatusServiceUnavailable
+}
+
+func NewUnavailableError(message string) UnavailableError {
+	return UnavailableError{
+		message: message,
+	}
+}
@@ -12,12 +12,26 @@ type DiagnosedConditionRepo interface {
 	InsertDiagnosedCondition(ctx context.Context, condition models.DiagnosedCondition) (int, error)
 	DeleteDiagnosedCondition(ctx context.Context, conditionId int) error
 	DeleteDiagnosedConditionsByPatientId(ctx context.Context, patientId int) error
+	ReassignDiagnosedConditions(ctx context.Context, fromPatientId int, toPatientId int) error
+	RestoreDiagnosedCondition(ctx context.Context, conditionId int) error
+	GetDiagnosedCondition(ctx context.Context, conditionId int) (models.DiagnosedCondition, error)
+	UpdateDiagnosedCondition(ctx context.Context, condition models.DiagnosedCondition) error
+	GetDiagnosedConditionsByPatientId(ctx context.Context, search models.DiagnosedConditionSearch) ([]models.DiagnosedCondition, error)
 }
 
 type PatientService interface {
 	ValidatePatientId(ctx context.Context, patientId int) error
 }
 
+type EncounterService interface {
+	ValidateEncounterId(ctx context.Context, patientId int, encounterId int) error
+}
+
+type CodeService interface {
+	LookupCoding(ctx context.Context, system string, code string) (models.Code, error)
+	Expand(ctx context.Context, codings []models.Coding) []models.Coding
+}
+
 type Tracer interface {
 	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
 	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
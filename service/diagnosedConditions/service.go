@@ -3,6 +3,7 @@ package diagnosedconditions
 import (
 	"context"
 	"fmt"
+	"mcg-app-backend/service/customerrors"
 	"mcg-app-backend/service/models"
 	"time"
 
@@ -10,40 +11,87 @@ import (
 )
 
 type DiagnosedConditionService struct {
-	repo       DiagnosedConditionRepo
-	patientSvc PatientService
-	tracer     Tracer
+	repo         DiagnosedConditionRepo
+	patientSvc   PatientService
+	encounterSvc EncounterService
+	codeSvc      CodeService
+	tracer       Tracer
 }
 
-func NewDiagnosedConditionService(repo DiagnosedConditionRepo, patientSvc PatientService, tracer Tracer) DiagnosedConditionService {
+func NewDiagnosedConditionService(repo DiagnosedConditionRepo, patientSvc PatientService, encounterSvc EncounterService, codeSvc CodeService, tracer Tracer) DiagnosedConditionService {
 	return DiagnosedConditionService{
-		repo:       repo,
-		patientSvc: patientSvc,
-		tracer:     tracer,
+		repo:         repo,
+		patientSvc:   patientSvc,
+		encounterSvc: encounterSvc,
+		codeSvc:      codeSvc,
+		tracer:       tracer,
 	}
 }
 
-func (s DiagnosedConditionService) AddDiagnosedConditionToPatient(ctx context.Context, patientId int, name string, code string, description string, date time.Time) (models.DiagnosedCondition, error) {
+// an encounter id of 0 means the condition was not diagnosed at an encounter
+func (s DiagnosedConditionService) validateEncounter(ctx context.Context, patientId int, encounterId int) error {
+	if encounterId == 0 {
+		return nil
+	}
+	return s.encounterSvc.ValidateEncounterId(ctx, patientId, encounterId)
+}
+
+// replaces the code with its canonical form and fills in the name of the code when none was given
+func (s DiagnosedConditionService) applyCode(ctx context.Context, condition *models.DiagnosedCondition) error {
+	if condition.CodeSystem == "" {
+		condition.CodeSystem = models.CodeSystemICD10CM
+	}
+	code, err := s.codeSvc.LookupCoding(ctx, condition.CodeSystem, condition.Code)
+	if err != nil {
+		return err
+	}
+	condition.Code = code.Code
+	if condition.Name == "" {
+		condition.Name = code.Name
+	}
+	if condition.Name == "" {
+		return s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("name is required as %v code %v has no known name", condition.CodeSystem, condition.Code)))
+	}
+	return nil
+}
+
+func (s DiagnosedConditionService) AddDiagnosedConditionToPatient(ctx context.Context, patientId int, name string, code string, codeSystem string, description string, date time.Time, onsetDate *time.Time, encounterId int) (models.DiagnosedCondition, error) {
 	ctx, span := s.tracer.NewSpan(ctx, "AddDiagnosedConditionToPatient")
 	defer span.End()
 	s.tracer.SetAttributes(ctx,
 		attribute.Int("patientId", patientId),
 		attribute.String("name", name),
 		attribute.String("code", code),
+		attribute.String("codeSystem", codeSystem),
 		attribute.String("description", description),
-		attribute.String("date", fmt.Sprintf("%v", date)))
+		attribute.String("date", fmt.Sprintf("%v", date)),
+		attribute.String("onsetDate", fmt.Sprintf("%v", onsetDate)),
+		attribute.Int("encounterId", encounterId))
 
 	err := s.patientSvc.ValidatePatientId(ctx, patientId)
 	if err != nil {
 		return models.DiagnosedCondition{}, err
 	}
+	err = s.validateEncounter(ctx, patientId, encounterId)
+	if err != nil {
+		return models.DiagnosedCondition{}, err
+	}
 
 	condition := models.DiagnosedCondition{
-		PatientId:   patientId,
-		Name:        name,
-		Code:        code,
-		Description: description,
-		Date:        date,
+		PatientId:          patientId,
+		Name:               name,
+		Code:               code,
+		CodeSystem:         codeSystem,
+		Description:        description,
+		Date:               date,
+		OnsetDate:          onsetDate,
+		ClinicalStatus:     models.ClinicalStatusActive,
+		VerificationStatus: models.VerificationStatusConfirmed,
+		EncounterId:        encounterId,
+	}
+	err = s.applyCode(ctx, &condition)
+	if err != nil {
+		return models.DiagnosedCondition{}, err
 	}
 
 	id, err := s.repo.InsertDiagnosedCondition(ctx, condition)
@@ -55,6 +103,65 @@ func (s DiagnosedConditionService) AddDiagnosedConditionToPatient(ctx context.Co
 	return condition, nil
 }
 
+func (s DiagnosedConditionService) validateDates(ctx context.Context, condition models.DiagnosedCondition) error {
+	if condition.OnsetDate != nil && condition.AbatementDate != nil && condition.AbatementDate.Before(*condition.OnsetDate) {
+		return s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("onset date must not be after the abatement date"))
+	}
+	return nil
+}
+
+func (s DiagnosedConditionService) TransitionDiagnosedConditionStatus(ctx context.Context, conditionId int, clinicalStatus string, verificationStatus string, date time.Time) (models.DiagnosedCondition, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "TransitionDiagnosedConditionStatus")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("conditionId", conditionId),
+		attribute.String("clinicalStatus", clinicalStatus),
+		attribute.String("verificationStatus", verificationStatus),
+		attribute.String("date", fmt.Sprintf("%v", date)))
+
+	if clinicalStatus == "" && verificationStatus == "" {
+		return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("a clinical status or verification status is required"))
+	}
+
+	condition, err := s.repo.GetDiagnosedCondition(ctx, conditionId)
+	if err != nil {
+		return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting diagnosed condition %w", err))
+	}
+
+	if condition.VerificationStatus == models.VerificationStatusEnteredInError {
+		return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("the status of a condition entered in error cannot be changed"))
+	}
+	if verificationStatus != "" {
+		if !isAllowedTransition(verificationStatusTransitions, condition.VerificationStatus, verificationStatus) {
+			return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("verification status cannot change from %v to %v", condition.VerificationStatus, verificationStatus)))
+		}
+		condition.VerificationStatus = verificationStatus
+	}
+	if clinicalStatus != "" {
+		if !isAllowedTransition(clinicalStatusTransitions, condition.ClinicalStatus, clinicalStatus) {
+			return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("clinical status cannot change from %v to %v", condition.ClinicalStatus, clinicalStatus)))
+		}
+		condition.ClinicalStatus = clinicalStatus
+		switch clinicalStatus {
+		case models.ClinicalStatusRecurrence, models.ClinicalStatusRelapse:
+			condition.AbatementDate = nil
+		default:
+			condition.AbatementDate = &date
+		}
+	}
+	err = s.validateDates(ctx, condition)
+	if err != nil {
+		return models.DiagnosedCondition{}, err
+	}
+
+	err = s.repo.UpdateDiagnosedCondition(ctx, condition)
+	if err != nil {
+		return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, fmt.Errorf("error updating diagnosed condition %w", err))
+	}
+
+	return condition, nil
+}
+
 func (s DiagnosedConditionService) DeleteDiagnosedCondition(ctx context.Context, conditionId int) error {
 	ctx, span := s.tracer.NewSpan(ctx, "DeleteDiagnosedCondition")
 	defer span.End()
@@ -68,6 +175,19 @@ func (s DiagnosedConditionService) DeleteDiagnosedCondition(ctx context.Context,
 	return nil
 }
 
+func (s DiagnosedConditionService) RestoreDiagnosedCondition(ctx context.Context, conditionId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "RestoreDiagnosedCondition")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("conditionId", conditionId))
+
+	err := s.repo.RestoreDiagnosedCondition(ctx, conditionId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error restoring diagnosed condition %w", err))
+	}
+
+	return nil
+}
+
 func (s DiagnosedConditionService) DeletePatientDiagnosedConditions(ctx context.Context, patientId int) error {
 	ctx, span := s.tracer.NewSpan(ctx, "DeletePatientDiagnosedConditions")
 	defer span.End()
@@ -80,3 +200,174 @@ func (s DiagnosedConditionService) DeletePatientDiagnosedConditions(ctx context.
 
 	return nil
 }
+
+// moves the conditions of one patient to another, such as when duplicate records of a patient are merged
+func (s DiagnosedConditionService) MoveDiagnosedConditionsToPatient(ctx context.Context, fromPatientId int, toPatientId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "MoveDiagnosedConditionsToPatient")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("fromPatientId", fromPatientId),
+		attribute.Int("toPatientId", toPatientId))
+
+	err := s.patientSvc.ValidatePatientId(ctx, toPatientId)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.ReassignDiagnosedConditions(ctx, fromPatientId, toPatientId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error moving diagnosed conditions %w", err))
+	}
+
+	return nil
+}
+
+func (s DiagnosedConditionService) GetDiagnosedCondition(ctx context.Context, conditionId int) (models.DiagnosedCondition, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetDiagnosedCondition")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("conditionId", conditionId))
+
+	condition, err := s.repo.GetDiagnosedCondition(ctx, conditionId)
+	if err != nil {
+		return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting diagnosed condition %w", err))
+	}
+
+	return condition, nil
+}
+
+func (s DiagnosedConditionService) UpdateDiagnosedCondition(ctx context.Context, conditionId int, name string, code string, codeSystem string, description string, date time.Time, onsetDate *time.Time, encounterId int) (models.DiagnosedCondition, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "UpdateDiagnosedCondition")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("conditionId", conditionId),
+		attribute.String("name", name),
+		attribute.String("code", code),
+		attribute.String("codeSystem", codeSystem),
+		attribute.String("description", description),
+		attribute.String("date", fmt.Sprintf("%v", date)),
+		attribute.String("onsetDate", fmt.Sprintf("%v", onsetDate)),
+		attribute.Int("encounterId", encounterId))
+
+	condition, err := s.repo.GetDiagnosedCondition(ctx, conditionId)
+	if err != nil {
+		return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting diagnosed condition %w", err))
+	}
+
+	condition.Name = name
+	condition.Code = code
+	condition.CodeSystem = codeSystem
+	condition.Description = description
+	condition.Date = date
+	condition.OnsetDate = onsetDate
+	condition.EncounterId = encounterId
+	err = s.validateDates(ctx, condition)
+	if err != nil {
+		return models.DiagnosedCondition{}, err
+	}
+	err = s.validateEncounter(ctx, condition.PatientId, condition.EncounterId)
+	if err != nil {
+		return models.DiagnosedCondition{}, err
+	}
+	err = s.applyCode(ctx, &condition)
+	if err != nil {
+		return models.DiagnosedCondition{}, err
+	}
+
+	err = s.repo.UpdateDiagnosedCondition(ctx, condition)
+	if err != nil {
+		return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, fmt.Errorf("error updating diagnosed condition %w", err))
+	}
+
+	return condition, nil
+}
+
+func (s DiagnosedConditionService) PatchDiagnosedCondition(ctx context.Context, conditionId int, patch models.DiagnosedConditionPatch) (models.DiagnosedCondition, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "PatchDiagnosedCondition")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("conditionId", conditionId))
+
+	condition, err := s.repo.GetDiagnosedCondition(ctx, conditionId)
+	if err != nil {
+		return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting diagnosed condition %w", err))
+	}
+
+	if patch.Name != nil {
+		condition.Name = *patch.Name
+	}
+	if patch.Code != nil {
+		condition.Code = *patch.Code
+	}
+	if patch.CodeSystem != nil {
+		condition.CodeSystem = *patch.CodeSystem
+	}
+	if patch.Description != nil {
+		condition.Description = *patch.Description
+	}
+	if patch.Date != nil {
+		condition.Date = *patch.Date
+	}
+	if patch.OnsetDate != nil {
+		condition.OnsetDate = patch.OnsetDate
+	}
+	if patch.EncounterId != nil {
+		condition.EncounterId = *patch.EncounterId
+	}
+	err = s.validateDates(ctx, condition)
+	if err != nil {
+		return models.DiagnosedCondition{}, err
+	}
+	if patch.EncounterId != nil {
+		err = s.validateEncounter(ctx, condition.PatientId, condition.EncounterId)
+		if err != nil {
+			return models.DiagnosedCondition{}, err
+		}
+	}
+	err = s.applyCode(ctx, &condition)
+	if err != nil {
+		return models.DiagnosedCondition{}, err
+	}
+
+	err = s.repo.UpdateDiagnosedCondition(ctx, condition)
+	if err != nil {
+		return models.DiagnosedCondition{}, s.tracer.RecordError(ctx, fmt.Errorf("error updating diagnosed condition %w", err))
+	}
+
+	return condition, nil
+}
+
+func (s DiagnosedConditionService) GetPatientDiagnosedConditions(ctx context.Context, search models.DiagnosedConditionSearch) ([]models.DiagnosedCondition, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetPatientDiagnosedConditions")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", search.PatientId),
+		attribute.String("search.from", fmt.Sprintf("%v", search.From)),
+		attribute.String("search.to", fmt.Sprintf("%v", search.To)),
+		attribute.String("search.code", search.Code),
+		attribute.String("search.codeSystem", search.CodeSystem),
+		attribute.Bool("search.active", search.Active))
+
+	if !search.From.IsZero() && !search.To.IsZero() && search.To.Before(search.From) {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("to must not be before from"))
+	}
+
+	err := s.patientSvc.ValidatePatientId(ctx, search.PatientId)
+	if err != nil {
+		return nil, err
+	}
+
+	search.Codings = nil
+	if search.Code != "" {
+		system := search.CodeSystem
+		if system == "" {
+			system = models.CodeSystemICD10CM
+		}
+		search.Codings = s.codeSvc.Expand(ctx, []models.Coding{{System: system, Code: search.Code}})
+	}
+
+	conditions, err := s.repo.GetDiagnosedConditionsByPatientId(ctx, search)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting diagnosed conditions for patient %w", err))
+	}
+
+	return conditions, nil
+}
@@ -3,6 +3,7 @@ package diagnosedconditions
 import (
 	"context"
 	"fmt"
+	"mcg-app-backend/service/customerrors"
 	"mcg-app-backend/service/models"
 	"testing"
 	"time"
@@ -33,6 +34,31 @@ func (m *MockDiagnosedConditionRepo) DeleteDiagnosedConditionsByPatientId(ctx co
 	return args.Error(0)
 }
 
+func (m *MockDiagnosedConditionRepo) ReassignDiagnosedConditions(ctx context.Context, fromPatientId int, toPatientId int) error {
+	args := m.Called(ctx, fromPatientId, toPatientId)
+	return args.Error(0)
+}
+
+func (m *MockDiagnosedConditionRepo) RestoreDiagnosedCondition(ctx context.Context, conditionId int) error {
+	args := m.Called(ctx, conditionId)
+	return args.Error(0)
+}
+
+func (m *MockDiagnosedConditionRepo) GetDiagnosedCondition(ctx context.Context, conditionId int) (models.DiagnosedCondition, error) {
+	args := m.Called(ctx, conditionId)
+	return args.Get(0).(models.DiagnosedCondition), args.Error(1)
+}
+
+func (m *MockDiagnosedConditionRepo) UpdateDiagnosedCondition(ctx context.Context, condition models.DiagnosedCondition) error {
+	args := m.Called(ctx, condition)
+	return args.Error(0)
+}
+
+func (m *MockDiagnosedConditionRepo) GetDiagnosedConditionsByPatientId(ctx context.Context, search models.DiagnosedConditionSearch) ([]models.DiagnosedCondition, error) {
+	args := m.Called(ctx, search)
+	return args.Get(0).([]models.DiagnosedCondition), args.Error(1)
+}
+
 type MockPatientService struct {
 	mock.Mock
 }
@@ -42,6 +68,29 @@ func (m *MockPatientService) ValidatePatientId(ctx context.Context, patientId in
 	return args.Error(0)
 }
 
+type MockEncounterService struct {
+	mock.Mock
+}
+
+func (m *MockEncounterService) ValidateEncounterId(ctx context.Context, patientId int, encounterId int) error {
+	args := m.Called(ctx, patientId, encounterId)
+	return args.Error(0)
+}
+
+type MockCodeService struct {
+	mock.Mock
+}
+
+func (m *MockCodeService) LookupCoding(ctx context.Context, system string, code string) (models.Code, error) {
+	args := m.Called(ctx, system, code)
+	return args.Get(0).(models.Code), args.Error(1)
+}
+
+func (m *MockCodeService) Expand(ctx context.Context, codings []models.Coding) []models.Coding {
+	args := m.Called(ctx, codings)
+	return args.Get(0).([]models.Coding)
+}
+
 type MockTracer struct {
 	mock.Mock
 }
@@ -56,40 +105,114 @@ func (m *MockTracer) RecordError(ctx context.Context, err error) error {
 	return err
 }
 
-func getMocksAndService() (*MockDiagnosedConditionRepo, *MockPatientService, DiagnosedConditionService) {
+func getMocksAndService() (*MockDiagnosedConditionRepo, *MockPatientService, *MockCodeService, DiagnosedConditionService) {
+	mockRepo, mockPatientSvc, _, mockCodeSvc, service := getMocksAndServiceWithEncounters()
+	return mockRepo, mockPatientSvc, mockCodeSvc, service
+}
+
+func getMocksAndServiceWithEncounters() (*MockDiagnosedConditionRepo, *MockPatientService, *MockEncounterService, *MockCodeService, DiagnosedConditionService) {
 	mockRepo := new(MockDiagnosedConditionRepo)
 	mockPatientSvc := new(MockPatientService)
+	mockEncounterSvc := new(MockEncounterService)
+	mockCodeSvc := new(MockCodeService)
 	mockTracer := new(MockTracer)
-	service := NewDiagnosedConditionService(mockRepo, mockPatientSvc, mockTracer)
-	return mockRepo, mockPatientSvc, service
+	service := NewDiagnosedConditionService(mockRepo, mockPatientSvc, mockEncounterSvc, mockCodeSvc, mockTracer)
+	return mockRepo, mockPatientSvc, mockEncounterSvc, mockCodeSvc, service
 }
 
 func TestAddDiagnosedConditionToPatient(t *testing.T) {
 	patientId := 1
 	name := "Diabetes"
-	code := "ABC123"
+	code := "e119"
 	description := "Type 2 Diabetes"
 	date := time.Now()
+	icd10Code := models.Code{Code: "E11.9", Name: "Type 2 diabetes mellitus without complications"}
 
 	t.Run("AddDiagnosedCondition_Success", func(t *testing.T) {
-		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockRepo, mockPatientSvc, mockCodeSvc, service := getMocksAndService()
 		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, code).Return(icd10Code, nil)
 		mockRepo.On("InsertDiagnosedCondition", mock.Anything, mock.AnythingOfType("models.DiagnosedCondition")).Return(1, nil)
 
-		condition, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, name, code, description, date)
+		condition, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, name, code, "", description, date, nil, 0)
 		assert.Nil(t, err)
 		assert.Equal(t, name, condition.Name)
+		assert.Equal(t, "E11.9", condition.Code)
 		assert.Equal(t, patientId, condition.PatientId)
 
 		mockRepo.AssertExpectations(t)
 		mockPatientSvc.AssertExpectations(t)
+		mockCodeSvc.AssertExpectations(t)
+	})
+
+	t.Run("AddDiagnosedCondition_WithEncounter", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockEncounterSvc, mockCodeSvc, service := getMocksAndServiceWithEncounters()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockEncounterSvc.On("ValidateEncounterId", mock.Anything, patientId, 7).Return(nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, code).Return(icd10Code, nil)
+		mockRepo.On("InsertDiagnosedCondition", mock.Anything, mock.AnythingOfType("models.DiagnosedCondition")).Return(1, nil)
+
+		condition, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, name, code, "", description, date, nil, 7)
+		assert.Nil(t, err)
+		assert.Equal(t, 7, condition.EncounterId)
+
+		mockEncounterSvc.AssertExpectations(t)
+	})
+
+	t.Run("AddDiagnosedCondition_InvalidEncounter", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockEncounterSvc, _, service := getMocksAndServiceWithEncounters()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockEncounterSvc.On("ValidateEncounterId", mock.Anything, patientId, 7).Return(customerrors.NewInvalidInputError("encounter not found"))
+
+		_, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, name, code, "", description, date, nil, 7)
+		assert.NotNil(t, err)
+		assert.Equal(t, "encounter not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertDiagnosedCondition", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddDiagnosedCondition_NameDefaultsToCodeName", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockCodeSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, code).Return(icd10Code, nil)
+		mockRepo.On("InsertDiagnosedCondition", mock.Anything, models.DiagnosedCondition{
+			PatientId:          patientId,
+			Name:               icd10Code.Name,
+			Code:               icd10Code.Code,
+			CodeSystem:         models.CodeSystemICD10CM,
+			Description:        description,
+			Date:               date,
+			ClinicalStatus:     models.ClinicalStatusActive,
+			VerificationStatus: models.VerificationStatusConfirmed,
+		}).Return(1, nil)
+
+		condition, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, "", code, "", description, date, nil, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, icd10Code.Name, condition.Name)
+
+		mockRepo.AssertExpectations(t)
+		mockCodeSvc.AssertExpectations(t)
+	})
+
+	t.Run("AddDiagnosedCondition_InvalidCode", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockCodeSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, "ABCD").Return(models.Code{}, fmt.Errorf("ABCD is not a valid ICD-10-CM code"))
+
+		condition, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, name, "ABCD", "", description, date, nil, 0)
+		assert.NotNil(t, err)
+		assert.Empty(t, condition)
+		assert.Equal(t, "ABCD is not a valid ICD-10-CM code", err.Error())
+
+		mockRepo.AssertExpectations(t)
+		mockCodeSvc.AssertExpectations(t)
 	})
 
 	t.Run("AddDiagnosedCondition_InvalidPatientId", func(t *testing.T) {
-		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockRepo, mockPatientSvc, _, service := getMocksAndService()
 		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(fmt.Errorf("patient id not found"))
 
-		condition, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, name, code, description, date)
+		condition, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, name, code, "", description, date, nil, 0)
 		assert.NotNil(t, err)
 		assert.Empty(t, condition)
 		assert.Equal(t, "patient id not found", err.Error())
@@ -98,12 +221,27 @@ func TestAddDiagnosedConditionToPatient(t *testing.T) {
 		mockPatientSvc.AssertExpectations(t)
 	})
 
+	t.Run("AddDiagnosedCondition_SNOMEDWithoutKnownName", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockCodeSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemSNOMEDCT, "123456").Return(models.Code{Code: "123456"}, nil)
+
+		condition, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, "", "123456", models.CodeSystemSNOMEDCT, description, date, nil, 0)
+		assert.NotNil(t, err)
+		assert.Empty(t, condition)
+		assert.Equal(t, "name is required as SNOMED-CT code 123456 has no known name", err.Error())
+
+		mockRepo.AssertExpectations(t)
+		mockCodeSvc.AssertExpectations(t)
+	})
+
 	t.Run("AddDiagnosedCondition_RepoError", func(t *testing.T) {
-		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockRepo, mockPatientSvc, mockCodeSvc, service := getMocksAndService()
 		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, code).Return(icd10Code, nil)
 		mockRepo.On("InsertDiagnosedCondition", mock.Anything, mock.AnythingOfType("models.DiagnosedCondition")).Return(0, fmt.Errorf("db error"))
 
-		condition, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, name, code, description, date)
+		condition, err := service.AddDiagnosedConditionToPatient(context.Background(), patientId, name, code, "", description, date, nil, 0)
 		assert.NotNil(t, err)
 		assert.Empty(t, condition)
 		assert.Equal(t, "error inserting diagnosed condition db error", err.Error())
@@ -116,7 +254,7 @@ func TestDeleteDiagnosedCondition(t *testing.T) {
 	conditionId := 1
 
 	t.Run("DeleteDiagnosedCondition_Success", func(t *testing.T) {
-		mockRepo, _, service := getMocksAndService()
+		mockRepo, _, _, service := getMocksAndService()
 		mockRepo.On("DeleteDiagnosedCondition", mock.Anything, conditionId).Return(nil)
 
 		err := service.DeleteDiagnosedCondition(context.Background(), conditionId)
@@ -126,7 +264,7 @@ func TestDeleteDiagnosedCondition(t *testing.T) {
 	})
 
 	t.Run("DeleteDiagnosedCondition_RepoError", func(t *testing.T) {
-		mockRepo, _, service := getMocksAndService()
+		mockRepo, _, _, service := getMocksAndService()
 		mockRepo.On("DeleteDiagnosedCondition", mock.Anything, conditionId).Return(fmt.Errorf("db error"))
 
 		err := service.DeleteDiagnosedCondition(context.Background(), conditionId)
@@ -141,7 +279,7 @@ func TestDeletePatientDiagnosedConditions(t *testing.T) {
 	patientId := 1
 
 	t.Run("DeletePatientDiagnosedConditions_Success", func(t *testing.T) {
-		mockRepo, _, service := getMocksAndService()
+		mockRepo, _, _, service := getMocksAndService()
 		mockRepo.On("DeleteDiagnosedConditionsByPatientId", mock.Anything, patientId).Return(nil)
 
 		err := service.DeletePatientDiagnosedConditions(context.Background(), patientId)
@@ -151,7 +289,7 @@ func TestDeletePatientDiagnosedConditions(t *testing.T) {
 	})
 
 	t.Run("DeletePatientDiagnosedConditions_RepoError", func(t *testing.T) {
-		mockRepo, _, service := getMocksAndService()
+		mockRepo, _, _, service := getMocksAndService()
 		mockRepo.On("DeleteDiagnosedConditionsByPatientId", mock.Anything, patientId).Return(fmt.Errorf("db error"))
 
 		err := service.DeletePatientDiagnosedConditions(context.Background(), patientId)
@@ -161,3 +299,381 @@ func TestDeletePatientDiagnosedConditions(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestMoveDiagnosedConditionsToPatient(t *testing.T) {
+	t.Run("MoveDiagnosedConditionsToPatient_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, _, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 2).Return(nil)
+		mockRepo.On("ReassignDiagnosedConditions", mock.Anything, 1, 2).Return(nil)
+
+		err := service.MoveDiagnosedConditionsToPatient(context.Background(), 1, 2)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("MoveDiagnosedConditionsToPatient_InvalidPatientId", func(t *testing.T) {
+		mockRepo, mockPatientSvc, _, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 2).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		err := service.MoveDiagnosedConditionsToPatient(context.Background(), 1, 2)
+		assert.Equal(t, customerrors.NewInvalidInputError("patient id not found"), err)
+
+		mockRepo.AssertNotCalled(t, "ReassignDiagnosedConditions", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRestoreDiagnosedCondition(t *testing.T) {
+	conditionId := 1
+
+	t.Run("RestoreDiagnosedCondition_Success", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		mockRepo.On("RestoreDiagnosedCondition", mock.Anything, conditionId).Return(nil)
+
+		err := service.RestoreDiagnosedCondition(context.Background(), conditionId)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("RestoreDiagnosedCondition_Error", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		mockRepo.On("RestoreDiagnosedCondition", mock.Anything, conditionId).Return(fmt.Errorf("not deleted"))
+
+		err := service.RestoreDiagnosedCondition(context.Background(), conditionId)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error restoring diagnosed condition not deleted", err.Error())
+
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestGetDiagnosedCondition(t *testing.T) {
+	conditionId := 1
+
+	t.Run("GetDiagnosedCondition_Success", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		expected := models.DiagnosedCondition{Id: conditionId, Name: "Diabetes"}
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, conditionId).Return(expected, nil)
+
+		condition, err := service.GetDiagnosedCondition(context.Background(), conditionId)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("GetDiagnosedCondition_RepoError", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, conditionId).Return(models.DiagnosedCondition{}, fmt.Errorf("db error"))
+
+		condition, err := service.GetDiagnosedCondition(context.Background(), conditionId)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting diagnosed condition db error", err.Error())
+		assert.Empty(t, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestUpdateDiagnosedCondition(t *testing.T) {
+	date := time.Now()
+	existing := models.DiagnosedCondition{
+		Id:          1,
+		PatientId:   2,
+		Name:        "Diabetis",
+		Code:        "E11.9",
+		CodeSystem:  models.CodeSystemICD10CM,
+		Description: "typo",
+		Date:        date,
+	}
+
+	t.Run("UpdateDiagnosedCondition_Success", func(t *testing.T) {
+		mockRepo, _, mockCodeSvc, service := getMocksAndService()
+		expected := existing
+		expected.Name = "Diabetes"
+		expected.Description = "Type 2 Diabetes"
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(existing, nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, "E11.9").Return(models.Code{Code: "E11.9", Name: "Type 2 diabetes mellitus without complications"}, nil)
+		mockRepo.On("UpdateDiagnosedCondition", mock.Anything, expected).Return(nil)
+
+		condition, err := service.UpdateDiagnosedCondition(context.Background(), existing.Id, expected.Name, expected.Code, "", expected.Description, date, nil, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("UpdateDiagnosedCondition_NotFound", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(models.DiagnosedCondition{}, fmt.Errorf("not found"))
+
+		condition, err := service.UpdateDiagnosedCondition(context.Background(), existing.Id, "Diabetes", "E11.9", "", "", date, nil, 0)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting diagnosed condition not found", err.Error())
+		assert.Empty(t, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("UpdateDiagnosedCondition_InvalidCode", func(t *testing.T) {
+		mockRepo, _, mockCodeSvc, service := getMocksAndService()
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(existing, nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, "XYZ").Return(models.Code{}, fmt.Errorf("XYZ is not a valid ICD-10-CM code"))
+
+		condition, err := service.UpdateDiagnosedCondition(context.Background(), existing.Id, "Diabetes", "XYZ", "", "", date, nil, 0)
+		assert.NotNil(t, err)
+		assert.Equal(t, "XYZ is not a valid ICD-10-CM code", err.Error())
+		assert.Empty(t, condition)
+
+		mockRepo.AssertExpectations(t)
+		mockCodeSvc.AssertExpectations(t)
+	})
+
+	t.Run("UpdateDiagnosedCondition_RepoError", func(t *testing.T) {
+		mockRepo, _, mockCodeSvc, service := getMocksAndService()
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(existing, nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, "E11.9").Return(models.Code{Code: "E11.9", Name: "Type 2 diabetes mellitus without complications"}, nil)
+		mockRepo.On("UpdateDiagnosedCondition", mock.Anything, mock.Anything).Return(fmt.Errorf("db error"))
+
+		condition, err := service.UpdateDiagnosedCondition(context.Background(), existing.Id, "Diabetes", "E11.9", "", "", date, nil, 0)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error updating diagnosed condition db error", err.Error())
+		assert.Empty(t, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestPatchDiagnosedCondition(t *testing.T) {
+	existing := models.DiagnosedCondition{
+		Id:          1,
+		PatientId:   2,
+		Name:        "Diabetes",
+		Code:        "E11",
+		CodeSystem:  models.CodeSystemICD10CM,
+		Description: "Type 2 Diabetes",
+		Date:        time.Now(),
+	}
+
+	t.Run("PatchDiagnosedCondition_OnlyChangesProvidedFields", func(t *testing.T) {
+		mockRepo, _, mockCodeSvc, service := getMocksAndService()
+		code := "E11.9"
+		expected := existing
+		expected.Code = code
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(existing, nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, code).Return(models.Code{Code: code, Name: "Type 2 diabetes mellitus without complications"}, nil)
+		mockRepo.On("UpdateDiagnosedCondition", mock.Anything, expected).Return(nil)
+
+		condition, err := service.PatchDiagnosedCondition(context.Background(), existing.Id, models.DiagnosedConditionPatch{Code: &code})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("PatchDiagnosedCondition_Encounter", func(t *testing.T) {
+		mockRepo, _, mockEncounterSvc, mockCodeSvc, service := getMocksAndServiceWithEncounters()
+		encounterId := 3
+		expected := existing
+		expected.EncounterId = encounterId
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(existing, nil)
+		mockEncounterSvc.On("ValidateEncounterId", mock.Anything, existing.PatientId, encounterId).Return(nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, existing.Code).Return(models.Code{Code: existing.Code, Name: "Type 2 diabetes mellitus"}, nil)
+		mockRepo.On("UpdateDiagnosedCondition", mock.Anything, expected).Return(nil)
+
+		condition, err := service.PatchDiagnosedCondition(context.Background(), existing.Id, models.DiagnosedConditionPatch{EncounterId: &encounterId})
+		assert.Nil(t, err)
+		assert.Equal(t, encounterId, condition.EncounterId)
+
+		mockRepo.AssertExpectations(t)
+		mockEncounterSvc.AssertExpectations(t)
+	})
+
+	t.Run("PatchDiagnosedCondition_ClearEncounter", func(t *testing.T) {
+		mockRepo, _, mockEncounterSvc, mockCodeSvc, service := getMocksAndServiceWithEncounters()
+		withEncounter := existing
+		withEncounter.EncounterId = 3
+		none := 0
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(withEncounter, nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, existing.Code).Return(models.Code{Code: existing.Code, Name: "Type 2 diabetes mellitus"}, nil)
+		mockRepo.On("UpdateDiagnosedCondition", mock.Anything, existing).Return(nil)
+
+		condition, err := service.PatchDiagnosedCondition(context.Background(), existing.Id, models.DiagnosedConditionPatch{EncounterId: &none})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, condition.EncounterId)
+
+		mockEncounterSvc.AssertNotCalled(t, "ValidateEncounterId", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("PatchDiagnosedCondition_NotFound", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(models.DiagnosedCondition{}, fmt.Errorf("not found"))
+
+		condition, err := service.PatchDiagnosedCondition(context.Background(), existing.Id, models.DiagnosedConditionPatch{})
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting diagnosed condition not found", err.Error())
+		assert.Empty(t, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestGetPatientDiagnosedConditions(t *testing.T) {
+	search := models.DiagnosedConditionSearch{
+		PatientId: 1,
+		From:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:        time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		Code:      "E11.9",
+	}
+
+	t.Run("GetPatientDiagnosedConditions_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockCodeSvc, service := getMocksAndService()
+		expected := []models.DiagnosedCondition{
+			{Id: 1, PatientId: 1, Code: "E11.9", CodeSystem: models.CodeSystemICD10CM},
+			{Id: 2, PatientId: 1, Code: "44054006", CodeSystem: models.CodeSystemSNOMEDCT},
+		}
+		codings := []models.Coding{
+			{System: models.CodeSystemICD10CM, Code: "E11.9"},
+			{System: models.CodeSystemSNOMEDCT, Code: "44054006"},
+		}
+		expectedSearch := search
+		expectedSearch.Codings = codings
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, search.PatientId).Return(nil)
+		mockCodeSvc.On("Expand", mock.Anything, []models.Coding{{System: models.CodeSystemICD10CM, Code: "E11.9"}}).Return(codings)
+		mockRepo.On("GetDiagnosedConditionsByPatientId", mock.Anything, expectedSearch).Return(expected, nil)
+
+		conditions, err := service.GetPatientDiagnosedConditions(context.Background(), search)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, conditions)
+
+		mockRepo.AssertExpectations(t)
+		mockPatientSvc.AssertExpectations(t)
+		mockCodeSvc.AssertExpectations(t)
+	})
+
+	t.Run("GetPatientDiagnosedConditions_InvalidRange", func(t *testing.T) {
+		mockRepo, mockPatientSvc, _, service := getMocksAndService()
+		invalid := search
+		invalid.From, invalid.To = search.To, search.From
+
+		conditions, err := service.GetPatientDiagnosedConditions(context.Background(), invalid)
+		assert.NotNil(t, err)
+		assert.Equal(t, "to must not be before from", err.Error())
+		assert.Nil(t, conditions)
+
+		mockRepo.AssertExpectations(t)
+		mockPatientSvc.AssertExpectations(t)
+	})
+
+	t.Run("GetPatientDiagnosedConditions_InvalidPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, _, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, search.PatientId).Return(fmt.Errorf("invalid patient"))
+
+		conditions, err := service.GetPatientDiagnosedConditions(context.Background(), search)
+		assert.NotNil(t, err)
+		assert.Equal(t, "invalid patient", err.Error())
+		assert.Nil(t, conditions)
+
+		mockRepo.AssertExpectations(t)
+		mockPatientSvc.AssertExpectations(t)
+	})
+}
+
+func TestTransitionDiagnosedConditionStatus(t *testing.T) {
+	onset := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	existing := models.DiagnosedCondition{
+		Id:                 1,
+		PatientId:          2,
+		Name:               "Headache",
+		Code:               "R51.9",
+		CodeSystem:         models.CodeSystemICD10CM,
+		OnsetDate:          &onset,
+		ClinicalStatus:     models.ClinicalStatusActive,
+		VerificationStatus: models.VerificationStatusConfirmed,
+	}
+
+	t.Run("TransitionStatus_Resolve", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		expected := existing
+		expected.ClinicalStatus = models.ClinicalStatusResolved
+		expected.AbatementDate = &date
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(existing, nil)
+		mockRepo.On("UpdateDiagnosedCondition", mock.Anything, expected).Return(nil)
+
+		condition, err := service.TransitionDiagnosedConditionStatus(context.Background(), existing.Id, models.ClinicalStatusResolved, "", date)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("TransitionStatus_RecurrenceClearsAbatement", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		resolved := existing
+		resolved.ClinicalStatus = models.ClinicalStatusResolved
+		resolved.AbatementDate = &date
+		expected := existing
+		expected.ClinicalStatus = models.ClinicalStatusRecurrence
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(resolved, nil)
+		mockRepo.On("UpdateDiagnosedCondition", mock.Anything, expected).Return(nil)
+
+		condition, err := service.TransitionDiagnosedConditionStatus(context.Background(), existing.Id, models.ClinicalStatusRecurrence, "", date)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("TransitionStatus_NotAllowed", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		resolved := existing
+		resolved.ClinicalStatus = models.ClinicalStatusResolved
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(resolved, nil)
+
+		condition, err := service.TransitionDiagnosedConditionStatus(context.Background(), existing.Id, models.ClinicalStatusRelapse, "", date)
+		assert.NotNil(t, err)
+		assert.Equal(t, "clinical status cannot change from resolved to relapse", err.Error())
+		assert.Empty(t, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("TransitionStatus_EnteredInErrorIsFinal", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		enteredInError := existing
+		enteredInError.VerificationStatus = models.VerificationStatusEnteredInError
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(enteredInError, nil)
+
+		condition, err := service.TransitionDiagnosedConditionStatus(context.Background(), existing.Id, "", models.VerificationStatusConfirmed, date)
+		assert.NotNil(t, err)
+		assert.Equal(t, "the status of a condition entered in error cannot be changed", err.Error())
+		assert.Empty(t, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("TransitionStatus_AbatementBeforeOnset", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+		mockRepo.On("GetDiagnosedCondition", mock.Anything, existing.Id).Return(existing, nil)
+
+		condition, err := service.TransitionDiagnosedConditionStatus(context.Background(), existing.Id, models.ClinicalStatusRemission, "", onset.Add(-time.Hour))
+		assert.NotNil(t, err)
+		assert.Equal(t, "onset date must not be after the abatement date", err.Error())
+		assert.Empty(t, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("TransitionStatus_NoStatus", func(t *testing.T) {
+		mockRepo, _, _, service := getMocksAndService()
+
+		condition, err := service.TransitionDiagnosedConditionStatus(context.Background(), existing.Id, "", "", date)
+		assert.NotNil(t, err)
+		assert.Equal(t, "a clinical status or verification status is required", err.Error())
+		assert.Empty(t, condition)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,32 @@
+package diagnosedconditions
+
+import "mcg-app-backend/service/models"
+
+// the clinical statuses a condition can move to from each clinical status.  A condition which comes back
+// after resolving or becoming inactive is a recurrence, and one which comes back after remission is a relapse
+var clinicalStatusTransitions = map[string][]string{
+	models.ClinicalStatusActive:     {models.ClinicalStatusInactive, models.ClinicalStatusRemission, models.ClinicalStatusResolved},
+	models.ClinicalStatusRecurrence: {models.ClinicalStatusInactive, models.ClinicalStatusRemission, models.ClinicalStatusResolved},
+	models.ClinicalStatusRelapse:    {models.ClinicalStatusInactive, models.ClinicalStatusRemission, models.ClinicalStatusResolved},
+	models.ClinicalStatusInactive:   {models.ClinicalStatusRecurrence, models.ClinicalStatusResolved},
+	models.ClinicalStatusRemission:  {models.ClinicalStatusRelapse, models.ClinicalStatusInactive, models.ClinicalStatusResolved},
+	models.ClinicalStatusResolved:   {models.ClinicalStatusRecurrence},
+}
+
+// the verification statuses a condition can move to from each verification status.  Entered in error is final
+var verificationStatusTransitions = map[string][]string{
+	models.VerificationStatusUnconfirmed:  {models.VerificationStatusProvisional, models.VerificationStatusDifferential, models.VerificationStatusConfirmed, models.VerificationStatusRefuted, models.VerificationStatusEnteredInError},
+	models.VerificationStatusProvisional:  {models.VerificationStatusDifferential, models.VerificationStatusConfirmed, models.VerificationStatusRefuted, models.VerificationStatusEnteredInError},
+	models.VerificationStatusDifferential: {models.VerificationStatusProvisional, models.VerificationStatusConfirmed, models.VerificationStatusRefuted, models.VerificationStatusEnteredInError},
+	models.VerificationStatusConfirmed:    {models.VerificationStatusRefuted, models.VerificationStatusEnteredInError},
+	models.VerificationStatusRefuted:      {models.VerificationStatusConfirmed, models.VerificationStatusEnteredInError},
+}
+
+func isAllowedTransition(transitions map[string][]string, from string, to string) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
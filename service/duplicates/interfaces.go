@@ -0,0 +1,40 @@
+package duplicates
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type DuplicateRepo interface {
+	GetPatientsPage(ctx context.Context, afterId int, limit int) ([]models.Patient, error)
+	InsertPatientLink(ctx context.Context, link models.PatientLink) (int, error)
+	GetPatientLinks(ctx context.Context, patientId int) ([]models.PatientLink, error)
+	ReassignEncounters(ctx context.Context, fromPatientId int, toPatientId int) error
+	ReassignMedications(ctx context.Context, fromPatientId int, toPatientId int) error
+	ReassignAllergies(ctx context.Context, fromPatientId int, toPatientId int) error
+	ReassignObservations(ctx context.Context, fromPatientId int, toPatientId int) error
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type PatientService interface {
+	GetPatient(ctx context.Context, patientId int) (models.Patient, error)
+	ValidatePatientId(ctx context.Context, patientId int) error
+	DeletePatient(ctx context.Context, patientId int) error
+}
+
+type DiagnosedConditionService interface {
+	MoveDiagnosedConditionsToPatient(ctx context.Context, fromPatientId int, toPatientId int) error
+}
+
+type AttatchmentService interface {
+	MoveAttatchmentsToPatient(ctx context.Context, fromPatientId int, toPatientId int) error
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
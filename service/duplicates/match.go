@@ -0,0 +1,234 @@
+package duplicates
+
+import (
+	"math"
+	"mcg-app-backend/service/models"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// a field compared between patients.  Following Fellegi-Sunter, m is how often the field agrees between two records
+// of the same person, allowing for typos and changes such as moving house, and u is how often it agrees by chance
+// between records of different people.  u is set high enough for phone numbers and addresses that members of the same
+// household are not taken for duplicates
+type field struct {
+	name string
+	m    float64
+	u    float64
+	// similarity at or below which values are taken to disagree entirely
+	threshold float64
+}
+
+var (
+	nameField        = field{name: "name", m: 0.95, u: 0.01, threshold: 0.8}
+	dateOfBirthField = field{name: "dateOfBirth", m: 0.97, u: 0.003, threshold: 0.5}
+	phoneNumberField = field{name: "phoneNumber", m: 0.9, u: 0.01, threshold: 0}
+	addressField     = field{name: "address", m: 0.75, u: 0.01, threshold: 0.4}
+)
+
+// the chance that two patients picked at random are the same person, before comparing them
+const priorProbability = 0.001
+
+// the weight of evidence for the patients being the same person, from the log2 likelihood ratio of the field agreeing
+// when it is fully similar, to that of it disagreeing at the threshold, scaled linearly in between
+func (f field) weight(similarity float64) float64 {
+	agree := math.Log2(f.m / f.u)
+	disagree := math.Log2((1 - f.m) / (1 - f.u))
+	if similarity <= f.threshold {
+		return disagree
+	}
+	return disagree + (agree-disagree)*(similarity-f.threshold)/(1-f.threshold)
+}
+
+// compares each field recorded for both patients.  Fields missing from either are left out, as they are no evidence
+// either way
+func compare(patient models.Patient, candidate models.Patient) models.PotentialDuplicate {
+	duplicate := models.PotentialDuplicate{Patient: candidate, Fields: []models.FieldComparison{}}
+	add := func(f field, similarity float64, known bool) {
+		if !known {
+			return
+		}
+		similarity = math.Round(similarity*1000) / 1000
+		weight := math.Round(f.weight(similarity)*100) / 100
+		duplicate.Fields = append(duplicate.Fields, models.FieldComparison{Field: f.name, Similarity: similarity, Weight: weight})
+		duplicate.Score += weight
+	}
+
+	similarity, known := nameSimilarity(patient.Name, candidate.Name)
+	add(nameField, similarity, known)
+	similarity, known = dateSimilarity(patient.DateOfBirth, candidate.DateOfBirth)
+	add(dateOfBirthField, similarity, known)
+	similarity, known = phoneSimilarity(patient.PhoneNumber, candidate.PhoneNumber)
+	add(phoneNumberField, similarity, known)
+	similarity, known = addressSimilarity(patient.Address, candidate.Address)
+	add(addressField, similarity, known)
+
+	duplicate.Score = math.Round(duplicate.Score*100) / 100
+	odds := priorProbability / (1 - priorProbability) * math.Exp2(duplicate.Score)
+	duplicate.Probability = math.Round(odds/(1+odds)*1000) / 1000
+	return duplicate
+}
+
+// names are compared both as written and with their words sorted, so "Smith John" matches "John Smith"
+func nameSimilarity(a string, b string) (float64, bool) {
+	wordsA, wordsB := words(a), words(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0, false
+	}
+	similarity := jaroWinkler(strings.Join(wordsA, " "), strings.Join(wordsB, " "))
+	slices.Sort(wordsA)
+	slices.Sort(wordsB)
+	return max(similarity, jaroWinkler(strings.Join(wordsA, " "), strings.Join(wordsB, " "))), true
+}
+
+// dates which differ only in one of their year, month or day, or which have the day and month swapped, are likely
+// to be typos of each other
+func dateSimilarity(a time.Time, b time.Time) (float64, bool) {
+	if a.IsZero() || b.IsZero() {
+		return 0, false
+	}
+	yearA, monthA, dayA := a.Date()
+	yearB, monthB, dayB := b.Date()
+	agreeing := 0
+	for _, agrees := range []bool{yearA == yearB, monthA == monthB, dayA == dayB} {
+		if agrees {
+			agreeing++
+		}
+	}
+	switch {
+	case agreeing == 3:
+		return 1, true
+	case agreeing == 2, yearA == yearB && int(monthA) == dayB && dayA == int(monthB):
+		return 0.75, true
+	default:
+		return 0, true
+	}
+}
+
+// phone numbers agree when their last 10 digits do, so country codes and formatting are ignored
+func phoneSimilarity(a string, b string) (float64, bool) {
+	digitsA, digitsB := lastDigits(a, 10), lastDigits(b, 10)
+	if len(digitsA) < 7 || len(digitsB) < 7 {
+		return 0, false
+	}
+	if digitsA == digitsB {
+		return 1, true
+	}
+	return 0, true
+}
+
+// the share of words the addresses have in common, after common abbreviations are expanded
+func addressSimilarity(a string, b string) (float64, bool) {
+	wordsA, wordsB := words(a), words(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0, false
+	}
+	setA := map[string]bool{}
+	for _, word := range wordsA {
+		setA[expandAbbreviation(word)] = true
+	}
+	setB := map[string]bool{}
+	for _, word := range wordsB {
+		setB[expandAbbreviation(word)] = true
+	}
+	shared := 0
+	for word := range setA {
+		if setB[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(setA)+len(setB)-shared), true
+}
+
+var abbreviations = map[string]string{
+	"st":   "street",
+	"rd":   "road",
+	"ave":  "avenue",
+	"av":   "avenue",
+	"dr":   "drive",
+	"ln":   "lane",
+	"blvd": "boulevard",
+	"ct":   "court",
+	"pl":   "place",
+	"apt":  "apartment",
+	"ste":  "suite",
+	"n":    "north",
+	"s":    "south",
+	"e":    "east",
+	"w":    "west",
+}
+
+func expandAbbreviation(word string) string {
+	if expanded, ok := abbreviations[word]; ok {
+		return expanded
+	}
+	return word
+}
+
+// the lower case words of s, ignoring punctuation
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func lastDigits(s string, n int) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	return digits[max(0, len(digits)-n):]
+}
+
+// the Jaro-Winkler similarity of a and b, from 0 to 1, which favours strings that share a prefix
+func jaroWinkler(a string, b string) float64 {
+	runesA, runesB := []rune(a), []rune(b)
+	if len(runesA) == 0 || len(runesB) == 0 {
+		return 0
+	}
+
+	window := max(0, max(len(runesA), len(runesB))/2-1)
+	matchedA := make([]bool, len(runesA))
+	matchedB := make([]bool, len(runesB))
+	matches := 0
+	for i, r := range runesA {
+		for j := max(0, i-window); j < min(len(runesB), i+window+1); j++ {
+			if !matchedB[j] && runesB[j] == r {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i, r := range runesA {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if r != runesB[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(runesA)) + m/float64(len(runesB)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(runesA), len(runesB)) && runesA[prefix] == runesB[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
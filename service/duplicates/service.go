@@ -0,0 +1,153 @@
+package duplicates
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// the number of patients read from the repo at a time while looking for duplicates
+const pageSize = 500
+
+// the score above which a patient is reported as a potential duplicate.  Patients sharing a name and date of birth,
+// but nothing else, score just above it
+const minimumScore = 8.0
+
+type DuplicateService struct {
+	repo           DuplicateRepo
+	patientSvc     PatientService
+	conditionSvc   DiagnosedConditionService
+	attatchmentSvc AttatchmentService
+	tracer         Tracer
+}
+
+func NewDuplicateService(repo DuplicateRepo, patientSvc PatientService, conditionSvc DiagnosedConditionService, attatchmentSvc AttatchmentService, tracer Tracer) DuplicateService {
+	return DuplicateService{
+		repo:           repo,
+		patientSvc:     patientSvc,
+		conditionSvc:   conditionSvc,
+		attatchmentSvc: attatchmentSvc,
+		tracer:         tracer,
+	}
+}
+
+// the other patients who may be the same person as the given one, most likely first.  Every patient is compared, a
+// page at a time, so a typo in any one field cannot hide a duplicate
+func (s DuplicateService) FindPotentialDuplicates(ctx context.Context, patientId int) ([]models.PotentialDuplicate, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "FindPotentialDuplicates")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	patient, err := s.patientSvc.GetPatient(ctx, patientId)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting patient %w", err))
+	}
+
+	duplicates := []models.PotentialDuplicate{}
+	afterId := 0
+	for {
+		candidates, err := s.repo.GetPatientsPage(ctx, afterId, pageSize)
+		if err != nil {
+			return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting page of patients %w", err))
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		for _, candidate := range candidates {
+			if candidate.Id == patient.Id {
+				continue
+			}
+			duplicate := compare(patient, candidate)
+			if duplicate.Score >= minimumScore {
+				duplicates = append(duplicates, duplicate)
+			}
+		}
+		afterId = candidates[len(candidates)-1].Id
+	}
+
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].Score > duplicates[j].Score
+	})
+	return duplicates, nil
+}
+
+// merges the duplicate patient into the surviving one.  Their encounters, conditions, attatchments, medications, allergies
+// and observations are moved over, then the rest of their record is deleted, so it can be restored if the merge was a
+// mistake.  The merge is recorded as a link between the patients.  HL7 merges are made through here too, so a merge
+// keeps the same records however it is made
+func (s DuplicateService) MergePatients(ctx context.Context, patientId int, duplicatePatientId int) (models.PatientLink, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "MergePatients")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("duplicatePatientId", duplicatePatientId))
+
+	if patientId == duplicatePatientId {
+		return models.PatientLink{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("a patient cannot be merged into themselves"))
+	}
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return models.PatientLink{}, err
+	}
+	err = s.patientSvc.ValidatePatientId(ctx, duplicatePatientId)
+	if err != nil {
+		return models.PatientLink{}, err
+	}
+
+	link := models.PatientLink{PatientId: patientId, MergedPatientId: duplicatePatientId, MergedAt: time.Now()}
+	err = s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		//encounters are moved first, so the conditions and attatchments moved after them stay linked to them
+		err := s.repo.ReassignEncounters(ctx, duplicatePatientId, patientId)
+		if err != nil {
+			return fmt.Errorf("error moving encounters %w", err)
+		}
+		err = s.conditionSvc.MoveDiagnosedConditionsToPatient(ctx, duplicatePatientId, patientId)
+		if err != nil {
+			return err
+		}
+		err = s.attatchmentSvc.MoveAttatchmentsToPatient(ctx, duplicatePatientId, patientId)
+		if err != nil {
+			return err
+		}
+		err = s.repo.ReassignMedications(ctx, duplicatePatientId, patientId)
+		if err != nil {
+			return fmt.Errorf("error moving medications %w", err)
+		}
+		err = s.repo.ReassignAllergies(ctx, duplicatePatientId, patientId)
+		if err != nil {
+			return fmt.Errorf("error moving allergies %w", err)
+		}
+		err = s.repo.ReassignObservations(ctx, duplicatePatientId, patientId)
+		if err != nil {
+			return fmt.Errorf("error moving observations %w", err)
+		}
+		link.Id, err = s.repo.InsertPatientLink(ctx, link)
+		if err != nil {
+			return fmt.Errorf("error inserting patient link %w", err)
+		}
+		return s.patientSvc.DeletePatient(ctx, duplicatePatientId)
+	})
+	if err != nil {
+		return models.PatientLink{}, s.tracer.RecordError(ctx, err)
+	}
+
+	return link, nil
+}
+
+// the merges the patient was part of, either as the surviving or the merged patient
+func (s DuplicateService) GetPatientLinks(ctx context.Context, patientId int) ([]models.PatientLink, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetPatientLinks")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	links, err := s.repo.GetPatientLinks(ctx, patientId)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting patient links %w", err))
+	}
+	return links, nil
+}
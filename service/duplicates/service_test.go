@@ -0,0 +1,301 @@
+package duplicates
+
+import (
+	"context"
+	"errors"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockDuplicateRepo struct {
+	mock.Mock
+}
+
+func (m *MockDuplicateRepo) GetPatientsPage(ctx context.Context, afterId int, limit int) ([]models.Patient, error) {
+	args := m.Called(ctx, afterId, limit)
+	return args.Get(0).([]models.Patient), args.Error(1)
+}
+
+func (m *MockDuplicateRepo) InsertPatientLink(ctx context.Context, link models.PatientLink) (int, error) {
+	args := m.Called(ctx, link)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockDuplicateRepo) GetPatientLinks(ctx context.Context, patientId int) ([]models.PatientLink, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).([]models.PatientLink), args.Error(1)
+}
+
+func (m *MockDuplicateRepo) ReassignEncounters(ctx context.Context, fromPatientId int, toPatientId int) error {
+	args := m.Called(ctx, fromPatientId, toPatientId)
+	return args.Error(0)
+}
+
+func (m *MockDuplicateRepo) ReassignMedications(ctx context.Context, fromPatientId int, toPatientId int) error {
+	args := m.Called(ctx, fromPatientId, toPatientId)
+	return args.Error(0)
+}
+
+func (m *MockDuplicateRepo) ReassignAllergies(ctx context.Context, fromPatientId int, toPatientId int) error {
+	args := m.Called(ctx, fromPatientId, toPatientId)
+	return args.Error(0)
+}
+
+func (m *MockDuplicateRepo) ReassignObservations(ctx context.Context, fromPatientId int, toPatientId int) error {
+	args := m.Called(ctx, fromPatientId, toPatientId)
+	return args.Error(0)
+}
+
+func (m *MockDuplicateRepo) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) GetPatient(ctx context.Context, patientId int) (models.Patient, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).(models.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) ValidatePatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+func (m *MockPatientService) DeletePatient(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockDiagnosedConditionService struct {
+	mock.Mock
+}
+
+func (m *MockDiagnosedConditionService) MoveDiagnosedConditionsToPatient(ctx context.Context, fromPatientId int, toPatientId int) error {
+	args := m.Called(ctx, fromPatientId, toPatientId)
+	return args.Error(0)
+}
+
+type MockAttatchmentService struct {
+	mock.Mock
+}
+
+func (m *MockAttatchmentService) MoveAttatchmentsToPatient(ctx context.Context, fromPatientId int, toPatientId int) error {
+	args := m.Called(ctx, fromPatientId, toPatientId)
+	return args.Error(0)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService() (*MockDuplicateRepo, *MockPatientService, *MockDiagnosedConditionService, *MockAttatchmentService, DuplicateService) {
+	mockRepo := new(MockDuplicateRepo)
+	mockPatientSvc := new(MockPatientService)
+	mockConditionSvc := new(MockDiagnosedConditionService)
+	mockAttatchmentSvc := new(MockAttatchmentService)
+	service := NewDuplicateService(mockRepo, mockPatientSvc, mockConditionSvc, mockAttatchmentSvc, new(MockTracer))
+	return mockRepo, mockPatientSvc, mockConditionSvc, mockAttatchmentSvc, service
+}
+
+func date(value string) time.Time {
+	parsed, _ := time.Parse(time.DateOnly, value)
+	return parsed
+}
+
+var john = models.Patient{Id: 1, Name: "John Smith", DateOfBirth: date("1980-05-17"), PhoneNumber: "(555) 987-6543", Address: "12 High Street"}
+
+func TestJaroWinkler(t *testing.T) {
+	assert.InDelta(t, 0.961, jaroWinkler("martha", "marhta"), 0.001)
+	assert.InDelta(t, 0.840, jaroWinkler("dwayne", "duane"), 0.001)
+	assert.InDelta(t, 0.813, jaroWinkler("dixon", "dicksonx"), 0.001)
+	assert.Equal(t, 1.0, jaroWinkler("smith", "smith"))
+	assert.Equal(t, 0.0, jaroWinkler("abc", "xyz"))
+}
+
+func TestCompare(t *testing.T) {
+	t.Run("Compare_MisspeltName", func(t *testing.T) {
+		jon := models.Patient{Id: 2, Name: "Jon Smith", DateOfBirth: date("1980-05-17"), PhoneNumber: "+1 555 987 6543"}
+		duplicate := compare(john, jon)
+		assert.Greater(t, duplicate.Score, minimumScore)
+		assert.Greater(t, duplicate.Probability, 0.99)
+		assert.Equal(t, []string{"name", "dateOfBirth", "phoneNumber"}, fieldNames(duplicate))
+	})
+
+	t.Run("Compare_SameNameAndDateOfBirth", func(t *testing.T) {
+		moved := models.Patient{Id: 2, Name: "Smith, John", DateOfBirth: date("1980-05-17"), PhoneNumber: "555 111 2222", Address: "3 Low Rd"}
+		duplicate := compare(john, moved)
+		assert.Greater(t, duplicate.Score, minimumScore)
+	})
+
+	t.Run("Compare_DateOfBirthTypo", func(t *testing.T) {
+		typo := john
+		typo.DateOfBirth = date("1980-05-18")
+		assert.Less(t, compare(john, typo).Score, compare(john, john).Score)
+		assert.Greater(t, compare(john, typo).Score, minimumScore)
+
+		swapped := john
+		swapped.DateOfBirth = date("1980-07-05")
+		similarity, _ := dateSimilarity(date("1980-05-07"), swapped.DateOfBirth)
+		assert.Equal(t, 0.75, similarity)
+	})
+
+	t.Run("Compare_SameHousehold", func(t *testing.T) {
+		mary := models.Patient{Id: 2, Name: "Mary Smith", DateOfBirth: date("1982-11-02"), PhoneNumber: "555 987 6543", Address: "12 High St"}
+		duplicate := compare(john, mary)
+		assert.Less(t, duplicate.Score, minimumScore)
+		assert.Less(t, duplicate.Probability, 0.5)
+	})
+
+	t.Run("Compare_Unrelated", func(t *testing.T) {
+		other := models.Patient{Id: 2, Name: "Alice Jones", DateOfBirth: date("1990-01-01"), PhoneNumber: "555 000 1111", Address: "9 Elm Avenue"}
+		duplicate := compare(john, other)
+		assert.Less(t, duplicate.Score, 0.0)
+		assert.Equal(t, 0.0, duplicate.Probability)
+	})
+
+	t.Run("Compare_MissingFieldsAreLeftOut", func(t *testing.T) {
+		duplicate := compare(john, models.Patient{Id: 2, Name: "John Smith"})
+		assert.Equal(t, []string{"name"}, fieldNames(duplicate))
+	})
+}
+
+func fieldNames(duplicate models.PotentialDuplicate) []string {
+	var names []string
+	for _, field := range duplicate.Fields {
+		names = append(names, field.Field)
+	}
+	return names
+}
+
+func TestAddressSimilarity(t *testing.T) {
+	similarity, known := addressSimilarity("12 High St.", "12 high street")
+	assert.True(t, known)
+	assert.Equal(t, 1.0, similarity)
+
+	_, known = addressSimilarity("", "12 High Street")
+	assert.False(t, known)
+}
+
+func TestFindPotentialDuplicates(t *testing.T) {
+	t.Run("FindPotentialDuplicates_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 1).Return(john, nil)
+		jon := models.Patient{Id: 4, Name: "Jon Smith", DateOfBirth: date("1980-05-17"), PhoneNumber: "555 987 6543"}
+		moved := models.Patient{Id: 7, Name: "John Smith", DateOfBirth: date("1980-05-17"), PhoneNumber: "555 111 2222"}
+		mockRepo.On("GetPatientsPage", mock.Anything, 0, pageSize).Return([]models.Patient{john, {Id: 2, Name: "Alice Jones", DateOfBirth: date("1990-01-01")}, moved}, nil)
+		mockRepo.On("GetPatientsPage", mock.Anything, 7, pageSize).Return([]models.Patient{jon}, nil)
+		mockRepo.On("GetPatientsPage", mock.Anything, 4, pageSize).Return([]models.Patient{}, nil)
+
+		duplicates, err := service.FindPotentialDuplicates(context.Background(), 1)
+		assert.Nil(t, err)
+		assert.Len(t, duplicates, 2)
+		assert.Equal(t, 4, duplicates[0].Patient.Id)
+		assert.Equal(t, 7, duplicates[1].Patient.Id)
+	})
+
+	t.Run("FindPotentialDuplicates_PatientNotFound", func(t *testing.T) {
+		_, mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 9).Return(models.Patient{}, customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.FindPotentialDuplicates(context.Background(), 9)
+		var inputError customerrors.InvalidInputError
+		assert.True(t, errors.As(err, &inputError))
+	})
+}
+
+func TestMergePatients(t *testing.T) {
+	t.Run("MergePatients_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockConditionSvc, mockAttatchmentSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 4).Return(nil)
+		mockRepo.On("ReassignEncounters", mock.Anything, 4, 1).Return(nil)
+		mockConditionSvc.On("MoveDiagnosedConditionsToPatient", mock.Anything, 4, 1).Return(nil)
+		mockAttatchmentSvc.On("MoveAttatchmentsToPatient", mock.Anything, 4, 1).Return(nil)
+		mockRepo.On("ReassignMedications", mock.Anything, 4, 1).Return(nil)
+		mockRepo.On("ReassignAllergies", mock.Anything, 4, 1).Return(nil)
+		mockRepo.On("ReassignObservations", mock.Anything, 4, 1).Return(nil)
+		mockRepo.On("InsertPatientLink", mock.Anything, mock.MatchedBy(func(link models.PatientLink) bool {
+			return link.PatientId == 1 && link.MergedPatientId == 4 && !link.MergedAt.IsZero()
+		})).Return(3, nil)
+		mockPatientSvc.On("DeletePatient", mock.Anything, 4).Return(nil)
+
+		link, err := service.MergePatients(context.Background(), 1, 4)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, link.Id)
+		assert.Equal(t, 1, link.PatientId)
+		assert.Equal(t, 4, link.MergedPatientId)
+		mockPatientSvc.AssertExpectations(t)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("MergePatients_IntoThemselves", func(t *testing.T) {
+		_, _, _, _, service := getMocksAndService()
+		_, err := service.MergePatients(context.Background(), 1, 1)
+		assert.Equal(t, customerrors.NewInvalidInputError("a patient cannot be merged into themselves"), err)
+	})
+
+	t.Run("MergePatients_PatientNotFound", func(t *testing.T) {
+		mockRepo, mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.MergePatients(context.Background(), 1, 4)
+		assert.Equal(t, customerrors.NewInvalidInputError("patient id not found"), err)
+		mockRepo.AssertNotCalled(t, "ReassignEncounters", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("MergePatients_DuplicateNotFound", func(t *testing.T) {
+		_, mockPatientSvc, mockConditionSvc, _, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 4).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.MergePatients(context.Background(), 1, 4)
+		assert.Equal(t, customerrors.NewInvalidInputError("patient id not found"), err)
+		mockConditionSvc.AssertNotCalled(t, "MoveDiagnosedConditionsToPatient", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("MergePatients_MoveFails", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockConditionSvc, mockAttatchmentSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 4).Return(nil)
+		mockRepo.On("ReassignEncounters", mock.Anything, 4, 1).Return(nil)
+		mockConditionSvc.On("MoveDiagnosedConditionsToPatient", mock.Anything, 4, 1).Return(nil)
+		mockAttatchmentSvc.On("MoveAttatchmentsToPatient", mock.Anything, 4, 1).Return(errors.New("store unavailable"))
+
+		_, err := service.MergePatients(context.Background(), 1, 4)
+		assert.NotNil(t, err)
+		mockRepo.AssertNotCalled(t, "InsertPatientLink", mock.Anything, mock.Anything)
+		mockPatientSvc.AssertNotCalled(t, "DeletePatient", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetPatientLinks(t *testing.T) {
+	t.Run("GetPatientLinks_Success", func(t *testing.T) {
+		mockRepo, _, _, _, service := getMocksAndService()
+		mockRepo.On("GetPatientLinks", mock.Anything, 1).Return([]models.PatientLink{{Id: 3, PatientId: 1, MergedPatientId: 4}}, nil)
+
+		links, err := service.GetPatientLinks(context.Background(), 1)
+		assert.Nil(t, err)
+		assert.Equal(t, []models.PatientLink{{Id: 3, PatientId: 1, MergedPatientId: 4}}, links)
+	})
+}
@@ -0,0 +1,31 @@
+package encounters
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type EncounterRepo interface {
+	InsertEncounter(ctx context.Context, encounter models.Encounter) (int, error)
+	GetEncounter(ctx context.Context, encounterId int) (models.Encounter, error)
+	UpdateEncounter(ctx context.Context, encounter models.Encounter) error
+	DeleteEncounter(ctx context.Context, encounterId int) error
+	RestoreEncounter(ctx context.Context, patientId int, encounterId int) error
+	GetEncountersByPatientId(ctx context.Context, patientId int) ([]models.Encounter, error)
+	DeleteEncountersByPatientId(ctx context.Context, patientId int) error
+	GetDiagnosedConditionsByEncounterId(ctx context.Context, encounterId int) ([]models.DiagnosedCondition, error)
+	GetAttatchmentsByEncounterId(ctx context.Context, encounterId int) ([]models.Attatchment, error)
+}
+
+type PatientService interface {
+	ValidatePatientId(ctx context.Context, patientId int) error
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
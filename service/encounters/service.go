@@ -0,0 +1,242 @@
+package encounters
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var encounterTypes = map[string]bool{
+	models.EncounterTypeAmbulatory: true,
+	models.EncounterTypeEmergency:  true,
+	models.EncounterTypeInpatient:  true,
+	models.EncounterTypeHomeHealth: true,
+	models.EncounterTypeVirtual:    true,
+}
+
+type EncounterService struct {
+	repo       EncounterRepo
+	patientSvc PatientService
+	tracer     Tracer
+}
+
+func NewEncounterService(repo EncounterRepo, patientSvc PatientService, tracer Tracer) EncounterService {
+	return EncounterService{
+		repo:       repo,
+		patientSvc: patientSvc,
+		tracer:     tracer,
+	}
+}
+
+func (s EncounterService) AddEncounterToPatient(ctx context.Context, patientId int, details models.EncounterRequest) (models.Encounter, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "AddEncounterToPatient")
+	defer span.End()
+	s.setDetailAttributes(ctx, details)
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	details, err := s.validateDetails(ctx, details)
+	if err != nil {
+		return models.Encounter{}, err
+	}
+
+	err = s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return models.Encounter{}, err
+	}
+
+	encounter := models.Encounter{
+		PatientId:        patientId,
+		EncounterRequest: details,
+	}
+
+	id, err := s.repo.InsertEncounter(ctx, encounter)
+	if err != nil {
+		return models.Encounter{}, s.tracer.RecordError(ctx, fmt.Errorf("error inserting encounter %w", err))
+	}
+
+	encounter.Id = id
+	return encounter, nil
+}
+
+func (s EncounterService) GetEncounter(ctx context.Context, patientId int, encounterId int) (models.Encounter, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetEncounter")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("encounterId", encounterId))
+
+	return s.getPatientEncounter(ctx, patientId, encounterId)
+}
+
+func (s EncounterService) GetPatientEncounters(ctx context.Context, patientId int) ([]models.Encounter, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetPatientEncounters")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return nil, err
+	}
+
+	encounters, err := s.repo.GetEncountersByPatientId(ctx, patientId)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting encounters for patient %w", err))
+	}
+
+	return encounters, nil
+}
+
+func (s EncounterService) UpdateEncounter(ctx context.Context, patientId int, encounterId int, details models.EncounterRequest) (models.Encounter, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "UpdateEncounter")
+	defer span.End()
+	s.setDetailAttributes(ctx, details)
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("encounterId", encounterId))
+
+	details, err := s.validateDetails(ctx, details)
+	if err != nil {
+		return models.Encounter{}, err
+	}
+
+	encounter, err := s.getPatientEncounter(ctx, patientId, encounterId)
+	if err != nil {
+		return models.Encounter{}, err
+	}
+
+	encounter.EncounterRequest = details
+	err = s.repo.UpdateEncounter(ctx, encounter)
+	if err != nil {
+		return models.Encounter{}, s.tracer.RecordError(ctx, fmt.Errorf("error updating encounter %w", err))
+	}
+
+	return encounter, nil
+}
+
+func (s EncounterService) DeleteEncounter(ctx context.Context, patientId int, encounterId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "DeleteEncounter")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("encounterId", encounterId))
+
+	_, err := s.getPatientEncounter(ctx, patientId, encounterId)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteEncounter(ctx, encounterId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error deleting encounter %w", err))
+	}
+
+	return nil
+}
+
+func (s EncounterService) RestoreEncounter(ctx context.Context, patientId int, encounterId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "RestoreEncounter")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("encounterId", encounterId))
+
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.RestoreEncounter(ctx, patientId, encounterId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error restoring encounter %w", err))
+	}
+
+	return nil
+}
+
+func (s EncounterService) DeletePatientEncounters(ctx context.Context, patientId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "DeletePatientEncounters")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.repo.DeleteEncountersByPatientId(ctx, patientId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error deleting encounters for patient %w", err))
+	}
+
+	return nil
+}
+
+// the conditions and attatchments documented at the encounter, retrieved together with it
+func (s EncounterService) GetEncounterDocumentation(ctx context.Context, patientId int, encounterId int) (models.EncounterDocumentation, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetEncounterDocumentation")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("encounterId", encounterId))
+
+	encounter, err := s.getPatientEncounter(ctx, patientId, encounterId)
+	if err != nil {
+		return models.EncounterDocumentation{}, err
+	}
+
+	conditions, err := s.repo.GetDiagnosedConditionsByEncounterId(ctx, encounterId)
+	if err != nil {
+		return models.EncounterDocumentation{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting diagnosed conditions for encounter %w", err))
+	}
+
+	attatchments, err := s.repo.GetAttatchmentsByEncounterId(ctx, encounterId)
+	if err != nil {
+		return models.EncounterDocumentation{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting attatchments for encounter %w", err))
+	}
+
+	return models.EncounterDocumentation{
+		Encounter:           encounter,
+		DiagnosedConditions: conditions,
+		Attatchments:        attatchments,
+	}, nil
+}
+
+// records can only be documented at an encounter of the same patient
+func (s EncounterService) ValidateEncounterId(ctx context.Context, patientId int, encounterId int) error {
+	_, err := s.getPatientEncounter(ctx, patientId, encounterId)
+	return err
+}
+
+// encounters are addressed through their patient, so one belonging to another patient is treated as missing
+func (s EncounterService) getPatientEncounter(ctx context.Context, patientId int, encounterId int) (models.Encounter, error) {
+	encounter, err := s.repo.GetEncounter(ctx, encounterId)
+	if err != nil {
+		return models.Encounter{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting encounter %w", err))
+	}
+	if encounter.PatientId != patientId {
+		return models.Encounter{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("encounter not found"))
+	}
+	return encounter, nil
+}
+
+func (s EncounterService) validateDetails(ctx context.Context, details models.EncounterRequest) (models.EncounterRequest, error) {
+	details.Type = strings.ToLower(strings.TrimSpace(details.Type))
+	if !encounterTypes[details.Type] {
+		return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a valid encounter type, expected ambulatory, emergency, inpatient, home-health or virtual", details.Type)))
+	}
+	if details.Start.IsZero() {
+		return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("start is required"))
+	}
+	if details.End != nil && details.End.Before(details.Start) {
+		return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("end must not be before the start"))
+	}
+	return details, nil
+}
+
+func (s EncounterService) setDetailAttributes(ctx context.Context, details models.EncounterRequest) {
+	s.tracer.SetAttributes(ctx,
+		attribute.String("type", details.Type),
+		attribute.String("start", fmt.Sprintf("%v", details.Start)),
+		attribute.String("end", fmt.Sprintf("%v", details.End)),
+		attribute.String("location", details.Location),
+		attribute.String("practitioner", details.Practitioner))
+}
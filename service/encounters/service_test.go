@@ -0,0 +1,418 @@
+package encounters
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockEncounterRepo struct {
+	mock.Mock
+}
+
+func (m *MockEncounterRepo) InsertEncounter(ctx context.Context, encounter models.Encounter) (int, error) {
+	args := m.Called(ctx, encounter)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockEncounterRepo) GetEncounter(ctx context.Context, encounterId int) (models.Encounter, error) {
+	args := m.Called(ctx, encounterId)
+	return args.Get(0).(models.Encounter), args.Error(1)
+}
+
+func (m *MockEncounterRepo) UpdateEncounter(ctx context.Context, encounter models.Encounter) error {
+	args := m.Called(ctx, encounter)
+	return args.Error(0)
+}
+
+func (m *MockEncounterRepo) DeleteEncounter(ctx context.Context, encounterId int) error {
+	args := m.Called(ctx, encounterId)
+	return args.Error(0)
+}
+
+func (m *MockEncounterRepo) RestoreEncounter(ctx context.Context, patientId int, encounterId int) error {
+	args := m.Called(ctx, patientId, encounterId)
+	return args.Error(0)
+}
+
+func (m *MockEncounterRepo) GetEncountersByPatientId(ctx context.Context, patientId int) ([]models.Encounter, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).([]models.Encounter), args.Error(1)
+}
+
+func (m *MockEncounterRepo) DeleteEncountersByPatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+func (m *MockEncounterRepo) GetDiagnosedConditionsByEncounterId(ctx context.Context, encounterId int) ([]models.DiagnosedCondition, error) {
+	args := m.Called(ctx, encounterId)
+	return args.Get(0).([]models.DiagnosedCondition), args.Error(1)
+}
+
+func (m *MockEncounterRepo) GetAttatchmentsByEncounterId(ctx context.Context, encounterId int) ([]models.Attatchment, error) {
+	args := m.Called(ctx, encounterId)
+	return args.Get(0).([]models.Attatchment), args.Error(1)
+}
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) ValidatePatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService() (*MockEncounterRepo, *MockPatientService, EncounterService) {
+	mockRepo := new(MockEncounterRepo)
+	mockPatientSvc := new(MockPatientService)
+	service := NewEncounterService(mockRepo, mockPatientSvc, new(MockTracer))
+	return mockRepo, mockPatientSvc, service
+}
+
+func getDetails() models.EncounterRequest {
+	return models.EncounterRequest{
+		Type:         models.EncounterTypeAmbulatory,
+		Start:        time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		Location:     "Main Street Clinic",
+		Practitioner: "Dr. Alice Jones",
+	}
+}
+
+func TestAddEncounterToPatient(t *testing.T) {
+	patientId := 1
+
+	t.Run("AddEncounter_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := getDetails()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertEncounter", mock.Anything, models.Encounter{PatientId: patientId, EncounterRequest: details}).Return(4, nil)
+
+		encounter, err := service.AddEncounterToPatient(context.Background(), patientId, details)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, encounter.Id)
+		assert.Equal(t, patientId, encounter.PatientId)
+		assert.Equal(t, details, encounter.EncounterRequest)
+
+		mockRepo.AssertExpectations(t)
+		mockPatientSvc.AssertExpectations(t)
+	})
+
+	t.Run("AddEncounter_InvalidPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.AddEncounterToPatient(context.Background(), patientId, getDetails())
+		assert.NotNil(t, err)
+		assert.Equal(t, "patient id not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertEncounter", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddEncounter_NormalizesType", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := getDetails()
+		details.Type = " Ambulatory "
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertEncounter", mock.Anything, models.Encounter{PatientId: patientId, EncounterRequest: getDetails()}).Return(1, nil)
+
+		encounter, err := service.AddEncounterToPatient(context.Background(), patientId, details)
+		assert.Nil(t, err)
+		assert.Equal(t, models.EncounterTypeAmbulatory, encounter.Type)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("AddEncounter_InvalidType", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := getDetails()
+		details.Type = "checkup"
+
+		_, err := service.AddEncounterToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, `"checkup" is not a valid encounter type, expected ambulatory, emergency, inpatient, home-health or virtual`, err.Error())
+
+		mockPatientSvc.AssertNotCalled(t, "ValidatePatientId", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "InsertEncounter", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddEncounter_MissingStart", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.Start = time.Time{}
+
+		_, err := service.AddEncounterToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "start is required", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertEncounter", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddEncounter_EndBeforeStart", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		end := details.Start.Add(-time.Hour)
+		details.End = &end
+
+		_, err := service.AddEncounterToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "end must not be before the start", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertEncounter", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddEncounter_RepoError", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertEncounter", mock.Anything, mock.Anything).Return(0, fmt.Errorf("db error"))
+
+		_, err := service.AddEncounterToPatient(context.Background(), patientId, getDetails())
+		assert.NotNil(t, err)
+		assert.Equal(t, "error inserting encounter db error", err.Error())
+	})
+}
+
+func TestGetEncounter(t *testing.T) {
+	encounter := models.Encounter{Id: 3, PatientId: 1, EncounterRequest: getDetails()}
+
+	t.Run("GetEncounter_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(encounter, nil)
+
+		result, err := service.GetEncounter(context.Background(), 1, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, encounter, result)
+	})
+
+	t.Run("GetEncounter_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(encounter, nil)
+
+		_, err := service.GetEncounter(context.Background(), 2, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "encounter not found", err.Error())
+	})
+
+	t.Run("GetEncounter_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(models.Encounter{}, fmt.Errorf("db error"))
+
+		_, err := service.GetEncounter(context.Background(), 1, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting encounter db error", err.Error())
+	})
+}
+
+func TestGetPatientEncounters(t *testing.T) {
+	t.Run("GetPatientEncounters_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		encounters := []models.Encounter{{Id: 1, PatientId: 1, EncounterRequest: getDetails()}}
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("GetEncountersByPatientId", mock.Anything, 1).Return(encounters, nil)
+
+		result, err := service.GetPatientEncounters(context.Background(), 1)
+		assert.Nil(t, err)
+		assert.Equal(t, encounters, result)
+	})
+
+	t.Run("GetPatientEncounters_InvalidPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.GetPatientEncounters(context.Background(), 1)
+		assert.NotNil(t, err)
+
+		mockRepo.AssertNotCalled(t, "GetEncountersByPatientId", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUpdateEncounter(t *testing.T) {
+	existing := models.Encounter{Id: 3, PatientId: 1, EncounterRequest: getDetails()}
+
+	t.Run("UpdateEncounter_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.Location = "Hospital Ward 4"
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(existing, nil)
+		mockRepo.On("UpdateEncounter", mock.Anything, models.Encounter{Id: 3, PatientId: 1, EncounterRequest: details}).Return(nil)
+
+		encounter, err := service.UpdateEncounter(context.Background(), 1, 3, details)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hospital Ward 4", encounter.Location)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("UpdateEncounter_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(existing, nil)
+
+		_, err := service.UpdateEncounter(context.Background(), 2, 3, getDetails())
+		assert.NotNil(t, err)
+		assert.Equal(t, "encounter not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "UpdateEncounter", mock.Anything, mock.Anything)
+	})
+
+	t.Run("UpdateEncounter_InvalidDetails", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.Type = ""
+
+		_, err := service.UpdateEncounter(context.Background(), 1, 3, details)
+		assert.NotNil(t, err)
+
+		mockRepo.AssertNotCalled(t, "GetEncounter", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeleteEncounter(t *testing.T) {
+	existing := models.Encounter{Id: 3, PatientId: 1, EncounterRequest: getDetails()}
+
+	t.Run("DeleteEncounter_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(existing, nil)
+		mockRepo.On("DeleteEncounter", mock.Anything, 3).Return(nil)
+
+		err := service.DeleteEncounter(context.Background(), 1, 3)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("DeleteEncounter_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(existing, nil)
+
+		err := service.DeleteEncounter(context.Background(), 2, 3)
+		assert.NotNil(t, err)
+
+		mockRepo.AssertNotCalled(t, "DeleteEncounter", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRestoreEncounter(t *testing.T) {
+	t.Run("RestoreEncounter_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("RestoreEncounter", mock.Anything, 1, 3).Return(nil)
+
+		err := service.RestoreEncounter(context.Background(), 1, 3)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("RestoreEncounter_RepoError", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("RestoreEncounter", mock.Anything, 1, 3).Return(fmt.Errorf("db error"))
+
+		err := service.RestoreEncounter(context.Background(), 1, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error restoring encounter db error", err.Error())
+	})
+}
+
+func TestDeletePatientEncounters(t *testing.T) {
+	t.Run("DeletePatientEncounters_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("DeleteEncountersByPatientId", mock.Anything, 1).Return(nil)
+
+		err := service.DeletePatientEncounters(context.Background(), 1)
+		assert.Nil(t, err)
+	})
+
+	t.Run("DeletePatientEncounters_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("DeleteEncountersByPatientId", mock.Anything, 1).Return(fmt.Errorf("db error"))
+
+		err := service.DeletePatientEncounters(context.Background(), 1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error deleting encounters for patient db error", err.Error())
+	})
+}
+
+func TestGetEncounterDocumentation(t *testing.T) {
+	existing := models.Encounter{Id: 3, PatientId: 1, EncounterRequest: getDetails()}
+
+	t.Run("GetEncounterDocumentation_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		conditions := []models.DiagnosedCondition{{Id: 5, PatientId: 1, EncounterId: 3}}
+		attatchments := []models.Attatchment{{Id: 6, PatientId: 1, EncounterId: 3}}
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(existing, nil)
+		mockRepo.On("GetDiagnosedConditionsByEncounterId", mock.Anything, 3).Return(conditions, nil)
+		mockRepo.On("GetAttatchmentsByEncounterId", mock.Anything, 3).Return(attatchments, nil)
+
+		documentation, err := service.GetEncounterDocumentation(context.Background(), 1, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, existing, documentation.Encounter)
+		assert.Equal(t, conditions, documentation.DiagnosedConditions)
+		assert.Equal(t, attatchments, documentation.Attatchments)
+	})
+
+	t.Run("GetEncounterDocumentation_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(existing, nil)
+
+		_, err := service.GetEncounterDocumentation(context.Background(), 2, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "encounter not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "GetDiagnosedConditionsByEncounterId", mock.Anything, mock.Anything)
+	})
+
+	t.Run("GetEncounterDocumentation_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(existing, nil)
+		mockRepo.On("GetDiagnosedConditionsByEncounterId", mock.Anything, 3).Return([]models.DiagnosedCondition{}, fmt.Errorf("db error"))
+
+		_, err := service.GetEncounterDocumentation(context.Background(), 1, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting diagnosed conditions for encounter db error", err.Error())
+	})
+}
+
+func TestValidateEncounterId(t *testing.T) {
+	existing := models.Encounter{Id: 3, PatientId: 1, EncounterRequest: getDetails()}
+
+	t.Run("ValidateEncounterId_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(existing, nil)
+
+		assert.Nil(t, service.ValidateEncounterId(context.Background(), 1, 3))
+	})
+
+	t.Run("ValidateEncounterId_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetEncounter", mock.Anything, 3).Return(existing, nil)
+
+		err := service.ValidateEncounterId(context.Background(), 2, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "encounter not found", err.Error())
+	})
+}
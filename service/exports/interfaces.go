@@ -0,0 +1,36 @@
+package exports
+
+import (
+	"context"
+	"io"
+	"mcg-app-backend/service/models"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ExportRepo interface {
+	InsertExportJob(ctx context.Context, job models.ExportJob) (int, error)
+	UpdateExportJob(ctx context.Context, job models.ExportJob) error
+	GetExportJob(ctx context.Context, id int) (models.ExportJob, error)
+	DeleteExportJob(ctx context.Context, id int) error
+	GetExportJobsCompletedBefore(ctx context.Context, cutoff time.Time) ([]models.ExportJob, error)
+	GetPatientsPage(ctx context.Context, afterId int, limit int) ([]models.Patient, error)
+	GetDiagnosedConditionsPage(ctx context.Context, afterId int, limit int) ([]models.DiagnosedCondition, error)
+	GetAttatchmentsPage(ctx context.Context, afterId int, limit int) ([]models.Attatchment, error)
+}
+
+// where exported files are written, and read back from when they are downloaded
+type FileStore interface {
+	Create(ctx context.Context, name string) (io.WriteCloser, error)
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	Remove(ctx context.Context, name string) error
+	List(ctx context.Context) ([]string, error)
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
@@ -0,0 +1,388 @@
+package exports
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/fhir"
+	"mcg-app-backend/service/models"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// the number of records read from the repo at a time, so an export never holds more than this many in memory
+const pageSize = 500
+
+// resource types in the order they are exported
+var resourceTypes = []string{"Patient", "Condition", "DocumentReference"}
+
+var outputFormats = []string{"application/fhir+ndjson", "application/ndjson", "ndjson"}
+
+// returned while saving the progress of an export which has been deleted, to stop it
+var errExportDeleted = errors.New("export has been deleted")
+
+type ExportService struct {
+	repo   ExportRepo
+	files  FileStore
+	tracer Tracer
+	//how long after completing an export its files are kept for
+	expiry time.Duration
+}
+
+func NewExportService(repo ExportRepo, files FileStore, tracer Tracer, expiry time.Duration) ExportService {
+	return ExportService{
+		repo:   repo,
+		files:  files,
+		tracer: tracer,
+		expiry: expiry,
+	}
+}
+
+// starts a FHIR Bulk Data export of every patient, and their conditions and documents, in the background.  request is
+// the url the export was asked for with, which is reported back in its manifest
+func (s ExportService) StartExport(ctx context.Context, request string, params url.Values) (models.ExportJob, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "StartExport")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("request", request))
+
+	types, err := parseParams(params)
+	if err != nil {
+		return models.ExportJob{}, s.tracer.RecordError(ctx, err)
+	}
+
+	job := models.ExportJob{
+		Request:         request,
+		Types:           types,
+		Status:          models.ExportStatusRunning,
+		TransactionTime: time.Now(),
+		Output:          []models.ExportFile{},
+	}
+	id, err := s.repo.InsertExportJob(ctx, job)
+	if err != nil {
+		return models.ExportJob{}, s.tracer.RecordError(ctx, fmt.Errorf("error inserting export job %w", err))
+	}
+	job.Id = id
+
+	go s.run(context.WithoutCancel(ctx), job)
+	return job, nil
+}
+
+func (s ExportService) GetExport(ctx context.Context, id string) (models.ExportJob, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetExport")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("id", id))
+
+	jobId, err := strconv.Atoi(id)
+	if err != nil {
+		return models.ExportJob{}, s.tracer.RecordError(ctx, customerrors.NewNotFoundError(fmt.Sprintf("export %v not found", id)))
+	}
+	job, err := s.repo.GetExportJob(ctx, jobId)
+	if err != nil {
+		var inputError customerrors.InvalidInputError
+		if errors.As(err, &inputError) {
+			return models.ExportJob{}, s.tracer.RecordError(ctx, customerrors.NewNotFoundError(fmt.Sprintf("export %v not found", id)))
+		}
+		return models.ExportJob{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting export job %w", err))
+	}
+	return job, nil
+}
+
+// opens the file of resources of the given type written by a completed export
+func (s ExportService) OpenExportFile(ctx context.Context, id string, resourceType string) (io.ReadCloser, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "OpenExportFile")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.String("id", id),
+		attribute.String("resourceType", resourceType))
+
+	job, err := s.GetExport(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	index := slices.IndexFunc(job.Output, func(file models.ExportFile) bool { return file.Type == resourceType })
+	if job.Status != models.ExportStatusCompleted || index < 0 {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewNotFoundError(fmt.Sprintf("%v file of export %v not found", resourceType, id)))
+	}
+	file, err := s.files.Open(ctx, job.Output[index].Name)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error opening export file %w", err))
+	}
+	return file, nil
+}
+
+// deletes the export and its files.  A running export is stopped
+func (s ExportService) DeleteExport(ctx context.Context, id string) error {
+	ctx, span := s.tracer.NewSpan(ctx, "DeleteExport")
+	defer span.End()
+
+	job, err := s.GetExport(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = s.repo.DeleteExportJob(ctx, job.Id)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error deleting export job %w", err))
+	}
+	err = s.files.Remove(ctx, directory(job.Id))
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error removing export files %w", err))
+	}
+	return nil
+}
+
+// deletes exports which completed longer ago than the expiry, then removes any files which do not belong to an export,
+// such as those of exports deleted while they were running, or written before a restart
+func (s ExportService) ExpireExports(ctx context.Context, now time.Time) error {
+	ctx, span := s.tracer.NewSpan(ctx, "ExpireExports")
+	defer span.End()
+	cutoff := now.Add(-s.expiry)
+	s.tracer.SetAttributes(ctx, attribute.String("cutoff", fmt.Sprintf("%v", cutoff)))
+
+	expired, err := s.repo.GetExportJobsCompletedBefore(ctx, cutoff)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error getting expired export jobs %w", err))
+	}
+	for _, job := range expired {
+		err = s.repo.DeleteExportJob(ctx, job.Id)
+		if err != nil {
+			return s.tracer.RecordError(ctx, fmt.Errorf("error deleting export job %w", err))
+		}
+	}
+
+	names, err := s.files.List(ctx)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error listing export files %w", err))
+	}
+	removed := 0
+	for _, name := range names {
+		//anything not named like an export's directory was not written by one, so is left alone
+		id, err := strconv.Atoi(name)
+		if err != nil || directory(id) != name {
+			continue
+		}
+		_, err = s.repo.GetExportJob(ctx, id)
+		var inputError customerrors.InvalidInputError
+		if !errors.As(err, &inputError) {
+			if err != nil {
+				return s.tracer.RecordError(ctx, fmt.Errorf("error getting export job %w", err))
+			}
+			continue
+		}
+		err = s.files.Remove(ctx, name)
+		if err != nil {
+			return s.tracer.RecordError(ctx, fmt.Errorf("error removing export files %w", err))
+		}
+		removed++
+	}
+
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("expired.exports", len(expired)),
+		attribute.Int("removed.directories", removed))
+	return nil
+}
+
+// runs ExpireExports straight away, so files left from before a restart are removed before any new export is written,
+// and then every interval until ctx is cancelled.  A failure is passed to onError and tried again at the next interval
+func (s ExportService) Start(ctx context.Context, interval time.Duration, onError func(err error)) {
+	err := s.ExpireExports(ctx, time.Now())
+	if err != nil {
+		onError(err)
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				err := s.ExpireExports(ctx, now)
+				if err != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// only the resource types to export and the format of the files can be chosen.  Other parameters of the Bulk Data
+// specification, such as _since, are rejected rather than ignored, as ignoring them would export more than was asked for
+func parseParams(params url.Values) ([]string, error) {
+	types := resourceTypes
+	for name, values := range params {
+		switch name {
+		case "_type":
+			var requested []string
+			for _, value := range values {
+				for _, resourceType := range strings.Split(value, ",") {
+					resourceType = strings.TrimSpace(resourceType)
+					if !slices.Contains(resourceTypes, resourceType) {
+						return nil, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a supported resource type, expected any of %v", resourceType, strings.Join(resourceTypes, ", ")))
+					}
+					requested = append(requested, resourceType)
+				}
+			}
+			types = slices.DeleteFunc(slices.Clone(resourceTypes), func(resourceType string) bool { return !slices.Contains(requested, resourceType) })
+		case "_outputFormat":
+			for _, value := range values {
+				if !slices.Contains(outputFormats, value) {
+					return nil, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a supported output format, expected application/fhir+ndjson", value))
+				}
+			}
+		default:
+			return nil, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a supported export parameter, expected _type or _outputFormat", name))
+		}
+	}
+	return types, nil
+}
+
+// writes each resource type to its own file, saving the job's progress after each page so it can be polled
+func (s ExportService) run(ctx context.Context, job models.ExportJob) models.ExportJob {
+	ctx, span := s.tracer.NewSpan(ctx, "RunExport")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("id", job.Id),
+		attribute.StringSlice("types", job.Types))
+
+	job.Status = models.ExportStatusCompleted
+	for _, resourceType := range job.Types {
+		err := s.exportType(ctx, &job, resourceType)
+		if errors.Is(err, errExportDeleted) {
+			s.removeDeleted(ctx, job)
+			return job
+		}
+		if err != nil {
+			s.tracer.RecordError(ctx, err)
+			job.Status = models.ExportStatusFailed
+			job.Error = fmt.Sprintf("error exporting %v resources", resourceType)
+			break
+		}
+	}
+
+	completedAt := time.Now()
+	job.CompletedAt = &completedAt
+	if errors.Is(s.saveProgress(ctx, job), errExportDeleted) {
+		s.removeDeleted(ctx, job)
+	}
+	return job
+}
+
+// files written after their export was deleted are removed as soon as it is noticed, rather than waiting for them to
+// expire
+func (s ExportService) removeDeleted(ctx context.Context, job models.ExportJob) {
+	err := s.files.Remove(ctx, directory(job.Id))
+	if err != nil {
+		s.tracer.RecordError(ctx, fmt.Errorf("error removing files of deleted export %w", err))
+	}
+}
+
+// every file of an export is written beneath a directory named by its id
+func directory(id int) string {
+	return strconv.Itoa(id)
+}
+
+// the file is only created once there is a resource to write, so types without any are left out of the export
+func (s ExportService) exportType(ctx context.Context, job *models.ExportJob, resourceType string) error {
+	name := fmt.Sprintf("%v/%v.ndjson", directory(job.Id), resourceType)
+	var file io.WriteCloser
+	var writer *bufio.Writer
+	count := 0
+	afterId := 0
+	for {
+		resources, lastId, err := s.page(ctx, resourceType, afterId)
+		if err != nil {
+			return err
+		}
+		if len(resources) == 0 {
+			break
+		}
+		if file == nil {
+			file, err = s.files.Create(ctx, name)
+			if err != nil {
+				return fmt.Errorf("error creating export file %w", err)
+			}
+			defer file.Close()
+			writer = bufio.NewWriter(file)
+		}
+		encoder := json.NewEncoder(writer)
+		for _, resource := range resources {
+			err = encoder.Encode(resource)
+			if err != nil {
+				return fmt.Errorf("error writing export file %w", err)
+			}
+		}
+		count += len(resources)
+		job.ExportedResources += len(resources)
+		err = s.saveProgress(ctx, *job)
+		if err != nil {
+			return err
+		}
+		afterId = lastId
+	}
+	if file == nil {
+		return nil
+	}
+
+	err := writer.Flush()
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("error writing export file %w", err)
+	}
+	job.Output = append(job.Output, models.ExportFile{Type: resourceType, Count: count, Name: name})
+	return nil
+}
+
+// the next page of resources of the given type, along with the id to read the page after it from
+func (s ExportService) page(ctx context.Context, resourceType string, afterId int) ([]any, int, error) {
+	switch resourceType {
+	case "Patient":
+		patients, err := s.repo.GetPatientsPage(ctx, afterId, pageSize)
+		return toResources(patients, err, func(patient models.Patient) int { return patient.Id }, fhir.ToPatient)
+	case "Condition":
+		conditions, err := s.repo.GetDiagnosedConditionsPage(ctx, afterId, pageSize)
+		return toResources(conditions, err, func(condition models.DiagnosedCondition) int { return condition.Id }, fhir.ToCondition)
+	case "DocumentReference":
+		attatchments, err := s.repo.GetAttatchmentsPage(ctx, afterId, pageSize)
+		return toResources(attatchments, err, func(attatchment models.Attatchment) int { return attatchment.Id }, fhir.ToDocumentReference)
+	default:
+		return nil, 0, fmt.Errorf("%v is not a supported resource type", resourceType)
+	}
+}
+
+func toResources[T any, R any](records []T, err error, id func(T) int, toResource func(T) R) ([]any, int, error) {
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting page of records %w", err)
+	}
+	resources := make([]any, 0, len(records))
+	lastId := 0
+	for _, record := range records {
+		resources = append(resources, toResource(record))
+		lastId = id(record)
+	}
+	return resources, lastId, nil
+}
+
+// a failure to save progress is only recorded, as the export carries on regardless and a later save may succeed.  The
+// only error returned is errExportDeleted, when the export has been deleted and should stop
+func (s ExportService) saveProgress(ctx context.Context, job models.ExportJob) error {
+	err := s.repo.UpdateExportJob(ctx, job)
+	var inputError customerrors.InvalidInputError
+	if errors.As(err, &inputError) {
+		return errExportDeleted
+	}
+	if err != nil {
+		s.tracer.RecordError(ctx, fmt.Errorf("error updating export job %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package exports
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockExportRepo struct {
+	mock.Mock
+}
+
+func (m *MockExportRepo) InsertExportJob(ctx context.Context, job models.ExportJob) (int, error) {
+	args := m.Called(ctx, job)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockExportRepo) UpdateExportJob(ctx context.Context, job models.ExportJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockExportRepo) GetExportJob(ctx context.Context, id int) (models.ExportJob, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.ExportJob), args.Error(1)
+}
+
+func (m *MockExportRepo) DeleteExportJob(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockExportRepo) GetExportJobsCompletedBefore(ctx context.Context, cutoff time.Time) ([]models.ExportJob, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).([]models.ExportJob), args.Error(1)
+}
+
+func (m *MockExportRepo) GetPatientsPage(ctx context.Context, afterId int, limit int) ([]models.Patient, error) {
+	args := m.Called(ctx, afterId, limit)
+	return args.Get(0).([]models.Patient), args.Error(1)
+}
+
+func (m *MockExportRepo) GetDiagnosedConditionsPage(ctx context.Context, afterId int, limit int) ([]models.DiagnosedCondition, error) {
+	args := m.Called(ctx, afterId, limit)
+	return args.Get(0).([]models.DiagnosedCondition), args.Error(1)
+}
+
+func (m *MockExportRepo) GetAttatchmentsPage(ctx context.Context, afterId int, limit int) ([]models.Attatchment, error) {
+	args := m.Called(ctx, afterId, limit)
+	return args.Get(0).([]models.Attatchment), args.Error(1)
+}
+
+// keeps files in memory
+type MockFileStore struct {
+	files map[string]*bytes.Buffer
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (w nopWriteCloser) Close() error {
+	return nil
+}
+
+func (m *MockFileStore) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	m.files[name] = &bytes.Buffer{}
+	return nopWriteCloser{m.files[name]}, nil
+}
+
+func (m *MockFileStore) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	file, exists := m.files[name]
+	if !exists {
+		return nil, errors.New("file does not exist")
+	}
+	return io.NopCloser(bytes.NewReader(file.Bytes())), nil
+}
+
+func (m *MockFileStore) Remove(ctx context.Context, name string) error {
+	for file := range m.files {
+		if strings.HasPrefix(file, name+"/") {
+			delete(m.files, file)
+		}
+	}
+	return nil
+}
+
+func (m *MockFileStore) List(ctx context.Context) ([]string, error) {
+	var names []string
+	for file := range m.files {
+		name, _, _ := strings.Cut(file, "/")
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService() (*MockExportRepo, *MockFileStore, ExportService) {
+	mockRepo := new(MockExportRepo)
+	mockFiles := &MockFileStore{files: make(map[string]*bytes.Buffer)}
+	service := NewExportService(mockRepo, mockFiles, new(MockTracer), time.Hour)
+	return mockRepo, mockFiles, service
+}
+
+func TestStartExport(t *testing.T) {
+	t.Run("StartExport_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("InsertExportJob", mock.Anything, mock.MatchedBy(func(job models.ExportJob) bool {
+			return job.Status == models.ExportStatusRunning && assert.ObjectsAreEqual([]string{"Patient", "DocumentReference"}, job.Types)
+		})).Return(4, nil)
+		mockRepo.On("GetPatientsPage", mock.Anything, mock.Anything, mock.Anything).Return([]models.Patient{}, nil).Maybe()
+		mockRepo.On("GetAttatchmentsPage", mock.Anything, mock.Anything, mock.Anything).Return([]models.Attatchment{}, nil).Maybe()
+		mockRepo.On("UpdateExportJob", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+		job, err := service.StartExport(context.Background(), "http://localhost/fhir/R4/$export", url.Values{
+			"_type":         {"DocumentReference,Patient"},
+			"_outputFormat": {"application/fhir+ndjson"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 4, job.Id)
+		assert.Equal(t, "http://localhost/fhir/R4/$export", job.Request)
+	})
+
+	t.Run("StartExport_UnsupportedResourceType", func(t *testing.T) {
+		_, _, service := getMocksAndService()
+		_, err := service.StartExport(context.Background(), "", url.Values{"_type": {"Observation"}})
+		assert.Equal(t, customerrors.NewInvalidInputError(`"Observation" is not a supported resource type, expected any of Patient, Condition, DocumentReference`), err)
+	})
+
+	t.Run("StartExport_UnsupportedParameter", func(t *testing.T) {
+		_, _, service := getMocksAndService()
+		_, err := service.StartExport(context.Background(), "", url.Values{"_since": {"2024-01-01T00:00:00Z"}})
+		assert.Equal(t, customerrors.NewInvalidInputError(`"_since" is not a supported export parameter, expected _type or _outputFormat`), err)
+	})
+
+	t.Run("StartExport_UnsupportedOutputFormat", func(t *testing.T) {
+		_, _, service := getMocksAndService()
+		_, err := service.StartExport(context.Background(), "", url.Values{"_outputFormat": {"text/csv"}})
+		assert.Equal(t, customerrors.NewInvalidInputError(`"text/csv" is not a supported output format, expected application/fhir+ndjson`), err)
+	})
+}
+
+func TestRun(t *testing.T) {
+	t.Run("Run_WritesEachTypeAPageAtATime", func(t *testing.T) {
+		mockRepo, mockFiles, service := getMocksAndService()
+		mockRepo.On("GetPatientsPage", mock.Anything, 0, pageSize).Return([]models.Patient{{Id: 1, Name: "Jane Smith"}, {Id: 3, Name: "John Smith"}}, nil)
+		mockRepo.On("GetPatientsPage", mock.Anything, 3, pageSize).Return([]models.Patient{{Id: 8, Name: "Sam Lee"}}, nil)
+		mockRepo.On("GetPatientsPage", mock.Anything, 8, pageSize).Return([]models.Patient{}, nil)
+		mockRepo.On("GetDiagnosedConditionsPage", mock.Anything, 0, pageSize).Return([]models.DiagnosedCondition{}, nil)
+		mockRepo.On("UpdateExportJob", mock.Anything, mock.Anything).Return(nil)
+
+		job := service.run(context.Background(), models.ExportJob{Id: 2, Types: []string{"Patient", "Condition"}, Status: models.ExportStatusRunning})
+
+		assert.Equal(t, models.ExportStatusCompleted, job.Status)
+		assert.NotNil(t, job.CompletedAt)
+		assert.Equal(t, 3, job.ExportedResources)
+		assert.Equal(t, []models.ExportFile{{Type: "Patient", Count: 3, Name: "2/Patient.ndjson"}}, job.Output)
+
+		lines := strings.Split(strings.TrimSuffix(mockFiles.files["2/Patient.ndjson"].String(), "\n"), "\n")
+		assert.Equal(t, 3, len(lines))
+		assert.Contains(t, lines[2], `"id":"8"`)
+		assert.NotContains(t, mockFiles.files, "2/Condition.ndjson")
+		mockRepo.AssertNumberOfCalls(t, "UpdateExportJob", 3)
+	})
+
+	t.Run("Run_StopsWhenDeleted", func(t *testing.T) {
+		mockRepo, mockFiles, service := getMocksAndService()
+		mockRepo.On("GetPatientsPage", mock.Anything, 0, pageSize).Return([]models.Patient{{Id: 1, Name: "Jane Smith"}}, nil)
+		mockRepo.On("UpdateExportJob", mock.Anything, mock.Anything).Return(customerrors.NewInvalidInputError("export not found"))
+
+		service.run(context.Background(), models.ExportJob{Id: 2, Types: []string{"Patient", "Condition"}, Status: models.ExportStatusRunning})
+
+		assert.Empty(t, mockFiles.files)
+		mockRepo.AssertNotCalled(t, "GetPatientsPage", mock.Anything, 1, pageSize)
+		mockRepo.AssertNotCalled(t, "GetDiagnosedConditionsPage", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Run_Failure", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetDiagnosedConditionsPage", mock.Anything, 0, pageSize).Return([]models.DiagnosedCondition{}, errors.New("store unavailable"))
+		mockRepo.On("UpdateExportJob", mock.Anything, mock.Anything).Return(nil)
+
+		job := service.run(context.Background(), models.ExportJob{Id: 2, Types: []string{"Condition"}})
+
+		assert.Equal(t, models.ExportStatusFailed, job.Status)
+		assert.Equal(t, "error exporting Condition resources", job.Error)
+		assert.NotNil(t, job.CompletedAt)
+	})
+}
+
+func TestGetExport(t *testing.T) {
+	t.Run("GetExport_NotFound", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetExportJob", mock.Anything, 9).Return(models.ExportJob{}, customerrors.NewInvalidInputError("export not found"))
+
+		_, err := service.GetExport(context.Background(), "9")
+		assert.Equal(t, customerrors.NewNotFoundError("export 9 not found"), err)
+
+		_, err = service.GetExport(context.Background(), "abc")
+		assert.Equal(t, customerrors.NewNotFoundError("export abc not found"), err)
+	})
+}
+
+func TestDeleteExport(t *testing.T) {
+	t.Run("DeleteExport_Success", func(t *testing.T) {
+		mockRepo, mockFiles, service := getMocksAndService()
+		mockFiles.files["2/Patient.ndjson"] = bytes.NewBufferString("{}\n")
+		mockFiles.files["3/Patient.ndjson"] = bytes.NewBufferString("{}\n")
+		mockRepo.On("GetExportJob", mock.Anything, 2).Return(models.ExportJob{Id: 2, Status: models.ExportStatusCompleted}, nil)
+		mockRepo.On("DeleteExportJob", mock.Anything, 2).Return(nil)
+
+		err := service.DeleteExport(context.Background(), "2")
+		assert.Nil(t, err)
+		assert.NotContains(t, mockFiles.files, "2/Patient.ndjson")
+		assert.Contains(t, mockFiles.files, "3/Patient.ndjson")
+	})
+
+	t.Run("DeleteExport_NotFound", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetExportJob", mock.Anything, 9).Return(models.ExportJob{}, customerrors.NewInvalidInputError("export not found"))
+
+		err := service.DeleteExport(context.Background(), "9")
+		assert.Equal(t, customerrors.NewNotFoundError("export 9 not found"), err)
+		mockRepo.AssertNotCalled(t, "DeleteExportJob", mock.Anything, mock.Anything)
+	})
+}
+
+func TestExpireExports(t *testing.T) {
+	t.Run("ExpireExports_RemovesExpiredAndOrphanedFiles", func(t *testing.T) {
+		mockRepo, mockFiles, service := getMocksAndService()
+		now := time.Now()
+		mockFiles.files["1/Patient.ndjson"] = bytes.NewBufferString("{}\n")
+		mockFiles.files["2/Patient.ndjson"] = bytes.NewBufferString("{}\n")
+		mockFiles.files["3/Patient.ndjson"] = bytes.NewBufferString("{}\n")
+		mockFiles.files["notes/readme.txt"] = bytes.NewBufferString("kept")
+		mockRepo.On("GetExportJobsCompletedBefore", mock.Anything, now.Add(-time.Hour)).Return([]models.ExportJob{{Id: 1}}, nil)
+		mockRepo.On("DeleteExportJob", mock.Anything, 1).Return(nil)
+		mockRepo.On("GetExportJob", mock.Anything, 1).Return(models.ExportJob{}, customerrors.NewInvalidInputError("export not found"))
+		mockRepo.On("GetExportJob", mock.Anything, 2).Return(models.ExportJob{Id: 2, Status: models.ExportStatusCompleted}, nil)
+		mockRepo.On("GetExportJob", mock.Anything, 3).Return(models.ExportJob{}, customerrors.NewInvalidInputError("export not found"))
+
+		err := service.ExpireExports(context.Background(), now)
+		assert.Nil(t, err)
+		names, _ := mockFiles.List(context.Background())
+		assert.Equal(t, []string{"2", "notes"}, names)
+	})
+
+	t.Run("ExpireExports_Failure", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetExportJobsCompletedBefore", mock.Anything, mock.Anything).Return([]models.ExportJob{}, errors.New("store unavailable"))
+
+		err := service.ExpireExports(context.Background(), time.Now())
+		assert.NotNil(t, err)
+	})
+}
+
+func TestOpenExportFile(t *testing.T) {
+	output := []models.ExportFile{{Type: "Patient", Count: 1, Name: "2/Patient.ndjson"}}
+
+	t.Run("OpenExportFile_Success", func(t *testing.T) {
+		mockRepo, mockFiles, service := getMocksAndService()
+		mockFiles.files["2/Patient.ndjson"] = bytes.NewBufferString("{}\n")
+		mockRepo.On("GetExportJob", mock.Anything, 2).Return(models.ExportJob{Id: 2, Status: models.ExportStatusCompleted, Output: output}, nil)
+
+		file, err := service.OpenExportFile(context.Background(), "2", "Patient")
+		assert.Nil(t, err)
+		data, _ := io.ReadAll(file)
+		assert.Equal(t, "{}\n", string(data))
+	})
+
+	t.Run("OpenExportFile_TypeNotExported", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetExportJob", mock.Anything, 2).Return(models.ExportJob{Id: 2, Status: models.ExportStatusCompleted, Output: output}, nil)
+
+		_, err := service.OpenExportFile(context.Background(), "2", "Condition")
+		assert.Equal(t, customerrors.NewNotFoundError("Condition file of export 2 not found"), err)
+	})
+
+	t.Run("OpenExportFile_StillRunning", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetExportJob", mock.Anything, 2).Return(models.ExportJob{Id: 2, Status: models.ExportStatusRunning, Output: output}, nil)
+
+		_, err := service.OpenExportFile(context.Background(), "2", "Patient")
+		assert.Equal(t, customerrors.NewNotFoundError("Patient file of export 2 not found"), err)
+	})
+}
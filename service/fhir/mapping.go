@@ -0,0 +1,350 @@
+package fhir
+
+import (
+	"errors"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func ToPatient(patient models.Patient) Patient {
+	resource := Patient{
+		ResourceType: "Patient",
+		Id:           strconv.Itoa(patient.Id),
+		Name:         []HumanName{toHumanName(patient.Name)},
+	}
+	for _, identifier := range patient.Identifiers {
+		resource.Identifier = append(resource.Identifier, toIdentifier(identifier))
+	}
+	if patient.PhoneNumber != "" {
+		resource.Telecom = []ContactPoint{{System: "phone", Value: patient.PhoneNumber}}
+	}
+	if !patient.DateOfBirth.IsZero() {
+		resource.BirthDate = patient.DateOfBirth.Format(time.DateOnly)
+	}
+	if patient.Address != "" {
+		resource.Address = []Address{{Text: patient.Address}}
+	}
+	return resource
+}
+
+// only the first name, phone number and address of the resource are recorded
+func FromPatient(resource Patient) (models.PatientRequest, error) {
+	if resource.ResourceType != "Patient" {
+		return models.PatientRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("expected a Patient resource, but got %q", resource.ResourceType))
+	}
+
+	var details models.PatientRequest
+	if len(resource.Name) > 0 {
+		details.Name = fromHumanName(resource.Name[0])
+	}
+	if len(details.Name) < 3 {
+		return models.PatientRequest{}, customerrors.NewInvalidInputError("Patient.name is required, with at least 3 characters")
+	}
+	for _, telecom := range resource.Telecom {
+		if telecom.System == "phone" {
+			details.PhoneNumber = telecom.Value
+			break
+		}
+	}
+	if len(details.PhoneNumber) < 10 {
+		return models.PatientRequest{}, customerrors.NewInvalidInputError("a Patient.telecom phone number is required, with at least 10 characters")
+	}
+	//identifiers replace the patient's existing ones, as the whole resource is sent
+	details.Identifiers = []models.PatientIdentifier{}
+	for _, identifier := range resource.Identifier {
+		if identifier.Value == "" {
+			return models.PatientRequest{}, customerrors.NewInvalidInputError("Patient.identifier requires a value")
+		}
+		details.Identifiers = append(details.Identifiers, fromIdentifier(identifier))
+	}
+	if len(details.Identifiers) == 0 {
+		return models.PatientRequest{}, customerrors.NewInvalidInputError("Patient.identifier is required")
+	}
+	dateOfBirth, err := time.Parse(time.DateOnly, resource.BirthDate)
+	if err != nil {
+		return models.PatientRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("Patient.birthDate %q is not a valid date, expected the format 2006-01-02", resource.BirthDate))
+	}
+	details.DateOfBirth = dateOfBirth
+	if len(resource.Address) > 0 {
+		details.Address = fromAddress(resource.Address[0])
+	}
+	return details, nil
+}
+
+// the external system is the one identifiers were recorded in before they had systems, so it is sent without one
+func toIdentifier(identifier models.PatientIdentifier) Identifier {
+	if identifier.System == models.IdentifierSystemExternal {
+		return Identifier{Value: identifier.Value}
+	}
+	return Identifier{System: identifier.System, Value: identifier.Value}
+}
+
+func fromIdentifier(identifier Identifier) models.PatientIdentifier {
+	if identifier.System == "" {
+		return models.PatientIdentifier{System: models.IdentifierSystemExternal, Value: identifier.Value}
+	}
+	return models.PatientIdentifier{System: identifier.System, Value: identifier.Value}
+}
+
+func fromHumanName(name HumanName) string {
+	if name.Text != "" {
+		return strings.TrimSpace(name.Text)
+	}
+	return strings.Join(strings.Fields(strings.Join(append(name.Given, name.Family), " ")), " ")
+}
+
+func fromAddress(address Address) string {
+	if address.Text != "" {
+		return address.Text
+	}
+	var parts []string
+	for _, part := range append(address.Line, address.City, strings.TrimSpace(address.State+" "+address.PostalCode), address.Country) {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// names are stored whole, so the last word is taken as the family name and the rest as given names
+func toHumanName(name string) HumanName {
+	humanName := HumanName{Text: name}
+	words := strings.Fields(name)
+	if len(words) > 0 {
+		humanName.Family = words[len(words)-1]
+		humanName.Given = words[:len(words)-1]
+	}
+	return humanName
+}
+
+func ToCondition(condition models.DiagnosedCondition) Condition {
+	resource := Condition{
+		ResourceType: "Condition",
+		Id:           strconv.Itoa(condition.Id),
+		Code: CodeableConcept{
+			Coding: []Coding{{System: toCodeSystemUrl(condition.CodeSystem), Code: condition.Code, Display: condition.Name}},
+			Text:   condition.Name,
+		},
+		Subject:      patientReference(condition.PatientId),
+		RecordedDate: formatDateTime(condition.Date),
+	}
+	//a condition entered in error has no clinical status
+	if condition.ClinicalStatus != "" && condition.VerificationStatus != models.VerificationStatusEnteredInError {
+		resource.ClinicalStatus = &CodeableConcept{Coding: []Coding{{System: CodeSystemClinicalStatus, Code: condition.ClinicalStatus}}}
+	}
+	if condition.VerificationStatus != "" {
+		resource.VerificationStatus = &CodeableConcept{Coding: []Coding{{System: CodeSystemVerificationStatus, Code: condition.VerificationStatus}}}
+	}
+	if condition.EncounterId != 0 {
+		resource.Encounter = &Reference{Reference: fmt.Sprintf("Encounter/%v", condition.EncounterId)}
+	}
+	if condition.OnsetDate != nil {
+		resource.OnsetDateTime = formatDateTime(*condition.OnsetDate)
+	}
+	if condition.AbatementDate != nil {
+		resource.AbatementDateTime = formatDateTime(*condition.AbatementDate)
+	}
+	if condition.Description != "" {
+		resource.Note = []Annotation{{Text: condition.Description}}
+	}
+	return resource
+}
+
+// the id of the patient the resource is for, and its details as they are recorded.  The status is not included,
+// as a condition is always recorded as active and confirmed, then changed by transitioning it
+func FromCondition(resource Condition) (int, models.DiagnosedConditionRequest, error) {
+	if resource.ResourceType != "Condition" {
+		return 0, models.DiagnosedConditionRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("expected a Condition resource, but got %q", resource.ResourceType))
+	}
+
+	patientId, err := parseReference("Patient", resource.Subject)
+	if err != nil {
+		return 0, models.DiagnosedConditionRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("Condition.subject %v", err))
+	}
+
+	details := models.DiagnosedConditionRequest{Name: resource.Code.Text}
+	for _, coding := range resource.Code.Coding {
+		if codeSystem, known := fromCodeSystemUrl(coding.System); known {
+			details.Code = coding.Code
+			details.CodeSystem = codeSystem
+			if details.Name == "" {
+				details.Name = coding.Display
+			}
+			break
+		}
+	}
+	if details.Code == "" {
+		return 0, models.DiagnosedConditionRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("Condition.code requires a coding from %v or %v", CodeSystemICD10CM, CodeSystemSNOMEDCT))
+	}
+	if len(resource.Note) > 0 {
+		details.Description = resource.Note[0].Text
+	}
+
+	//a condition without a recorded date is recorded now
+	details.Date = time.Now()
+	if resource.RecordedDate != "" {
+		details.Date, err = parseDateTime(resource.RecordedDate)
+		if err != nil {
+			return 0, models.DiagnosedConditionRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("Condition.recordedDate %v", err))
+		}
+	}
+	if resource.OnsetDateTime != "" {
+		onsetDate, err := parseDateTime(resource.OnsetDateTime)
+		if err != nil {
+			return 0, models.DiagnosedConditionRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("Condition.onsetDateTime %v", err))
+		}
+		details.OnsetDate = &onsetDate
+	}
+	if resource.Encounter != nil {
+		details.EncounterId, err = parseReference("Encounter", *resource.Encounter)
+		if err != nil {
+			return 0, models.DiagnosedConditionRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("Condition.encounter %v", err))
+		}
+	}
+	return patientId, details, nil
+}
+
+// the code of the first coding of the status, or "" when there is none
+func statusCode(status *CodeableConcept) string {
+	if status == nil || len(status.Coding) == 0 {
+		return ""
+	}
+	return status.Coding[0].Code
+}
+
+// the id in a relative reference, such as Patient/3
+func parseReference(resourceType string, reference Reference) (int, error) {
+	id, found := strings.CutPrefix(reference.Reference, resourceType+"/")
+	parsed, err := strconv.Atoi(id)
+	if !found || err != nil {
+		return 0, fmt.Errorf("%q is not a valid reference, expected %v/{id}", reference.Reference, resourceType)
+	}
+	return parsed, nil
+}
+
+// accepts a full date and time, or just a date
+func parseDateTime(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a valid date, expected the format 2006-01-02 or 2006-01-02T15:04:05Z07:00", value)
+	}
+	return parsed, nil
+}
+
+// the content itself is served as a Binary with the same id
+func ToDocumentReference(attatchment models.Attatchment) DocumentReference {
+	resource := DocumentReference{
+		ResourceType: "DocumentReference",
+		Id:           strconv.Itoa(attatchment.Id),
+		Status:       "current",
+		Subject:      patientReference(attatchment.PatientId),
+		Description:  attatchment.Description,
+		Content: []DocumentReferenceContent{{Attachment: Attachment{
+			ContentType: ContentType(attatchment),
+			Url:         fmt.Sprintf("Binary/%v", attatchment.Id),
+			Size:        len(attatchment.Data),
+			Title:       attatchment.Name,
+		}}},
+	}
+	if attatchment.Type != "" {
+		resource.Type = &CodeableConcept{Text: attatchment.Type}
+	}
+	if attatchment.EncounterId != 0 {
+		resource.Context = &DocumentReferenceContext{Encounter: []Reference{{Reference: fmt.Sprintf("Encounter/%v", attatchment.EncounterId)}}}
+	}
+	return resource
+}
+
+func ToBinary(attatchment models.Attatchment) Binary {
+	return Binary{
+		ResourceType: "Binary",
+		Id:           strconv.Itoa(attatchment.Id),
+		ContentType:  ContentType(attatchment),
+		Data:         attatchment.Data,
+	}
+}
+
+// attatchments are stored without a content type, so it is worked out from their data
+func ContentType(attatchment models.Attatchment) string {
+	if attatchment.Dicom != nil {
+		return "application/dicom"
+	}
+	return http.DetectContentType(attatchment.Data)
+}
+
+// describes the error, along with the http status it should be returned with
+func ToOperationOutcome(err error) (int, OperationOutcome) {
+	status, code, diagnostics := http.StatusInternalServerError, "exception", "internal server error"
+
+	var notFoundError customerrors.NotFoundError
+	var inputError customerrors.InvalidInputError
+	var alreadyExistsError customerrors.AlreadyExistsError
+	switch {
+	case errors.As(err, &notFoundError):
+		status, code, diagnostics = http.StatusNotFound, "not-found", notFoundError.Error()
+	case errors.As(err, &inputError):
+		status, code, diagnostics = http.StatusBadRequest, "invalid", inputError.Error()
+	case errors.As(err, &alreadyExistsError):
+		status, code, diagnostics = http.StatusConflict, "duplicate", alreadyExistsError.Error()
+	}
+
+	return status, OperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue:        []OperationOutcomeIssue{{Severity: "error", Code: code, Diagnostics: diagnostics}},
+	}
+}
+
+// files are downloaded from the FHIR base url with the same bearer token as the rest of the api
+func ToExportManifest(base string, job models.ExportJob) ExportManifest {
+	manifest := ExportManifest{
+		TransactionTime:     formatDateTime(job.TransactionTime),
+		Request:             job.Request,
+		RequiresAccessToken: true,
+		Output:              []ExportManifestOutput{},
+		Error:               []ExportManifestOutput{},
+	}
+	for _, file := range job.Output {
+		manifest.Output = append(manifest.Output, ExportManifestOutput{
+			Type:  file.Type,
+			Url:   fmt.Sprintf("%v/$export-file/%v/%v", base, job.Id, file.Type),
+			Count: file.Count,
+		})
+	}
+	return manifest
+}
+
+func patientReference(patientId int) Reference {
+	return Reference{Reference: fmt.Sprintf("Patient/%v", patientId)}
+}
+
+func toCodeSystemUrl(codeSystem string) string {
+	switch codeSystem {
+	case models.CodeSystemSNOMEDCT:
+		return CodeSystemSNOMEDCT
+	default:
+		return CodeSystemICD10CM
+	}
+}
+
+func fromCodeSystemUrl(url string) (string, bool) {
+	switch url {
+	case CodeSystemICD10CM:
+		return models.CodeSystemICD10CM, true
+	case CodeSystemSNOMEDCT:
+		return models.CodeSystemSNOMEDCT, true
+	default:
+		return "", false
+	}
+}
+
+func formatDateTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,173 @@
+package fhir
+
+// the subset of FHIR R4 resources and data types which our records are mapped to
+
+const (
+	CodeSystemICD10CM            = "http://hl7.org/fhir/sid/icd-10-cm"
+	CodeSystemSNOMEDCT           = "http://snomed.info/sct"
+	CodeSystemClinicalStatus     = "http://terminology.hl7.org/CodeSystem/condition-clinical"
+	CodeSystemVerificationStatus = "http://terminology.hl7.org/CodeSystem/condition-ver-status"
+)
+
+type Patient struct {
+	ResourceType string         `json:"resourceType"`
+	Id           string         `json:"id,omitempty"`
+	Identifier   []Identifier   `json:"identifier,omitempty"`
+	Name         []HumanName    `json:"name,omitempty"`
+	Telecom      []ContactPoint `json:"telecom,omitempty"`
+	BirthDate    string         `json:"birthDate,omitempty"`
+	Address      []Address      `json:"address,omitempty"`
+}
+
+type Identifier struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value"`
+}
+
+type HumanName struct {
+	Text   string   `json:"text,omitempty"`
+	Family string   `json:"family,omitempty"`
+	Given  []string `json:"given,omitempty"`
+}
+
+type ContactPoint struct {
+	System string `json:"system"`
+	Value  string `json:"value"`
+}
+
+// addresses are recorded as text, so any other parts are joined into it when written
+type Address struct {
+	Text       string   `json:"text,omitempty"`
+	Line       []string `json:"line,omitempty"`
+	City       string   `json:"city,omitempty"`
+	State      string   `json:"state,omitempty"`
+	PostalCode string   `json:"postalCode,omitempty"`
+	Country    string   `json:"country,omitempty"`
+}
+
+type Condition struct {
+	ResourceType       string           `json:"resourceType"`
+	Id                 string           `json:"id,omitempty"`
+	ClinicalStatus     *CodeableConcept `json:"clinicalStatus,omitempty"`
+	VerificationStatus *CodeableConcept `json:"verificationStatus,omitempty"`
+	Code               CodeableConcept  `json:"code"`
+	Subject            Reference        `json:"subject"`
+	Encounter          *Reference       `json:"encounter,omitempty"`
+	OnsetDateTime      string           `json:"onsetDateTime,omitempty"`
+	AbatementDateTime  string           `json:"abatementDateTime,omitempty"`
+	RecordedDate       string           `json:"recordedDate,omitempty"`
+	Note               []Annotation     `json:"note,omitempty"`
+}
+
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+type Reference struct {
+	Reference string `json:"reference"`
+}
+
+type Annotation struct {
+	Text string `json:"text"`
+}
+
+type DocumentReference struct {
+	ResourceType string                     `json:"resourceType"`
+	Id           string                     `json:"id,omitempty"`
+	Status       string                     `json:"status"`
+	Type         *CodeableConcept           `json:"type,omitempty"`
+	Subject      Reference                  `json:"subject"`
+	Description  string                     `json:"description,omitempty"`
+	Content      []DocumentReferenceContent `json:"content"`
+	Context      *DocumentReferenceContext  `json:"context,omitempty"`
+}
+
+type DocumentReferenceContent struct {
+	Attachment Attachment `json:"attachment"`
+}
+
+type DocumentReferenceContext struct {
+	Encounter []Reference `json:"encounter,omitempty"`
+}
+
+type Attachment struct {
+	ContentType string `json:"contentType,omitempty"`
+	Url         string `json:"url"`
+	Size        int    `json:"size,omitempty"`
+	Title       string `json:"title,omitempty"`
+}
+
+type Binary struct {
+	ResourceType string `json:"resourceType"`
+	Id           string `json:"id,omitempty"`
+	ContentType  string `json:"contentType"`
+	Data         []byte `json:"data,omitempty"`
+}
+
+type Bundle struct {
+	ResourceType string        `json:"resourceType"`
+	Type         string        `json:"type"`
+	Total        *int          `json:"total,omitempty"`
+	Link         []BundleLink  `json:"link,omitempty"`
+	Entry        []BundleEntry `json:"entry,omitempty"`
+}
+
+type BundleLink struct {
+	Relation string `json:"relation"`
+	Url      string `json:"url"`
+}
+
+type BundleEntry struct {
+	FullUrl  string               `json:"fullUrl,omitempty"`
+	Resource any                  `json:"resource,omitempty"`
+	Search   *BundleEntrySearch   `json:"search,omitempty"`
+	Request  *BundleEntryRequest  `json:"request,omitempty"`
+	Response *BundleEntryResponse `json:"response,omitempty"`
+}
+
+type BundleEntrySearch struct {
+	Mode string `json:"mode"`
+}
+
+type BundleEntryRequest struct {
+	Method string `json:"method"`
+	Url    string `json:"url"`
+}
+
+type BundleEntryResponse struct {
+	Status   string `json:"status"`
+	Location string `json:"location,omitempty"`
+}
+
+type OperationOutcome struct {
+	ResourceType string                  `json:"resourceType"`
+	Issue        []OperationOutcomeIssue `json:"issue"`
+}
+
+type OperationOutcomeIssue struct {
+	Severity    string `json:"severity"`
+	Code        string `json:"code"`
+	Diagnostics string `json:"diagnostics,omitempty"`
+}
+
+// the completion status of a Bulk Data export, which is not itself a resource
+type ExportManifest struct {
+	TransactionTime     string                 `json:"transactionTime"`
+	Request             string                 `json:"request"`
+	RequiresAccessToken bool                   `json:"requiresAccessToken"`
+	Output              []ExportManifestOutput `json:"output"`
+	Error               []ExportManifestOutput `json:"error"`
+}
+
+type ExportManifestOutput struct {
+	Type  string `json:"type"`
+	Url   string `json:"url"`
+	Count int    `json:"count,omitempty"`
+}
@@ -0,0 +1,485 @@
+package fhir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type Service struct {
+	repo           TransactionRunner
+	patientSvc     PatientService
+	conditionSvc   DiagnosedConditionService
+	attatchmentSvc AttatchmentService
+	tracer         Tracer
+}
+
+func NewService(repo TransactionRunner, patientSvc PatientService, conditionSvc DiagnosedConditionService, attatchmentSvc AttatchmentService, tracer Tracer) Service {
+	return Service{
+		repo:           repo,
+		patientSvc:     patientSvc,
+		conditionSvc:   conditionSvc,
+		attatchmentSvc: attatchmentSvc,
+		tracer:         tracer,
+	}
+}
+
+func (s Service) ReadPatient(ctx context.Context, id string) (Patient, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "ReadPatient")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("id", id))
+
+	patientId, err := s.parseId(ctx, "Patient", id)
+	if err != nil {
+		return Patient{}, err
+	}
+
+	patient, err := s.patientSvc.GetPatient(ctx, patientId)
+	if err != nil {
+		return Patient{}, s.readError(ctx, "Patient", id, err)
+	}
+
+	return ToPatient(patient), nil
+}
+
+func (s Service) CreatePatient(ctx context.Context, resource Patient) (Patient, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "CreatePatient")
+	defer span.End()
+
+	details, err := FromPatient(resource)
+	if err != nil {
+		return Patient{}, s.tracer.RecordError(ctx, err)
+	}
+
+	patient, err := s.patientSvc.CreatePatient(ctx, details.Name, details.Address, details.PhoneNumber, details.DateOfBirth, details.ExternalIdentifier, details.Identifiers)
+	if err != nil {
+		return Patient{}, err
+	}
+
+	return ToPatient(patient), nil
+}
+
+// replaces the patient's details.  Patients cannot be created by an update, as their ids are assigned by us
+func (s Service) UpdatePatient(ctx context.Context, id string, resource Patient) (Patient, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "UpdatePatient")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("id", id))
+
+	patientId, err := s.parseUpdateId(ctx, "Patient", id, resource.Id)
+	if err != nil {
+		return Patient{}, err
+	}
+	details, err := FromPatient(resource)
+	if err != nil {
+		return Patient{}, s.tracer.RecordError(ctx, err)
+	}
+
+	_, err = s.patientSvc.GetPatient(ctx, patientId)
+	if err != nil {
+		return Patient{}, s.readError(ctx, "Patient", id, err)
+	}
+
+	patient, err := s.patientSvc.UpdatePatient(ctx, patientId, details.Name, details.Address, details.PhoneNumber, details.DateOfBirth, details.ExternalIdentifier, details.Identifiers)
+	if err != nil {
+		return Patient{}, err
+	}
+
+	return ToPatient(patient), nil
+}
+
+// supports the name, identifier and birthdate search parameters.  Identifiers sent without a system are those in the
+// external system
+func (s Service) SearchPatients(ctx context.Context, base string, params url.Values) (Bundle, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "SearchPatients")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("search", params.Encode()))
+
+	search := func(search models.PatientSearch) ([]models.Patient, error) {
+		return s.patientSvc.SearchPatients(ctx, search)
+	}
+	patients, err := searchAll(params, map[string]func(string) ([]models.Patient, error){
+		"name": func(value string) ([]models.Patient, error) {
+			return search(models.PatientSearch{NamePart: value})
+		},
+		"identifier": func(value string) ([]models.Patient, error) {
+			//|value asks for an identifier without a system, while a value alone matches any system
+			system, identifier, found := strings.Cut(value, "|")
+			switch {
+			case !found:
+				return search(models.PatientSearch{Identifier: value})
+			case system == "":
+				system = models.IdentifierSystemExternal
+			}
+			return search(models.PatientSearch{Identifier: system + "|" + identifier})
+		},
+		"birthdate": func(value string) ([]models.Patient, error) {
+			return search(models.PatientSearch{BirthDate: strings.TrimPrefix(value, "eq")})
+		},
+	}, func(patient models.Patient) int { return patient.Id })
+	if err != nil {
+		return Bundle{}, s.tracer.RecordError(ctx, err)
+	}
+
+	entries := make([]BundleEntry, len(patients))
+	for i, patient := range patients {
+		entries[i] = matchEntry(base, "Patient", patient.Id, ToPatient(patient))
+	}
+	return newSearchset(base, "Patient", params, entries), nil
+}
+
+func (s Service) ReadCondition(ctx context.Context, id string) (Condition, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "ReadCondition")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("id", id))
+
+	conditionId, err := s.parseId(ctx, "Condition", id)
+	if err != nil {
+		return Condition{}, err
+	}
+
+	condition, err := s.conditionSvc.GetDiagnosedCondition(ctx, conditionId)
+	if err != nil {
+		return Condition{}, s.readError(ctx, "Condition", id, err)
+	}
+
+	return ToCondition(condition), nil
+}
+
+func (s Service) CreateCondition(ctx context.Context, resource Condition) (Condition, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "CreateCondition")
+	defer span.End()
+
+	patientId, details, err := FromCondition(resource)
+	if err != nil {
+		return Condition{}, s.tracer.RecordError(ctx, err)
+	}
+	err = s.validateStatus(ctx, resource, models.ClinicalStatusActive, models.VerificationStatusConfirmed)
+	if err != nil {
+		return Condition{}, err
+	}
+
+	condition, err := s.conditionSvc.AddDiagnosedConditionToPatient(ctx, patientId, details.Name, details.Code, details.CodeSystem, details.Description, details.Date, details.OnsetDate, details.EncounterId)
+	if err != nil {
+		return Condition{}, err
+	}
+
+	return ToCondition(condition), nil
+}
+
+// replaces the condition's details.  Its patient and status cannot be changed by an update
+func (s Service) UpdateCondition(ctx context.Context, id string, resource Condition) (Condition, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "UpdateCondition")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("id", id))
+
+	conditionId, err := s.parseUpdateId(ctx, "Condition", id, resource.Id)
+	if err != nil {
+		return Condition{}, err
+	}
+	patientId, details, err := FromCondition(resource)
+	if err != nil {
+		return Condition{}, s.tracer.RecordError(ctx, err)
+	}
+
+	existing, err := s.conditionSvc.GetDiagnosedCondition(ctx, conditionId)
+	if err != nil {
+		return Condition{}, s.readError(ctx, "Condition", id, err)
+	}
+	if existing.PatientId != patientId {
+		return Condition{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("Condition.subject cannot be changed from Patient/%v", existing.PatientId)))
+	}
+	err = s.validateStatus(ctx, resource, existing.ClinicalStatus, existing.VerificationStatus)
+	if err != nil {
+		return Condition{}, err
+	}
+
+	condition, err := s.conditionSvc.UpdateDiagnosedCondition(ctx, conditionId, details.Name, details.Code, details.CodeSystem, details.Description, details.Date, details.OnsetDate, details.EncounterId)
+	if err != nil {
+		return Condition{}, err
+	}
+
+	return ToCondition(condition), nil
+}
+
+// supports the patient (or subject) and code search parameters.  A code without a system matches either code system
+func (s Service) SearchConditions(ctx context.Context, base string, params url.Values) (Bundle, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "SearchConditions")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("search", params.Encode()))
+
+	byPatient := func(value string) ([]models.DiagnosedCondition, error) {
+		patient, found, err := s.referencedPatient(ctx, value)
+		if !found {
+			return nil, err
+		}
+		return patient.DiagnosedConditions, nil
+	}
+	conditions, err := searchAll(params, map[string]func(string) ([]models.DiagnosedCondition, error){
+		"patient": byPatient,
+		"subject": byPatient,
+		"code": func(value string) ([]models.DiagnosedCondition, error) {
+			return s.conditionsWithCode(ctx, value)
+		},
+	}, func(condition models.DiagnosedCondition) int { return condition.Id })
+	if err != nil {
+		return Bundle{}, s.tracer.RecordError(ctx, err)
+	}
+
+	entries := make([]BundleEntry, len(conditions))
+	for i, condition := range conditions {
+		entries[i] = matchEntry(base, "Condition", condition.Id, ToCondition(condition))
+	}
+	return newSearchset(base, "Condition", params, entries), nil
+}
+
+func (s Service) ReadDocumentReference(ctx context.Context, id string) (DocumentReference, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "ReadDocumentReference")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("id", id))
+
+	attatchment, err := s.getAttatchment(ctx, "DocumentReference", id)
+	if err != nil {
+		return DocumentReference{}, err
+	}
+
+	return ToDocumentReference(attatchment), nil
+}
+
+// supports the patient (or subject) search parameter
+func (s Service) SearchDocumentReferences(ctx context.Context, base string, params url.Values) (Bundle, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "SearchDocumentReferences")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("search", params.Encode()))
+
+	byPatient := func(value string) ([]models.Attatchment, error) {
+		patient, found, err := s.referencedPatient(ctx, value)
+		if !found {
+			return nil, err
+		}
+		return patient.Attatchments, nil
+	}
+	attatchments, err := searchAll(params, map[string]func(string) ([]models.Attatchment, error){
+		"patient": byPatient,
+		"subject": byPatient,
+	}, func(attatchment models.Attatchment) int { return attatchment.Id })
+	if err != nil {
+		return Bundle{}, s.tracer.RecordError(ctx, err)
+	}
+
+	entries := make([]BundleEntry, len(attatchments))
+	for i, attatchment := range attatchments {
+		entries[i] = matchEntry(base, "DocumentReference", attatchment.Id, ToDocumentReference(attatchment))
+	}
+	return newSearchset(base, "DocumentReference", params, entries), nil
+}
+
+// the current content of the attatchment with the same id as the DocumentReference
+func (s Service) ReadBinary(ctx context.Context, id string) (Binary, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "ReadBinary")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.String("id", id))
+
+	attatchment, err := s.getAttatchment(ctx, "Binary", id)
+	if err != nil {
+		return Binary{}, err
+	}
+
+	return ToBinary(attatchment), nil
+}
+
+func (s Service) getAttatchment(ctx context.Context, resourceType string, id string) (models.Attatchment, error) {
+	attatchmentId, err := s.parseId(ctx, resourceType, id)
+	if err != nil {
+		return models.Attatchment{}, err
+	}
+
+	attatchment, err := s.attatchmentSvc.GetAttatchmentMetadata(ctx, attatchmentId)
+	if err != nil {
+		return models.Attatchment{}, s.readError(ctx, resourceType, id, err)
+	}
+	version, err := s.attatchmentSvc.GetAttatchmentVersion(ctx, attatchmentId, attatchment.Version)
+	if err != nil {
+		return models.Attatchment{}, s.readError(ctx, resourceType, id, err)
+	}
+
+	attatchment.Data = version.Data
+	return attatchment, nil
+}
+
+// a reference to a patient who does not exist matches nothing, rather than being an error
+func (s Service) referencedPatient(ctx context.Context, reference string) (models.Patient, bool, error) {
+	patientId, err := strconv.Atoi(strings.TrimPrefix(reference, "Patient/"))
+	if err != nil {
+		return models.Patient{}, false, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a valid patient reference", reference))
+	}
+
+	patient, err := s.patientSvc.GetPatient(ctx, patientId)
+	var inputError customerrors.InvalidInputError
+	if errors.As(err, &inputError) {
+		return models.Patient{}, false, nil
+	}
+	if err != nil {
+		return models.Patient{}, false, err
+	}
+	return patient, true, nil
+}
+
+// finds the patients with a condition coded with the code, or one mapped to it, then their matching conditions
+func (s Service) conditionsWithCode(ctx context.Context, token string) ([]models.DiagnosedCondition, error) {
+	systemUrl, code := parseToken(token)
+	codeSystems := []string{models.CodeSystemICD10CM, models.CodeSystemSNOMEDCT}
+	if systemUrl != "" {
+		codeSystem, known := fromCodeSystemUrl(systemUrl)
+		if !known {
+			return nil, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a supported code system, expected %v or %v", systemUrl, CodeSystemICD10CM, CodeSystemSNOMEDCT))
+		}
+		codeSystems = []string{codeSystem}
+	}
+
+	var conditions []models.DiagnosedCondition
+	for _, codeSystem := range codeSystems {
+		patients, err := s.patientSvc.SearchPatients(ctx, models.PatientSearch{
+			DiagnosedConditionCode:       code,
+			DiagnosedConditionCodeSystem: codeSystem,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, patient := range patients {
+			matches, err := s.conditionSvc.GetPatientDiagnosedConditions(ctx, models.DiagnosedConditionSearch{
+				PatientId:  patient.Id,
+				Code:       code,
+				CodeSystem: codeSystem,
+			})
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, matches...)
+		}
+	}
+	return conditions, nil
+}
+
+func (s Service) parseId(ctx context.Context, resourceType string, id string) (int, error) {
+	parsed, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, s.tracer.RecordError(ctx, customerrors.NewNotFoundError(fmt.Sprintf("%v/%v not found", resourceType, id)))
+	}
+	return parsed, nil
+}
+
+// the id in the url of an update, which the resource must agree with when it has one
+func (s Service) parseUpdateId(ctx context.Context, resourceType string, id string, resourceId string) (int, error) {
+	if resourceId != "" && resourceId != id {
+		return 0, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%v.id %q does not match the id %q in the url", resourceType, resourceId, id)))
+	}
+	return s.parseId(ctx, resourceType, id)
+}
+
+// statuses are changed by transitioning the condition, so any given when it is written must be the ones it will have
+func (s Service) validateStatus(ctx context.Context, resource Condition, clinicalStatus string, verificationStatus string) error {
+	if code := statusCode(resource.ClinicalStatus); code != "" && code != clinicalStatus {
+		return s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("Condition.clinicalStatus must be %v, other statuses are set by transitioning the condition", clinicalStatus)))
+	}
+	if code := statusCode(resource.VerificationStatus); code != "" && code != verificationStatus {
+		return s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("Condition.verificationStatus must be %v, other statuses are set by transitioning the condition", verificationStatus)))
+	}
+	return nil
+}
+
+// the services report missing records as invalid input, which FHIR reads report as not found
+func (s Service) readError(ctx context.Context, resourceType string, id string, err error) error {
+	var inputError customerrors.InvalidInputError
+	if errors.As(err, &inputError) {
+		return s.tracer.RecordError(ctx, customerrors.NewNotFoundError(fmt.Sprintf("%v/%v not found", resourceType, id)))
+	}
+	return s.tracer.RecordError(ctx, fmt.Errorf("error reading %v %w", resourceType, err))
+}
+
+// each occurrence of a parameter narrows the matches, while comma separated values within one occurrence widen them
+func searchAll[T any](params url.Values, searches map[string]func(value string) ([]T, error), id func(T) int) ([]T, error) {
+	var matches map[int]T
+	for name, values := range params {
+		search, supported := searches[name]
+		if !supported {
+			return nil, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a supported search parameter, expected one of %v", name, strings.Join(sortedKeys(searches), ", ")))
+		}
+		for _, value := range values {
+			found := make(map[int]T)
+			for _, alternative := range strings.Split(value, ",") {
+				results, err := search(alternative)
+				if err != nil {
+					return nil, err
+				}
+				for _, result := range results {
+					found[id(result)] = result
+				}
+			}
+			if matches == nil {
+				matches = found
+				continue
+			}
+			for key := range matches {
+				if _, exists := found[key]; !exists {
+					delete(matches, key)
+				}
+			}
+		}
+	}
+	if matches == nil {
+		return nil, customerrors.NewInvalidInputError(fmt.Sprintf("at least one of the %v search parameters is required", strings.Join(sortedKeys(searches), ", ")))
+	}
+
+	results := make([]T, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, match)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return id(results[i]) < id(results[j])
+	})
+	return results, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splits a token search value of the form system|code, where the system is optional
+func parseToken(token string) (string, string) {
+	if system, code, found := strings.Cut(token, "|"); found {
+		return system, code
+	}
+	return "", token
+}
+
+func matchEntry(base string, resourceType string, id int, resource any) BundleEntry {
+	return BundleEntry{
+		FullUrl:  fmt.Sprintf("%v/%v/%v", base, resourceType, id),
+		Resource: resource,
+		Search:   &BundleEntrySearch{Mode: "match"},
+	}
+}
+
+func newSearchset(base string, resourceType string, params url.Values, entries []BundleEntry) Bundle {
+	total := len(entries)
+	return Bundle{
+		ResourceType: "Bundle",
+		Type:         "searchset",
+		Total:        &total,
+		Link:         []BundleLink{{Relation: "self", Url: fmt.Sprintf("%v/%v?%v", base, resourceType, params.Encode())}},
+		Entry:        entries,
+	}
+}
@@ -0,0 +1,557 @@
+package fhir
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runs fn directly, as undoing its changes is left to the repo
+type MockTransactionRunner struct{}
+
+func (m MockTransactionRunner) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) CreatePatient(ctx context.Context, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error) {
+	args := m.Called(ctx, name, address, phoneNumber, dateOfBirth, externalIdentifier, identifiers)
+	return args.Get(0).(models.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) UpdatePatient(ctx context.Context, id int, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error) {
+	args := m.Called(ctx, id, name, address, phoneNumber, dateOfBirth, externalIdentifier, identifiers)
+	return args.Get(0).(models.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) GetPatient(ctx context.Context, patientId int) (models.Patient, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).(models.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) SearchPatients(ctx context.Context, search models.PatientSearch) ([]models.Patient, error) {
+	args := m.Called(ctx, search)
+	return args.Get(0).([]models.Patient), args.Error(1)
+}
+
+type MockDiagnosedConditionService struct {
+	mock.Mock
+}
+
+func (m *MockDiagnosedConditionService) AddDiagnosedConditionToPatient(ctx context.Context, patientId int, name string, code string, codeSystem string, description string, date time.Time, onsetDate *time.Time, encounterId int) (models.DiagnosedCondition, error) {
+	args := m.Called(ctx, patientId, name, code, codeSystem, description, date, onsetDate, encounterId)
+	return args.Get(0).(models.DiagnosedCondition), args.Error(1)
+}
+
+func (m *MockDiagnosedConditionService) UpdateDiagnosedCondition(ctx context.Context, conditionId int, name string, code string, codeSystem string, description string, date time.Time, onsetDate *time.Time, encounterId int) (models.DiagnosedCondition, error) {
+	args := m.Called(ctx, conditionId, name, code, codeSystem, description, date, onsetDate, encounterId)
+	return args.Get(0).(models.DiagnosedCondition), args.Error(1)
+}
+
+func (m *MockDiagnosedConditionService) GetDiagnosedCondition(ctx context.Context, conditionId int) (models.DiagnosedCondition, error) {
+	args := m.Called(ctx, conditionId)
+	return args.Get(0).(models.DiagnosedCondition), args.Error(1)
+}
+
+func (m *MockDiagnosedConditionService) GetPatientDiagnosedConditions(ctx context.Context, search models.DiagnosedConditionSearch) ([]models.DiagnosedCondition, error) {
+	args := m.Called(ctx, search)
+	return args.Get(0).([]models.DiagnosedCondition), args.Error(1)
+}
+
+type MockAttatchmentService struct {
+	mock.Mock
+}
+
+func (m *MockAttatchmentService) GetAttatchmentMetadata(ctx context.Context, attatchmentId int) (models.Attatchment, error) {
+	args := m.Called(ctx, attatchmentId)
+	return args.Get(0).(models.Attatchment), args.Error(1)
+}
+
+func (m *MockAttatchmentService) GetAttatchmentVersion(ctx context.Context, attatchmentId int, version int) (models.AttatchmentVersion, error) {
+	args := m.Called(ctx, attatchmentId, version)
+	return args.Get(0).(models.AttatchmentVersion), args.Error(1)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService() (*MockPatientService, *MockDiagnosedConditionService, *MockAttatchmentService, Service) {
+	mockPatientSvc := new(MockPatientService)
+	mockConditionSvc := new(MockDiagnosedConditionService)
+	mockAttatchmentSvc := new(MockAttatchmentService)
+	service := NewService(MockTransactionRunner{}, mockPatientSvc, mockConditionSvc, mockAttatchmentSvc, new(MockTracer))
+	return mockPatientSvc, mockConditionSvc, mockAttatchmentSvc, service
+}
+
+const base = "http://localhost:8080/fhir/R4"
+
+func TestToPatient(t *testing.T) {
+	patient := ToPatient(models.Patient{
+		Id:                 3,
+		Name:               "Jane Ann Smith",
+		Address:            "1 main street",
+		PhoneNumber:        "8044955578",
+		ExternalIdentifier: "123-45-6789",
+		Identifiers: []models.PatientIdentifier{
+			{System: models.IdentifierSystemExternal, Value: "123-45-6789"},
+			{System: "urn:oid:2.16.840.1.113883.19.5", Value: "MRN001"},
+		},
+		DateOfBirth: time.Date(1990, 2, 1, 0, 0, 0, 0, time.UTC),
+	})
+
+	assert.Equal(t, Patient{
+		ResourceType: "Patient",
+		Id:           "3",
+		Identifier:   []Identifier{{Value: "123-45-6789"}, {System: "urn:oid:2.16.840.1.113883.19.5", Value: "MRN001"}},
+		Name:         []HumanName{{Text: "Jane Ann Smith", Family: "Smith", Given: []string{"Jane", "Ann"}}},
+		Telecom:      []ContactPoint{{System: "phone", Value: "8044955578"}},
+		BirthDate:    "1990-02-01",
+		Address:      []Address{{Text: "1 main street"}},
+	}, patient)
+}
+
+func TestToCondition(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	condition := models.DiagnosedCondition{
+		Id:                 4,
+		PatientId:          3,
+		Name:               "Type 2 diabetes",
+		Code:               "44054006",
+		CodeSystem:         models.CodeSystemSNOMEDCT,
+		Date:               date,
+		ClinicalStatus:     models.ClinicalStatusActive,
+		VerificationStatus: models.VerificationStatusConfirmed,
+		EncounterId:        7,
+	}
+
+	t.Run("ToCondition_Success", func(t *testing.T) {
+		resource := ToCondition(condition)
+		assert.Equal(t, "4", resource.Id)
+		assert.Equal(t, []Coding{{System: CodeSystemSNOMEDCT, Code: "44054006", Display: "Type 2 diabetes"}}, resource.Code.Coding)
+		assert.Equal(t, Reference{Reference: "Patient/3"}, resource.Subject)
+		assert.Equal(t, &Reference{Reference: "Encounter/7"}, resource.Encounter)
+		assert.Equal(t, "active", resource.ClinicalStatus.Coding[0].Code)
+		assert.Equal(t, "confirmed", resource.VerificationStatus.Coding[0].Code)
+		assert.Equal(t, "2024-03-01T00:00:00Z", resource.RecordedDate)
+	})
+
+	t.Run("ToCondition_EnteredInErrorHasNoClinicalStatus", func(t *testing.T) {
+		enteredInError := condition
+		enteredInError.VerificationStatus = models.VerificationStatusEnteredInError
+
+		resource := ToCondition(enteredInError)
+		assert.Nil(t, resource.ClinicalStatus)
+		assert.Equal(t, "entered-in-error", resource.VerificationStatus.Coding[0].Code)
+	})
+}
+
+func TestToOperationOutcome(t *testing.T) {
+	status, outcome := ToOperationOutcome(customerrors.NewNotFoundError("Patient/9 not found"))
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, []OperationOutcomeIssue{{Severity: "error", Code: "not-found", Diagnostics: "Patient/9 not found"}}, outcome.Issue)
+
+	status, outcome = ToOperationOutcome(fmt.Errorf("db error"))
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, "internal server error", outcome.Issue[0].Diagnostics)
+}
+
+func TestReadPatient(t *testing.T) {
+	t.Run("ReadPatient_Success", func(t *testing.T) {
+		mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 3).Return(models.Patient{Id: 3, Name: "Jane Smith"}, nil)
+
+		patient, err := service.ReadPatient(context.Background(), "3")
+		assert.Nil(t, err)
+		assert.Equal(t, "3", patient.Id)
+	})
+
+	t.Run("ReadPatient_Missing", func(t *testing.T) {
+		mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 3).Return(models.Patient{}, customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.ReadPatient(context.Background(), "3")
+		assert.Equal(t, customerrors.NewNotFoundError("Patient/3 not found"), err)
+	})
+
+	t.Run("ReadPatient_InvalidId", func(t *testing.T) {
+		mockPatientSvc, _, _, service := getMocksAndService()
+
+		_, err := service.ReadPatient(context.Background(), "abc")
+		assert.Equal(t, customerrors.NewNotFoundError("Patient/abc not found"), err)
+
+		mockPatientSvc.AssertNotCalled(t, "GetPatient", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSearchPatients(t *testing.T) {
+	jane := models.Patient{Id: 1, Name: "Jane Smith"}
+	john := models.Patient{Id: 2, Name: "John Smith"}
+
+	t.Run("SearchPatients_ParametersNarrowTheMatches", func(t *testing.T) {
+		mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{NamePart: "smith"}).Return([]models.Patient{john, jane}, nil)
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{BirthDate: "1990-02-01"}).Return([]models.Patient{jane}, nil)
+
+		bundle, err := service.SearchPatients(context.Background(), base, url.Values{"name": {"smith"}, "birthdate": {"eq1990-02-01"}})
+		assert.Nil(t, err)
+		assert.Equal(t, "searchset", bundle.Type)
+		assert.Equal(t, 1, *bundle.Total)
+		assert.Equal(t, base+"/Patient/1", bundle.Entry[0].FullUrl)
+		assert.Equal(t, "match", bundle.Entry[0].Search.Mode)
+	})
+
+	t.Run("SearchPatients_CommaSeparatedValuesWidenTheMatches", func(t *testing.T) {
+		mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{Identifier: "a1"}).Return([]models.Patient{jane}, nil)
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{Identifier: models.IdentifierSystemExternal + "|b2"}).Return([]models.Patient{john}, nil)
+
+		bundle, err := service.SearchPatients(context.Background(), base, url.Values{"identifier": {"a1,|b2"}})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, *bundle.Total)
+		assert.Equal(t, "1", bundle.Entry[0].Resource.(Patient).Id)
+		assert.Equal(t, "2", bundle.Entry[1].Resource.(Patient).Id)
+	})
+
+	t.Run("SearchPatients_IdentifierWithSystem", func(t *testing.T) {
+		mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{Identifier: "http://hl7.org/fhir/sid/us-ssn|a1"}).Return([]models.Patient{jane}, nil)
+
+		bundle, err := service.SearchPatients(context.Background(), base, url.Values{"identifier": {"http://hl7.org/fhir/sid/us-ssn|a1"}})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, *bundle.Total)
+	})
+
+	t.Run("SearchPatients_UnsupportedParameter", func(t *testing.T) {
+		_, _, _, service := getMocksAndService()
+
+		_, err := service.SearchPatients(context.Background(), base, url.Values{"gender": {"female"}})
+		assert.NotNil(t, err)
+		assert.Equal(t, `"gender" is not a supported search parameter, expected one of birthdate, identifier, name`, err.Error())
+	})
+
+	t.Run("SearchPatients_NoParameters", func(t *testing.T) {
+		_, _, _, service := getMocksAndService()
+
+		_, err := service.SearchPatients(context.Background(), base, url.Values{})
+		assert.NotNil(t, err)
+		assert.Equal(t, "at least one of the birthdate, identifier, name search parameters is required", err.Error())
+	})
+}
+
+func TestSearchConditions(t *testing.T) {
+	first := models.DiagnosedCondition{Id: 1, PatientId: 1, Code: "E11.9", CodeSystem: models.CodeSystemICD10CM}
+	second := models.DiagnosedCondition{Id: 2, PatientId: 1, Code: "I10", CodeSystem: models.CodeSystemICD10CM}
+
+	t.Run("SearchConditions_PatientAndCode", func(t *testing.T) {
+		mockPatientSvc, mockConditionSvc, _, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 1).Return(models.Patient{Id: 1, DiagnosedConditions: []models.DiagnosedCondition{first, second}}, nil)
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{DiagnosedConditionCode: "E11.9", DiagnosedConditionCodeSystem: models.CodeSystemICD10CM}).Return([]models.Patient{{Id: 1}}, nil)
+		mockConditionSvc.On("GetPatientDiagnosedConditions", mock.Anything, models.DiagnosedConditionSearch{PatientId: 1, Code: "E11.9", CodeSystem: models.CodeSystemICD10CM}).Return([]models.DiagnosedCondition{first}, nil)
+
+		bundle, err := service.SearchConditions(context.Background(), base, url.Values{"patient": {"Patient/1"}, "code": {CodeSystemICD10CM + "|E11.9"}})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, *bundle.Total)
+		assert.Equal(t, "1", bundle.Entry[0].Resource.(Condition).Id)
+	})
+
+	t.Run("SearchConditions_MissingPatient", func(t *testing.T) {
+		mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 9).Return(models.Patient{}, customerrors.NewInvalidInputError("patient id not found"))
+
+		bundle, err := service.SearchConditions(context.Background(), base, url.Values{"subject": {"9"}})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, *bundle.Total)
+	})
+
+	t.Run("SearchConditions_UnsupportedCodeSystem", func(t *testing.T) {
+		_, _, _, service := getMocksAndService()
+
+		_, err := service.SearchConditions(context.Background(), base, url.Values{"code": {"http://loinc.org|8867-4"}})
+		assert.NotNil(t, err)
+		assert.Equal(t, `"http://loinc.org" is not a supported code system, expected http://hl7.org/fhir/sid/icd-10-cm or http://snomed.info/sct`, err.Error())
+	})
+}
+
+func TestReadBinary(t *testing.T) {
+	t.Run("ReadBinary_CurrentVersion", func(t *testing.T) {
+		_, _, mockAttatchmentSvc, service := getMocksAndService()
+		mockAttatchmentSvc.On("GetAttatchmentMetadata", mock.Anything, 5).Return(models.Attatchment{Id: 5, Version: 2}, nil)
+		mockAttatchmentSvc.On("GetAttatchmentVersion", mock.Anything, 5, 2).Return(models.AttatchmentVersion{AttatchmentId: 5, Version: 2, Data: []byte("second version")}, nil)
+
+		binary, err := service.ReadBinary(context.Background(), "5")
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("second version"), binary.Data)
+		assert.Equal(t, "text/plain; charset=utf-8", binary.ContentType)
+	})
+}
+
+func TestFromPatient(t *testing.T) {
+	resource := Patient{
+		ResourceType: "Patient",
+		Identifier:   []Identifier{{System: "http://hl7.org/fhir/sid/us-ssn", Value: "123-45-6789"}},
+		Name:         []HumanName{{Family: "Smith", Given: []string{"Jane", "Ann"}}},
+		Telecom:      []ContactPoint{{System: "email", Value: "jane@example.com"}, {System: "phone", Value: "8044955578"}},
+		BirthDate:    "1990-02-01",
+		Address:      []Address{{Line: []string{"1 main street"}, City: "Richmond", State: "VA", PostalCode: "23220"}},
+	}
+
+	t.Run("FromPatient_Success", func(t *testing.T) {
+		details, err := FromPatient(resource)
+		assert.Nil(t, err)
+		assert.Equal(t, models.PatientRequest{
+			Name:        "Jane Ann Smith",
+			Address:     "1 main street, Richmond, VA 23220",
+			PhoneNumber: "8044955578",
+			DateOfBirth: time.Date(1990, 2, 1, 0, 0, 0, 0, time.UTC),
+			Identifiers: []models.PatientIdentifier{{System: "http://hl7.org/fhir/sid/us-ssn", Value: "123-45-6789"}},
+		}, details)
+	})
+
+	t.Run("FromPatient_IdentifierWithoutSystem", func(t *testing.T) {
+		noSystem := resource
+		noSystem.Identifier = []Identifier{{Value: "123-45-6789"}}
+
+		details, err := FromPatient(noSystem)
+		assert.Nil(t, err)
+		assert.Equal(t, []models.PatientIdentifier{{System: models.IdentifierSystemExternal, Value: "123-45-6789"}}, details.Identifiers)
+	})
+
+	t.Run("FromPatient_MissingIdentifier", func(t *testing.T) {
+		noIdentifier := resource
+		noIdentifier.Identifier = nil
+
+		_, err := FromPatient(noIdentifier)
+		assert.Equal(t, customerrors.NewInvalidInputError("Patient.identifier is required"), err)
+	})
+
+	t.Run("FromPatient_MissingPhoneNumber", func(t *testing.T) {
+		noPhone := resource
+		noPhone.Telecom = nil
+
+		_, err := FromPatient(noPhone)
+		assert.Equal(t, customerrors.NewInvalidInputError("a Patient.telecom phone number is required, with at least 10 characters"), err)
+	})
+
+	t.Run("FromPatient_WrongResourceType", func(t *testing.T) {
+		_, err := FromPatient(Patient{ResourceType: "Condition"})
+		assert.Equal(t, customerrors.NewInvalidInputError(`expected a Patient resource, but got "Condition"`), err)
+	})
+}
+
+func TestFromCondition(t *testing.T) {
+	resource := Condition{
+		ResourceType: "Condition",
+		Code: CodeableConcept{Coding: []Coding{
+			{System: "http://loinc.org", Code: "8867-4"},
+			{System: CodeSystemSNOMEDCT, Code: "44054006", Display: "Type 2 diabetes"},
+		}},
+		Subject:       Reference{Reference: "Patient/3"},
+		Encounter:     &Reference{Reference: "Encounter/7"},
+		RecordedDate:  "2024-03-01T10:00:00Z",
+		OnsetDateTime: "2024-01-15",
+		Note:          []Annotation{{Text: "diet controlled"}},
+	}
+
+	t.Run("FromCondition_Success", func(t *testing.T) {
+		patientId, details, err := FromCondition(resource)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, patientId)
+		assert.Equal(t, "Type 2 diabetes", details.Name)
+		assert.Equal(t, "44054006", details.Code)
+		assert.Equal(t, models.CodeSystemSNOMEDCT, details.CodeSystem)
+		assert.Equal(t, "diet controlled", details.Description)
+		assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), details.Date)
+		assert.Equal(t, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), *details.OnsetDate)
+		assert.Equal(t, 7, details.EncounterId)
+	})
+
+	t.Run("FromCondition_UnsupportedCodeSystem", func(t *testing.T) {
+		loinc := resource
+		loinc.Code = CodeableConcept{Coding: []Coding{{System: "http://loinc.org", Code: "8867-4"}}}
+
+		_, _, err := FromCondition(loinc)
+		assert.Equal(t, customerrors.NewInvalidInputError("Condition.code requires a coding from http://hl7.org/fhir/sid/icd-10-cm or http://snomed.info/sct"), err)
+	})
+
+	t.Run("FromCondition_InvalidSubject", func(t *testing.T) {
+		group := resource
+		group.Subject = Reference{Reference: "Group/3"}
+
+		_, _, err := FromCondition(group)
+		assert.Equal(t, customerrors.NewInvalidInputError(`Condition.subject "Group/3" is not a valid reference, expected Patient/{id}`), err)
+	})
+}
+
+func TestUpdatePatient(t *testing.T) {
+	resource := Patient{
+		ResourceType: "Patient",
+		Id:           "3",
+		Identifier:   []Identifier{{Value: "123-45-6789"}},
+		Name:         []HumanName{{Text: "Jane Smith"}},
+		Telecom:      []ContactPoint{{System: "phone", Value: "8044955578"}},
+		BirthDate:    "1990-02-01",
+	}
+	dateOfBirth := time.Date(1990, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("UpdatePatient_Success", func(t *testing.T) {
+		mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 3).Return(models.Patient{Id: 3}, nil)
+		mockPatientSvc.On("UpdatePatient", mock.Anything, 3, "Jane Smith", "", "8044955578", dateOfBirth, "", []models.PatientIdentifier{{System: models.IdentifierSystemExternal, Value: "123-45-6789"}}).Return(models.Patient{Id: 3, Name: "Jane Smith"}, nil)
+
+		patient, err := service.UpdatePatient(context.Background(), "3", resource)
+		assert.Nil(t, err)
+		assert.Equal(t, "3", patient.Id)
+	})
+
+	t.Run("UpdatePatient_Missing", func(t *testing.T) {
+		mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("GetPatient", mock.Anything, 3).Return(models.Patient{}, customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.UpdatePatient(context.Background(), "3", resource)
+		assert.Equal(t, customerrors.NewNotFoundError("Patient/3 not found"), err)
+
+		mockPatientSvc.AssertNotCalled(t, "UpdatePatient", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("UpdatePatient_MismatchedId", func(t *testing.T) {
+		_, _, _, service := getMocksAndService()
+
+		_, err := service.UpdatePatient(context.Background(), "4", resource)
+		assert.Equal(t, customerrors.NewInvalidInputError(`Patient.id "3" does not match the id "4" in the url`), err)
+	})
+}
+
+func TestCreateCondition(t *testing.T) {
+	resource := Condition{
+		ResourceType: "Condition",
+		Code:         CodeableConcept{Coding: []Coding{{System: CodeSystemICD10CM, Code: "E11.9"}}},
+		Subject:      Reference{Reference: "Patient/3"},
+		RecordedDate: "2024-03-01",
+	}
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("CreateCondition_Success", func(t *testing.T) {
+		_, mockConditionSvc, _, service := getMocksAndService()
+		mockConditionSvc.On("AddDiagnosedConditionToPatient", mock.Anything, 3, "", "E11.9", models.CodeSystemICD10CM, "", date, (*time.Time)(nil), 0).Return(models.DiagnosedCondition{Id: 4, PatientId: 3, Code: "E11.9", CodeSystem: models.CodeSystemICD10CM}, nil)
+
+		condition, err := service.CreateCondition(context.Background(), resource)
+		assert.Nil(t, err)
+		assert.Equal(t, "4", condition.Id)
+	})
+
+	t.Run("CreateCondition_InactiveStatus", func(t *testing.T) {
+		_, mockConditionSvc, _, service := getMocksAndService()
+		inactive := resource
+		inactive.ClinicalStatus = &CodeableConcept{Coding: []Coding{{System: CodeSystemClinicalStatus, Code: models.ClinicalStatusResolved}}}
+
+		_, err := service.CreateCondition(context.Background(), inactive)
+		assert.Equal(t, customerrors.NewInvalidInputError("Condition.clinicalStatus must be active, other statuses are set by transitioning the condition"), err)
+
+		mockConditionSvc.AssertNotCalled(t, "AddDiagnosedConditionToPatient", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestUpdateCondition(t *testing.T) {
+	t.Run("UpdateCondition_ChangedSubject", func(t *testing.T) {
+		_, mockConditionSvc, _, service := getMocksAndService()
+		mockConditionSvc.On("GetDiagnosedCondition", mock.Anything, 4).Return(models.DiagnosedCondition{Id: 4, PatientId: 3}, nil)
+
+		_, err := service.UpdateCondition(context.Background(), "4", Condition{
+			ResourceType: "Condition",
+			Code:         CodeableConcept{Coding: []Coding{{System: CodeSystemICD10CM, Code: "E11.9"}}},
+			Subject:      Reference{Reference: "Patient/5"},
+		})
+		assert.Equal(t, customerrors.NewInvalidInputError("Condition.subject cannot be changed from Patient/3"), err)
+	})
+}
+
+func TestProcessTransaction(t *testing.T) {
+	patient := map[string]any{
+		"resourceType": "Patient",
+		"identifier":   []any{map[string]any{"value": "123-45-6789"}},
+		"name":         []any{map[string]any{"text": "Jane Smith"}},
+		"telecom":      []any{map[string]any{"system": "phone", "value": "8044955578"}},
+		"birthDate":    "1990-02-01",
+	}
+	condition := map[string]any{
+		"resourceType": "Condition",
+		"code":         map[string]any{"coding": []any{map[string]any{"system": CodeSystemICD10CM, "code": "E11.9"}}},
+		"subject":      map[string]any{"reference": "urn:uuid:61ebe359-bfdc-4613-8bf2-c5e300945f0a"},
+		"recordedDate": "2024-03-01",
+	}
+	bundle := Bundle{
+		ResourceType: "Bundle",
+		Type:         "transaction",
+		Entry: []BundleEntry{
+			{Resource: condition, Request: &BundleEntryRequest{Method: http.MethodPost, Url: "Condition"}},
+			{FullUrl: "urn:uuid:61ebe359-bfdc-4613-8bf2-c5e300945f0a", Resource: patient, Request: &BundleEntryRequest{Method: http.MethodPost, Url: "Patient"}},
+		},
+	}
+	dateOfBirth := time.Date(1990, 2, 1, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("ProcessTransaction_ResolvesReferencesToCreatedPatients", func(t *testing.T) {
+		mockPatientSvc, mockConditionSvc, _, service := getMocksAndService()
+		mockPatientSvc.On("CreatePatient", mock.Anything, "Jane Smith", "", "8044955578", dateOfBirth, "", []models.PatientIdentifier{{System: models.IdentifierSystemExternal, Value: "123-45-6789"}}).Return(models.Patient{Id: 3, Name: "Jane Smith"}, nil)
+		mockConditionSvc.On("AddDiagnosedConditionToPatient", mock.Anything, 3, "", "E11.9", models.CodeSystemICD10CM, "", date, (*time.Time)(nil), 0).Return(models.DiagnosedCondition{Id: 4, PatientId: 3}, nil)
+
+		response, err := service.ProcessTransaction(context.Background(), base, bundle)
+		assert.Nil(t, err)
+		assert.Equal(t, "transaction-response", response.Type)
+		assert.Nil(t, response.Total)
+		assert.Equal(t, &BundleEntryResponse{Status: "201 Created", Location: "Condition/4"}, response.Entry[0].Response)
+		assert.Equal(t, &BundleEntryResponse{Status: "201 Created", Location: "Patient/3"}, response.Entry[1].Response)
+		assert.Equal(t, base+"/Patient/3", response.Entry[1].FullUrl)
+	})
+
+	t.Run("ProcessTransaction_FailedEntry", func(t *testing.T) {
+		mockPatientSvc, mockConditionSvc, _, service := getMocksAndService()
+		mockPatientSvc.On("CreatePatient", mock.Anything, "Jane Smith", "", "8044955578", dateOfBirth, "", []models.PatientIdentifier{{System: models.IdentifierSystemExternal, Value: "123-45-6789"}}).Return(models.Patient{Id: 3}, nil)
+		mockConditionSvc.On("AddDiagnosedConditionToPatient", mock.Anything, 3, "", "E11.9", models.CodeSystemICD10CM, "", date, (*time.Time)(nil), 0).Return(models.DiagnosedCondition{}, customerrors.NewInvalidInputError("E11.9 is not a valid ICD-10-CM code"))
+
+		_, err := service.ProcessTransaction(context.Background(), base, bundle)
+		assert.Equal(t, customerrors.NewInvalidInputError("entry 0: E11.9 is not a valid ICD-10-CM code"), err)
+	})
+
+	t.Run("ProcessTransaction_UnsupportedMethod", func(t *testing.T) {
+		mockPatientSvc, _, _, service := getMocksAndService()
+		deletion := Bundle{ResourceType: "Bundle", Type: "transaction", Entry: []BundleEntry{{Request: &BundleEntryRequest{Method: http.MethodDelete, Url: "Patient/3"}}}}
+
+		_, err := service.ProcessTransaction(context.Background(), base, deletion)
+		assert.Equal(t, customerrors.NewInvalidInputError(`entry 0: "DELETE" is not a supported method, expected POST or PUT`), err)
+
+		mockPatientSvc.AssertNotCalled(t, "CreatePatient", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ProcessTransaction_Batch", func(t *testing.T) {
+		_, _, _, service := getMocksAndService()
+
+		_, err := service.ProcessTransaction(context.Background(), base, Bundle{ResourceType: "Bundle", Type: "batch"})
+		assert.Equal(t, customerrors.NewInvalidInputError(`expected a transaction Bundle, but got a "batch" Bundle`), err)
+	})
+}
@@ -0,0 +1,167 @@
+package fhir
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// applies every entry of a transaction Bundle, or none of them if any fails.  Entries may refer to a patient created by an
+// earlier entry through its fullUrl, such as urn:uuid:..., which is replaced with a reference to the created patient
+func (s Service) ProcessTransaction(ctx context.Context, base string, bundle Bundle) (Bundle, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "ProcessTransaction")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.String("type", bundle.Type),
+		attribute.Int("entries", len(bundle.Entry)))
+
+	if bundle.ResourceType != "Bundle" || bundle.Type != "transaction" {
+		return Bundle{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("expected a transaction Bundle, but got a %q %v", bundle.Type, bundle.ResourceType)))
+	}
+	for i, entry := range bundle.Entry {
+		if entry.Request == nil {
+			return Bundle{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("entry %v: request is required", i)))
+		}
+		if entry.Request.Method != http.MethodPost && entry.Request.Method != http.MethodPut {
+			return Bundle{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("entry %v: %q is not a supported method, expected POST or PUT", i, entry.Request.Method)))
+		}
+	}
+
+	response := Bundle{
+		ResourceType: "Bundle",
+		Type:         "transaction-response",
+		Entry:        make([]BundleEntry, len(bundle.Entry)),
+	}
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		references := make(map[string]Reference)
+		//creates are processed before updates, as FHIR requires, and patients before the conditions which may refer to them
+		for _, method := range []string{http.MethodPost, http.MethodPut} {
+			for _, patients := range []bool{true, false} {
+				for i, entry := range bundle.Entry {
+					if entry.Request.Method != method || isResourceUrl(entry.Request.Url, "Patient") != patients {
+						continue
+					}
+					result, err := s.processEntry(ctx, base, entry, references)
+					if err != nil {
+						return entryError(i, err)
+					}
+					response.Entry[i] = result
+					if entry.FullUrl != "" {
+						references[entry.FullUrl] = Reference{Reference: result.Response.Location}
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Bundle{}, s.tracer.RecordError(ctx, err)
+	}
+
+	return response, nil
+}
+
+func (s Service) processEntry(ctx context.Context, base string, entry BundleEntry, references map[string]Reference) (BundleEntry, error) {
+	resourceType, id, hasId := strings.Cut(entry.Request.Url, "/")
+	if hasId == (entry.Request.Method == http.MethodPost) {
+		return BundleEntry{}, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a valid url for %v, expected %v", entry.Request.Url, entry.Request.Method, expectedUrl(entry.Request.Method)))
+	}
+
+	var resource any
+	var resourceId string
+	switch resourceType {
+	case "Patient":
+		var patient Patient
+		err := decodeResource(entry.Resource, &patient)
+		if err != nil {
+			return BundleEntry{}, err
+		}
+		if hasId {
+			patient, err = s.UpdatePatient(ctx, id, patient)
+		} else {
+			patient, err = s.CreatePatient(ctx, patient)
+		}
+		if err != nil {
+			return BundleEntry{}, err
+		}
+		resource, resourceId = patient, patient.Id
+	case "Condition":
+		var condition Condition
+		err := decodeResource(entry.Resource, &condition)
+		if err != nil {
+			return BundleEntry{}, err
+		}
+		if reference, found := references[condition.Subject.Reference]; found {
+			condition.Subject = reference
+		}
+		if hasId {
+			condition, err = s.UpdateCondition(ctx, id, condition)
+		} else {
+			condition, err = s.CreateCondition(ctx, condition)
+		}
+		if err != nil {
+			return BundleEntry{}, err
+		}
+		resource, resourceId = condition, condition.Id
+	default:
+		return BundleEntry{}, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a supported resource type, expected Patient or Condition", resourceType))
+	}
+
+	status := "200 OK"
+	if !hasId {
+		status = "201 Created"
+	}
+	location := fmt.Sprintf("%v/%v", resourceType, resourceId)
+	return BundleEntry{
+		FullUrl:  fmt.Sprintf("%v/%v", base, location),
+		Resource: resource,
+		Response: &BundleEntryResponse{Status: status, Location: location},
+	}, nil
+}
+
+func isResourceUrl(url string, resourceType string) bool {
+	return url == resourceType || strings.HasPrefix(url, resourceType+"/")
+}
+
+func expectedUrl(method string) string {
+	if method == http.MethodPost {
+		return "Patient or Condition"
+	}
+	return "Patient/{id} or Condition/{id}"
+}
+
+// entry resources are decoded without knowing their type, so are re-encoded into the type their url names
+func decodeResource(resource any, target any) error {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return customerrors.NewInvalidInputError(fmt.Sprintf("resource is not valid %v", err))
+	}
+	err = json.Unmarshal(data, target)
+	if err != nil {
+		return customerrors.NewInvalidInputError(fmt.Sprintf("resource is not valid %v", err))
+	}
+	return nil
+}
+
+// prefixes the error with the position of its entry, keeping its type so it is reported with the right status
+func entryError(index int, err error) error {
+	var notFoundError customerrors.NotFoundError
+	var inputError customerrors.InvalidInputError
+	var alreadyExistsError customerrors.AlreadyExistsError
+	switch {
+	case errors.As(err, &notFoundError):
+		return customerrors.NewNotFoundError(fmt.Sprintf("entry %v: %v", index, notFoundError.Error()))
+	case errors.As(err, &inputError):
+		return customerrors.NewInvalidInputError(fmt.Sprintf("entry %v: %v", index, inputError.Error()))
+	case errors.As(err, &alreadyExistsError):
+		return customerrors.NewAlreadyExistsError(fmt.Sprintf("entry %v: %v", index, alreadyExistsError.Error()))
+	default:
+		return fmt.Errorf("error processing entry %v %w", index, err)
+	}
+}
@@ -0,0 +1,36 @@
+package hl7
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runs fn as a single unit of work, so every change made through ctx is undone if it returns an error
+type TransactionRunner interface {
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type PatientService interface {
+	CreatePatient(ctx context.Context, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error)
+	UpdatePatient(ctx context.Context, id int, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error)
+	SearchPatients(ctx context.Context, search models.PatientSearch) ([]models.Patient, error)
+}
+
+type DiagnosedConditionService interface {
+	AddDiagnosedConditionToPatient(ctx context.Context, patientId int, name string, code string, codeSystem string, description string, date time.Time, onsetDate *time.Time, encounterId int) (models.DiagnosedCondition, error)
+}
+
+// merges patients the same way as merging them through the api, so every part of the prior patient's record is kept
+type MergeService interface {
+	MergePatients(ctx context.Context, patientId int, duplicatePatientId int) (models.PatientLink, error)
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
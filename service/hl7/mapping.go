@@ -0,0 +1,134 @@
+package hl7
+
+import (
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"strings"
+	"time"
+)
+
+// the patient's details from a PID segment.  The first identifier of PID-3 is the patient's external identifier, and
+// later ones are recorded in the system of their assigning authority.  Only the first phone number of PID-13 is
+// recorded, and fields which were not sent are left empty
+func FromPID(pid Segment) (models.PatientRequest, error) {
+	details := models.PatientRequest{
+		ExternalIdentifier: pid.Component(3, 1),
+		Identifiers:        fromIdentifiers(pid),
+		Name:               fromPersonName(pid),
+		Address:            fromAddress(pid),
+		PhoneNumber:        fromPhoneNumber(pid),
+	}
+	if details.ExternalIdentifier == "" {
+		return models.PatientRequest{}, customerrors.NewInvalidInputError("PID-3 patient identifier is required")
+	}
+	if birthDate := pid.Component(7, 1); birthDate != "" {
+		dateOfBirth, err := parseTimestamp(birthDate)
+		if err != nil {
+			return models.PatientRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("PID-7 %v", err))
+		}
+		details.DateOfBirth = dateOfBirth
+	}
+	return details, nil
+}
+
+// identifiers without an assigning authority in CX-4 have no system to be recorded in, so are skipped
+func fromIdentifiers(pid Segment) []models.PatientIdentifier {
+	identifiers := []models.PatientIdentifier{}
+	repetitions := pid.Repetitions(3)
+	for i := 1; i < len(repetitions); i++ {
+		value := pid.RepetitionComponent(repetitions[i], 1)
+		system := pid.RepetitionComponent(repetitions[i], 4)
+		if value != "" && system != "" {
+			identifiers = append(identifiers, models.PatientIdentifier{System: system, Value: value})
+		}
+	}
+	return identifiers
+}
+
+// names are recorded whole, as given and middle names followed by the family name
+func fromPersonName(pid Segment) string {
+	return joinNonEmpty(" ", pid.Component(5, 2), pid.Component(5, 3), pid.Component(5, 1))
+}
+
+func fromAddress(pid Segment) string {
+	return joinNonEmpty(", ",
+		pid.Component(11, 1),
+		pid.Component(11, 2),
+		pid.Component(11, 3),
+		joinNonEmpty(" ", pid.Component(11, 4), pid.Component(11, 5)),
+		pid.Component(11, 6))
+}
+
+// newer versions of HL7 split the number into its area code and local number, rather than sending it formatted
+func fromPhoneNumber(pid Segment) string {
+	if formatted := pid.Component(13, 1); formatted != "" {
+		return formatted
+	}
+	if local := pid.Component(13, 7); local != "" {
+		return pid.Component(13, 6) + local
+	}
+	return pid.Component(13, 12)
+}
+
+// the condition from a DG1 segment, coded as code^text^coding system in DG1-3.  Conditions without a diagnosis date in
+// DG1-5 are recorded as diagnosed now
+func FromDG1(dg1 Segment) (models.DiagnosedConditionRequest, error) {
+	details := models.DiagnosedConditionRequest{
+		Code:        dg1.Component(3, 1),
+		Name:        dg1.Component(3, 2),
+		Description: dg1.Component(4, 1),
+		Date:        time.Now(),
+	}
+	if details.Code == "" {
+		return models.DiagnosedConditionRequest{}, customerrors.NewInvalidInputError("DG1-3 diagnosis code is required")
+	}
+	codeSystem, known := fromCodingSystem(dg1.Component(3, 3))
+	if !known {
+		return models.DiagnosedConditionRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("DG1-3 %q is not a supported coding system, expected I10 or SCT", dg1.Component(3, 3)))
+	}
+	details.CodeSystem = codeSystem
+	if date := dg1.Component(5, 1); date != "" {
+		parsed, err := parseTimestamp(date)
+		if err != nil {
+			return models.DiagnosedConditionRequest{}, customerrors.NewInvalidInputError(fmt.Sprintf("DG1-5 %v", err))
+		}
+		details.Date = parsed
+	}
+	return details, nil
+}
+
+// HL7 table 0396 names ICD-10 and SNOMED CT in several ways.  Codes without a coding system are taken to be ICD-10-CM
+func fromCodingSystem(system string) (string, bool) {
+	switch strings.ToUpper(system) {
+	case "", "I10", "I10C", "ICD10", "ICD10CM", "ICD-10-CM":
+		return models.CodeSystemICD10CM, true
+	case "SCT", "SNM", "SNOMEDCT", "SNOMED-CT":
+		return models.CodeSystemSNOMEDCT, true
+	default:
+		return "", false
+	}
+}
+
+// HL7 timestamps are written as YYYYMMDD, followed by as much of HHMMSS as is known and an optional offset such as -0500
+func parseTimestamp(value string) (time.Time, error) {
+	if whole, fraction, found := strings.Cut(value, "."); found {
+		value = whole + strings.TrimLeft(fraction, "0123456789")
+	}
+	for _, layout := range []string{"20060102150405-0700", "200601021504-0700", "20060102-0700", "20060102150405", "200601021504", "2006010215", "20060102"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a valid timestamp, expected the format YYYYMMDD[HHMM[SS]]", value)
+}
+
+func joinNonEmpty(separator string, values ...string) string {
+	var nonEmpty []string
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+	return strings.Join(nonEmpty, separator)
+}
@@ -0,0 +1,141 @@
+package hl7
+
+import (
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"strings"
+)
+
+// a parsed HL7 v2 message.  Fields are numbered as in the HL7 standard, so MSH-9 is Segment("MSH").Field(9)
+type Message struct {
+	Segments []Segment
+}
+
+type Segment struct {
+	Name       string
+	fields     []string
+	delimiters delimiters
+}
+
+type delimiters struct {
+	field        byte
+	component    byte
+	repetition   byte
+	escape       byte
+	subcomponent byte
+}
+
+var defaultDelimiters = delimiters{field: '|', component: '^', repetition: '~', escape: '\\', subcomponent: '&'}
+
+// the delimiters are read from the MSH segment, which must come first.  Segments may be separated by \r, \n or both
+func ParseMessage(raw string) (Message, error) {
+	raw = strings.ReplaceAll(raw, "\r\n", "\r")
+	raw = strings.ReplaceAll(raw, "\n", "\r")
+	if !strings.HasPrefix(raw, "MSH") || len(raw) < 8 {
+		return Message{}, customerrors.NewInvalidInputError("message must begin with an MSH segment")
+	}
+
+	d := delimiters{field: raw[3], component: raw[4], repetition: raw[5], escape: raw[6], subcomponent: raw[7]}
+	var message Message
+	for _, line := range strings.Split(raw, "\r") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, string(d.field))
+		if len(fields[0]) != 3 {
+			return Message{}, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a valid segment", line))
+		}
+		//MSH-1 is the field separator itself, so the fields after it are shifted along by one
+		if fields[0] == "MSH" {
+			fields = append([]string{"MSH", string(d.field)}, fields[1:]...)
+		}
+		message.Segments = append(message.Segments, Segment{Name: fields[0], fields: fields, delimiters: d})
+	}
+	return message, nil
+}
+
+// the first segment with the name, or an empty segment if there is none
+func (m Message) Segment(name string) Segment {
+	for _, segment := range m.Segments {
+		if segment.Name == name {
+			return segment
+		}
+	}
+	return Segment{Name: name, delimiters: defaultDelimiters}
+}
+
+func (m Message) All(name string) []Segment {
+	var segments []Segment
+	for _, segment := range m.Segments {
+		if segment.Name == name {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// the raw value of the field, including any components and repetitions
+func (s Segment) Field(field int) string {
+	if field < len(s.fields) {
+		return s.fields[field]
+	}
+	return ""
+}
+
+func (s Segment) Repetitions(field int) []string {
+	value := s.Field(field)
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, string(s.delimiters.repetition))
+}
+
+// the unescaped component of the first repetition of the field, numbered from 1
+func (s Segment) Component(field int, component int) string {
+	repetitions := s.Repetitions(field)
+	if len(repetitions) == 0 {
+		return ""
+	}
+	return s.RepetitionComponent(repetitions[0], component)
+}
+
+func (s Segment) RepetitionComponent(repetition string, component int) string {
+	components := strings.Split(repetition, string(s.delimiters.component))
+	if component < 1 || component > len(components) {
+		return ""
+	}
+	//subcomponents are not recorded, so only the first is kept
+	value, _, _ := strings.Cut(components[component-1], string(s.delimiters.subcomponent))
+	//"" asks for a value to be removed, which we cannot do for the fields we record, so it is treated as not sent
+	if value == `""` {
+		return ""
+	}
+	return s.delimiters.unescapeText(value)
+}
+
+func (d delimiters) unescapeText(value string) string {
+	escape := string(d.escape)
+	if !strings.Contains(value, escape) {
+		return value
+	}
+	return strings.NewReplacer(
+		escape+"F"+escape, string(d.field),
+		escape+"S"+escape, string(d.component),
+		escape+"T"+escape, string(d.subcomponent),
+		escape+"R"+escape, string(d.repetition),
+		escape+"E"+escape, escape,
+	).Replace(value)
+}
+
+func (d delimiters) escapeText(value string) string {
+	escape := string(d.escape)
+	return strings.NewReplacer(
+		escape, escape+"E"+escape,
+		string(d.field), escape+"F"+escape,
+		string(d.component), escape+"S"+escape,
+		string(d.subcomponent), escape+"T"+escape,
+		string(d.repetition), escape+"R"+escape,
+		"\r", " ",
+		"\n", " ",
+	).Replace(value)
+}
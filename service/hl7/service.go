@@ -0,0 +1,273 @@
+package hl7
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// acknowledgement codes, from HL7 table 0008
+const (
+	AckAccept = "AA"
+	AckError  = "AE"
+	AckReject = "AR"
+)
+
+// error codes, from HL7 table 0357
+const (
+	errorSegmentSequence        = "100"
+	errorUnsupportedMessageType = "200"
+	errorUnsupportedEventCode   = "201"
+	errorUnknownKeyIdentifier   = "204"
+	errorDuplicateKeyIdentifier = "205"
+	errorApplicationInternal    = "207"
+)
+
+type Service struct {
+	repo         TransactionRunner
+	patientSvc   PatientService
+	conditionSvc DiagnosedConditionService
+	mergeSvc     MergeService
+	tracer       Tracer
+}
+
+func NewService(repo TransactionRunner, patientSvc PatientService, conditionSvc DiagnosedConditionService, mergeSvc MergeService, tracer Tracer) Service {
+	return Service{
+		repo:         repo,
+		patientSvc:   patientSvc,
+		conditionSvc: conditionSvc,
+		mergeSvc:     mergeSvc,
+		tracer:       tracer,
+	}
+}
+
+// processes an ADT message, returning the acknowledgement to reply with.  Messages which cannot be parsed, or are not a
+// supported event, are rejected (AR).  Those which fail to apply are an application error (AE), and change nothing
+func (s Service) HandleMessage(ctx context.Context, raw string) string {
+	ctx, span := s.tracer.NewSpan(ctx, "HandleMessage")
+	defer span.End()
+
+	message, err := ParseMessage(raw)
+	if err != nil {
+		return s.acknowledge(ctx, message, AckReject, errorSegmentSequence, err)
+	}
+	msh := message.Segment("MSH")
+	s.tracer.SetAttributes(ctx,
+		attribute.String("messageType", msh.Field(9)),
+		attribute.String("controlId", msh.Field(10)))
+
+	if msh.Component(9, 1) != "ADT" {
+		return s.acknowledge(ctx, message, AckReject, errorUnsupportedMessageType, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a supported message type, expected ADT", msh.Component(9, 1))))
+	}
+	var apply func(ctx context.Context, message Message) error
+	switch msh.Component(9, 2) {
+	case "A01", "A04", "A08":
+		apply = s.registerPatient
+	case "A40":
+		apply = s.mergePatient
+	default:
+		return s.acknowledge(ctx, message, AckReject, errorUnsupportedEventCode, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a supported event, expected A01, A04, A08 or A40", msh.Component(9, 2))))
+	}
+
+	err = s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		return apply(ctx, message)
+	})
+	if err != nil {
+		return s.acknowledge(ctx, message, AckError, errorCode(err), err)
+	}
+	return s.acknowledge(ctx, message, AckAccept, "", nil)
+}
+
+// admissions, registrations and updates all record the patient as sent, along with any diagnoses not already recorded
+func (s Service) registerPatient(ctx context.Context, message Message) error {
+	details, err := FromPID(message.Segment("PID"))
+	if err != nil {
+		return err
+	}
+	patient, err := s.savePatient(ctx, details)
+	if err != nil {
+		return err
+	}
+
+	for _, dg1 := range message.All("DG1") {
+		condition, err := FromDG1(dg1)
+		if err != nil {
+			return err
+		}
+		if hasCondition(patient, condition.Code, condition.CodeSystem) {
+			continue
+		}
+		added, err := s.conditionSvc.AddDiagnosedConditionToPatient(ctx, patient.Id, condition.Name, condition.Code, condition.CodeSystem, condition.Description, condition.Date, condition.OnsetDate, 0)
+		if err != nil {
+			return err
+		}
+		patient.DiagnosedConditions = append(patient.DiagnosedConditions, added)
+	}
+	return nil
+}
+
+// the patient in MRG-1 is merged into the one in PID-3, moving their whole record over and linking the two, just as a
+// merge through the api does
+func (s Service) mergePatient(ctx context.Context, message Message) error {
+	details, err := FromPID(message.Segment("PID"))
+	if err != nil {
+		return err
+	}
+	priorIdentifier := message.Segment("MRG").Component(1, 1)
+	if priorIdentifier == "" {
+		return customerrors.NewInvalidInputError("MRG-1 prior patient identifier is required")
+	}
+	if priorIdentifier == details.ExternalIdentifier {
+		return customerrors.NewInvalidInputError("MRG-1 prior patient identifier must differ from PID-3")
+	}
+
+	prior, found, err := s.findPatient(ctx, priorIdentifier)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return customerrors.NewNotFoundError(fmt.Sprintf("prior patient %q was not found", priorIdentifier))
+	}
+	patient, err := s.savePatient(ctx, details)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.mergeSvc.MergePatients(ctx, patient.Id, prior.Id)
+	return err
+}
+
+// creates the patient if they are not yet recorded.  Otherwise fields which were not sent keep their recorded values
+func (s Service) savePatient(ctx context.Context, details models.PatientRequest) (models.Patient, error) {
+	existing, found, err := s.findPatient(ctx, details.ExternalIdentifier)
+	if err != nil {
+		return models.Patient{}, err
+	}
+
+	if !found {
+		switch {
+		case len(details.Name) < 3:
+			return models.Patient{}, customerrors.NewInvalidInputError("PID-5 patient name is required, with at least 3 characters")
+		case len(details.PhoneNumber) < 10:
+			return models.Patient{}, customerrors.NewInvalidInputError("PID-13 phone number is required, with at least 10 characters")
+		case details.DateOfBirth.IsZero():
+			return models.Patient{}, customerrors.NewInvalidInputError("PID-7 date of birth is required")
+		}
+		return s.patientSvc.CreatePatient(ctx, details.Name, details.Address, details.PhoneNumber, details.DateOfBirth, details.ExternalIdentifier, details.Identifiers)
+	}
+
+	if details.Name == "" {
+		details.Name = existing.Name
+	}
+	if details.Address == "" {
+		details.Address = existing.Address
+	}
+	if details.PhoneNumber == "" {
+		details.PhoneNumber = existing.PhoneNumber
+	}
+	if details.DateOfBirth.IsZero() {
+		details.DateOfBirth = existing.DateOfBirth
+	}
+	patient, err := s.patientSvc.UpdatePatient(ctx, existing.Id, details.Name, details.Address, details.PhoneNumber, details.DateOfBirth, details.ExternalIdentifier, addedIdentifiers(existing, details.Identifiers))
+	if err != nil {
+		return models.Patient{}, err
+	}
+	patient.DiagnosedConditions = existing.DiagnosedConditions
+	return patient, nil
+}
+
+func (s Service) findPatient(ctx context.Context, externalIdentifier string) (models.Patient, bool, error) {
+	patients, err := s.patientSvc.SearchPatients(ctx, models.PatientSearch{ExternalIdentifier: externalIdentifier})
+	if err != nil {
+		return models.Patient{}, false, err
+	}
+	if len(patients) == 0 {
+		return models.Patient{}, false, nil
+	}
+	return patients[0], true, nil
+}
+
+// messages only carry the identifiers the sender knows of, so those sent are added to the ones the patient already has
+// in other systems
+func addedIdentifiers(patient models.Patient, identifiers []models.PatientIdentifier) []models.PatientIdentifier {
+	added := []models.PatientIdentifier{}
+	for _, identifier := range patient.Identifiers {
+		if identifier.System != models.IdentifierSystemExternal {
+			added = append(added, identifier)
+		}
+	}
+	for _, identifier := range identifiers {
+		if !slices.Contains(added, identifier) {
+			added = append(added, identifier)
+		}
+	}
+	return added
+}
+
+// diagnoses are often resent with every update of the patient, so a code which is already recorded is not added again
+func hasCondition(patient models.Patient, code string, codeSystem string) bool {
+	for _, condition := range patient.DiagnosedConditions {
+		if condition.CodeSystem == codeSystem && normalizeCode(condition.Code) == normalizeCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// ICD-10-CM codes are sometimes sent without their dot
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(code, ".", ""))
+}
+
+func errorCode(err error) string {
+	var notFoundError customerrors.NotFoundError
+	var alreadyExistsError customerrors.AlreadyExistsError
+	switch {
+	case errors.As(err, &notFoundError):
+		return errorUnknownKeyIdentifier
+	case errors.As(err, &alreadyExistsError):
+		return errorDuplicateKeyIdentifier
+	default:
+		return errorApplicationInternal
+	}
+}
+
+// builds an ACK addressed back to the sender of the message.  Errors are described in an ERR segment, unless they are internal
+func (s Service) acknowledge(ctx context.Context, message Message, code string, errorCode string, err error) string {
+	msh := message.Segment("MSH")
+	d := msh.delimiters
+	field := string(d.field)
+	encoding := string([]byte{d.component, d.repetition, d.escape, d.subcomponent})
+	version := msh.Field(12)
+	if version == "" {
+		version = "2.5.1"
+	}
+
+	segments := []string{
+		strings.Join([]string{"MSH", encoding, msh.Field(5), msh.Field(6), msh.Field(3), msh.Field(4), time.Now().Format("20060102150405"), "",
+			strings.Join([]string{"ACK", msh.Component(9, 2), "ACK"}, string(d.component)),
+			"ACK" + msh.Field(10), msh.Field(11), version}, field),
+		strings.Join([]string{"MSA", code, msh.Field(10)}, field),
+	}
+	if err != nil {
+		err = s.tracer.RecordError(ctx, err)
+		diagnostics := "internal error"
+		var inputError customerrors.InvalidInputError
+		var notFoundError customerrors.NotFoundError
+		var alreadyExistsError customerrors.AlreadyExistsError
+		if errors.As(err, &inputError) || errors.As(err, &notFoundError) || errors.As(err, &alreadyExistsError) {
+			diagnostics = err.Error()
+		}
+		segments = append(segments, strings.Join([]string{"ERR", "", "",
+			strings.Join([]string{errorCode, "", "HL70357"}, string(d.component)),
+			"E", "", "", "", d.escapeText(diagnostics)}, field))
+	}
+	return strings.Join(segments, "\r") + "\r"
+}
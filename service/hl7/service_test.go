@@ -0,0 +1,296 @@
+package hl7
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockTransactionRunner struct {
+	mock.Mock
+}
+
+func (m *MockTransactionRunner) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.Called(ctx)
+	return fn(ctx)
+}
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) CreatePatient(ctx context.Context, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error) {
+	args := m.Called(ctx, name, address, phoneNumber, dateOfBirth, externalIdentifier, identifiers)
+	return args.Get(0).(models.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) UpdatePatient(ctx context.Context, id int, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error) {
+	args := m.Called(ctx, id, name, address, phoneNumber, dateOfBirth, externalIdentifier, identifiers)
+	return args.Get(0).(models.Patient), args.Error(1)
+}
+
+func (m *MockPatientService) SearchPatients(ctx context.Context, search models.PatientSearch) ([]models.Patient, error) {
+	args := m.Called(ctx, search)
+	return args.Get(0).([]models.Patient), args.Error(1)
+}
+
+type MockDiagnosedConditionService struct {
+	mock.Mock
+}
+
+func (m *MockDiagnosedConditionService) AddDiagnosedConditionToPatient(ctx context.Context, patientId int, name string, code string, codeSystem string, description string, date time.Time, onsetDate *time.Time, encounterId int) (models.DiagnosedCondition, error) {
+	args := m.Called(ctx, patientId, name, code, codeSystem, description, date, onsetDate, encounterId)
+	return args.Get(0).(models.DiagnosedCondition), args.Error(1)
+}
+
+type MockMergeService struct {
+	mock.Mock
+}
+
+func (m *MockMergeService) MergePatients(ctx context.Context, patientId int, duplicatePatientId int) (models.PatientLink, error) {
+	args := m.Called(ctx, patientId, duplicatePatientId)
+	return args.Get(0).(models.PatientLink), args.Error(1)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService() (*MockTransactionRunner, *MockPatientService, *MockDiagnosedConditionService, *MockMergeService, Service) {
+	mockRepo := new(MockTransactionRunner)
+	mockRepo.On("RunInTransaction", mock.Anything)
+	mockPatientSvc := new(MockPatientService)
+	mockConditionSvc := new(MockDiagnosedConditionService)
+	mockMergeSvc := new(MockMergeService)
+	service := NewService(mockRepo, mockPatientSvc, mockConditionSvc, mockMergeSvc, new(MockTracer))
+	return mockRepo, mockPatientSvc, mockConditionSvc, mockMergeSvc, service
+}
+
+func adtMessage(event string, segments ...string) string {
+	msh := fmt.Sprintf(`MSH|^~\&|REGADT|HOSP|MCG|MCG|20240301101500||ADT^%v^ADT_A01|MSG00001|P|2.5.1`, event)
+	return strings.Join(append([]string{msh}, segments...), "\r")
+}
+
+const pid = `PID|1||MRN001^^^HOSP^MR~123-45-6789^^^SSA^SS||Smith^Jane^Ann||19900201|F|||1 main street^^Richmond^VA^23220||(804)495-5578`
+
+func segment(t *testing.T, ack string, name string) Segment {
+	message, err := ParseMessage(ack)
+	assert.Nil(t, err)
+	return message.Segment(name)
+}
+
+func TestParseMessage(t *testing.T) {
+	t.Run("ParseMessage_FieldsAreNumberedFromTheSegmentName", func(t *testing.T) {
+		message, err := ParseMessage(adtMessage("A04", pid))
+		assert.Nil(t, err)
+
+		msh := message.Segment("MSH")
+		assert.Equal(t, "|", msh.Field(1))
+		assert.Equal(t, `^~\&`, msh.Field(2))
+		assert.Equal(t, "A04", msh.Component(9, 2))
+		assert.Equal(t, "MSG00001", msh.Field(10))
+		assert.Equal(t, "MRN001", message.Segment("PID").Component(3, 1))
+		assert.Equal(t, []string{"MRN001^^^HOSP^MR", "123-45-6789^^^SSA^SS"}, message.Segment("PID").Repetitions(3))
+	})
+
+	t.Run("ParseMessage_EscapesAndCustomDelimiters", func(t *testing.T) {
+		message, err := ParseMessage("MSH#*~!@#REGADT\nNTE#1##Salt \\F\\ pepper!F! and !S! more!E!")
+		assert.Nil(t, err)
+		assert.Equal(t, `Salt \F\ pepper# and * more!`, message.Segment("NTE").Component(3, 1))
+	})
+
+	t.Run("ParseMessage_MissingMSH", func(t *testing.T) {
+		_, err := ParseMessage("PID|1||MRN001")
+		assert.Equal(t, customerrors.NewInvalidInputError("message must begin with an MSH segment"), err)
+	})
+}
+
+func TestFromPID(t *testing.T) {
+	message, _ := ParseMessage(adtMessage("A04", pid))
+
+	details, err := FromPID(message.Segment("PID"))
+	assert.Nil(t, err)
+	assert.Equal(t, models.PatientRequest{
+		Name:               "Jane Ann Smith",
+		Address:            "1 main street, Richmond, VA 23220",
+		PhoneNumber:        "(804)495-5578",
+		ExternalIdentifier: "MRN001",
+		DateOfBirth:        time.Date(1990, 2, 1, 0, 0, 0, 0, time.UTC),
+		Identifiers:        []models.PatientIdentifier{{System: "SSA", Value: "123-45-6789"}},
+	}, details)
+}
+
+func TestFromDG1(t *testing.T) {
+	t.Run("FromDG1_Success", func(t *testing.T) {
+		message, _ := ParseMessage(adtMessage("A01", "DG1|1||44054006^Type 2 diabetes^SCT|diet controlled|20240301|A"))
+
+		details, err := FromDG1(message.Segment("DG1"))
+		assert.Nil(t, err)
+		assert.Equal(t, models.DiagnosedConditionRequest{
+			Name:        "Type 2 diabetes",
+			Code:        "44054006",
+			CodeSystem:  models.CodeSystemSNOMEDCT,
+			Description: "diet controlled",
+			Date:        time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		}, details)
+	})
+
+	t.Run("FromDG1_UnsupportedCodingSystem", func(t *testing.T) {
+		message, _ := ParseMessage(adtMessage("A01", "DG1|1||8867-4^Heart rate^LN"))
+
+		_, err := FromDG1(message.Segment("DG1"))
+		assert.Equal(t, customerrors.NewInvalidInputError(`DG1-3 "LN" is not a supported coding system, expected I10 or SCT`), err)
+	})
+}
+
+func TestHandleMessage(t *testing.T) {
+	dateOfBirth := time.Date(1990, 2, 1, 0, 0, 0, 0, time.UTC)
+	diagnosed := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("HandleMessage_RegistersNewPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockConditionSvc, _, service := getMocksAndService()
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{ExternalIdentifier: "MRN001"}).Return([]models.Patient{}, nil)
+		mockPatientSvc.On("CreatePatient", mock.Anything, "Jane Ann Smith", "1 main street, Richmond, VA 23220", "(804)495-5578", dateOfBirth, "MRN001", []models.PatientIdentifier{{System: "SSA", Value: "123-45-6789"}}).Return(models.Patient{Id: 3}, nil)
+		mockConditionSvc.On("AddDiagnosedConditionToPatient", mock.Anything, 3, "Type 2 diabetes", "E11.9", models.CodeSystemICD10CM, "", diagnosed, (*time.Time)(nil), 0).Return(models.DiagnosedCondition{Id: 4, Code: "E11.9", CodeSystem: models.CodeSystemICD10CM}, nil)
+
+		ack := service.HandleMessage(context.Background(), adtMessage("A04", pid,
+			"DG1|1||E11.9^Type 2 diabetes^I10||20240301",
+			"DG1|2||E119^Type 2 diabetes^I10||20240301"))
+
+		msh := segment(t, ack, "MSH")
+		assert.Equal(t, "MCG", msh.Field(3))
+		assert.Equal(t, "REGADT", msh.Field(5))
+		assert.Equal(t, "ACK", msh.Component(9, 1))
+		assert.Equal(t, "A04", msh.Component(9, 2))
+		assert.Equal(t, "AA", segment(t, ack, "MSA").Field(1))
+		assert.Equal(t, "MSG00001", segment(t, ack, "MSA").Field(2))
+
+		mockRepo.AssertExpectations(t)
+		mockConditionSvc.AssertNumberOfCalls(t, "AddDiagnosedConditionToPatient", 1)
+	})
+
+	t.Run("HandleMessage_UpdateKeepsFieldsWhichWereNotSent", func(t *testing.T) {
+		_, mockPatientSvc, mockConditionSvc, _, service := getMocksAndService()
+		existing := models.Patient{
+			Id:                  3,
+			Name:                "Jane Smith",
+			Address:             "1 main street",
+			PhoneNumber:         "8044955578",
+			DateOfBirth:         dateOfBirth,
+			ExternalIdentifier:  "MRN001",
+			Identifiers:         []models.PatientIdentifier{{System: models.IdentifierSystemExternal, Value: "MRN001"}, {System: "SSA", Value: "123-45-6789"}},
+			DiagnosedConditions: []models.DiagnosedCondition{{Id: 4, Code: "E11.9", CodeSystem: models.CodeSystemICD10CM}},
+		}
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{ExternalIdentifier: "MRN001"}).Return([]models.Patient{existing}, nil)
+		mockPatientSvc.On("UpdatePatient", mock.Anything, 3, "Jane Ann Smith", "1 main street", "8044955578", dateOfBirth, "MRN001", []models.PatientIdentifier{{System: "SSA", Value: "123-45-6789"}, {System: "PAYER", Value: "M42"}}).Return(models.Patient{Id: 3}, nil)
+
+		ack := service.HandleMessage(context.Background(), adtMessage("A08", `PID|1||MRN001~M42^^^PAYER||Smith^Jane^Ann||""`, "DG1|1||E11.9^^I10"))
+		assert.Equal(t, "AA", segment(t, ack, "MSA").Field(1))
+
+		mockConditionSvc.AssertNotCalled(t, "AddDiagnosedConditionToPatient", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("HandleMessage_MergesPriorPatient", func(t *testing.T) {
+		_, mockPatientSvc, _, mockMergeSvc, service := getMocksAndService()
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{ExternalIdentifier: "MRN002"}).Return([]models.Patient{{Id: 5, ExternalIdentifier: "MRN002"}}, nil)
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{ExternalIdentifier: "MRN001"}).Return([]models.Patient{{Id: 3, ExternalIdentifier: "MRN001", Name: "Jane Smith", PhoneNumber: "8044955578", DateOfBirth: dateOfBirth}}, nil)
+		mockPatientSvc.On("UpdatePatient", mock.Anything, 3, "Jane Smith", "", "8044955578", dateOfBirth, "MRN001", []models.PatientIdentifier{}).Return(models.Patient{Id: 3}, nil)
+		mockMergeSvc.On("MergePatients", mock.Anything, 3, 5).Return(models.PatientLink{Id: 1, PatientId: 3, MergedPatientId: 5}, nil)
+
+		ack := service.HandleMessage(context.Background(), adtMessage("A40", "PID|1||MRN001", "MRG|MRN002^^^HOSP^MR"))
+		assert.Equal(t, "AA", segment(t, ack, "MSA").Field(1))
+
+		mockMergeSvc.AssertExpectations(t)
+		mockPatientSvc.AssertExpectations(t)
+	})
+
+	t.Run("HandleMessage_MergeFails", func(t *testing.T) {
+		_, mockPatientSvc, _, mockMergeSvc, service := getMocksAndService()
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{ExternalIdentifier: "MRN002"}).Return([]models.Patient{{Id: 5, ExternalIdentifier: "MRN002"}}, nil)
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{ExternalIdentifier: "MRN001"}).Return([]models.Patient{{Id: 3, ExternalIdentifier: "MRN001", Name: "Jane Smith", PhoneNumber: "8044955578", DateOfBirth: dateOfBirth}}, nil)
+		mockPatientSvc.On("UpdatePatient", mock.Anything, 3, "Jane Smith", "", "8044955578", dateOfBirth, "MRN001", []models.PatientIdentifier{}).Return(models.Patient{Id: 3}, nil)
+		mockMergeSvc.On("MergePatients", mock.Anything, 3, 5).Return(models.PatientLink{}, errors.New("store unavailable"))
+
+		ack := service.HandleMessage(context.Background(), adtMessage("A40", "PID|1||MRN001", "MRG|MRN002^^^HOSP^MR"))
+		assert.Equal(t, "AE", segment(t, ack, "MSA").Field(1))
+		assert.Equal(t, "207", segment(t, ack, "ERR").Component(3, 1))
+	})
+
+	t.Run("HandleMessage_MergeOfUnknownPatient", func(t *testing.T) {
+		_, mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{ExternalIdentifier: "MRN002"}).Return([]models.Patient{}, nil)
+
+		ack := service.HandleMessage(context.Background(), adtMessage("A40", "PID|1||MRN001", "MRG|MRN002"))
+		assert.Equal(t, "AE", segment(t, ack, "MSA").Field(1))
+		assert.Equal(t, "204", segment(t, ack, "ERR").Component(3, 1))
+		assert.Equal(t, `prior patient "MRN002" was not found`, segment(t, ack, "ERR").Component(8, 1))
+	})
+
+	t.Run("HandleMessage_MissingRequiredField", func(t *testing.T) {
+		_, mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{ExternalIdentifier: "MRN001"}).Return([]models.Patient{}, nil)
+
+		ack := service.HandleMessage(context.Background(), adtMessage("A04", "PID|1||MRN001||Smith^Jane||19900201"))
+		assert.Equal(t, "AE", segment(t, ack, "MSA").Field(1))
+		assert.Equal(t, "PID-13 phone number is required, with at least 10 characters", segment(t, ack, "ERR").Component(8, 1))
+
+		mockPatientSvc.AssertNotCalled(t, "CreatePatient", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("HandleMessage_InternalErrorIsNotDescribed", func(t *testing.T) {
+		_, mockPatientSvc, _, _, service := getMocksAndService()
+		mockPatientSvc.On("SearchPatients", mock.Anything, models.PatientSearch{ExternalIdentifier: "MRN001"}).Return([]models.Patient{}, fmt.Errorf("db error"))
+
+		ack := service.HandleMessage(context.Background(), adtMessage("A04", pid))
+		assert.Equal(t, "AE", segment(t, ack, "MSA").Field(1))
+		assert.Equal(t, "207", segment(t, ack, "ERR").Component(3, 1))
+		assert.Equal(t, "internal error", segment(t, ack, "ERR").Component(8, 1))
+	})
+
+	t.Run("HandleMessage_UnsupportedEvent", func(t *testing.T) {
+		mockRepo, _, _, _, service := getMocksAndService()
+
+		ack := service.HandleMessage(context.Background(), adtMessage("A03", pid))
+		assert.Equal(t, "AR", segment(t, ack, "MSA").Field(1))
+		assert.Equal(t, "201", segment(t, ack, "ERR").Component(3, 1))
+
+		mockRepo.AssertNotCalled(t, "RunInTransaction", mock.Anything)
+	})
+
+	t.Run("HandleMessage_UnsupportedMessageType", func(t *testing.T) {
+		_, _, _, _, service := getMocksAndService()
+
+		ack := service.HandleMessage(context.Background(), strings.Replace(adtMessage("A01"), "ADT^A01^ADT_A01", "ORU^R01^ORU_R01", 1))
+		assert.Equal(t, "AR", segment(t, ack, "MSA").Field(1))
+		assert.Equal(t, "200", segment(t, ack, "ERR").Component(3, 1))
+	})
+
+	t.Run("HandleMessage_Unparseable", func(t *testing.T) {
+		_, _, _, _, service := getMocksAndService()
+
+		ack := service.HandleMessage(context.Background(), "not a message")
+		assert.Equal(t, "AR", segment(t, ack, "MSA").Field(1))
+		assert.Equal(t, "100", segment(t, ack, "ERR").Component(3, 1))
+	})
+}
@@ -0,0 +1,36 @@
+package imports
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ImportRepo interface {
+	InsertImportJob(ctx context.Context, job models.ImportJob) (int, error)
+	UpdateImportJob(ctx context.Context, job models.ImportJob) error
+	GetImportJob(ctx context.Context, id int) (models.ImportJob, error)
+	GetPatientIdsWithIdentifier(ctx context.Context, identifier models.PatientIdentifier) ([]int, error)
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type PatientService interface {
+	CreatePatient(ctx context.Context, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error)
+}
+
+type DiagnosedConditionService interface {
+	AddDiagnosedConditionToPatient(ctx context.Context, patientId int, name string, code string, codeSystem string, description string, date time.Time, onsetDate *time.Time, encounterId int) (models.DiagnosedCondition, error)
+}
+
+type CodeService interface {
+	LookupCoding(ctx context.Context, system string, code string) (models.Code, error)
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
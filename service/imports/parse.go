@@ -0,0 +1,263 @@
+package imports
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+const maxLineSize = 1 << 20
+
+var csvColumns = []string{
+	"externalIdentifier", "name", "address", "phoneNumber", "dateOfBirth",
+	"conditionCode", "conditionCodeSystem", "conditionName", "conditionDescription", "conditionDate",
+}
+
+var requiredCsvColumns = []string{"externalIdentifier", "name", "phoneNumber", "dateOfBirth"}
+
+var conditionCsvColumns = []string{"conditionCode", "conditionCodeSystem", "conditionName", "conditionDescription", "conditionDate"}
+
+// a patient to import along with their conditions, and the rows they were read from.  Records which could not be read
+// carry the reasons why in errors
+type record struct {
+	row        int
+	rows       int
+	patient    models.PatientRequest
+	conditions []condition
+	errors     []models.ImportRowError
+}
+
+type condition struct {
+	row     int
+	details models.DiagnosedConditionRequest
+}
+
+func (r *record) fail(row int, messages ...string) {
+	for _, message := range messages {
+		r.errors = append(r.errors, models.ImportRowError{Row: row, ExternalIdentifier: r.patient.ExternalIdentifier, Message: message})
+	}
+}
+
+// each row of a csv file has a patient and optionally one of their conditions.  A patient with several conditions is
+// given on several rows with the same externalIdentifier, and the patient columns of all but the first are ignored.
+// Problems with the file as a whole, such as unknown columns, are returned as an error, while problems with a row are
+// recorded against the row so the rest can still be imported
+func parseCSV(data []byte) ([]*record, int, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, 0, customerrors.NewInvalidInputError("csv header is missing")
+	}
+	if err != nil {
+		return nil, 0, customerrors.NewInvalidInputError(fmt.Sprintf("csv header could not be read: %v", err))
+	}
+
+	columns := make(map[string]int)
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if !slices.Contains(csvColumns, column) {
+			return nil, 0, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a supported column, expected any of %v", column, strings.Join(csvColumns, ", ")))
+		}
+		if _, exists := columns[column]; exists {
+			return nil, 0, customerrors.NewInvalidInputError(fmt.Sprintf("column %q appears more than once", column))
+		}
+		columns[column] = i
+	}
+	for _, column := range requiredCsvColumns {
+		if _, exists := columns[column]; !exists {
+			return nil, 0, customerrors.NewInvalidInputError(fmt.Sprintf("the %q column is required", column))
+		}
+	}
+
+	var records []*record
+	byIdentifier := make(map[string]*record)
+	total := 0
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		total++
+		if err != nil {
+			var parseError *csv.ParseError
+			if !errors.As(err, &parseError) {
+				return nil, 0, err
+			}
+			invalid := &record{row: parseError.StartLine, rows: 1}
+			invalid.fail(parseError.StartLine, fmt.Sprintf("row could not be read: %v", parseError.Err))
+			records = append(records, invalid)
+			continue
+		}
+		row, _ := reader.FieldPos(0)
+		get := func(column string) string {
+			if i, exists := columns[column]; exists {
+				return strings.TrimSpace(values[i])
+			}
+			return ""
+		}
+
+		identifier := get("externalIdentifier")
+		current, exists := byIdentifier[identifier]
+		if !exists || identifier == "" {
+			current = &record{row: row}
+			var problems []string
+			current.patient, problems = parsePatient(identifier, get("name"), get("address"), get("phoneNumber"), get("dateOfBirth"))
+			current.fail(row, problems...)
+			byIdentifier[identifier] = current
+			records = append(records, current)
+		}
+		current.rows++
+
+		hasCondition := slices.ContainsFunc(conditionCsvColumns, func(column string) bool { return get(column) != "" })
+		if exists && identifier != "" && !hasCondition {
+			current.fail(row, fmt.Sprintf("externalIdentifier %q appears on an earlier row, so this row must add a condition", identifier))
+			continue
+		}
+		if hasCondition {
+			details, problems := parseCondition(get("conditionCode"), get("conditionCodeSystem"), get("conditionName"), get("conditionDescription"), get("conditionDate"))
+			current.fail(row, problems...)
+			current.conditions = append(current.conditions, condition{row: row, details: details})
+		}
+	}
+	return records, total, nil
+}
+
+type ndjsonPatient struct {
+	ExternalIdentifier  string            `json:"externalIdentifier"`
+	Name                string            `json:"name"`
+	Address             string            `json:"address"`
+	PhoneNumber         string            `json:"phoneNumber"`
+	DateOfBirth         string            `json:"dateOfBirth"`
+	DiagnosedConditions []ndjsonCondition `json:"diagnosedConditions"`
+}
+
+type ndjsonCondition struct {
+	Code        string `json:"code"`
+	CodeSystem  string `json:"codeSystem"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Date        string `json:"date"`
+}
+
+// each line of an ndjson file is a patient, with their conditions in diagnosedConditions.  Blank lines are skipped
+func parseNDJSON(data []byte) ([]*record, int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var records []*record
+	identifiers := make(map[string]bool)
+	row := 0
+	for scanner.Scan() {
+		row++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		current := &record{row: row, rows: 1}
+		records = append(records, current)
+
+		var patient ndjsonPatient
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&patient)
+		if err == nil && decoder.More() {
+			err = errors.New("unexpected data after the patient")
+		}
+		if err != nil {
+			current.fail(row, fmt.Sprintf("line is not a valid patient: %v", err))
+			continue
+		}
+
+		var problems []string
+		current.patient, problems = parsePatient(strings.TrimSpace(patient.ExternalIdentifier), strings.TrimSpace(patient.Name),
+			strings.TrimSpace(patient.Address), strings.TrimSpace(patient.PhoneNumber), strings.TrimSpace(patient.DateOfBirth))
+		current.fail(row, problems...)
+		if identifier := current.patient.ExternalIdentifier; identifier != "" {
+			if identifiers[identifier] {
+				current.fail(row, fmt.Sprintf("externalIdentifier %q appears on an earlier row", identifier))
+			}
+			identifiers[identifier] = true
+		}
+		for _, c := range patient.DiagnosedConditions {
+			details, problems := parseCondition(strings.TrimSpace(c.Code), strings.TrimSpace(c.CodeSystem), strings.TrimSpace(c.Name),
+				strings.TrimSpace(c.Description), strings.TrimSpace(c.Date))
+			current.fail(row, problems...)
+			current.conditions = append(current.conditions, condition{row: row, details: details})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, 0, customerrors.NewInvalidInputError(fmt.Sprintf("line %v is longer than %v bytes", row+1, maxLineSize))
+		}
+		return nil, 0, err
+	}
+	return records, len(records), nil
+}
+
+// checks the patient's details as the api does, other than whether they are already recorded
+func parsePatient(externalIdentifier string, name string, address string, phoneNumber string, dateOfBirth string) (models.PatientRequest, []string) {
+	patient := models.PatientRequest{
+		ExternalIdentifier: externalIdentifier,
+		Name:               name,
+		Address:            address,
+		PhoneNumber:        phoneNumber,
+	}
+	var problems []string
+	if len(externalIdentifier) < 3 {
+		problems = append(problems, "externalIdentifier must be at least 3 characters")
+	}
+	if len(name) < 3 {
+		problems = append(problems, "name must be at least 3 characters")
+	}
+	if len(phoneNumber) < 10 {
+		problems = append(problems, "phoneNumber must be at least 10 characters")
+	}
+	parsed, err := parseDate("dateOfBirth", dateOfBirth)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	patient.DateOfBirth = parsed
+	return patient, problems
+}
+
+// checks the condition's details as the api does.  Whether its code is valid is checked when it is imported
+func parseCondition(code string, codeSystem string, name string, description string, date string) (models.DiagnosedConditionRequest, []string) {
+	details := models.DiagnosedConditionRequest{
+		Code:        code,
+		CodeSystem:  codeSystem,
+		Name:        name,
+		Description: description,
+	}
+	var problems []string
+	if len(code) < 3 {
+		problems = append(problems, "condition code must be at least 3 characters")
+	}
+	parsed, err := parseDate("condition date", date)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	details.Date = parsed
+	return details, problems
+}
+
+// dates are written as YYYY-MM-DD, or as a full RFC 3339 timestamp as the api accepts
+func parseDate(name string, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%v is required", name)
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%v %q is not a valid date, expected the format YYYY-MM-DD", name, value)
+}
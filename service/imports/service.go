@@ -0,0 +1,210 @@
+package imports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type ImportService struct {
+	repo         ImportRepo
+	patientSvc   PatientService
+	conditionSvc DiagnosedConditionService
+	codeSvc      CodeService
+	tracer       Tracer
+}
+
+func NewImportService(repo ImportRepo, patientSvc PatientService, conditionSvc DiagnosedConditionService, codeSvc CodeService, tracer Tracer) ImportService {
+	return ImportService{
+		repo:         repo,
+		patientSvc:   patientSvc,
+		conditionSvc: conditionSvc,
+		codeSvc:      codeSvc,
+		tracer:       tracer,
+	}
+}
+
+// reads the data and starts importing it in the background, returning the job to poll for its progress.  Data which
+// cannot be read as a whole is rejected, while rows which cannot be imported are reported on the job
+func (s ImportService) StartImport(ctx context.Context, format string, dryRun bool, data []byte) (models.ImportJob, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "StartImport")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.String("format", format),
+		attribute.Bool("dryRun", dryRun),
+		attribute.Int("size", len(data)))
+
+	var records []*record
+	var total int
+	var err error
+	switch format {
+	case models.ImportFormatCSV:
+		records, total, err = parseCSV(data)
+	case models.ImportFormatNDJSON:
+		records, total, err = parseNDJSON(data)
+	default:
+		err = customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a supported format, expected csv or ndjson", format))
+	}
+	if err != nil {
+		return models.ImportJob{}, s.tracer.RecordError(ctx, err)
+	}
+	if total == 0 {
+		return models.ImportJob{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("there are no rows to import"))
+	}
+
+	job := models.ImportJob{
+		Format:    format,
+		DryRun:    dryRun,
+		Status:    models.ImportStatusRunning,
+		TotalRows: total,
+		Errors:    []models.ImportRowError{},
+		CreatedAt: time.Now(),
+	}
+	id, err := s.repo.InsertImportJob(ctx, job)
+	if err != nil {
+		return models.ImportJob{}, s.tracer.RecordError(ctx, fmt.Errorf("error inserting import job %w", err))
+	}
+	job.Id = id
+
+	go s.run(context.WithoutCancel(ctx), job, records)
+	return job, nil
+}
+
+func (s ImportService) GetImport(ctx context.Context, id int) (models.ImportJob, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetImport")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("id", id))
+
+	job, err := s.repo.GetImportJob(ctx, id)
+	if err != nil {
+		return models.ImportJob{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting import job %w", err))
+	}
+	return job, nil
+}
+
+// imports each record in turn, saving the job's progress after each so it can be polled
+func (s ImportService) run(ctx context.Context, job models.ImportJob, records []*record) models.ImportJob {
+	ctx, span := s.tracer.NewSpan(ctx, "RunImport")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("id", job.Id),
+		attribute.Int("records", len(records)))
+
+	for _, record := range records {
+		rowErrors := s.importRecord(ctx, job.DryRun, record)
+		if len(rowErrors) == 0 {
+			job.ImportedPatients++
+			job.ImportedConditions += len(record.conditions)
+		}
+		job.Errors = append(job.Errors, rowErrors...)
+		job.ProcessedRows += record.rows
+		s.saveProgress(ctx, job)
+	}
+
+	completedAt := time.Now()
+	job.Status = models.ImportStatusCompleted
+	job.CompletedAt = &completedAt
+	s.saveProgress(ctx, job)
+	return job
+}
+
+// a failure to save progress is only recorded, as the import carries on regardless and a later save may succeed
+func (s ImportService) saveProgress(ctx context.Context, job models.ImportJob) {
+	err := s.repo.UpdateImportJob(ctx, job)
+	if err != nil {
+		s.tracer.RecordError(ctx, fmt.Errorf("error updating import job %w", err))
+	}
+}
+
+// creates the record's patient along with their conditions, or none of them if any cannot be.  In a dry run the record
+// is only checked, and the errors which importing it would have are returned
+func (s ImportService) importRecord(ctx context.Context, dryRun bool, record *record) []models.ImportRowError {
+	if len(record.errors) > 0 {
+		return record.errors
+	}
+	fail := func(row int, err error) []models.ImportRowError {
+		return []models.ImportRowError{{Row: row, ExternalIdentifier: record.patient.ExternalIdentifier, Message: s.rowMessage(ctx, err)}}
+	}
+
+	//the record is checked in the same transaction as its patient is created, so the patient cannot be created by
+	//anyone else in between.  A dry run checks it the same way, then stops before creating anything
+	var rowErrors []models.ImportRowError
+	row := record.row
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		ids, err := s.repo.GetPatientIdsWithIdentifier(ctx, models.PatientIdentifier{System: models.IdentifierSystemExternal, Value: record.patient.ExternalIdentifier})
+		if err != nil {
+			return fmt.Errorf("error getting patients with externalIdentifier %w", err)
+		}
+		if len(ids) > 0 {
+			return customerrors.NewAlreadyExistsError("patient with matching externalIdentifier already exists")
+		}
+		for _, condition := range record.conditions {
+			err := s.validateCode(ctx, condition.details)
+			if err != nil {
+				rowErrors = append(rowErrors, fail(condition.row, err)...)
+			}
+		}
+		if len(rowErrors) > 0 || dryRun {
+			return nil
+		}
+
+		patient, err := s.patientSvc.CreatePatient(ctx, record.patient.Name, record.patient.Address, record.patient.PhoneNumber,
+			record.patient.DateOfBirth, record.patient.ExternalIdentifier, nil)
+		if err != nil {
+			return err
+		}
+		for _, condition := range record.conditions {
+			details := condition.details
+			_, err := s.conditionSvc.AddDiagnosedConditionToPatient(ctx, patient.Id, details.Name, details.Code, details.CodeSystem,
+				details.Description, details.Date, nil, 0)
+			if err != nil {
+				row = condition.row
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fail(row, err)
+	}
+	return rowErrors
+}
+
+// checks the condition's code as adding the condition would, so that a dry run finds the same problems
+func (s ImportService) validateCode(ctx context.Context, details models.DiagnosedConditionRequest) error {
+	codeSystem := details.CodeSystem
+	if codeSystem == "" {
+		codeSystem = models.CodeSystemICD10CM
+	}
+	code, err := s.codeSvc.LookupCoding(ctx, codeSystem, details.Code)
+	if err != nil {
+		return err
+	}
+	if details.Name == "" && code.Name == "" {
+		return customerrors.NewInvalidInputError(fmt.Sprintf("name is required as %v code %v has no known name", codeSystem, code.Code))
+	}
+	return nil
+}
+
+// the reason a row could not be imported.  Unexpected errors are recorded rather than reported, as they are on the api
+func (s ImportService) rowMessage(ctx context.Context, err error) string {
+	var inputError customerrors.InvalidInputError
+	if errors.As(err, &inputError) {
+		return inputError.Error()
+	}
+	var alreadyExistsError customerrors.AlreadyExistsError
+	if errors.As(err, &alreadyExistsError) {
+		return alreadyExistsError.Error()
+	}
+	var notFoundError customerrors.NotFoundError
+	if errors.As(err, &notFoundError) {
+		return notFoundError.Error()
+	}
+	s.tracer.RecordError(ctx, err)
+	return "internal error"
+}
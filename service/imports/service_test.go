@@ -0,0 +1,298 @@
+package imports
+
+import (
+	"context"
+	"errors"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockImportRepo struct {
+	mock.Mock
+}
+
+func (m *MockImportRepo) InsertImportJob(ctx context.Context, job models.ImportJob) (int, error) {
+	args := m.Called(ctx, job)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockImportRepo) UpdateImportJob(ctx context.Context, job models.ImportJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockImportRepo) GetImportJob(ctx context.Context, id int) (models.ImportJob, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.ImportJob), args.Error(1)
+}
+
+func (m *MockImportRepo) GetPatientIdsWithIdentifier(ctx context.Context, identifier models.PatientIdentifier) ([]int, error) {
+	args := m.Called(ctx, identifier)
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockImportRepo) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.Called(ctx)
+	return fn(ctx)
+}
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) CreatePatient(ctx context.Context, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error) {
+	args := m.Called(ctx, name, address, phoneNumber, dateOfBirth, externalIdentifier, identifiers)
+	return args.Get(0).(models.Patient), args.Error(1)
+}
+
+type MockDiagnosedConditionService struct {
+	mock.Mock
+}
+
+func (m *MockDiagnosedConditionService) AddDiagnosedConditionToPatient(ctx context.Context, patientId int, name string, code string, codeSystem string, description string, date time.Time, onsetDate *time.Time, encounterId int) (models.DiagnosedCondition, error) {
+	args := m.Called(ctx, patientId, name, code, codeSystem, description, date, onsetDate, encounterId)
+	return args.Get(0).(models.DiagnosedCondition), args.Error(1)
+}
+
+type MockCodeService struct {
+	mock.Mock
+}
+
+func (m *MockCodeService) LookupCoding(ctx context.Context, system string, code string) (models.Code, error) {
+	args := m.Called(ctx, system, code)
+	return args.Get(0).(models.Code), args.Error(1)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService() (*MockImportRepo, *MockPatientService, *MockDiagnosedConditionService, *MockCodeService, ImportService) {
+	mockRepo := new(MockImportRepo)
+	mockRepo.On("RunInTransaction", mock.Anything)
+	mockPatientSvc := new(MockPatientService)
+	mockConditionSvc := new(MockDiagnosedConditionService)
+	mockCodeSvc := new(MockCodeService)
+	service := NewImportService(mockRepo, mockPatientSvc, mockConditionSvc, mockCodeSvc, new(MockTracer))
+	return mockRepo, mockPatientSvc, mockConditionSvc, mockCodeSvc, service
+}
+
+var dateOfBirth = time.Date(1980, 7, 4, 0, 0, 0, 0, time.UTC)
+var diagnosedOn = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func TestParseCSV(t *testing.T) {
+	t.Run("ParseCSV_GroupsConditionsOfTheSamePatient", func(t *testing.T) {
+		records, total, err := parseCSV([]byte("externalIdentifier,name,phoneNumber,dateOfBirth,conditionCode,conditionDate\n" +
+			"MRN001,Jane Smith,8045550111,1980-07-04,E11.9,2024-03-01\n" +
+			"MRN002,John Smith,8045550112,1981-01-02,,\n" +
+			"MRN001,,,,I10,2024-03-01\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, 2, len(records))
+
+		assert.Equal(t, 2, records[0].row)
+		assert.Equal(t, 2, records[0].rows)
+		assert.Equal(t, models.PatientRequest{Name: "Jane Smith", PhoneNumber: "8045550111", ExternalIdentifier: "MRN001", DateOfBirth: dateOfBirth}, records[0].patient)
+		assert.Equal(t, []condition{
+			{row: 2, details: models.DiagnosedConditionRequest{Code: "E11.9", Date: diagnosedOn}},
+			{row: 4, details: models.DiagnosedConditionRequest{Code: "I10", Date: diagnosedOn}},
+		}, records[0].conditions)
+		assert.Nil(t, records[0].errors)
+
+		assert.Equal(t, 3, records[1].row)
+		assert.Nil(t, records[1].conditions)
+	})
+
+	t.Run("ParseCSV_RowErrors", func(t *testing.T) {
+		records, total, err := parseCSV([]byte("externalIdentifier,name,phoneNumber,dateOfBirth,conditionCode,conditionDate\n" +
+			"MRN001,Jo,8045550111,07/04/1980,E11.9,\n" +
+			"MRN001,Jane Smith,8045550111,1980-07-04,,\n" +
+			"MRN003,Jane Smith,8045550111\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, 2, len(records))
+		assert.Equal(t, []models.ImportRowError{
+			{Row: 2, ExternalIdentifier: "MRN001", Message: "name must be at least 3 characters"},
+			{Row: 2, ExternalIdentifier: "MRN001", Message: `dateOfBirth "07/04/1980" is not a valid date, expected the format YYYY-MM-DD`},
+			{Row: 2, ExternalIdentifier: "MRN001", Message: "condition date is required"},
+			{Row: 3, ExternalIdentifier: "MRN001", Message: `externalIdentifier "MRN001" appears on an earlier row, so this row must add a condition`},
+		}, records[0].errors)
+		assert.Equal(t, []models.ImportRowError{
+			{Row: 4, Message: "row could not be read: wrong number of fields"},
+		}, records[1].errors)
+	})
+
+	t.Run("ParseCSV_UnsupportedColumn", func(t *testing.T) {
+		_, _, err := parseCSV([]byte("externalIdentifier,name,phoneNumber,dateOfBirth,ssn\n"))
+		assert.Equal(t, customerrors.NewInvalidInputError(`"ssn" is not a supported column, expected any of externalIdentifier, name, address, phoneNumber, dateOfBirth, conditionCode, conditionCodeSystem, conditionName, conditionDescription, conditionDate`), err)
+	})
+
+	t.Run("ParseCSV_MissingRequiredColumn", func(t *testing.T) {
+		_, _, err := parseCSV([]byte("externalIdentifier,name,dateOfBirth\n"))
+		assert.Equal(t, customerrors.NewInvalidInputError(`the "phoneNumber" column is required`), err)
+	})
+}
+
+func TestParseNDJSON(t *testing.T) {
+	t.Run("ParseNDJSON_Success", func(t *testing.T) {
+		records, total, err := parseNDJSON([]byte(`{"externalIdentifier":"MRN001","name":"Jane Smith","phoneNumber":"8045550111","dateOfBirth":"1980-07-04",` +
+			`"diagnosedConditions":[{"code":"44054006","codeSystem":"SNOMED-CT","date":"2024-03-01"}]}` + "\n\n" +
+			`{"externalIdentifier":"MRN002","name":"John Smith","phoneNumber":"8045550112","dateOfBirth":"1981-01-02T00:00:00Z"}` + "\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, total)
+		assert.Equal(t, 1, records[0].row)
+		assert.Equal(t, []condition{
+			{row: 1, details: models.DiagnosedConditionRequest{Code: "44054006", CodeSystem: models.CodeSystemSNOMEDCT, Date: diagnosedOn}},
+		}, records[0].conditions)
+		assert.Equal(t, 3, records[1].row)
+		assert.Equal(t, "MRN002", records[1].patient.ExternalIdentifier)
+		assert.Nil(t, records[1].errors)
+	})
+
+	t.Run("ParseNDJSON_RowErrors", func(t *testing.T) {
+		records, _, err := parseNDJSON([]byte(`{"externalIdentifier":"MRN001","name":"Jane Smith","phoneNumber":"8045550111","dateOfBirth":"1980-07-04"}` + "\n" +
+			`{"externalIdentifier":"MRN001","name":"Jane Smith","phoneNumber":"8045550111","dateOfBirth":"1980-07-04"}` + "\n" +
+			`{"externalIdentifier":"MRN002","ssn":"123-45-6789"}` + "\n" +
+			`not json` + "\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(records))
+		assert.Nil(t, records[0].errors)
+		assert.Equal(t, []models.ImportRowError{
+			{Row: 2, ExternalIdentifier: "MRN001", Message: `externalIdentifier "MRN001" appears on an earlier row`},
+		}, records[1].errors)
+		assert.Equal(t, `line is not a valid patient: json: unknown field "ssn"`, records[2].errors[0].Message)
+		assert.Equal(t, 4, records[3].errors[0].Row)
+	})
+}
+
+func TestStartImport(t *testing.T) {
+	t.Run("StartImport_Success", func(t *testing.T) {
+		mockRepo, _, _, _, service := getMocksAndService()
+		mockRepo.On("InsertImportJob", mock.Anything, mock.MatchedBy(func(job models.ImportJob) bool {
+			return job.Format == models.ImportFormatCSV && job.DryRun && job.Status == models.ImportStatusRunning && job.TotalRows == 1
+		})).Return(3, nil)
+		mockRepo.On("UpdateImportJob", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+		job, err := service.StartImport(context.Background(), models.ImportFormatCSV, true,
+			[]byte("externalIdentifier,name,phoneNumber,dateOfBirth\nMRN001,Jo,8045550111,1980-07-04\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, 3, job.Id)
+		assert.Equal(t, models.ImportStatusRunning, job.Status)
+		assert.Equal(t, []models.ImportRowError{}, job.Errors)
+	})
+
+	t.Run("StartImport_UnsupportedFormat", func(t *testing.T) {
+		_, _, _, _, service := getMocksAndService()
+		_, err := service.StartImport(context.Background(), "xml", false, []byte("<patients/>"))
+		assert.Equal(t, customerrors.NewInvalidInputError(`"xml" is not a supported format, expected csv or ndjson`), err)
+	})
+
+	t.Run("StartImport_NoRows", func(t *testing.T) {
+		_, _, _, _, service := getMocksAndService()
+		_, err := service.StartImport(context.Background(), models.ImportFormatCSV, false, []byte("externalIdentifier,name,phoneNumber,dateOfBirth\n"))
+		assert.Equal(t, customerrors.NewInvalidInputError("there are no rows to import"), err)
+	})
+}
+
+func TestRun(t *testing.T) {
+	patient := models.PatientRequest{Name: "Jane Smith", PhoneNumber: "8045550111", ExternalIdentifier: "MRN001", DateOfBirth: dateOfBirth}
+	mrn001 := models.PatientIdentifier{System: models.IdentifierSystemExternal, Value: "MRN001"}
+	conditions := []condition{
+		{row: 2, details: models.DiagnosedConditionRequest{Code: "E11.9", Date: diagnosedOn}},
+		{row: 3, details: models.DiagnosedConditionRequest{Code: "I10", Date: diagnosedOn}},
+	}
+
+	t.Run("Run_ImportsPatientsAndConditions", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockConditionSvc, mockCodeSvc, service := getMocksAndService()
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, mrn001).Return([]int{}, nil)
+		mockRepo.On("UpdateImportJob", mock.Anything, mock.Anything).Return(nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, mock.Anything).Return(models.Code{Code: "E11.9", Name: "Type 2 diabetes mellitus without complications"}, nil)
+		mockPatientSvc.On("CreatePatient", mock.Anything, "Jane Smith", "", "8045550111", dateOfBirth, "MRN001", []models.PatientIdentifier(nil)).Return(models.Patient{Id: 7}, nil)
+		mockConditionSvc.On("AddDiagnosedConditionToPatient", mock.Anything, 7, "", mock.Anything, "", "", diagnosedOn, (*time.Time)(nil), 0).Return(models.DiagnosedCondition{}, nil)
+
+		job := service.run(context.Background(), models.ImportJob{Id: 1, Status: models.ImportStatusRunning, TotalRows: 3, Errors: []models.ImportRowError{}},
+			[]*record{{row: 2, rows: 2, patient: patient, conditions: conditions}, {row: 4, rows: 1, errors: []models.ImportRowError{{Row: 4, Message: "name must be at least 3 characters"}}}})
+
+		assert.Equal(t, models.ImportStatusCompleted, job.Status)
+		assert.NotNil(t, job.CompletedAt)
+		assert.Equal(t, 3, job.ProcessedRows)
+		assert.Equal(t, 1, job.ImportedPatients)
+		assert.Equal(t, 2, job.ImportedConditions)
+		assert.Equal(t, []models.ImportRowError{{Row: 4, Message: "name must be at least 3 characters"}}, job.Errors)
+		mockConditionSvc.AssertNumberOfCalls(t, "AddDiagnosedConditionToPatient", 2)
+		mockRepo.AssertNumberOfCalls(t, "UpdateImportJob", 3)
+	})
+
+	t.Run("Run_DryRunImportsNothing", func(t *testing.T) {
+		mockRepo, mockPatientSvc, _, mockCodeSvc, service := getMocksAndService()
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, mrn001).Return([]int{}, nil)
+		mockRepo.On("UpdateImportJob", mock.Anything, mock.Anything).Return(nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, "E11.9").Return(models.Code{Code: "E11.9", Name: "Type 2 diabetes mellitus without complications"}, nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, models.CodeSystemICD10CM, "I10").Return(models.Code{}, customerrors.NewInvalidInputError("I10 is not a valid ICD-10-CM code"))
+
+		job := service.run(context.Background(), models.ImportJob{Id: 1, DryRun: true, Errors: []models.ImportRowError{}},
+			[]*record{{row: 2, rows: 2, patient: patient, conditions: conditions}})
+
+		assert.Equal(t, 0, job.ImportedPatients)
+		assert.Equal(t, []models.ImportRowError{{Row: 3, ExternalIdentifier: "MRN001", Message: "I10 is not a valid ICD-10-CM code"}}, job.Errors)
+		mockPatientSvc.AssertNotCalled(t, "CreatePatient")
+	})
+
+	t.Run("Run_DuplicatePatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, _, _, service := getMocksAndService()
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, mrn001).Return([]int{5}, nil)
+		mockRepo.On("UpdateImportJob", mock.Anything, mock.Anything).Return(nil)
+
+		job := service.run(context.Background(), models.ImportJob{Id: 1, Errors: []models.ImportRowError{}},
+			[]*record{{row: 2, rows: 1, patient: patient}})
+
+		assert.Equal(t, 0, job.ImportedPatients)
+		assert.Equal(t, []models.ImportRowError{{Row: 2, ExternalIdentifier: "MRN001", Message: "patient with matching externalIdentifier already exists"}}, job.Errors)
+		mockPatientSvc.AssertNotCalled(t, "CreatePatient")
+	})
+
+	t.Run("Run_ReportsTheRowOfAConditionWhichFails", func(t *testing.T) {
+		mockRepo, mockPatientSvc, mockConditionSvc, mockCodeSvc, service := getMocksAndService()
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, mrn001).Return([]int{}, nil)
+		mockRepo.On("UpdateImportJob", mock.Anything, mock.Anything).Return(nil)
+		mockCodeSvc.On("LookupCoding", mock.Anything, mock.Anything, mock.Anything).Return(models.Code{Name: "known"}, nil)
+		mockPatientSvc.On("CreatePatient", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Patient{Id: 7}, nil)
+		mockConditionSvc.On("AddDiagnosedConditionToPatient", mock.Anything, 7, "", "E11.9", "", "", diagnosedOn, (*time.Time)(nil), 0).Return(models.DiagnosedCondition{}, nil)
+		mockConditionSvc.On("AddDiagnosedConditionToPatient", mock.Anything, 7, "", "I10", "", "", diagnosedOn, (*time.Time)(nil), 0).Return(models.DiagnosedCondition{}, errors.New("store unavailable"))
+
+		job := service.run(context.Background(), models.ImportJob{Id: 1, Errors: []models.ImportRowError{}},
+			[]*record{{row: 2, rows: 2, patient: patient, conditions: conditions}})
+
+		assert.Equal(t, 0, job.ImportedPatients)
+		assert.Equal(t, 0, job.ImportedConditions)
+		assert.Equal(t, []models.ImportRowError{{Row: 3, ExternalIdentifier: "MRN001", Message: "internal error"}}, job.Errors)
+	})
+}
+
+func TestGetImport(t *testing.T) {
+	t.Run("GetImport_NotFound", func(t *testing.T) {
+		mockRepo, _, _, _, service := getMocksAndService()
+		mockRepo.On("GetImportJob", mock.Anything, 9).Return(models.ImportJob{}, customerrors.NewInvalidInputError("import not found"))
+
+		_, err := service.GetImport(context.Background(), 9)
+		assert.True(t, errors.As(err, new(customerrors.InvalidInputError)))
+	})
+}
@@ -0,0 +1,29 @@
+package medications
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MedicationRepo interface {
+	InsertMedication(ctx context.Context, medication models.Medication) (int, error)
+	GetMedication(ctx context.Context, medicationId int) (models.Medication, error)
+	UpdateMedication(ctx context.Context, medication models.Medication) error
+	DeleteMedication(ctx context.Context, medicationId int) error
+	RestoreMedication(ctx context.Context, patientId int, medicationId int) error
+	GetMedicationsByPatientId(ctx context.Context, patientId int) ([]models.Medication, error)
+	DeleteMedicationsByPatientId(ctx context.Context, patientId int) error
+}
+
+type PatientService interface {
+	ValidatePatientId(ctx context.Context, patientId int) error
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
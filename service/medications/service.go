@@ -0,0 +1,201 @@
+package medications
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type MedicationService struct {
+	repo       MedicationRepo
+	patientSvc PatientService
+	tracer     Tracer
+}
+
+func NewMedicationService(repo MedicationRepo, patientSvc PatientService, tracer Tracer) MedicationService {
+	return MedicationService{
+		repo:       repo,
+		patientSvc: patientSvc,
+		tracer:     tracer,
+	}
+}
+
+func (s MedicationService) AddMedicationToPatient(ctx context.Context, patientId int, details models.MedicationRequest) (models.Medication, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "AddMedicationToPatient")
+	defer span.End()
+	s.setDetailAttributes(ctx, details)
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.validateDetails(ctx, details)
+	if err != nil {
+		return models.Medication{}, err
+	}
+
+	err = s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return models.Medication{}, err
+	}
+
+	medication := models.Medication{
+		PatientId:         patientId,
+		MedicationRequest: details,
+	}
+
+	id, err := s.repo.InsertMedication(ctx, medication)
+	if err != nil {
+		return models.Medication{}, s.tracer.RecordError(ctx, fmt.Errorf("error inserting medication %w", err))
+	}
+
+	medication.Id = id
+	return medication, nil
+}
+
+func (s MedicationService) GetMedication(ctx context.Context, patientId int, medicationId int) (models.Medication, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetMedication")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("medicationId", medicationId))
+
+	return s.getPatientMedication(ctx, patientId, medicationId)
+}
+
+func (s MedicationService) GetPatientMedications(ctx context.Context, patientId int) ([]models.Medication, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetPatientMedications")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return nil, err
+	}
+
+	medications, err := s.repo.GetMedicationsByPatientId(ctx, patientId)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting medications for patient %w", err))
+	}
+
+	return medications, nil
+}
+
+func (s MedicationService) UpdateMedication(ctx context.Context, patientId int, medicationId int, details models.MedicationRequest) (models.Medication, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "UpdateMedication")
+	defer span.End()
+	s.setDetailAttributes(ctx, details)
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("medicationId", medicationId))
+
+	err := s.validateDetails(ctx, details)
+	if err != nil {
+		return models.Medication{}, err
+	}
+
+	medication, err := s.getPatientMedication(ctx, patientId, medicationId)
+	if err != nil {
+		return models.Medication{}, err
+	}
+
+	medication.MedicationRequest = details
+	err = s.repo.UpdateMedication(ctx, medication)
+	if err != nil {
+		return models.Medication{}, s.tracer.RecordError(ctx, fmt.Errorf("error updating medication %w", err))
+	}
+
+	return medication, nil
+}
+
+func (s MedicationService) DeleteMedication(ctx context.Context, patientId int, medicationId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "DeleteMedication")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("medicationId", medicationId))
+
+	_, err := s.getPatientMedication(ctx, patientId, medicationId)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteMedication(ctx, medicationId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error deleting medication %w", err))
+	}
+
+	return nil
+}
+
+func (s MedicationService) RestoreMedication(ctx context.Context, patientId int, medicationId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "RestoreMedication")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.Int("medicationId", medicationId))
+
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.RestoreMedication(ctx, patientId, medicationId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error restoring medication %w", err))
+	}
+
+	return nil
+}
+
+func (s MedicationService) DeletePatientMedications(ctx context.Context, patientId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "DeletePatientMedications")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.repo.DeleteMedicationsByPatientId(ctx, patientId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error deleting medications for patient %w", err))
+	}
+
+	return nil
+}
+
+// medications are addressed through their patient, so one belonging to another patient is treated as missing
+func (s MedicationService) getPatientMedication(ctx context.Context, patientId int, medicationId int) (models.Medication, error) {
+	medication, err := s.repo.GetMedication(ctx, medicationId)
+	if err != nil {
+		return models.Medication{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting medication %w", err))
+	}
+	if medication.PatientId != patientId {
+		return models.Medication{}, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("medication not found"))
+	}
+	return medication, nil
+}
+
+func (s MedicationService) validateDetails(ctx context.Context, details models.MedicationRequest) error {
+	if strings.TrimSpace(details.DrugName) == "" {
+		return s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("drug name is required"))
+	}
+	for _, c := range details.RxNormCode {
+		if c < '0' || c > '9' {
+			return s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%v is not a valid RxNorm code", details.RxNormCode)))
+		}
+	}
+	if details.EndDate != nil && details.EndDate.Before(details.StartDate) {
+		return s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("end date must not be before the start date"))
+	}
+	return nil
+}
+
+func (s MedicationService) setDetailAttributes(ctx context.Context, details models.MedicationRequest) {
+	s.tracer.SetAttributes(ctx,
+		attribute.String("drugName", details.DrugName),
+		attribute.String("rxNormCode", details.RxNormCode),
+		attribute.String("dose", details.Dose),
+		attribute.String("route", details.Route),
+		attribute.String("frequency", details.Frequency),
+		attribute.String("startDate", fmt.Sprintf("%v", details.StartDate)),
+		attribute.String("endDate", fmt.Sprintf("%v", details.EndDate)))
+}
@@ -0,0 +1,336 @@
+package medications
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockMedicationRepo struct {
+	mock.Mock
+}
+
+func (m *MockMedicationRepo) InsertMedication(ctx context.Context, medication models.Medication) (int, error) {
+	args := m.Called(ctx, medication)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockMedicationRepo) GetMedication(ctx context.Context, medicationId int) (models.Medication, error) {
+	args := m.Called(ctx, medicationId)
+	return args.Get(0).(models.Medication), args.Error(1)
+}
+
+func (m *MockMedicationRepo) UpdateMedication(ctx context.Context, medication models.Medication) error {
+	args := m.Called(ctx, medication)
+	return args.Error(0)
+}
+
+func (m *MockMedicationRepo) DeleteMedication(ctx context.Context, medicationId int) error {
+	args := m.Called(ctx, medicationId)
+	return args.Error(0)
+}
+
+func (m *MockMedicationRepo) RestoreMedication(ctx context.Context, patientId int, medicationId int) error {
+	args := m.Called(ctx, patientId, medicationId)
+	return args.Error(0)
+}
+
+func (m *MockMedicationRepo) GetMedicationsByPatientId(ctx context.Context, patientId int) ([]models.Medication, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).([]models.Medication), args.Error(1)
+}
+
+func (m *MockMedicationRepo) DeleteMedicationsByPatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) ValidatePatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService() (*MockMedicationRepo, *MockPatientService, MedicationService) {
+	mockRepo := new(MockMedicationRepo)
+	mockPatientSvc := new(MockPatientService)
+	service := NewMedicationService(mockRepo, mockPatientSvc, new(MockTracer))
+	return mockRepo, mockPatientSvc, service
+}
+
+func getDetails() models.MedicationRequest {
+	return models.MedicationRequest{
+		DrugName:   "Metformin",
+		RxNormCode: "860975",
+		Dose:       "500 mg",
+		Route:      "oral",
+		Frequency:  "twice daily",
+		StartDate:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestAddMedicationToPatient(t *testing.T) {
+	patientId := 1
+
+	t.Run("AddMedication_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := getDetails()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertMedication", mock.Anything, models.Medication{PatientId: patientId, MedicationRequest: details}).Return(4, nil)
+
+		medication, err := service.AddMedicationToPatient(context.Background(), patientId, details)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, medication.Id)
+		assert.Equal(t, patientId, medication.PatientId)
+		assert.Equal(t, details, medication.MedicationRequest)
+
+		mockRepo.AssertExpectations(t)
+		mockPatientSvc.AssertExpectations(t)
+	})
+
+	t.Run("AddMedication_InvalidPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.AddMedicationToPatient(context.Background(), patientId, getDetails())
+		assert.NotNil(t, err)
+		assert.Equal(t, "patient id not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertMedication", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddMedication_MissingDrugName", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := getDetails()
+		details.DrugName = "  "
+
+		_, err := service.AddMedicationToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "drug name is required", err.Error())
+
+		mockPatientSvc.AssertNotCalled(t, "ValidatePatientId", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "InsertMedication", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddMedication_InvalidRxNormCode", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.RxNormCode = "86A975"
+
+		_, err := service.AddMedicationToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "86A975 is not a valid RxNorm code", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertMedication", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddMedication_EndBeforeStart", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		endDate := details.StartDate.AddDate(0, 0, -1)
+		details.EndDate = &endDate
+
+		_, err := service.AddMedicationToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "end date must not be before the start date", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertMedication", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddMedication_RepoError", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertMedication", mock.Anything, mock.Anything).Return(0, fmt.Errorf("db error"))
+
+		_, err := service.AddMedicationToPatient(context.Background(), patientId, getDetails())
+		assert.NotNil(t, err)
+		assert.Equal(t, "error inserting medication db error", err.Error())
+	})
+}
+
+func TestGetMedication(t *testing.T) {
+	medication := models.Medication{Id: 3, PatientId: 1, MedicationRequest: getDetails()}
+
+	t.Run("GetMedication_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetMedication", mock.Anything, 3).Return(medication, nil)
+
+		result, err := service.GetMedication(context.Background(), 1, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, medication, result)
+	})
+
+	t.Run("GetMedication_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetMedication", mock.Anything, 3).Return(medication, nil)
+
+		_, err := service.GetMedication(context.Background(), 2, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "medication not found", err.Error())
+	})
+
+	t.Run("GetMedication_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetMedication", mock.Anything, 3).Return(models.Medication{}, fmt.Errorf("db error"))
+
+		_, err := service.GetMedication(context.Background(), 1, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting medication db error", err.Error())
+	})
+}
+
+func TestGetPatientMedications(t *testing.T) {
+	t.Run("GetPatientMedications_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		medications := []models.Medication{{Id: 1, PatientId: 1, MedicationRequest: getDetails()}}
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("GetMedicationsByPatientId", mock.Anything, 1).Return(medications, nil)
+
+		result, err := service.GetPatientMedications(context.Background(), 1)
+		assert.Nil(t, err)
+		assert.Equal(t, medications, result)
+	})
+
+	t.Run("GetPatientMedications_InvalidPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.GetPatientMedications(context.Background(), 1)
+		assert.NotNil(t, err)
+
+		mockRepo.AssertNotCalled(t, "GetMedicationsByPatientId", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUpdateMedication(t *testing.T) {
+	existing := models.Medication{Id: 3, PatientId: 1, MedicationRequest: getDetails()}
+
+	t.Run("UpdateMedication_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.Dose = "1000 mg"
+		mockRepo.On("GetMedication", mock.Anything, 3).Return(existing, nil)
+		mockRepo.On("UpdateMedication", mock.Anything, models.Medication{Id: 3, PatientId: 1, MedicationRequest: details}).Return(nil)
+
+		medication, err := service.UpdateMedication(context.Background(), 1, 3, details)
+		assert.Nil(t, err)
+		assert.Equal(t, "1000 mg", medication.Dose)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("UpdateMedication_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetMedication", mock.Anything, 3).Return(existing, nil)
+
+		_, err := service.UpdateMedication(context.Background(), 2, 3, getDetails())
+		assert.NotNil(t, err)
+		assert.Equal(t, "medication not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "UpdateMedication", mock.Anything, mock.Anything)
+	})
+
+	t.Run("UpdateMedication_InvalidDetails", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getDetails()
+		details.DrugName = ""
+
+		_, err := service.UpdateMedication(context.Background(), 1, 3, details)
+		assert.NotNil(t, err)
+
+		mockRepo.AssertNotCalled(t, "GetMedication", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeleteMedication(t *testing.T) {
+	existing := models.Medication{Id: 3, PatientId: 1, MedicationRequest: getDetails()}
+
+	t.Run("DeleteMedication_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetMedication", mock.Anything, 3).Return(existing, nil)
+		mockRepo.On("DeleteMedication", mock.Anything, 3).Return(nil)
+
+		err := service.DeleteMedication(context.Background(), 1, 3)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("DeleteMedication_OtherPatient", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetMedication", mock.Anything, 3).Return(existing, nil)
+
+		err := service.DeleteMedication(context.Background(), 2, 3)
+		assert.NotNil(t, err)
+
+		mockRepo.AssertNotCalled(t, "DeleteMedication", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRestoreMedication(t *testing.T) {
+	t.Run("RestoreMedication_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("RestoreMedication", mock.Anything, 1, 3).Return(nil)
+
+		err := service.RestoreMedication(context.Background(), 1, 3)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("RestoreMedication_RepoError", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("RestoreMedication", mock.Anything, 1, 3).Return(fmt.Errorf("db error"))
+
+		err := service.RestoreMedication(context.Background(), 1, 3)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error restoring medication db error", err.Error())
+	})
+}
+
+func TestDeletePatientMedications(t *testing.T) {
+	t.Run("DeletePatientMedications_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("DeleteMedicationsByPatientId", mock.Anything, 1).Return(nil)
+
+		err := service.DeletePatientMedications(context.Background(), 1)
+		assert.Nil(t, err)
+	})
+
+	t.Run("DeletePatientMedications_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("DeleteMedicationsByPatientId", mock.Anything, 1).Return(fmt.Errorf("db error"))
+
+		err := service.DeletePatientMedications(context.Background(), 1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error deleting medications for patient db error", err.Error())
+	})
+}
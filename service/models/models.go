@@ -34,6 +34,7 @@ type CreateAttatchmentRequest struct {
 	Description string         `formData:"descripiton" description:"description of this attatchment"`
 	Type        string         `formData:"type" description:"type of attatchment" minLength:"1"`
 	Data        multipart.File `formData:"data" description:"data associated with this attatchment"`
+	EncounterId int            `formData:"encounterId" description:"internal id of the encounter at which this attatchment was produced, if any"`
 	PatientId   int            `path:"patientId"`
 }
 
@@ -46,23 +47,46 @@ type DeleteByIdRequest struct {
 	Id int `path:"id"`
 }
 
+type RestoreByIdRequest struct {
+	Id int `path:"id"`
+}
+
+type GetByIdRequest struct {
+	Id int `path:"id"`
+}
+
 type PatientRequest struct {
 	Name               string    `json:"name" required:"true" minLength:"3" description:"name of the patient"`
 	Address            string    `json:"address"  description:"address of the patient"`
 	PhoneNumber        string    `json:"phoneNumber" required:"true" minLength:"10" description:"phone number of the patient"`
-	ExternalIdentifier string    `json:"externalIdentifier" required:"true" minLength:"3" description:"External identifier of the patient (for example - social security number)"`
+	ExternalIdentifier string    `json:"externalIdentifier,omitempty" minLength:"3" description:"External identifier of the patient (for example - social security number).  Kept for older clients, as the identifier in the external system"`
 	DateOfBirth        time.Time `json:"dateOfBirth" description:"date of birth of patient" required:"true"`
+	//nil when not sent, so that updates from older clients keep the patient's identifiers in other systems
+	Identifiers []PatientIdentifier `json:"identifiers" description:"identifiers of the patient, at most one of which may be in the external system.  Every patient needs at least one identifier, either here or as externalIdentifier"`
+}
+
+// the system of the identifier recorded as a patient's externalIdentifier, from before patients could have several
+const IdentifierSystemExternal = "external"
+
+// an identifier of the patient assigned by a system, such as a hospital's medical record numbers, social security
+// numbers or a partner's patient ids.  A value can only be held by one patient within a system
+type PatientIdentifier struct {
+	System string `json:"system" required:"true" minLength:"1" description:"namespace of the identifier, preferably a URI such as http://hl7.org/fhir/sid/us-ssn.  It cannot contain |"`
+	Value  string `json:"value" required:"true" minLength:"1" description:"the identifier within its system"`
 }
 
 type Patient struct {
 	Name                string               `json:"name" required:"true" minLength:"3" description:"name of the patient"`
 	Address             string               `json:"address"  description:"address of the patient"`
 	PhoneNumber         string               `json:"phoneNumber" required:"true" minLength:"10" description:"phone number of the patient"`
-	ExternalIdentifier  string               `json:"externalIdentifier" required:"true" minLength:"3" description:"External identifier of the patient (for example - social security number)"`
+	ExternalIdentifier  string               `json:"externalIdentifier" description:"External identifier of the patient (for example - social security number).  The value of the patient's identifier in the external system, if they have one"`
 	DateOfBirth         time.Time            `json:"dateOfBirth" description:"date of birth of patient" required:"true"`
+	Identifiers         []PatientIdentifier  `json:"identifiers" description:"identifiers of the patient, including the externalIdentifier"`
 	Id                  int                  `json:"id" description:"Internal id of the patient"`
 	DiagnosedConditions []DiagnosedCondition `json:"diagnosedConditions" description:"conditions with which the patient has been diagnosed"`
 	Attatchments        []Attatchment        `json:"attatchments" description:"attatchments for theph patient.  Could be any form of medical imaging or doctor's reports"`
+	Allergies           []Allergy            `json:"allergies" description:"allergies and intolerances of the patient"`
+	DeletedAt           time.Time            `json:"-"`
 }
 
 type CreateDiagnosedConditionRequest struct {
@@ -70,31 +94,501 @@ type CreateDiagnosedConditionRequest struct {
 	PatientId int `path:"patientId"`
 }
 
+type DiagnosedConditionRequest struct {
+	Name        string     `json:"name" description:"name of the condition, defaulting to the name of the code"`
+	Code        string     `json:"code" description:"ICD-10-CM code or SNOMED CT concept id to identify the condition" required:"true" minLength:"3"`
+	CodeSystem  string     `json:"codeSystem" description:"code system of the code, either ICD-10-CM (the default) or SNOMED-CT"`
+	Description string     `json:"description" description:"description of the condition"`
+	Date        time.Time  `json:"date" description:"date on which this condition was diagnosed" required:"true"`
+	OnsetDate   *time.Time `json:"onsetDate,omitempty" description:"date on which the condition began"`
+	EncounterId int        `json:"encounterId,omitempty" description:"internal id of the encounter at which the condition was diagnosed, if any"`
+}
+
+type UpdateDiagnosedConditionRequest struct {
+	DiagnosedConditionRequest
+	Id int `path:"id"`
+}
+
+type PatchDiagnosedConditionRequest struct {
+	DiagnosedConditionPatch
+	Id int `path:"id"`
+}
+
+type DiagnosedConditionPatch struct {
+	Name        *string    `json:"name,omitempty" description:"name of the condition, defaulting to the name of the code"`
+	Code        *string    `json:"code,omitempty" description:"ICD-10-CM code or SNOMED CT concept id to identify the condition" minLength:"3"`
+	CodeSystem  *string    `json:"codeSystem,omitempty" description:"code system of the code, either ICD-10-CM or SNOMED-CT"`
+	Description *string    `json:"description,omitempty" description:"description of the condition"`
+	Date        *time.Time `json:"date,omitempty" description:"date on which this condition was diagnosed"`
+	OnsetDate   *time.Time `json:"onsetDate,omitempty" description:"date on which the condition began"`
+	EncounterId *int       `json:"encounterId,omitempty" description:"internal id of the encounter at which the condition was diagnosed, or 0 for none"`
+}
+
+type DiagnosedConditionStatusRequest struct {
+	DiagnosedConditionStatus
+	Id int `path:"id"`
+}
+
+type DiagnosedConditionStatus struct {
+	ClinicalStatus     string     `json:"clinicalStatus,omitempty" description:"new clinical status of the condition, if it is changing"`
+	VerificationStatus string     `json:"verificationStatus,omitempty" description:"new verification status of the condition, if it is changing"`
+	Date               *time.Time `json:"date,omitempty" description:"date on which the status changed, defaulting to now.  Becomes the abatement date when the condition is no longer active"`
+}
+
+type DiagnosedConditionSearch struct {
+	PatientId  int       `path:"patientId"`
+	From       time.Time `query:"from" description:"only include conditions diagnosed at or after this time"`
+	To         time.Time `query:"to" description:"only include conditions diagnosed at or before this time"`
+	Code       string    `query:"code" description:"only include conditions with this code, or a code mapped to it in another code system"`
+	CodeSystem string    `query:"codeSystem" description:"code system of code, either ICD-10-CM (the default) or SNOMED-CT"`
+	Active     bool      `query:"active" description:"only include conditions which are active, recurring or relapsed, and have not been refuted or entered in error"`
+	//code and the codes mapped to it, resolved by the diagnosed condition service
+	Codings []Coding `json:"-"`
+}
+
 type DiagnosedCondition struct {
-	Id          int       `json:"id" description:"internal id of the diagnosed condition"`
-	PatientId   int       `json:"patientId" description:"internal id of the patient for whom this condition was diagnosed"`
-	Name        string    `json:"name" description:"name of the condition" required:"true" minLength:"3"`
-	Code        string    `json:"code" description:"medical code to identify the condition" required:"true" minLength:"3"`
-	Description string    `json:"description" description:"description of the condition"`
-	Date        time.Time `json:"date" description:"date on which this condition was diagnosed" required:"true"`
+	Id                 int        `json:"id" description:"internal id of the diagnosed condition"`
+	PatientId          int        `json:"patientId" description:"internal id of the patient for whom this condition was diagnosed"`
+	Name               string     `json:"name" description:"name of the condition, defaulting to the name of the code"`
+	Code               string     `json:"code" description:"ICD-10-CM code or SNOMED CT concept id to identify the condition" required:"true" minLength:"3"`
+	CodeSystem         string     `json:"codeSystem" description:"code system of the code, either ICD-10-CM (the default) or SNOMED-CT"`
+	Description        string     `json:"description" description:"description of the condition"`
+	Date               time.Time  `json:"date" description:"date on which this condition was diagnosed" required:"true"`
+	OnsetDate          *time.Time `json:"onsetDate,omitempty" description:"date on which the condition began"`
+	AbatementDate      *time.Time `json:"abatementDate,omitempty" description:"date on which the condition resolved, went into remission or became inactive"`
+	ClinicalStatus     string     `json:"clinicalStatus" readOnly:"true" description:"one of active, recurrence, relapse, inactive, remission or resolved"`
+	VerificationStatus string     `json:"verificationStatus" readOnly:"true" description:"one of unconfirmed, provisional, differential, confirmed, refuted or entered-in-error"`
+	EncounterId        int        `json:"encounterId,omitempty" description:"internal id of the encounter at which the condition was diagnosed, if any"`
+	DeletedAt          time.Time  `json:"-"`
+}
+
+const (
+	ClinicalStatusActive     = "active"
+	ClinicalStatusRecurrence = "recurrence"
+	ClinicalStatusRelapse    = "relapse"
+	ClinicalStatusInactive   = "inactive"
+	ClinicalStatusRemission  = "remission"
+	ClinicalStatusResolved   = "resolved"
+
+	VerificationStatusUnconfirmed    = "unconfirmed"
+	VerificationStatusProvisional    = "provisional"
+	VerificationStatusDifferential   = "differential"
+	VerificationStatusConfirmed      = "confirmed"
+	VerificationStatusRefuted        = "refuted"
+	VerificationStatusEnteredInError = "entered-in-error"
+)
+
+// whether the patient currently has the condition, as far as is known
+func (c DiagnosedCondition) IsActive() bool {
+	switch c.ClinicalStatus {
+	case ClinicalStatusActive, ClinicalStatusRecurrence, ClinicalStatusRelapse:
+	default:
+		return false
+	}
+	return c.VerificationStatus != VerificationStatusRefuted && c.VerificationStatus != VerificationStatusEnteredInError
+}
+
+type MedicationRequest struct {
+	DrugName   string     `json:"drugName" required:"true" minLength:"2" description:"name of the drug"`
+	RxNormCode string     `json:"rxNormCode" pattern:"^[0-9]*$" description:"RxNorm concept unique identifier (RXCUI) of the drug"`
+	Dose       string     `json:"dose" description:"amount taken each time, for example 500 mg"`
+	Route      string     `json:"route" description:"how the drug is taken, for example oral"`
+	Frequency  string     `json:"frequency" description:"how often the drug is taken, for example twice daily"`
+	StartDate  time.Time  `json:"startDate" required:"true" description:"date on which the patient started taking the drug"`
+	EndDate    *time.Time `json:"endDate,omitempty" description:"date on which the patient stopped or will stop taking the drug"`
+}
+
+type CreateMedicationRequest struct {
+	MedicationRequest
+	PatientId int `path:"patientId"`
+}
+
+type UpdateMedicationRequest struct {
+	MedicationRequest
+	PatientId int `path:"patientId"`
+	Id        int `path:"id"`
+}
+
+type PatientMedicationRequest struct {
+	PatientId int `path:"patientId"`
+	Id        int `path:"id"`
+}
+
+type PatientIdRequest struct {
+	PatientId int `path:"patientId"`
+}
+
+type Medication struct {
+	Id        int `json:"id" description:"internal id of the medication"`
+	PatientId int `json:"patientId" description:"internal id of the patient taking the medication"`
+	MedicationRequest
+	DeletedAt time.Time `json:"-"`
+}
+
+type AllergyRequest struct {
+	Allergen    string `json:"allergen" required:"true" minLength:"2" description:"substance to which the patient is allergic or intolerant, for example penicillin"`
+	Category    string `json:"category" required:"true" description:"one of food, medication, environment or biologic"`
+	Criticality string `json:"criticality" description:"potential for a future reaction to be life threatening, one of low, high or unable-to-assess"`
+	Reaction    string `json:"reaction" description:"manifestation of the reaction, for example hives"`
+	Severity    string `json:"severity" description:"severity of the reaction, one of mild, moderate or severe"`
+}
+
+const (
+	AllergyCategoryFood        = "food"
+	AllergyCategoryMedication  = "medication"
+	AllergyCategoryEnvironment = "environment"
+	AllergyCategoryBiologic    = "biologic"
+
+	AllergyCriticalityLow            = "low"
+	AllergyCriticalityHigh           = "high"
+	AllergyCriticalityUnableToAssess = "unable-to-assess"
+
+	AllergySeverityMild     = "mild"
+	AllergySeverityModerate = "moderate"
+	AllergySeveritySevere   = "severe"
+)
+
+type CreateAllergyRequest struct {
+	AllergyRequest
+	PatientId int `path:"patientId"`
+}
+
+type UpdateAllergyRequest struct {
+	AllergyRequest
+	PatientId int `path:"patientId"`
+	Id        int `path:"id"`
+}
+
+type PatientAllergyRequest struct {
+	PatientId int `path:"patientId"`
+	Id        int `path:"id"`
+}
+
+type Allergy struct {
+	Id        int `json:"id" description:"internal id of the allergy"`
+	PatientId int `json:"patientId" description:"internal id of the patient with the allergy"`
+	AllergyRequest
+	DeletedAt time.Time `json:"-"`
+}
+
+type CreateObservationRequest struct {
+	ObservationRequest
+	PatientId int `path:"patientId"`
+}
+
+type ObservationRequest struct {
+	Code          string                 `json:"code" required:"true" description:"LOINC code of what was observed, for example 8867-4 for heart rate"`
+	Name          string                 `json:"name" description:"name of what was observed, for example Heart rate"`
+	Value         *float64               `json:"value,omitempty" description:"observed value, required unless the observation is made up of components"`
+	Unit          string                 `json:"unit" description:"UCUM unit of the value, for example /min, kg or mm[Hg]"`
+	Components    []ObservationComponent `json:"components,omitempty" description:"values of observations made up of several measurements, such as the systolic and diastolic pressure of a blood pressure"`
+	EffectiveDate time.Time              `json:"effectiveDate" required:"true" description:"time at which the observation was made"`
+}
+
+type ObservationComponent struct {
+	Code  string  `json:"code" required:"true" description:"LOINC code of the component, for example 8480-6 for systolic blood pressure"`
+	Name  string  `json:"name" description:"name of the component"`
+	Value float64 `json:"value" description:"observed value of the component"`
+	Unit  string  `json:"unit" description:"UCUM unit of the value"`
+}
+
+type Observation struct {
+	Id        int `json:"id" description:"internal id of the observation"`
+	PatientId int `json:"patientId" description:"internal id of the patient observed"`
+	ObservationRequest
+	DeletedAt time.Time `json:"-"`
+}
+
+type ObservationSearch struct {
+	PatientId int       `path:"patientId"`
+	Code      string    `query:"code" description:"only include observations with this LOINC code"`
+	From      time.Time `query:"from" description:"only include observations made at or after this time"`
+	To        time.Time `query:"to" description:"only include observations made at or before this time"`
+}
+
+type EncounterRequest struct {
+	Type         string     `json:"type" required:"true" description:"one of ambulatory, emergency, inpatient, home-health or virtual"`
+	Start        time.Time  `json:"start" required:"true" description:"time at which the encounter started"`
+	End          *time.Time `json:"end,omitempty" description:"time at which the encounter ended"`
+	Location     string     `json:"location" description:"where the encounter took place"`
+	Practitioner string     `json:"practitioner" description:"practitioner who saw the patient"`
+}
+
+const (
+	EncounterTypeAmbulatory = "ambulatory"
+	EncounterTypeEmergency  = "emergency"
+	EncounterTypeInpatient  = "inpatient"
+	EncounterTypeHomeHealth = "home-health"
+	EncounterTypeVirtual    = "virtual"
+)
+
+type CreateEncounterRequest struct {
+	EncounterRequest
+	PatientId int `path:"patientId"`
+}
+
+type UpdateEncounterRequest struct {
+	EncounterRequest
+	PatientId int `path:"patientId"`
+	Id        int `path:"id"`
+}
+
+type PatientEncounterRequest struct {
+	PatientId int `path:"patientId"`
+	Id        int `path:"id"`
+}
+
+type Encounter struct {
+	Id        int `json:"id" description:"internal id of the encounter"`
+	PatientId int `json:"patientId" description:"internal id of the patient seen"`
+	EncounterRequest
+	DeletedAt time.Time `json:"-"`
+}
+
+type EncounterDocumentation struct {
+	Encounter
+	DiagnosedConditions []DiagnosedCondition `json:"diagnosedConditions" description:"conditions diagnosed at the encounter"`
+	Attatchments        []Attatchment        `json:"attatchments" description:"attatchments produced at the encounter"`
+}
+
+type SlotRequest struct {
+	Practitioner string    `json:"practitioner" required:"true" description:"practitioner who is available during the slot"`
+	Start        time.Time `json:"start" required:"true" description:"time at which the slot starts"`
+	End          time.Time `json:"end" required:"true" description:"time at which the slot ends"`
+}
+
+type Slot struct {
+	Id int `json:"id" description:"internal id of the slot"`
+	SlotRequest
+	AppointmentId int `json:"appointmentId,omitempty" description:"internal id of the appointment booked into the slot, if any"`
+}
+
+type ScheduleSearch struct {
+	Practitioner string `query:"practitioner" description:"only include this practitioner's schedule"`
+	Date         string `query:"date" required:"true" description:"day to list, formatted as 2006-01-02"`
+	Period       string `query:"period" description:"day, the default, or week for the monday to sunday week containing the date"`
+	Available    bool   `query:"available" description:"only include slots which have not been booked"`
+}
+
+type AppointmentRequest struct {
+	SlotId int    `json:"slotId" required:"true" description:"internal id of the slot to book"`
+	Reason string `json:"reason" description:"reason for the appointment"`
+}
+
+const (
+	AppointmentStatusBooked    = "booked"
+	AppointmentStatusCancelled = "cancelled"
+)
+
+type BookAppointmentRequest struct {
+	AppointmentRequest
+	PatientId int `path:"patientId"`
+}
+
+type RescheduleAppointmentRequest struct {
+	SlotId    int `json:"slotId" required:"true" description:"internal id of the slot to move the appointment to"`
+	PatientId int `path:"patientId"`
+	Id        int `path:"id"`
+}
+
+type PatientAppointmentRequest struct {
+	PatientId int `path:"patientId"`
+	Id        int `path:"id"`
+}
+
+type Appointment struct {
+	Id           int       `json:"id" description:"internal id of the appointment"`
+	PatientId    int       `json:"patientId" description:"internal id of the patient booked"`
+	SlotId       int       `json:"slotId" description:"internal id of the booked slot"`
+	Practitioner string    `json:"practitioner" description:"practitioner the patient will see"`
+	Start        time.Time `json:"start" description:"time at which the appointment starts"`
+	End          time.Time `json:"end" description:"time at which the appointment ends"`
+	Reason       string    `json:"reason" description:"reason for the appointment"`
+	Status       string    `json:"status" description:"booked or cancelled"`
 }
 
 type Attatchment struct {
-	Id          int    `json:"id" description:"id of the attatchment"`
-	PatientId   int    `json:"patientId" description:"id of the patient to whom this attatchment belongs"`
-	Name        string `json:"name" description:"name of this attatchment" required:"true" minLength:"5"`
-	Description string `json:"descripiton" description:"description of this attatchment"`
-	Type        string `json:"type" description:"type of attatchment" minLength:"4"`
-	Data        []byte `json:"data" description:"data associated with this attatchment"`
+	Id          int            `json:"id" description:"id of the attatchment"`
+	PatientId   int            `json:"patientId" description:"id of the patient to whom this attatchment belongs"`
+	Name        string         `json:"name" description:"name of this attatchment" required:"true" minLength:"5"`
+	Description string         `json:"descripiton" description:"description of this attatchment"`
+	Type        string         `json:"type" description:"type of attatchment" minLength:"4"`
+	Data        []byte         `json:"data" description:"data associated with this attatchment"`
+	Version     int            `json:"version" description:"current version of the attatchment's data"`
+	Dicom       *DicomMetadata `json:"dicom,omitempty" description:"metadata extracted from the attatchment when it is a DICOM file"`
+	EncounterId int            `json:"encounterId,omitempty" description:"internal id of the encounter at which this attatchment was produced, if any"`
+	DeletedAt   time.Time      `json:"-"`
+}
+
+type DicomMetadata struct {
+	StudyDate         time.Time `json:"studyDate" description:"date on which the imaging study was started"`
+	Modality          string    `json:"modality" description:"type of equipment that acquired the image, for example CT or MR"`
+	BodyPart          string    `json:"bodyPart" description:"body part examined"`
+	StudyInstanceUid  string    `json:"studyInstanceUid" description:"unique identifier of the imaging study"`
+	SeriesInstanceUid string    `json:"seriesInstanceUid" description:"unique identifier of the series within the study"`
+}
+
+type ReplaceAttatchmentContentRequest struct {
+	Id   int            `path:"id"`
+	Data multipart.File `formData:"data" description:"data for the new version of this attatchment"`
+}
+
+type GetAttatchmentThumbnailRequest struct {
+	Id   int `path:"id"`
+	Size int `query:"size" description:"size in pixels of the thumbnail's bounding box.  One of 64, 128 or 256, defaulting to 128"`
+}
+
+type AttatchmentThumbnail struct {
+	AttatchmentId int
+	Size          int
+	ContentType   string
+	Data          []byte
+}
+
+type GetAttatchmentVersionRequest struct {
+	Id      int `path:"id"`
+	Version int `path:"version"`
+}
+
+type AttatchmentVersion struct {
+	AttatchmentId int            `json:"attatchmentId" description:"id of the attatchment this version belongs to"`
+	Version       int            `json:"version" description:"version number, starting at 1 for the originally uploaded data"`
+	Data          []byte         `json:"data,omitempty" description:"data of this version of the attatchment"`
+	Dicom         *DicomMetadata `json:"dicom,omitempty" description:"metadata extracted from this version when it is a DICOM file"`
+	CreatedAt     time.Time      `json:"createdAt" description:"time at which this version was created"`
+}
+
+const (
+	CodeSystemICD10CM  = "ICD-10-CM"
+	CodeSystemSNOMEDCT = "SNOMED-CT"
+)
+
+type Coding struct {
+	System string `json:"system"`
+	Code   string `json:"code"`
+}
+
+type Code struct {
+	Code string `json:"code" description:"the code, in its canonical form"`
+	Name string `json:"name" description:"display name of the code"`
+}
+
+type CodeSearch struct {
+	Query string `query:"q" required:"true" minLength:"1" description:"code prefix or words from the name of the code to search for"`
+	Limit int    `query:"limit" description:"maximum number of codes to return, defaulting to 20"`
 }
 
 type PatientSearch struct {
-	Name                   string `query:"name" description:"name to search for"`
-	Address                string `query:"address" description:"address to search for"`
-	Phone                  string `query:"phone" description:"phone to search for"`
-	ExternalIdentifier     string `query:"externalIdentifier" description:"externalIdentifier to search for"`
-	DiagnosedConditionName string `query:"diagnosedConditionName" description:"name of the medical condition to search for"`
-	DiagnosedConditionCode string `query:"diagnosedConditionCode" description:"code of the medical condition to search for"`
-	AttatchmentName        string `query:"attatchmentName" description:"attatchment name to search for"`
-	AttatchmentType        string `query:"attatchmentType" description:"attatchment type to search for"`
+	Name               string `query:"name" description:"name to search for"`
+	NamePart           string `query:"namePart" description:"start of any word of the name to search for, ignoring case"`
+	BirthDate          string `query:"birthDate" description:"date of birth (YYYY-MM-DD) to search for"`
+	Address            string `query:"address" description:"address to search for"`
+	Phone              string `query:"phone" description:"phone to search for"`
+	ExternalIdentifier string `query:"externalIdentifier" description:"externalIdentifier to search for"`
+	Identifier         string `query:"identifier" description:"identifier to search for, as system|value to match within a system or a value to match in any system"`
+	//the system and value of the identifier, split by the patient service.  An empty system matches any
+	IdentifierSystem             string `json:"-"`
+	IdentifierValue              string `json:"-"`
+	DiagnosedConditionName       string `query:"diagnosedConditionName" description:"name of the medical condition to search for"`
+	DiagnosedConditionCode       string `query:"diagnosedConditionCode" description:"code of the medical condition to search for"`
+	DiagnosedConditionCodePrefix string `query:"diagnosedConditionCodePrefix" description:"ICD-10-CM code whose descendants are searched for, for example E11 matches every type 2 diabetes code"`
+	DiagnosedConditionCodeRange  string `query:"diagnosedConditionCodeRange" description:"inclusive range of ICD-10-CM codes and their descendants to search for, for example E08-E13"`
+	DiagnosedConditionCodeSystem string `query:"diagnosedConditionCodeSystem" description:"code system of diagnosedConditionCode, either ICD-10-CM (the default) or SNOMED-CT.  Conditions recorded with a code mapped to it in the other code system are also found"`
+	DiagnosedConditionActive     bool   `query:"diagnosedConditionActive" description:"only match on diagnosed conditions which are active, recurring or relapsed, and have not been refuted or entered in error"`
+	//the codes matched by the code, code prefix and range, along with the codes mapped to them, resolved by the patient service
+	DiagnosedConditionCodings []Coding `json:"-"`
+	AttatchmentName           string   `query:"attatchmentName" description:"attatchment name to search for"`
+	AttatchmentType           string   `query:"attatchmentType" description:"attatchment type to search for"`
+	StudyDate                 string   `query:"studyDate" description:"study date (YYYY-MM-DD) of a DICOM attatchment to search for"`
+	Modality                  string   `query:"modality" description:"modality of a DICOM attatchment to search for"`
+	BodyPart                  string   `query:"bodyPart" description:"body part examined in a DICOM attatchment to search for"`
+	StudyInstanceUid          string   `query:"studyInstanceUid" description:"study instance uid of a DICOM attatchment to search for"`
+	SeriesInstanceUid         string   `query:"seriesInstanceUid" description:"series instance uid of a DICOM attatchment to search for"`
+	Allergen                  string   `query:"allergen" description:"substance to which patients are allergic or intolerant to search for, ignoring case"`
+}
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+const (
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+)
+
+type ImportRequest struct {
+	Format string         `formData:"format" required:"true" enum:"csv,ndjson" description:"format of the data, either csv or ndjson"`
+	DryRun bool           `formData:"dryRun" description:"validate the data and report the errors which importing it would have, without importing anything"`
+	Data   multipart.File `formData:"data" required:"true" description:"patients to import, along with their diagnosed conditions"`
+}
+
+type ImportJob struct {
+	Id                 int              `json:"id" description:"internal id of the import"`
+	Format             string           `json:"format" description:"format of the imported data, either csv or ndjson"`
+	DryRun             bool             `json:"dryRun" description:"whether the data is only being validated"`
+	Status             string           `json:"status" description:"either running or completed"`
+	TotalRows          int              `json:"totalRows" description:"number of rows of data to import, excluding any csv header"`
+	ProcessedRows      int              `json:"processedRows" description:"number of rows which have been processed so far"`
+	ImportedPatients   int              `json:"importedPatients" description:"number of patients imported so far, or which would be in a dry run"`
+	ImportedConditions int              `json:"importedConditions" description:"number of diagnosed conditions imported so far, or which would be in a dry run"`
+	Errors             []ImportRowError `json:"errors" description:"rows which could not be imported, or could not be in a dry run"`
+	CreatedAt          time.Time        `json:"createdAt" description:"time at which the import was started"`
+	CompletedAt        *time.Time       `json:"completedAt,omitempty" description:"time at which the import completed"`
+}
+
+type ImportRowError struct {
+	Row                int    `json:"row" description:"line of the data on which the row begins, counting from 1 and including any csv header"`
+	ExternalIdentifier string `json:"externalIdentifier,omitempty" description:"external identifier of the patient on the row, if it could be read"`
+	Message            string `json:"message" description:"why the row could not be imported"`
+}
+
+const (
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+type ExportJob struct {
+	Id                int          `json:"id" description:"internal id of the export"`
+	Request           string       `json:"request" description:"url with which the export was started"`
+	Types             []string     `json:"types" description:"FHIR resource types being exported"`
+	Status            string       `json:"status" description:"either running, completed or failed"`
+	TransactionTime   time.Time    `json:"transactionTime" description:"time at which the export was started"`
+	ExportedResources int          `json:"exportedResources" description:"number of resources written so far"`
+	Output            []ExportFile `json:"output" description:"files written by the export, one for each resource type which had any resources"`
+	Error             string       `json:"error,omitempty" description:"why the export failed"`
+	CompletedAt       *time.Time   `json:"completedAt,omitempty" description:"time at which the export completed or failed"`
+}
+
+type ExportFile struct {
+	Type  string `json:"type" description:"FHIR resource type of the resources in the file"`
+	Count int    `json:"count" description:"number of resources in the file"`
+	Name  string `json:"name" description:"name of the file in export storage"`
+}
+
+type PotentialDuplicate struct {
+	Patient     Patient           `json:"patient" description:"patient who may be the same person"`
+	Score       float64           `json:"score" description:"total weight of evidence that the patients are the same person, the sum of the weights of each field compared"`
+	Probability float64           `json:"probability" description:"estimated probability, from 0 to 1, that the patients are the same person"`
+	Fields      []FieldComparison `json:"fields" description:"how each field recorded for both patients compared"`
+}
+
+type FieldComparison struct {
+	Field      string  `json:"field" description:"one of name, dateOfBirth, phoneNumber or address"`
+	Similarity float64 `json:"similarity" description:"how alike the values are, from 0 (nothing alike) to 1 (the same)"`
+	Weight     float64 `json:"weight" description:"evidence the field gives for (when positive) or against (when negative) the patients being the same person"`
+}
+
+type MergePatientRequest struct {
+	Id                 int `path:"id"`
+	DuplicatePatientId int `json:"duplicatePatientId" required:"true" description:"id of the patient to merge into this one, whose record is deleted once merged"`
+}
+
+// records that one patient was merged into another
+type PatientLink struct {
+	Id              int       `json:"id" description:"id of the link"`
+	PatientId       int       `json:"patientId" description:"id of the surviving patient"`
+	MergedPatientId int       `json:"mergedPatientId" description:"id of the duplicate patient merged into the surviving one"`
+	MergedAt        time.Time `json:"mergedAt" description:"time at which the patients were merged"`
 }
@@ -0,0 +1,29 @@
+package observations
+
+import (
+	"context"
+	"mcg-app-backend/service/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ObservationRepo interface {
+	InsertObservation(ctx context.Context, observation models.Observation) (int, error)
+	GetObservation(ctx context.Context, observationId int) (models.Observation, error)
+	DeleteObservation(ctx context.Context, observationId int) error
+	DeleteObservationsByPatientId(ctx context.Context, patientId int) error
+	RestoreObservation(ctx context.Context, observationId int) error
+	GetObservationsByPatientId(ctx context.Context, search models.ObservationSearch) ([]models.Observation, error)
+	GetLatestObservationsByPatientId(ctx context.Context, patientId int) ([]models.Observation, error)
+}
+
+type PatientService interface {
+	ValidatePatientId(ctx context.Context, patientId int) error
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
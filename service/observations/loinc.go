@@ -0,0 +1,28 @@
+package observations
+
+import "strings"
+
+// LOINC codes are up to seven digits, a hyphen and a mod 10 check digit, for example 8867-4
+func isValidLOINCCode(code string) bool {
+	number, check, found := strings.Cut(code, "-")
+	if !found || len(number) == 0 || len(number) > 7 || len(check) != 1 {
+		return false
+	}
+
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if digit < 0 || digit > 9 {
+			return false
+		}
+		//digits are doubled from the rightmost one, as in the Luhn algorithm
+		if (len(number)-1-i)%2 == 0 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return int(check[0]-'0') == (10-sum%10)%10
+}
@@ -0,0 +1,183 @@
+package observations
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type ObservationService struct {
+	repo       ObservationRepo
+	patientSvc PatientService
+	tracer     Tracer
+}
+
+func NewObservationService(repo ObservationRepo, patientSvc PatientService, tracer Tracer) ObservationService {
+	return ObservationService{
+		repo:       repo,
+		patientSvc: patientSvc,
+		tracer:     tracer,
+	}
+}
+
+func (s ObservationService) AddObservationToPatient(ctx context.Context, patientId int, details models.ObservationRequest) (models.Observation, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "AddObservationToPatient")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", patientId),
+		attribute.String("code", details.Code),
+		attribute.String("unit", details.Unit),
+		attribute.Int("components", len(details.Components)),
+		attribute.String("effectiveDate", fmt.Sprintf("%v", details.EffectiveDate)))
+
+	details, err := s.validateDetails(ctx, details)
+	if err != nil {
+		return models.Observation{}, err
+	}
+
+	err = s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return models.Observation{}, err
+	}
+
+	observation := models.Observation{
+		PatientId:          patientId,
+		ObservationRequest: details,
+	}
+
+	id, err := s.repo.InsertObservation(ctx, observation)
+	if err != nil {
+		return models.Observation{}, s.tracer.RecordError(ctx, fmt.Errorf("error inserting observation %w", err))
+	}
+
+	observation.Id = id
+	return observation, nil
+}
+
+func (s ObservationService) GetObservation(ctx context.Context, observationId int) (models.Observation, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetObservation")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("observationId", observationId))
+
+	observation, err := s.repo.GetObservation(ctx, observationId)
+	if err != nil {
+		return models.Observation{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting observation %w", err))
+	}
+
+	return observation, nil
+}
+
+func (s ObservationService) DeleteObservation(ctx context.Context, observationId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "DeleteObservation")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("observationId", observationId))
+
+	err := s.repo.DeleteObservation(ctx, observationId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error deleting observation %w", err))
+	}
+
+	return nil
+}
+
+func (s ObservationService) RestoreObservation(ctx context.Context, observationId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "RestoreObservation")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("observationId", observationId))
+
+	err := s.repo.RestoreObservation(ctx, observationId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error restoring observation %w", err))
+	}
+
+	return nil
+}
+
+func (s ObservationService) DeletePatientObservations(ctx context.Context, patientId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "DeletePatientObservations")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.repo.DeleteObservationsByPatientId(ctx, patientId)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error deleting observations for patient %w", err))
+	}
+
+	return nil
+}
+
+func (s ObservationService) GetPatientObservations(ctx context.Context, search models.ObservationSearch) ([]models.Observation, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetPatientObservations")
+	defer span.End()
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("patientId", search.PatientId),
+		attribute.String("search.code", search.Code),
+		attribute.String("search.from", fmt.Sprintf("%v", search.From)),
+		attribute.String("search.to", fmt.Sprintf("%v", search.To)))
+
+	if !search.From.IsZero() && !search.To.IsZero() && search.To.Before(search.From) {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("to must not be before from"))
+	}
+	search.Code = strings.TrimSpace(search.Code)
+	if search.Code != "" && !isValidLOINCCode(search.Code) {
+		return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%v is not a valid LOINC code", search.Code)))
+	}
+
+	err := s.patientSvc.ValidatePatientId(ctx, search.PatientId)
+	if err != nil {
+		return nil, err
+	}
+
+	observations, err := s.repo.GetObservationsByPatientId(ctx, search)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting observations for patient %w", err))
+	}
+
+	return observations, nil
+}
+
+// the most recent observation of each code, such as the last recorded weight and blood pressure
+func (s ObservationService) GetLatestObservations(ctx context.Context, patientId int) ([]models.Observation, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetLatestObservations")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.patientSvc.ValidatePatientId(ctx, patientId)
+	if err != nil {
+		return nil, err
+	}
+
+	observations, err := s.repo.GetLatestObservationsByPatientId(ctx, patientId)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting latest observations for patient %w", err))
+	}
+
+	return observations, nil
+}
+
+func (s ObservationService) validateDetails(ctx context.Context, details models.ObservationRequest) (models.ObservationRequest, error) {
+	details.Code = strings.TrimSpace(details.Code)
+	if !isValidLOINCCode(details.Code) {
+		return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%v is not a valid LOINC code", details.Code)))
+	}
+	if details.EffectiveDate.IsZero() {
+		return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("effective date is required"))
+	}
+	if details.Value == nil && len(details.Components) == 0 {
+		return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError("a value or components are required"))
+	}
+	details.Components = slices.Clone(details.Components)
+	for i, component := range details.Components {
+		component.Code = strings.TrimSpace(component.Code)
+		if !isValidLOINCCode(component.Code) {
+			return details, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%v is not a valid LOINC code", component.Code)))
+		}
+		details.Components[i] = component
+	}
+	return details, nil
+}
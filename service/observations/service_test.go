@@ -0,0 +1,346 @@
+package observations
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockObservationRepo struct {
+	mock.Mock
+}
+
+func (m *MockObservationRepo) InsertObservation(ctx context.Context, observation models.Observation) (int, error) {
+	args := m.Called(ctx, observation)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockObservationRepo) GetObservation(ctx context.Context, observationId int) (models.Observation, error) {
+	args := m.Called(ctx, observationId)
+	return args.Get(0).(models.Observation), args.Error(1)
+}
+
+func (m *MockObservationRepo) DeleteObservation(ctx context.Context, observationId int) error {
+	args := m.Called(ctx, observationId)
+	return args.Error(0)
+}
+
+func (m *MockObservationRepo) DeleteObservationsByPatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+func (m *MockObservationRepo) RestoreObservation(ctx context.Context, observationId int) error {
+	args := m.Called(ctx, observationId)
+	return args.Error(0)
+}
+
+func (m *MockObservationRepo) GetObservationsByPatientId(ctx context.Context, search models.ObservationSearch) ([]models.Observation, error) {
+	args := m.Called(ctx, search)
+	return args.Get(0).([]models.Observation), args.Error(1)
+}
+
+func (m *MockObservationRepo) GetLatestObservationsByPatientId(ctx context.Context, patientId int) ([]models.Observation, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).([]models.Observation), args.Error(1)
+}
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) ValidatePatientId(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService() (*MockObservationRepo, *MockPatientService, ObservationService) {
+	mockRepo := new(MockObservationRepo)
+	mockPatientSvc := new(MockPatientService)
+	service := NewObservationService(mockRepo, mockPatientSvc, new(MockTracer))
+	return mockRepo, mockPatientSvc, service
+}
+
+func getHeartRate() models.ObservationRequest {
+	value := 72.0
+	return models.ObservationRequest{
+		Code:          "8867-4",
+		Name:          "Heart rate",
+		Value:         &value,
+		Unit:          "/min",
+		EffectiveDate: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+	}
+}
+
+func getBloodPressure() models.ObservationRequest {
+	return models.ObservationRequest{
+		Code: "85354-9",
+		Name: "Blood pressure panel",
+		Components: []models.ObservationComponent{
+			{Code: "8480-6", Name: "Systolic blood pressure", Value: 120, Unit: "mm[Hg]"},
+			{Code: "8462-4", Name: "Diastolic blood pressure", Value: 80, Unit: "mm[Hg]"},
+		},
+		EffectiveDate: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestIsValidLOINCCode(t *testing.T) {
+	for _, code := range []string{"8867-4", "8480-6", "8462-4", "85354-9", "29463-7", "39156-5", "2345-7", "4548-4"} {
+		assert.True(t, isValidLOINCCode(code), code)
+	}
+	for _, code := range []string{"8867-5", "88674", "8867-", "-4", "88a7-4", "12345678-1", "8867-44", ""} {
+		assert.False(t, isValidLOINCCode(code), code)
+	}
+}
+
+func TestAddObservationToPatient(t *testing.T) {
+	patientId := 1
+
+	t.Run("AddObservation_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := getHeartRate()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertObservation", mock.Anything, models.Observation{PatientId: patientId, ObservationRequest: details}).Return(5, nil)
+
+		observation, err := service.AddObservationToPatient(context.Background(), patientId, details)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, observation.Id)
+		assert.Equal(t, patientId, observation.PatientId)
+
+		mockRepo.AssertExpectations(t)
+		mockPatientSvc.AssertExpectations(t)
+	})
+
+	t.Run("AddObservation_WithComponents", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertObservation", mock.Anything, mock.AnythingOfType("models.Observation")).Return(6, nil)
+
+		observation, err := service.AddObservationToPatient(context.Background(), patientId, getBloodPressure())
+		assert.Nil(t, err)
+		assert.Len(t, observation.Components, 2)
+	})
+
+	t.Run("AddObservation_InvalidCode", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		details := getHeartRate()
+		details.Code = "8867-5"
+
+		_, err := service.AddObservationToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "8867-5 is not a valid LOINC code", err.Error())
+
+		mockPatientSvc.AssertNotCalled(t, "ValidatePatientId", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "InsertObservation", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddObservation_InvalidComponentCode", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getBloodPressure()
+		details.Components[1].Code = "systolic"
+
+		_, err := service.AddObservationToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "systolic is not a valid LOINC code", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertObservation", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddObservation_MissingValue", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getHeartRate()
+		details.Value = nil
+
+		_, err := service.AddObservationToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "a value or components are required", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertObservation", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddObservation_MissingEffectiveDate", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		details := getHeartRate()
+		details.EffectiveDate = time.Time{}
+
+		_, err := service.AddObservationToPatient(context.Background(), patientId, details)
+		assert.NotNil(t, err)
+		assert.Equal(t, "effective date is required", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertObservation", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddObservation_InvalidPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.AddObservationToPatient(context.Background(), patientId, getHeartRate())
+		assert.NotNil(t, err)
+		assert.Equal(t, "patient id not found", err.Error())
+
+		mockRepo.AssertNotCalled(t, "InsertObservation", mock.Anything, mock.Anything)
+	})
+
+	t.Run("AddObservation_RepoError", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, patientId).Return(nil)
+		mockRepo.On("InsertObservation", mock.Anything, mock.Anything).Return(0, fmt.Errorf("db error"))
+
+		_, err := service.AddObservationToPatient(context.Background(), patientId, getHeartRate())
+		assert.NotNil(t, err)
+		assert.Equal(t, "error inserting observation db error", err.Error())
+	})
+}
+
+func TestGetObservation(t *testing.T) {
+	t.Run("GetObservation_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		expected := models.Observation{Id: 1, PatientId: 1, ObservationRequest: getHeartRate()}
+		mockRepo.On("GetObservation", mock.Anything, 1).Return(expected, nil)
+
+		observation, err := service.GetObservation(context.Background(), 1)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, observation)
+	})
+
+	t.Run("GetObservation_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("GetObservation", mock.Anything, 1).Return(models.Observation{}, fmt.Errorf("db error"))
+
+		_, err := service.GetObservation(context.Background(), 1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting observation db error", err.Error())
+	})
+}
+
+func TestGetPatientObservations(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	t.Run("GetPatientObservations_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		search := models.ObservationSearch{PatientId: 1, Code: "8867-4", From: from, To: to}
+		expected := []models.Observation{{Id: 1, PatientId: 1, ObservationRequest: getHeartRate()}}
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("GetObservationsByPatientId", mock.Anything, search).Return(expected, nil)
+
+		observations, err := service.GetPatientObservations(context.Background(), search)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, observations)
+	})
+
+	t.Run("GetPatientObservations_ToBeforeFrom", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+
+		_, err := service.GetPatientObservations(context.Background(), models.ObservationSearch{PatientId: 1, From: to, To: from})
+		assert.NotNil(t, err)
+		assert.Equal(t, "to must not be before from", err.Error())
+
+		mockRepo.AssertNotCalled(t, "GetObservationsByPatientId", mock.Anything, mock.Anything)
+	})
+
+	t.Run("GetPatientObservations_InvalidCode", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+
+		_, err := service.GetPatientObservations(context.Background(), models.ObservationSearch{PatientId: 1, Code: "heart"})
+		assert.NotNil(t, err)
+		assert.Equal(t, "heart is not a valid LOINC code", err.Error())
+
+		mockRepo.AssertNotCalled(t, "GetObservationsByPatientId", mock.Anything, mock.Anything)
+	})
+
+	t.Run("GetPatientObservations_InvalidPatient", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.GetPatientObservations(context.Background(), models.ObservationSearch{PatientId: 1})
+		assert.NotNil(t, err)
+
+		mockRepo.AssertNotCalled(t, "GetObservationsByPatientId", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetLatestObservations(t *testing.T) {
+	t.Run("GetLatestObservations_Success", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		expected := []models.Observation{{Id: 2, PatientId: 1, ObservationRequest: getHeartRate()}}
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("GetLatestObservationsByPatientId", mock.Anything, 1).Return(expected, nil)
+
+		observations, err := service.GetLatestObservations(context.Background(), 1)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, observations)
+	})
+
+	t.Run("GetLatestObservations_RepoError", func(t *testing.T) {
+		mockRepo, mockPatientSvc, service := getMocksAndService()
+		mockPatientSvc.On("ValidatePatientId", mock.Anything, 1).Return(nil)
+		mockRepo.On("GetLatestObservationsByPatientId", mock.Anything, 1).Return([]models.Observation{}, fmt.Errorf("db error"))
+
+		_, err := service.GetLatestObservations(context.Background(), 1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error getting latest observations for patient db error", err.Error())
+	})
+}
+
+func TestDeleteObservation(t *testing.T) {
+	t.Run("DeleteObservation_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("DeleteObservation", mock.Anything, 1).Return(nil)
+
+		err := service.DeleteObservation(context.Background(), 1)
+		assert.Nil(t, err)
+	})
+
+	t.Run("DeleteObservation_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("DeleteObservation", mock.Anything, 1).Return(fmt.Errorf("db error"))
+
+		err := service.DeleteObservation(context.Background(), 1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error deleting observation db error", err.Error())
+	})
+}
+
+func TestRestoreObservation(t *testing.T) {
+	t.Run("RestoreObservation_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("RestoreObservation", mock.Anything, 1).Return(nil)
+
+		err := service.RestoreObservation(context.Background(), 1)
+		assert.Nil(t, err)
+	})
+}
+
+func TestDeletePatientObservations(t *testing.T) {
+	t.Run("DeletePatientObservations_Success", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndService()
+		mockRepo.On("DeleteObservationsByPatientId", mock.Anything, 1).Return(nil)
+
+		err := service.DeletePatientObservations(context.Background(), 1)
+		assert.Nil(t, err)
+	})
+}
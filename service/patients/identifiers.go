@@ -0,0 +1,80 @@
+package patients
+
+import (
+	"context"
+	"fmt"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"slices"
+	"strings"
+)
+
+// records the legacy externalIdentifier as the patient's identifier in the external system, returning the identifiers
+// along with the externalIdentifier they give the patient.  Either may be given, but they must agree when both are
+func withExternalIdentifier(identifiers []models.PatientIdentifier, externalIdentifier string) ([]models.PatientIdentifier, string, error) {
+	all := []models.PatientIdentifier{}
+	external := ""
+	for _, identifier := range identifiers {
+		if identifier.System == "" || identifier.Value == "" {
+			return nil, "", customerrors.NewInvalidInputError("identifiers require both a system and a value")
+		}
+		if strings.Contains(identifier.System, "|") {
+			return nil, "", customerrors.NewInvalidInputError(fmt.Sprintf("identifier system %q cannot contain |", identifier.System))
+		}
+		if slices.Contains(all, identifier) {
+			return nil, "", customerrors.NewInvalidInputError(fmt.Sprintf("identifier %v|%v is listed more than once", identifier.System, identifier.Value))
+		}
+		if identifier.System == models.IdentifierSystemExternal {
+			if external != "" {
+				return nil, "", customerrors.NewInvalidInputError("patients can only have one identifier in the external system")
+			}
+			external = identifier.Value
+		}
+		all = append(all, identifier)
+	}
+
+	switch {
+	case externalIdentifier == "":
+	case external == "":
+		external = externalIdentifier
+		all = append([]models.PatientIdentifier{{System: models.IdentifierSystemExternal, Value: externalIdentifier}}, all...)
+	case external != externalIdentifier:
+		return nil, "", customerrors.NewInvalidInputError("externalIdentifier does not match the identifier in the external system")
+	}
+
+	if len(all) == 0 {
+		return nil, "", customerrors.NewInvalidInputError("patients require at least one identifier")
+	}
+	return all, external, nil
+}
+
+// identifiers are unique within their system, so none may be held by another patient
+func (s PatientService) ensureIdentifiersUnique(ctx context.Context, patientId int, identifiers []models.PatientIdentifier) error {
+	for _, identifier := range identifiers {
+		ids, err := s.repo.GetPatientIdsWithIdentifier(ctx, identifier)
+		if err != nil {
+			return fmt.Errorf("error getting patients with identifier %w", err)
+		}
+		for _, id := range ids {
+			if id == patientId {
+				continue
+			}
+			if identifier.System == models.IdentifierSystemExternal {
+				return customerrors.NewAlreadyExistsError("patient with matching externalIdentifier already exists")
+			}
+			return customerrors.NewAlreadyExistsError(fmt.Sprintf("patient with matching identifier %v|%v already exists", identifier.System, identifier.Value))
+		}
+	}
+	return nil
+}
+
+// the identifiers outside the external system, which clients from before identifiers do not send
+func otherIdentifiers(identifiers []models.PatientIdentifier) []models.PatientIdentifier {
+	others := []models.PatientIdentifier{}
+	for _, identifier := range identifiers {
+		if identifier.System != models.IdentifierSystemExternal {
+			others = append(others, identifier)
+		}
+	}
+	return others
+}
@@ -9,12 +9,24 @@ import (
 )
 
 type PatientRepo interface {
-	GetCountOfExternalIdentifier(ctx context.Context, externalId string) (int, error)
+	GetPatientIdsWithIdentifier(ctx context.Context, identifier models.PatientIdentifier) ([]int, error)
 	GetCountOfPatientId(ctx context.Context, patientId int) (int, error)
 	InsertPatient(ctx context.Context, patient models.Patient) (int, error)
 	UpdatePatient(ctx context.Context, patient models.Patient) error
 	DeletePatient(ctx context.Context, patientId int) error
+	RestorePatient(ctx context.Context, patientId int) error
+	GetPatient(ctx context.Context, patientId int) (models.Patient, error)
 	SearchPatients(ctx context.Context, search models.PatientSearch) ([]models.Patient, error)
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// deletes the records another service holds for a patient, such as their attachments
+type DependentRecordsDeleter func(ctx context.Context, patientId int) error
+
+type CodeService interface {
+	Descendants(ctx context.Context, prefix string) ([]string, error)
+	Range(ctx context.Context, codeRange string) ([]string, error)
+	Expand(ctx context.Context, codings []models.Coding) []models.Coding
 }
 
 type Tracer interface {
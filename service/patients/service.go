@@ -6,24 +6,34 @@ import (
 	"fmt"
 	"mcg-app-backend/service/customerrors"
 	"mcg-app-backend/service/models"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 )
 
 type PatientService struct {
-	repo   PatientRepo
-	tracer Tracer
+	repo       PatientRepo
+	codeSvc    CodeService
+	tracer     Tracer
+	dependents []DependentRecordsDeleter
 }
 
-func NewPatientService(repo PatientRepo, tracer Tracer) PatientService {
+func NewPatientService(repo PatientRepo, codeSvc CodeService, tracer Tracer) PatientService {
 	return PatientService{
-		repo:   repo,
-		tracer: tracer,
+		repo:    repo,
+		codeSvc: codeSvc,
+		tracer:  tracer,
 	}
 }
 
-func (s PatientService) CreatePatient(ctx context.Context, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string) (models.Patient, error) {
+// the services holding a patient's records depend on the patient service, so are supplied after construction
+func (s PatientService) WithDependentServices(dependents ...DependentRecordsDeleter) PatientService {
+	s.dependents = dependents
+	return s
+}
+
+func (s PatientService) CreatePatient(ctx context.Context, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error) {
 	ctx, span := s.tracer.NewSpan(ctx, "CreatePatient")
 	defer span.End()
 	s.tracer.SetAttributes(ctx,
@@ -32,12 +42,9 @@ func (s PatientService) CreatePatient(ctx context.Context, name string, address
 		attribute.String("dateOfBirth", fmt.Sprintf("%v", dateOfBirth)),
 		attribute.String("phoneNumber", phoneNumber))
 
-	count, err := s.repo.GetCountOfExternalIdentifier(ctx, externalIdentifier)
+	identifiers, externalIdentifier, err := withExternalIdentifier(identifiers, externalIdentifier)
 	if err != nil {
-		return models.Patient{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting count of externalIdentifier %w", err))
-	}
-	if count > 0 {
-		return models.Patient{}, s.tracer.RecordError(ctx, customerrors.NewAlreadyExistsError("patient with matching externalIdentifier already exists"))
+		return models.Patient{}, s.tracer.RecordError(ctx, err)
 	}
 
 	patient := models.Patient{
@@ -45,18 +52,30 @@ func (s PatientService) CreatePatient(ctx context.Context, name string, address
 		PhoneNumber:        phoneNumber,
 		Address:            address,
 		ExternalIdentifier: externalIdentifier,
+		Identifiers:        identifiers,
 		DateOfBirth:        dateOfBirth,
 	}
 
-	id, err := s.repo.InsertPatient(ctx, patient)
+	//the identifiers are checked and the patient inserted together, so no one else can be given them in between
+	err = s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		err := s.ensureIdentifiersUnique(ctx, 0, identifiers)
+		if err != nil {
+			return err
+		}
+		patient.Id, err = s.repo.InsertPatient(ctx, patient)
+		if err != nil {
+			return fmt.Errorf("error inserting patient %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return models.Patient{}, s.tracer.RecordError(ctx, fmt.Errorf("error inserting patient %w", err))
+		return models.Patient{}, s.tracer.RecordError(ctx, err)
 	}
-	patient.Id = id
 	return patient, nil
 }
 
-func (s PatientService) UpdatePatient(ctx context.Context, id int, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string) (models.Patient, error) {
+// identifiers replace the patient's existing ones.  When they are nil, the identifiers outside the external system are kept
+func (s PatientService) UpdatePatient(ctx context.Context, id int, name string, address string, phoneNumber string, dateOfBirth time.Time, externalIdentifier string, identifiers []models.PatientIdentifier) (models.Patient, error) {
 	ctx, span := s.tracer.NewSpan(ctx, "UpdatePatient")
 	defer span.End()
 	s.tracer.SetAttributes(ctx,
@@ -72,18 +91,42 @@ func (s PatientService) UpdatePatient(ctx context.Context, id int, name string,
 		return models.Patient{}, err
 	}
 
+	if identifiers == nil {
+		existing, err := s.repo.GetPatient(ctx, id)
+		if err != nil {
+			return models.Patient{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting patient %w", err))
+		}
+		identifiers = otherIdentifiers(existing.Identifiers)
+	}
+	identifiers, externalIdentifier, err = withExternalIdentifier(identifiers, externalIdentifier)
+	if err != nil {
+		return models.Patient{}, s.tracer.RecordError(ctx, err)
+	}
+
 	patient := models.Patient{
 		Name:               name,
 		Id:                 id,
 		Address:            address,
 		PhoneNumber:        phoneNumber,
 		ExternalIdentifier: externalIdentifier,
+		Identifiers:        identifiers,
 		DateOfBirth:        dateOfBirth,
 	}
 
-	err = s.repo.UpdatePatient(ctx, patient)
+	//as with creating a patient, the identifiers are checked and saved together
+	err = s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		err := s.ensureIdentifiersUnique(ctx, id, identifiers)
+		if err != nil {
+			return s.tracer.RecordError(ctx, err)
+		}
+		err = s.repo.UpdatePatient(ctx, patient)
+		if err != nil {
+			return fmt.Errorf("error updating patient %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return models.Patient{}, fmt.Errorf("error updating patient %w", err)
+		return models.Patient{}, err
 	}
 	return patient, nil
 }
@@ -98,14 +141,55 @@ func (s PatientService) DeletePatient(ctx context.Context, patientId int) error
 		return err
 	}
 
-	err = s.repo.DeletePatient(ctx, patientId)
+	//the patient and everything which depends on it are removed together, or not at all
+	err = s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		for _, deleteRecords := range s.dependents {
+			err := deleteRecords(ctx, patientId)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := s.repo.DeletePatient(ctx, patientId)
+		if err != nil {
+			return fmt.Errorf("error deleting patient %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return s.tracer.RecordError(ctx, err)
+	}
+
+	return nil
+}
+
+func (s PatientService) RestorePatient(ctx context.Context, patientId int) error {
+	ctx, span := s.tracer.NewSpan(ctx, "RestorePatient")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	err := s.repo.RestorePatient(ctx, patientId)
 	if err != nil {
-		return s.tracer.RecordError(ctx, fmt.Errorf("error deleting patient %w", err))
+		return s.tracer.RecordError(ctx, fmt.Errorf("error restoring patient %w", err))
 	}
 
 	return nil
 }
 
+// gets the patient along with their attatchments, diagnosed conditions and allergies, as returned by a search
+func (s PatientService) GetPatient(ctx context.Context, patientId int) (models.Patient, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetPatient")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	patient, err := s.repo.GetPatient(ctx, patientId)
+	if err != nil {
+		return models.Patient{}, s.tracer.RecordError(ctx, fmt.Errorf("error getting patient %w", err))
+	}
+
+	return patient, nil
+}
+
 func (s PatientService) SearchPatients(ctx context.Context, search models.PatientSearch) ([]models.Patient, error) {
 	ctx, span := s.tracer.NewSpan(ctx, "SearchPatients")
 	defer span.End()
@@ -114,11 +198,68 @@ func (s PatientService) SearchPatients(ctx context.Context, search models.Patien
 		attribute.String("search.attatchmentName", search.AttatchmentName),
 		attribute.String("search.attatchmentType", search.AttatchmentType),
 		attribute.String("search.diagnosedConditionCode", search.DiagnosedConditionCode),
+		attribute.String("search.diagnosedConditionCodeSystem", search.DiagnosedConditionCodeSystem),
+		attribute.Bool("search.diagnosedConditionActive", search.DiagnosedConditionActive),
 		attribute.String("search.diagnosedConditionName", search.DiagnosedConditionName),
+		attribute.String("search.diagnosedConditionCodePrefix", search.DiagnosedConditionCodePrefix),
+		attribute.String("search.diagnosedConditionCodeRange", search.DiagnosedConditionCodeRange),
 		attribute.String("search.name", search.Name),
+		attribute.String("search.namePart", search.NamePart),
+		attribute.String("search.birthDate", search.BirthDate),
 		attribute.String("search.phone", search.Phone),
+		attribute.String("search.identifier", search.Identifier),
+		attribute.String("search.studyDate", search.StudyDate),
+		attribute.String("search.modality", search.Modality),
+		attribute.String("search.bodyPart", search.BodyPart),
+		attribute.String("search.studyInstanceUid", search.StudyInstanceUid),
+		attribute.String("search.seriesInstanceUid", search.SeriesInstanceUid),
+		attribute.String("search.allergen", search.Allergen),
 	)
 
+	if search.Identifier != "" {
+		system, value, found := strings.Cut(search.Identifier, "|")
+		if !found {
+			system, value = "", search.Identifier
+		}
+		if (found && system == "") || value == "" {
+			return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a valid identifier, expected system|value or a value", search.Identifier)))
+		}
+		search.IdentifierSystem, search.IdentifierValue = system, value
+	}
+
+	if search.BirthDate != "" {
+		if _, err := time.Parse(time.DateOnly, search.BirthDate); err != nil {
+			return nil, s.tracer.RecordError(ctx, customerrors.NewInvalidInputError(fmt.Sprintf("%q is not a valid birth date, expected the format 2006-01-02", search.BirthDate)))
+		}
+	}
+
+	var codings []models.Coding
+	if search.DiagnosedConditionCode != "" {
+		system := search.DiagnosedConditionCodeSystem
+		if system == "" {
+			system = models.CodeSystemICD10CM
+		}
+		codings = append(codings, models.Coding{System: system, Code: search.DiagnosedConditionCode})
+	}
+	if search.DiagnosedConditionCodePrefix != "" {
+		codes, err := s.codeSvc.Descendants(ctx, search.DiagnosedConditionCodePrefix)
+		if err != nil {
+			return nil, s.tracer.RecordError(ctx, err)
+		}
+		codings = append(codings, icd10Codings(codes)...)
+	}
+	if search.DiagnosedConditionCodeRange != "" {
+		codes, err := s.codeSvc.Range(ctx, search.DiagnosedConditionCodeRange)
+		if err != nil {
+			return nil, s.tracer.RecordError(ctx, err)
+		}
+		codings = append(codings, icd10Codings(codes)...)
+	}
+	search.DiagnosedConditionCodings = nil
+	if len(codings) > 0 {
+		search.DiagnosedConditionCodings = s.codeSvc.Expand(ctx, codings)
+	}
+
 	patients, err := s.repo.SearchPatients(ctx, search)
 	if err != nil {
 		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error searching patients %w", err))
@@ -127,6 +268,14 @@ func (s PatientService) SearchPatients(ctx context.Context, search models.Patien
 	return patients, nil
 }
 
+func icd10Codings(codes []string) []models.Coding {
+	codings := make([]models.Coding, len(codes))
+	for i, code := range codes {
+		codings[i] = models.Coding{System: models.CodeSystemICD10CM, Code: code}
+	}
+	return codings
+}
+
 func (s PatientService) ValidatePatientId(ctx context.Context, patientId int) error {
 	count, err := s.repo.GetCountOfPatientId(ctx, patientId)
 	if err != nil {
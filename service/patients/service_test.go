@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"mcg-app-backend/service/customerrors"
 	"mcg-app-backend/service/models"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
@@ -20,9 +22,9 @@ type MockPatientRepo struct {
 	mock.Mock
 }
 
-func (m *MockPatientRepo) GetCountOfExternalIdentifier(ctx context.Context, externalIdentifier string) (int, error) {
-	args := m.Called(ctx, externalIdentifier)
-	return args.Int(0), args.Error(1)
+func (m *MockPatientRepo) GetPatientIdsWithIdentifier(ctx context.Context, identifier models.PatientIdentifier) ([]int, error) {
+	args := m.Called(ctx, identifier)
+	return args.Get(0).([]int), args.Error(1)
 }
 
 func (m *MockPatientRepo) InsertPatient(ctx context.Context, patient models.Patient) (int, error) {
@@ -40,16 +42,68 @@ func (m *MockPatientRepo) DeletePatient(ctx context.Context, patientId int) erro
 	return args.Error(0)
 }
 
+func (m *MockPatientRepo) RestorePatient(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
 func (m *MockPatientRepo) SearchPatients(ctx context.Context, search models.PatientSearch) ([]models.Patient, error) {
 	args := m.Called(ctx, search)
 	return args.Get(0).([]models.Patient), args.Error(1)
 }
 
+func (m *MockPatientRepo) GetPatient(ctx context.Context, patientId int) (models.Patient, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).(models.Patient), args.Error(1)
+}
+
 func (m *MockPatientRepo) GetCountOfPatientId(ctx context.Context, patientId int) (int, error) {
 	args := m.Called(ctx, patientId)
 	return args.Int(0), args.Error(1)
 }
 
+func (m *MockPatientRepo) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.Called(ctx)
+	return fn(ctx)
+}
+
+type MockAttachmentService struct {
+	mock.Mock
+}
+
+func (m *MockAttachmentService) DeletePatientAttachments(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockDiagnosedConditionService struct {
+	mock.Mock
+}
+
+func (m *MockDiagnosedConditionService) DeletePatientDiagnosedConditions(ctx context.Context, patientId int) error {
+	args := m.Called(ctx, patientId)
+	return args.Error(0)
+}
+
+type MockCodeService struct {
+	mock.Mock
+}
+
+func (m *MockCodeService) Descendants(ctx context.Context, prefix string) ([]string, error) {
+	args := m.Called(ctx, prefix)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockCodeService) Range(ctx context.Context, codeRange string) ([]string, error) {
+	args := m.Called(ctx, codeRange)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockCodeService) Expand(ctx context.Context, codings []models.Coding) []models.Coding {
+	args := m.Called(ctx, codings)
+	return args.Get(0).([]models.Coding)
+}
+
 type MockTracer struct {
 	mock.Mock
 }
@@ -64,36 +118,130 @@ func (m *MockTracer) RecordError(ctx context.Context, err error) error {
 	return err
 }
 
+// runs one transaction at a time like a real repo, and is slow to find identifiers, so creates which checked them
+// outside of a transaction would both find them free
+type TransactionalPatientRepo struct {
+	*MockPatientRepo
+	transactionMutex sync.Mutex
+	mutex            sync.Mutex
+	patients         []models.Patient
+}
+
+func (r *TransactionalPatientRepo) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	r.transactionMutex.Lock()
+	defer r.transactionMutex.Unlock()
+	return fn(ctx)
+}
+
+func (r *TransactionalPatientRepo) GetPatientIdsWithIdentifier(ctx context.Context, identifier models.PatientIdentifier) ([]int, error) {
+	r.mutex.Lock()
+	ids := []int{}
+	for _, patient := range r.patients {
+		if slices.Contains(patient.Identifiers, identifier) {
+			ids = append(ids, patient.Id)
+		}
+	}
+	r.mutex.Unlock()
+	time.Sleep(time.Millisecond * 10)
+	return ids, nil
+}
+
+func (r *TransactionalPatientRepo) InsertPatient(ctx context.Context, patient models.Patient) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	patient.Id = len(r.patients) + 1
+	r.patients = append(r.patients, patient)
+	return patient.Id, nil
+}
+
 func getMocksAndService() (*MockPatientRepo, PatientService) {
 	mockRepo := new(MockPatientRepo)
 	mockTracer := new(MockTracer)
-	service := NewPatientService(mockRepo, mockTracer)
+	service := NewPatientService(mockRepo, new(MockCodeService), mockTracer)
 	return mockRepo, service
 }
 
+func getMocksAndServiceWithDependents() (*MockPatientRepo, *MockAttachmentService, *MockDiagnosedConditionService, PatientService) {
+	mockRepo, service := getMocksAndService()
+	mockAttachmentSvc := new(MockAttachmentService)
+	mockConditionSvc := new(MockDiagnosedConditionService)
+	service = service.WithDependentServices(mockAttachmentSvc.DeletePatientAttachments, mockConditionSvc.DeletePatientDiagnosedConditions)
+	return mockRepo, mockAttachmentSvc, mockConditionSvc, service
+}
+
 func TestCreatePatient(t *testing.T) {
 	name := "John Doe"
 	address := "123 Main St"
 	phoneNumber := "1234567890"
 	externalIdentifier := "unique123"
+	external := models.PatientIdentifier{System: models.IdentifierSystemExternal, Value: externalIdentifier}
+	ssn := models.PatientIdentifier{System: "http://hl7.org/fhir/sid/us-ssn", Value: "123-45-6789"}
 
 	t.Run("CreatePatient_Success", func(t *testing.T) {
 		mockRepo, service := getMocksAndService()
-		mockRepo.On("GetCountOfExternalIdentifier", mock.Anything, externalIdentifier).Return(0, nil)
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, external).Return([]int{}, nil)
 		mockRepo.On("InsertPatient", mock.Anything, mock.AnythingOfType("models.Patient")).Return(1, nil)
 
-		patient, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), externalIdentifier)
+		patient, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), externalIdentifier, nil)
 		assert.Nil(t, err)
 		assert.Equal(t, name, patient.Name)
+		assert.Equal(t, []models.PatientIdentifier{external}, patient.Identifiers)
 
 		mockRepo.AssertExpectations(t)
 	})
 
+	t.Run("CreatePatient_WithIdentifiers", func(t *testing.T) {
+		mockRepo, service := getMocksAndService()
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, mock.Anything).Return([]int{}, nil)
+		mockRepo.On("InsertPatient", mock.Anything, mock.AnythingOfType("models.Patient")).Return(1, nil)
+
+		patient, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), externalIdentifier, []models.PatientIdentifier{ssn})
+		assert.Nil(t, err)
+		assert.Equal(t, externalIdentifier, patient.ExternalIdentifier)
+		assert.Equal(t, []models.PatientIdentifier{external, ssn}, patient.Identifiers)
+
+		mockRepo.AssertNumberOfCalls(t, "GetPatientIdsWithIdentifier", 2)
+	})
+
+	t.Run("CreatePatient_ExternalIdentifierFromIdentifiers", func(t *testing.T) {
+		mockRepo, service := getMocksAndService()
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, mock.Anything).Return([]int{}, nil)
+		mockRepo.On("InsertPatient", mock.Anything, mock.AnythingOfType("models.Patient")).Return(1, nil)
+
+		patient, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), "", []models.PatientIdentifier{ssn, external})
+		assert.Nil(t, err)
+		assert.Equal(t, externalIdentifier, patient.ExternalIdentifier)
+	})
+
+	t.Run("CreatePatient_InvalidIdentifiers", func(t *testing.T) {
+		_, service := getMocksAndService()
+
+		for _, test := range []struct {
+			externalIdentifier string
+			identifiers        []models.PatientIdentifier
+			message            string
+		}{
+			{"", nil, "patients require at least one identifier"},
+			{"", []models.PatientIdentifier{{System: "", Value: "1"}}, "identifiers require both a system and a value"},
+			{"", []models.PatientIdentifier{{System: "a|b", Value: "1"}}, `identifier system "a|b" cannot contain |`},
+			{"", []models.PatientIdentifier{ssn, ssn}, "identifier http://hl7.org/fhir/sid/us-ssn|123-45-6789 is listed more than once"},
+			{"", []models.PatientIdentifier{external, {System: models.IdentifierSystemExternal, Value: "other"}}, "patients can only have one identifier in the external system"},
+			{"other", []models.PatientIdentifier{external}, "externalIdentifier does not match the identifier in the external system"},
+		} {
+			_, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), test.externalIdentifier, test.identifiers)
+			assert.Equal(t, customerrors.NewInvalidInputError(test.message), err)
+		}
+	})
+
 	t.Run("CreatePatient_ExternalIdentifierAlreadyExists", func(t *testing.T) {
 		mockRepo, service := getMocksAndService()
-		mockRepo.On("GetCountOfExternalIdentifier", mock.Anything, externalIdentifier).Return(1, nil)
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, external).Return([]int{2}, nil)
 
-		patient, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), externalIdentifier)
+		patient, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), externalIdentifier, nil)
 		assert.NotNil(t, err)
 		assert.Empty(t, patient)
 		var alreadyExists customerrors.AlreadyExistsError
@@ -102,11 +250,24 @@ func TestCreatePatient(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 
-	t.Run("CreatePatient_RepoError_GetCountOfExternalIdentifier", func(t *testing.T) {
+	t.Run("CreatePatient_IdentifierAlreadyExists", func(t *testing.T) {
+		mockRepo, service := getMocksAndService()
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, external).Return([]int{}, nil)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, ssn).Return([]int{2}, nil)
+
+		_, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), externalIdentifier, []models.PatientIdentifier{ssn})
+		assert.Equal(t, customerrors.NewAlreadyExistsError("patient with matching identifier http://hl7.org/fhir/sid/us-ssn|123-45-6789 already exists"), err)
+
+		mockRepo.AssertNotCalled(t, "InsertPatient", mock.Anything, mock.Anything)
+	})
+
+	t.Run("CreatePatient_RepoError_GetPatientIdsWithIdentifier", func(t *testing.T) {
 		mockRepo, service := getMocksAndService()
-		mockRepo.On("GetCountOfExternalIdentifier", mock.Anything, externalIdentifier).Return(0, fmt.Errorf("db error"))
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, external).Return([]int{}, fmt.Errorf("db error"))
 
-		patient, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), externalIdentifier)
+		patient, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), externalIdentifier, nil)
 		assert.NotNil(t, err)
 		assert.Empty(t, patient)
 
@@ -115,10 +276,11 @@ func TestCreatePatient(t *testing.T) {
 
 	t.Run("CreatePatient_RepoError_InsertPatient", func(t *testing.T) {
 		mockRepo, service := getMocksAndService()
-		mockRepo.On("GetCountOfExternalIdentifier", mock.Anything, externalIdentifier).Return(0, nil)
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, external).Return([]int{}, nil)
 		mockRepo.On("InsertPatient", mock.Anything, mock.AnythingOfType("models.Patient")).Return(0, fmt.Errorf("db error"))
 
-		patient, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), externalIdentifier)
+		patient, err := service.CreatePatient(context.Background(), name, address, phoneNumber, time.Now(), externalIdentifier, nil)
 		assert.NotNil(t, err)
 		assert.Empty(t, patient)
 
@@ -126,31 +288,93 @@ func TestCreatePatient(t *testing.T) {
 	})
 }
 
+func TestCreatePatient_Concurrent(t *testing.T) {
+	repo := &TransactionalPatientRepo{MockPatientRepo: new(MockPatientRepo)}
+	service := NewPatientService(repo, new(MockCodeService), new(MockTracer))
+	mrn := models.PatientIdentifier{System: "urn:oid:2.16.840.1.113883.19.5", Value: "MRN001"}
+
+	errs := make(chan error, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.CreatePatient(context.Background(), "John Doe", "123 Main St", "1234567890", time.Now(), "", []models.PatientIdentifier{mrn})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.Equal(t, customerrors.NewAlreadyExistsError("patient with matching identifier urn:oid:2.16.840.1.113883.19.5|MRN001 already exists"), err)
+	}
+	assert.Equal(t, 1, created)
+	assert.Equal(t, 1, len(repo.patients))
+}
+
 func TestUpdatePatient(t *testing.T) {
+	external := models.PatientIdentifier{System: models.IdentifierSystemExternal, Value: "unique123"}
+	mrn := models.PatientIdentifier{System: "urn:oid:2.16.840.1.113883.19.5", Value: "MRN001"}
 	patient := models.Patient{
-		Id:          1,
-		Name:        "Jane Doe",
-		PhoneNumber: "9876543210",
-		Address:     "456 Elm St",
+		Id:                 1,
+		Name:               "Jane Doe",
+		PhoneNumber:        "9876543210",
+		Address:            "456 Elm St",
+		ExternalIdentifier: "unique123",
+		Identifiers:        []models.PatientIdentifier{external},
 	}
 
 	t.Run("UpdatePatient_Success", func(t *testing.T) {
 		mockRepo, service := getMocksAndService()
+		mockRepo.On("RunInTransaction", mock.Anything)
 		mockRepo.On("GetCountOfPatientId", mock.Anything, patient.Id).Return(1, nil) // Mocking the patient ID count to be 1 (valid)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, external).Return([]int{patient.Id}, nil)
 		mockRepo.On("UpdatePatient", mock.Anything, patient).Return(nil)
 
-		updatedPatient, err := service.UpdatePatient(context.Background(), patient.Id, patient.Name, patient.Address, patient.PhoneNumber, patient.DateOfBirth, patient.ExternalIdentifier)
+		updatedPatient, err := service.UpdatePatient(context.Background(), patient.Id, patient.Name, patient.Address, patient.PhoneNumber, patient.DateOfBirth, patient.ExternalIdentifier, patient.Identifiers)
 		assert.Nil(t, err)
 		assert.Equal(t, patient.Name, updatedPatient.Name)
 
 		mockRepo.AssertExpectations(t)
 	})
 
+	t.Run("UpdatePatient_KeepsOtherIdentifiersWhenNoneAreSent", func(t *testing.T) {
+		mockRepo, service := getMocksAndService()
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockRepo.On("GetCountOfPatientId", mock.Anything, patient.Id).Return(1, nil)
+		mockRepo.On("GetPatient", mock.Anything, patient.Id).Return(models.Patient{Id: patient.Id, Identifiers: []models.PatientIdentifier{{System: models.IdentifierSystemExternal, Value: "old"}, mrn}}, nil)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, mock.Anything).Return([]int{}, nil)
+		mockRepo.On("UpdatePatient", mock.Anything, mock.AnythingOfType("models.Patient")).Return(nil)
+
+		updatedPatient, err := service.UpdatePatient(context.Background(), patient.Id, patient.Name, patient.Address, patient.PhoneNumber, patient.DateOfBirth, patient.ExternalIdentifier, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []models.PatientIdentifier{external, mrn}, updatedPatient.Identifiers)
+	})
+
+	t.Run("UpdatePatient_IdentifierHeldByAnotherPatient", func(t *testing.T) {
+		mockRepo, service := getMocksAndService()
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockRepo.On("GetCountOfPatientId", mock.Anything, patient.Id).Return(1, nil)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, external).Return([]int{patient.Id}, nil)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, mrn).Return([]int{2}, nil)
+
+		_, err := service.UpdatePatient(context.Background(), patient.Id, patient.Name, patient.Address, patient.PhoneNumber, patient.DateOfBirth, "", []models.PatientIdentifier{external, mrn})
+		assert.Equal(t, customerrors.NewAlreadyExistsError("patient with matching identifier urn:oid:2.16.840.1.113883.19.5|MRN001 already exists"), err)
+
+		mockRepo.AssertNotCalled(t, "UpdatePatient", mock.Anything, mock.Anything)
+	})
+
 	t.Run("UpdatePatient_InvalidPatientId", func(t *testing.T) {
 		mockRepo, service := getMocksAndService()
 		mockRepo.On("GetCountOfPatientId", mock.Anything, patient.Id).Return(0, nil) // Mocking the patient ID count to be 0 (invalid)
 
-		updatedPatient, err := service.UpdatePatient(context.Background(), patient.Id, patient.Name, patient.Address, patient.PhoneNumber, patient.DateOfBirth, patient.ExternalIdentifier)
+		updatedPatient, err := service.UpdatePatient(context.Background(), patient.Id, patient.Name, patient.Address, patient.PhoneNumber, patient.DateOfBirth, patient.ExternalIdentifier, patient.Identifiers)
 		assert.NotNil(t, err)
 		assert.Equal(t, "patient id not found", err.Error())
 		assert.Empty(t, updatedPatient)
@@ -160,10 +384,12 @@ func TestUpdatePatient(t *testing.T) {
 
 	t.Run("UpdatePatient_RepoError", func(t *testing.T) {
 		mockRepo, service := getMocksAndService()
+		mockRepo.On("RunInTransaction", mock.Anything)
 		mockRepo.On("GetCountOfPatientId", mock.Anything, patient.Id).Return(1, nil)
+		mockRepo.On("GetPatientIdsWithIdentifier", mock.Anything, external).Return([]int{patient.Id}, nil)
 		mockRepo.On("UpdatePatient", mock.Anything, patient).Return(fmt.Errorf("db error"))
 
-		updatedPatient, err := service.UpdatePatient(context.Background(), patient.Id, patient.Name, patient.Address, patient.PhoneNumber, patient.DateOfBirth, patient.ExternalIdentifier)
+		updatedPatient, err := service.UpdatePatient(context.Background(), patient.Id, patient.Name, patient.Address, patient.PhoneNumber, patient.DateOfBirth, patient.ExternalIdentifier, patient.Identifiers)
 		assert.NotNil(t, err)
 		assert.Equal(t, "error updating patient db error", err.Error())
 		assert.Empty(t, updatedPatient)
@@ -176,18 +402,23 @@ func TestDeletePatient(t *testing.T) {
 	patientId := 1
 
 	t.Run("DeletePatient_Success", func(t *testing.T) {
-		mockRepo, service := getMocksAndService()
+		mockRepo, mockAttachmentSvc, mockConditionSvc, service := getMocksAndServiceWithDependents()
 		mockRepo.On("GetCountOfPatientId", mock.Anything, patientId).Return(1, nil) // Mocking the patient ID count to be 1 (valid)
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockAttachmentSvc.On("DeletePatientAttachments", mock.Anything, patientId).Return(nil)
+		mockConditionSvc.On("DeletePatientDiagnosedConditions", mock.Anything, patientId).Return(nil)
 		mockRepo.On("DeletePatient", mock.Anything, patientId).Return(nil)
 
 		err := service.DeletePatient(context.Background(), patientId)
 		assert.Nil(t, err)
 
 		mockRepo.AssertExpectations(t)
+		mockAttachmentSvc.AssertExpectations(t)
+		mockConditionSvc.AssertExpectations(t)
 	})
 
 	t.Run("DeletePatient_InvalidPatientId", func(t *testing.T) {
-		mockRepo, service := getMocksAndService()
+		mockRepo, mockAttachmentSvc, mockConditionSvc, service := getMocksAndServiceWithDependents()
 		mockRepo.On("GetCountOfPatientId", mock.Anything, patientId).Return(0, nil) // Mocking the patient ID count to be 0 (invalid)
 
 		err := service.DeletePatient(context.Background(), patientId)
@@ -195,11 +426,46 @@ func TestDeletePatient(t *testing.T) {
 		assert.Equal(t, "patient id not found", err.Error())
 
 		mockRepo.AssertExpectations(t)
+		mockAttachmentSvc.AssertExpectations(t)
+		mockConditionSvc.AssertExpectations(t)
+	})
+
+	t.Run("DeletePatient_AttachmentError", func(t *testing.T) {
+		mockRepo, mockAttachmentSvc, mockConditionSvc, service := getMocksAndServiceWithDependents()
+		mockRepo.On("GetCountOfPatientId", mock.Anything, patientId).Return(1, nil)
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockAttachmentSvc.On("DeletePatientAttachments", mock.Anything, patientId).Return(fmt.Errorf("error deleting attachments for patient db error"))
+
+		err := service.DeletePatient(context.Background(), patientId)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error deleting attachments for patient db error", err.Error())
+
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "DeletePatient", mock.Anything, patientId)
+		mockConditionSvc.AssertNotCalled(t, "DeletePatientDiagnosedConditions", mock.Anything, patientId)
+	})
+
+	t.Run("DeletePatient_ConditionError", func(t *testing.T) {
+		mockRepo, mockAttachmentSvc, mockConditionSvc, service := getMocksAndServiceWithDependents()
+		mockRepo.On("GetCountOfPatientId", mock.Anything, patientId).Return(1, nil)
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockAttachmentSvc.On("DeletePatientAttachments", mock.Anything, patientId).Return(nil)
+		mockConditionSvc.On("DeletePatientDiagnosedConditions", mock.Anything, patientId).Return(fmt.Errorf("error deleting diagnosed conditions for patient db error"))
+
+		err := service.DeletePatient(context.Background(), patientId)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error deleting diagnosed conditions for patient db error", err.Error())
+
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "DeletePatient", mock.Anything, patientId)
 	})
 
 	t.Run("DeletePatient_RepoError", func(t *testing.T) {
-		mockRepo, service := getMocksAndService()
+		mockRepo, mockAttachmentSvc, mockConditionSvc, service := getMocksAndServiceWithDependents()
 		mockRepo.On("GetCountOfPatientId", mock.Anything, patientId).Return(1, nil)
+		mockRepo.On("RunInTransaction", mock.Anything)
+		mockAttachmentSvc.On("DeletePatientAttachments", mock.Anything, patientId).Return(nil)
+		mockConditionSvc.On("DeletePatientDiagnosedConditions", mock.Anything, patientId).Return(nil)
 		mockRepo.On("DeletePatient", mock.Anything, patientId).Return(fmt.Errorf("db error"))
 
 		err := service.DeletePatient(context.Background(), patientId)
@@ -209,3 +475,128 @@ func TestDeletePatient(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestRestorePatient(t *testing.T) {
+	patientId := 1
+
+	t.Run("RestorePatient_Success", func(t *testing.T) {
+		mockRepo, service := getMocksAndService()
+		mockRepo.On("RestorePatient", mock.Anything, patientId).Return(nil)
+
+		err := service.RestorePatient(context.Background(), patientId)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("RestorePatient_RepoError", func(t *testing.T) {
+		mockRepo, service := getMocksAndService()
+		mockRepo.On("RestorePatient", mock.Anything, patientId).Return(customerrors.NewAlreadyExistsError("patient with matching externalIdentifier already exists"))
+
+		err := service.RestorePatient(context.Background(), patientId)
+		assert.NotNil(t, err)
+		var alreadyExists customerrors.AlreadyExistsError
+		assert.True(t, errors.As(err, &alreadyExists))
+
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestSearchPatients(t *testing.T) {
+	getMocksAndServiceWithCodes := func() (*MockPatientRepo, *MockCodeService, PatientService) {
+		mockRepo := new(MockPatientRepo)
+		mockCodeSvc := new(MockCodeService)
+		return mockRepo, mockCodeSvc, NewPatientService(mockRepo, mockCodeSvc, new(MockTracer))
+	}
+
+	t.Run("SearchPatients_ResolvesCodes", func(t *testing.T) {
+		mockRepo, mockCodeSvc, service := getMocksAndServiceWithCodes()
+		search := models.PatientSearch{
+			DiagnosedConditionCode:       "44054006",
+			DiagnosedConditionCodeSystem: models.CodeSystemSNOMEDCT,
+			DiagnosedConditionCodePrefix: "E11",
+			DiagnosedConditionCodeRange:  "I10-I11",
+		}
+		codings := []models.Coding{
+			{System: models.CodeSystemSNOMEDCT, Code: "44054006"},
+			{System: models.CodeSystemICD10CM, Code: "E11"},
+			{System: models.CodeSystemICD10CM, Code: "E11.9"},
+			{System: models.CodeSystemICD10CM, Code: "I10"},
+			{System: models.CodeSystemICD10CM, Code: "I11.9"},
+		}
+		expanded := append(codings, models.Coding{System: models.CodeSystemSNOMEDCT, Code: "38341003"})
+		expectedSearch := search
+		expectedSearch.DiagnosedConditionCodings = expanded
+		expected := []models.Patient{{Id: 1}}
+		mockCodeSvc.On("Descendants", mock.Anything, "E11").Return([]string{"E11", "E11.9"}, nil)
+		mockCodeSvc.On("Range", mock.Anything, "I10-I11").Return([]string{"I10", "I11.9"}, nil)
+		mockCodeSvc.On("Expand", mock.Anything, codings).Return(expanded)
+		mockRepo.On("SearchPatients", mock.Anything, expectedSearch).Return(expected, nil)
+
+		patients, err := service.SearchPatients(context.Background(), search)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, patients)
+
+		mockRepo.AssertExpectations(t)
+		mockCodeSvc.AssertExpectations(t)
+	})
+
+	t.Run("SearchPatients_InvalidCodeRange", func(t *testing.T) {
+		mockRepo, mockCodeSvc, service := getMocksAndServiceWithCodes()
+		mockCodeSvc.On("Range", mock.Anything, "E13-E08").Return([]string(nil), fmt.Errorf("range E13-E08 ends before it starts"))
+
+		patients, err := service.SearchPatients(context.Background(), models.PatientSearch{DiagnosedConditionCodeRange: "E13-E08"})
+		assert.NotNil(t, err)
+		assert.Equal(t, "range E13-E08 ends before it starts", err.Error())
+		assert.Nil(t, patients)
+
+		mockRepo.AssertExpectations(t)
+		mockCodeSvc.AssertExpectations(t)
+	})
+
+	t.Run("SearchPatients_InvalidBirthDate", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndServiceWithCodes()
+
+		_, err := service.SearchPatients(context.Background(), models.PatientSearch{BirthDate: "01/02/1990"})
+		assert.NotNil(t, err)
+		assert.Equal(t, `"01/02/1990" is not a valid birth date, expected the format 2006-01-02`, err.Error())
+
+		mockRepo.AssertNotCalled(t, "SearchPatients", mock.Anything, mock.Anything)
+	})
+
+	t.Run("SearchPatients_SplitsIdentifier", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndServiceWithCodes()
+		withSystem := models.PatientSearch{Identifier: "urn:oid:2.16.840.1.113883.19.5|MRN|001", IdentifierSystem: "urn:oid:2.16.840.1.113883.19.5", IdentifierValue: "MRN|001"}
+		anySystem := models.PatientSearch{Identifier: "MRN001", IdentifierValue: "MRN001"}
+		mockRepo.On("SearchPatients", mock.Anything, withSystem).Return([]models.Patient{{Id: 1}}, nil)
+		mockRepo.On("SearchPatients", mock.Anything, anySystem).Return([]models.Patient{{Id: 2}}, nil)
+
+		patients, err := service.SearchPatients(context.Background(), models.PatientSearch{Identifier: withSystem.Identifier})
+		assert.Nil(t, err)
+		assert.Equal(t, []models.Patient{{Id: 1}}, patients)
+		patients, err = service.SearchPatients(context.Background(), models.PatientSearch{Identifier: anySystem.Identifier})
+		assert.Nil(t, err)
+		assert.Equal(t, []models.Patient{{Id: 2}}, patients)
+	})
+
+	t.Run("SearchPatients_InvalidIdentifier", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndServiceWithCodes()
+
+		_, err := service.SearchPatients(context.Background(), models.PatientSearch{Identifier: "|MRN001"})
+		assert.Equal(t, customerrors.NewInvalidInputError(`"|MRN001" is not a valid identifier, expected system|value or a value`), err)
+
+		mockRepo.AssertNotCalled(t, "SearchPatients", mock.Anything, mock.Anything)
+	})
+
+	t.Run("SearchPatients_RepoError", func(t *testing.T) {
+		mockRepo, _, service := getMocksAndServiceWithCodes()
+		mockRepo.On("SearchPatients", mock.Anything, models.PatientSearch{Name: "John"}).Return([]models.Patient(nil), fmt.Errorf("db error"))
+
+		patients, err := service.SearchPatients(context.Background(), models.PatientSearch{Name: "John"})
+		assert.NotNil(t, err)
+		assert.Equal(t, "error searching patients db error", err.Error())
+		assert.Nil(t, patients)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,25 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type RetentionRepo interface {
+	PurgeDeletedPatients(ctx context.Context, deletedBefore time.Time) (int, error)
+	PurgeDeletedAttatchments(ctx context.Context, deletedBefore time.Time) (int, error)
+	PurgeDeletedDiagnosedConditions(ctx context.Context, deletedBefore time.Time) (int, error)
+	PurgeDeletedMedications(ctx context.Context, deletedBefore time.Time) (int, error)
+	PurgeDeletedAllergies(ctx context.Context, deletedBefore time.Time) (int, error)
+	PurgeDeletedObservations(ctx context.Context, deletedBefore time.Time) (int, error)
+	PurgeDeletedEncounters(ctx context.Context, deletedBefore time.Time) (int, error)
+}
+
+type Tracer interface {
+	NewSpan(ctx context.Context, name string) (context.Context, trace.Span)
+	SetAttributes(ctx context.Context, attrs ...attribute.KeyValue)
+	RecordError(ctx context.Context, err error) error
+}
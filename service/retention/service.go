@@ -0,0 +1,96 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type Service struct {
+	repo            RetentionRepo
+	tracer          Tracer
+	retentionPeriod time.Duration
+}
+
+func NewService(repo RetentionRepo, tracer Tracer, retentionPeriod time.Duration) Service {
+	return Service{
+		repo:            repo,
+		tracer:          tracer,
+		retentionPeriod: retentionPeriod,
+	}
+}
+
+// permanently removes records which were soft deleted longer ago than the retention period
+func (s Service) Purge(ctx context.Context, now time.Time) error {
+	ctx, span := s.tracer.NewSpan(ctx, "PurgeDeletedRecords")
+	defer span.End()
+	cutoff := now.Add(-s.retentionPeriod)
+	s.tracer.SetAttributes(ctx, attribute.String("cutoff", fmt.Sprintf("%v", cutoff)))
+
+	conditions, err := s.repo.PurgeDeletedDiagnosedConditions(ctx, cutoff)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error purging diagnosed conditions %w", err))
+	}
+
+	medications, err := s.repo.PurgeDeletedMedications(ctx, cutoff)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error purging medications %w", err))
+	}
+
+	allergies, err := s.repo.PurgeDeletedAllergies(ctx, cutoff)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error purging allergies %w", err))
+	}
+
+	observations, err := s.repo.PurgeDeletedObservations(ctx, cutoff)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error purging observations %w", err))
+	}
+
+	encounters, err := s.repo.PurgeDeletedEncounters(ctx, cutoff)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error purging encounters %w", err))
+	}
+
+	attachments, err := s.repo.PurgeDeletedAttatchments(ctx, cutoff)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error purging attachments %w", err))
+	}
+
+	patients, err := s.repo.PurgeDeletedPatients(ctx, cutoff)
+	if err != nil {
+		return s.tracer.RecordError(ctx, fmt.Errorf("error purging patients %w", err))
+	}
+
+	s.tracer.SetAttributes(ctx,
+		attribute.Int("purged.diagnosedConditions", conditions),
+		attribute.Int("purged.medications", medications),
+		attribute.Int("purged.allergies", allergies),
+		attribute.Int("purged.observations", observations),
+		attribute.Int("purged.encounters", encounters),
+		attribute.Int("purged.attachments", attachments),
+		attribute.Int("purged.patients", patients))
+	return nil
+}
+
+// runs Purge every interval until ctx is cancelled.  A failed purge is passed to onError and tried again at the next
+// interval
+func (s Service) Start(ctx context.Context, interval time.Duration, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				err := s.Purge(ctx, now)
+				if err != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,168 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockRetentionRepo struct {
+	mock.Mock
+}
+
+func (m *MockRetentionRepo) PurgeDeletedPatients(ctx context.Context, deletedBefore time.Time) (int, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRetentionRepo) PurgeDeletedAttatchments(ctx context.Context, deletedBefore time.Time) (int, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRetentionRepo) PurgeDeletedDiagnosedConditions(ctx context.Context, deletedBefore time.Time) (int, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRetentionRepo) PurgeDeletedMedications(ctx context.Context, deletedBefore time.Time) (int, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRetentionRepo) PurgeDeletedAllergies(ctx context.Context, deletedBefore time.Time) (int, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRetentionRepo) PurgeDeletedObservations(ctx context.Context, deletedBefore time.Time) (int, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRetentionRepo) PurgeDeletedEncounters(ctx context.Context, deletedBefore time.Time) (int, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Int(0), args.Error(1)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService(retentionPeriod time.Duration) (*MockRetentionRepo, Service) {
+	mockRepo := new(MockRetentionRepo)
+	service := NewService(mockRepo, new(MockTracer), retentionPeriod)
+	return mockRepo, service
+}
+
+func TestPurge(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	retentionPeriod := 30 * 24 * time.Hour
+	cutoff := now.Add(-retentionPeriod)
+
+	t.Run("Purge_Success", func(t *testing.T) {
+		mockRepo, service := getMocksAndService(retentionPeriod)
+		mockRepo.On("PurgeDeletedDiagnosedConditions", mock.Anything, cutoff).Return(2, nil)
+		mockRepo.On("PurgeDeletedMedications", mock.Anything, cutoff).Return(3, nil)
+		mockRepo.On("PurgeDeletedAllergies", mock.Anything, cutoff).Return(1, nil)
+		mockRepo.On("PurgeDeletedObservations", mock.Anything, cutoff).Return(5, nil)
+		mockRepo.On("PurgeDeletedEncounters", mock.Anything, cutoff).Return(1, nil)
+		mockRepo.On("PurgeDeletedAttatchments", mock.Anything, cutoff).Return(1, nil)
+		mockRepo.On("PurgeDeletedPatients", mock.Anything, cutoff).Return(1, nil)
+
+		err := service.Purge(context.Background(), now)
+		assert.Nil(t, err)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Purge_AttachmentError", func(t *testing.T) {
+		mockRepo, service := getMocksAndService(retentionPeriod)
+		mockRepo.On("PurgeDeletedDiagnosedConditions", mock.Anything, cutoff).Return(0, nil)
+		mockRepo.On("PurgeDeletedMedications", mock.Anything, cutoff).Return(0, nil)
+		mockRepo.On("PurgeDeletedAllergies", mock.Anything, cutoff).Return(0, nil)
+		mockRepo.On("PurgeDeletedObservations", mock.Anything, cutoff).Return(0, nil)
+		mockRepo.On("PurgeDeletedEncounters", mock.Anything, cutoff).Return(0, nil)
+		mockRepo.On("PurgeDeletedAttatchments", mock.Anything, cutoff).Return(0, fmt.Errorf("db error"))
+
+		err := service.Purge(context.Background(), now)
+		assert.NotNil(t, err)
+		assert.Equal(t, "error purging attachments db error", err.Error())
+
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "PurgeDeletedPatients", mock.Anything, mock.Anything)
+	})
+}
+
+func TestStart(t *testing.T) {
+	t.Run("Start_Purges", testStartPurges)
+	t.Run("Start_ReportsErrors", testStartReportsErrors)
+}
+
+func testStartPurges(t *testing.T) {
+	mockRepo, service := getMocksAndService(time.Hour)
+	purged := make(chan struct{}, 1)
+	mockRepo.On("PurgeDeletedDiagnosedConditions", mock.Anything, mock.Anything).Return(0, nil)
+	mockRepo.On("PurgeDeletedMedications", mock.Anything, mock.Anything).Return(0, nil)
+	mockRepo.On("PurgeDeletedAllergies", mock.Anything, mock.Anything).Return(0, nil)
+	mockRepo.On("PurgeDeletedObservations", mock.Anything, mock.Anything).Return(0, nil)
+	mockRepo.On("PurgeDeletedEncounters", mock.Anything, mock.Anything).Return(0, nil)
+	mockRepo.On("PurgeDeletedAttatchments", mock.Anything, mock.Anything).Return(0, nil)
+	mockRepo.On("PurgeDeletedPatients", mock.Anything, mock.Anything).Return(0, nil).Run(func(args mock.Arguments) {
+		select {
+		case purged <- struct{}{}:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service.Start(ctx, time.Millisecond, func(err error) {
+		t.Errorf("unexpected purge error %v", err)
+	})
+
+	select {
+	case <-purged:
+	case <-time.After(time.Second):
+		t.Fatal("expected purge to run")
+	}
+}
+
+func testStartReportsErrors(t *testing.T) {
+	mockRepo, service := getMocksAndService(time.Hour)
+	mockRepo.On("PurgeDeletedDiagnosedConditions", mock.Anything, mock.Anything).Return(0, fmt.Errorf("db error"))
+	reported := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service.Start(ctx, time.Millisecond, func(err error) {
+		select {
+		case reported <- err:
+		default:
+		}
+	})
+
+	select {
+	case err := <-reported:
+		assert.Equal(t, "error purging diagnosed conditions db error", err.Error())
+	case <-time.After(time.Second):
+		t.Fatal("expected purge error to be reported")
+	}
+}
@@ -0,0 +1,266 @@
+package summaries
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mcg-app-backend/service/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// sizes are in millimetres
+const (
+	margin       = 15.0
+	footerMargin = 20.0
+	lineHeight   = 5.0
+	logoHeight   = 14.0
+	font         = "Helvetica"
+	// cells are cut short after this many lines, so a single row can never be taller than a page
+	maxCellLines = 20
+)
+
+type column struct {
+	title string
+	width float64
+}
+
+var conditionColumns = []column{{"Code", 32}, {"Condition", 70}, {"Onset", 24}, {"Diagnosed", 24}, {"Status", 30}}
+
+var attatchmentColumns = []column{{"Id", 14}, {"Name", 50}, {"Type", 30}, {"Description", 62}, {"Study Date", 24}}
+
+// a page being written, along with the translation of text to the encoding of the page's font
+type page struct {
+	pdf       *fpdf.Fpdf
+	translate func(string) string
+}
+
+// writes the patient's summary as an A4 PDF, returning the number of pages written.  The built in fonts only cover
+// latin characters, so any others are printed as a placeholder
+func render(w io.Writer, patient models.Patient, header Header, now time.Time) (int, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	p := page{pdf: pdf, translate: pdf.UnicodeTranslatorFromDescriptor("")}
+	pdf.SetMargins(margin, margin, margin)
+	// rows are moved to the next page before they would run into the footer, rather than being split
+	pdf.SetAutoPageBreak(false, footerMargin)
+	pdf.AliasNbPages("")
+	pdf.SetTitle(fmt.Sprintf("Patient Summary - %v", patient.Name), true)
+	pdf.SetCreator("mcg-app-backend", true)
+	pdf.SetCreationDate(now)
+	pdf.SetHeaderFunc(func() { p.header(header) })
+	pdf.SetFooterFunc(func() { p.footer(patient, now) })
+
+	pdf.AddPage()
+	p.heading("Patient Summary")
+	p.demographics(patient, now)
+	p.heading("Active Conditions")
+	p.table(conditionColumns, conditionRows(patient.DiagnosedConditions), "No active conditions recorded.")
+	p.heading("Attatchments")
+	p.table(attatchmentColumns, attatchmentRows(patient.Attatchments), "No documents attatched.")
+
+	pages := pdf.PageCount()
+	err := pdf.Output(w)
+	if err != nil {
+		return 0, err
+	}
+	return pages, nil
+}
+
+func (p page) header(header Header) {
+	x := margin
+	if len(header.Logo) > 0 {
+		options := fpdf.ImageOptions{ImageType: header.logoFormat}
+		logo := p.pdf.RegisterImageOptionsReader("logo", options, bytes.NewReader(header.Logo))
+		if logo != nil {
+			p.pdf.ImageOptions("logo", margin, margin, 0, logoHeight, false, options, 0, "")
+			x += logo.Width()*logoHeight/logo.Height() + 4
+		}
+	}
+	p.pdf.SetXY(x, margin+1)
+	p.pdf.SetFont(font, "B", 14)
+	p.pdf.CellFormat(0, 7, p.translate(header.Title), "", 2, "L", false, 0, "")
+	p.pdf.SetFont(font, "", 10)
+	p.pdf.CellFormat(0, lineHeight, p.translate(header.Subtitle), "", 2, "L", false, 0, "")
+
+	pageWidth, _ := p.pdf.GetPageSize()
+	y := margin + logoHeight + 2
+	p.pdf.Line(margin, y, pageWidth-margin, y)
+	p.pdf.SetXY(margin, y+4)
+}
+
+func (p page) footer(patient models.Patient, now time.Time) {
+	pageWidth, _ := p.pdf.GetPageSize()
+	half := (pageWidth - 2*margin) / 2
+	p.pdf.SetXY(margin, -margin)
+	p.pdf.SetFont(font, "", 8)
+	p.pdf.CellFormat(half, lineHeight, p.translate(fmt.Sprintf("%v - printed %v", patient.Name, now.Format("2006-01-02 15:04"))), "", 0, "L", false, 0, "")
+	p.pdf.CellFormat(half, lineHeight, fmt.Sprintf("Page %v of {nb}", p.pdf.PageNo()), "", 0, "R", false, 0, "")
+}
+
+// a section heading, which is kept on the same page as at least the first row after it
+func (p page) heading(title string) {
+	p.ensureSpace(8 + 3*lineHeight)
+	p.pdf.Ln(3)
+	p.pdf.SetFont(font, "B", 12)
+	p.pdf.CellFormat(0, 7, p.translate(title), "", 1, "L", false, 0, "")
+	p.pdf.Ln(1)
+}
+
+func (p page) demographics(patient models.Patient, now time.Time) {
+	fields := [][]string{
+		{"Name", patient.Name},
+		{"Date of birth", fmt.Sprintf("%v (age %v)", patient.DateOfBirth.Format(time.DateOnly), age(patient.DateOfBirth, now))},
+		{"Phone number", patient.PhoneNumber},
+		{"Address", patient.Address},
+		{"External identifier", patient.ExternalIdentifier},
+		{"Patient id", strconv.Itoa(patient.Id)},
+	}
+	if others := otherIdentifiers(patient.Identifiers); others != "" {
+		fields = append(fields, []string{"Other identifiers", others})
+	}
+	columns := []column{{"", 40}, {"", 140}}
+	style := rowStyle{fonts: []string{"B", ""}}
+	for _, field := range fields {
+		p.row(columns, field, style, nil)
+	}
+}
+
+type rowStyle struct {
+	// the font style of each cell, such as B for bold
+	fonts  []string
+	border bool
+	fill   bool
+}
+
+// a table whose heading is repeated at the top of each page it runs onto
+func (p page) table(columns []column, rows [][]string, empty string) {
+	if len(rows) == 0 {
+		p.pdf.SetFont(font, "I", 10)
+		p.pdf.CellFormat(0, lineHeight, p.translate(empty), "", 1, "L", false, 0, "")
+		return
+	}
+	titles := make([]string, len(columns))
+	headingStyle := rowStyle{fonts: make([]string, len(columns)), border: true, fill: true}
+	cellStyle := rowStyle{fonts: make([]string, len(columns)), border: true}
+	for i, column := range columns {
+		titles[i] = column.title
+		headingStyle.fonts[i] = "B"
+	}
+	heading := func() { p.row(columns, titles, headingStyle, nil) }
+
+	p.pdf.SetFillColor(230, 230, 230)
+	heading()
+	for _, row := range rows {
+		p.row(columns, row, cellStyle, heading)
+	}
+}
+
+// writes a row of cells, wrapping the text of each to its column.  When the row does not fit on the page it is written
+// on a new one, after calling onNewPage to write anything which should come before it
+func (p page) row(columns []column, values []string, style rowStyle, onNewPage func()) {
+	cells := make([][][]byte, len(columns))
+	height := lineHeight
+	for i, column := range columns {
+		p.pdf.SetFont(font, style.fonts[i], 10)
+		lines := p.pdf.SplitLines([]byte(p.translate(values[i])), column.width)
+		if len(lines) > maxCellLines {
+			lines = append(lines[:maxCellLines-1], []byte("..."))
+		}
+		cells[i] = lines
+		height = max(height, float64(len(lines))*lineHeight)
+	}
+	if p.ensureSpace(height) && onNewPage != nil {
+		onNewPage()
+	}
+
+	x, y := margin, p.pdf.GetY()
+	for i, column := range columns {
+		p.pdf.SetFont(font, style.fonts[i], 10)
+		if style.fill {
+			p.pdf.Rect(x, y, column.width, height, "FD")
+		} else if style.border {
+			p.pdf.Rect(x, y, column.width, height, "D")
+		}
+		for j, line := range cells[i] {
+			p.pdf.SetXY(x, y+float64(j)*lineHeight)
+			p.pdf.CellFormat(column.width, lineHeight, string(line), "", 0, "L", false, 0, "")
+		}
+		x += column.width
+	}
+	p.pdf.SetXY(margin, y+height)
+}
+
+// starts a new page when there is not room for something of the given height on this one, returning whether it did
+func (p page) ensureSpace(height float64) bool {
+	_, pageHeight := p.pdf.GetPageSize()
+	if p.pdf.GetY()+height <= pageHeight-footerMargin {
+		return false
+	}
+	p.pdf.AddPage()
+	return true
+}
+
+// the conditions the patient currently has, as far as is known
+func conditionRows(conditions []models.DiagnosedCondition) [][]string {
+	var rows [][]string
+	for _, condition := range conditions {
+		if !condition.IsActive() {
+			continue
+		}
+		onset := ""
+		if condition.OnsetDate != nil {
+			onset = condition.OnsetDate.Format(time.DateOnly)
+		}
+		status := condition.ClinicalStatus
+		if condition.VerificationStatus != "" && condition.VerificationStatus != models.VerificationStatusConfirmed {
+			status = fmt.Sprintf("%v (%v)", status, condition.VerificationStatus)
+		}
+		code := condition.Code
+		if condition.CodeSystem == models.CodeSystemSNOMEDCT {
+			code += " (SNOMED CT)"
+		}
+		rows = append(rows, []string{
+			code,
+			condition.Name,
+			onset,
+			condition.Date.Format(time.DateOnly),
+			status,
+		})
+	}
+	return rows
+}
+
+func attatchmentRows(attatchments []models.Attatchment) [][]string {
+	var rows [][]string
+	for _, attatchment := range attatchments {
+		studyDate := ""
+		if attatchment.Dicom != nil && !attatchment.Dicom.StudyDate.IsZero() {
+			studyDate = attatchment.Dicom.StudyDate.Format(time.DateOnly)
+		}
+		rows = append(rows, []string{strconv.Itoa(attatchment.Id), attatchment.Name, attatchment.Type, attatchment.Description, studyDate})
+	}
+	return rows
+}
+
+// the identifiers besides the external identifier, each as system: value
+func otherIdentifiers(identifiers []models.PatientIdentifier) string {
+	var others []string
+	for _, identifier := range identifiers {
+		if identifier.System != models.IdentifierSystemExternal {
+			others = append(others, fmt.Sprintf("%v: %v", identifier.System, identifier.Value))
+		}
+	}
+	return strings.Join(others, "; ")
+}
+
+// whole years since the date of birth
+func age(dateOfBirth time.Time, now time.Time) int {
+	years := now.Year() - dateOfBirth.Year()
+	if now.Month() < dateOfBirth.Month() || now.Month() == dateOfBirth.Month() && now.Day() < dateOfBirth.Day() {
+		years--
+	}
+	return years
+}
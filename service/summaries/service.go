@@ -0,0 +1,87 @@
+package summaries
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// the letterhead printed at the top of every page of a summary
+type Header struct {
+	Title    string
+	Subtitle string
+	// a png or jpeg image printed to the left of the title, if any
+	Logo []byte
+	// png or jpeg
+	logoFormat string
+}
+
+// the header with the logo read from logoPath, or without a logo when no path is given
+func NewHeader(title string, subtitle string, logoPath string) (Header, error) {
+	header := Header{Title: title, Subtitle: subtitle}
+	if logoPath == "" {
+		return header, nil
+	}
+	logo, err := os.ReadFile(logoPath)
+	if err != nil {
+		return Header{}, fmt.Errorf("error reading logo %w", err)
+	}
+	_, format, err := image.DecodeConfig(bytes.NewReader(logo))
+	if err != nil {
+		return Header{}, fmt.Errorf("error reading logo %w", err)
+	}
+	if format != "png" && format != "jpeg" {
+		return Header{}, fmt.Errorf("logo is a %v image, expected png or jpeg", format)
+	}
+	// not every png can be embedded in a PDF, such as interlaced ones, so the logo is checked now rather than when the
+	// first summary is printed
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.RegisterImageOptionsReader("logo", fpdf.ImageOptions{ImageType: format}, bytes.NewReader(logo))
+	if pdf.Err() {
+		return Header{}, fmt.Errorf("error reading logo %w", pdf.Error())
+	}
+	header.Logo = logo
+	header.logoFormat = format
+	return header, nil
+}
+
+type SummaryService struct {
+	patientSvc PatientService
+	header     Header
+	tracer     Tracer
+}
+
+func NewSummaryService(patientSvc PatientService, header Header, tracer Tracer) SummaryService {
+	return SummaryService{
+		patientSvc: patientSvc,
+		header:     header,
+		tracer:     tracer,
+	}
+}
+
+// a printable PDF face sheet of the patient's demographics, active conditions and attatchments
+func (s SummaryService) GetPatientSummary(ctx context.Context, patientId int) ([]byte, error) {
+	ctx, span := s.tracer.NewSpan(ctx, "GetPatientSummary")
+	defer span.End()
+	s.tracer.SetAttributes(ctx, attribute.Int("patientId", patientId))
+
+	patient, err := s.patientSvc.GetPatient(ctx, patientId)
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error getting patient %w", err))
+	}
+
+	summary := &bytes.Buffer{}
+	_, err = render(summary, patient, s.header, time.Now())
+	if err != nil {
+		return nil, s.tracer.RecordError(ctx, fmt.Errorf("error rendering summary %w", err))
+	}
+	return summary.Bytes(), nil
+}
@@ -0,0 +1,194 @@
+package summaries
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"mcg-app-backend/service/customerrors"
+	"mcg-app-backend/service/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type MockPatientService struct {
+	mock.Mock
+}
+
+func (m *MockPatientService) GetPatient(ctx context.Context, patientId int) (models.Patient, error) {
+	args := m.Called(ctx, patientId)
+	return args.Get(0).(models.Patient), args.Error(1)
+}
+
+type MockTracer struct {
+	mock.Mock
+}
+
+func (m *MockTracer) NewSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("").Start(context.Background(), "")
+}
+
+func (m *MockTracer) SetAttributes(ctx context.Context, attrs ...attribute.KeyValue) {}
+
+func (m *MockTracer) RecordError(ctx context.Context, err error) error {
+	return err
+}
+
+func getMocksAndService(header Header) (*MockPatientService, SummaryService) {
+	mockPatientSvc := new(MockPatientService)
+	service := NewSummaryService(mockPatientSvc, header, new(MockTracer))
+	return mockPatientSvc, service
+}
+
+func date(value string) time.Time {
+	parsed, _ := time.Parse(time.DateOnly, value)
+	return parsed
+}
+
+func writeLogo(t *testing.T) string {
+	logo := &bytes.Buffer{}
+	png.Encode(logo, image.NewRGBA(image.Rect(0, 0, 200, 100)))
+	path := filepath.Join(t.TempDir(), "logo.png")
+	os.WriteFile(path, logo.Bytes(), 0o600)
+	return path
+}
+
+var patient = models.Patient{
+	Id:                 7,
+	Name:               "Zoë Smith",
+	Address:            "12 High St",
+	PhoneNumber:        "555 987 6543",
+	DateOfBirth:        date("1980-05-17"),
+	ExternalIdentifier: "MRN-7",
+	DiagnosedConditions: []models.DiagnosedCondition{
+		{Id: 1, Name: "Type 2 diabetes mellitus", Code: "E11.9", CodeSystem: models.CodeSystemICD10CM, Date: date("2020-01-02"),
+			ClinicalStatus: models.ClinicalStatusActive, VerificationStatus: models.VerificationStatusConfirmed},
+	},
+	Attatchments: []models.Attatchment{{Id: 4, Name: "referral.txt", Type: "referral", Description: "referral letter"}},
+}
+
+func TestNewHeader(t *testing.T) {
+	t.Run("NewHeader_WithoutLogo", func(t *testing.T) {
+		header, err := NewHeader("MCG Clinic", "1 Example Street", "")
+		assert.Nil(t, err)
+		assert.Equal(t, Header{Title: "MCG Clinic", Subtitle: "1 Example Street"}, header)
+	})
+
+	t.Run("NewHeader_WithLogo", func(t *testing.T) {
+		header, err := NewHeader("MCG Clinic", "", writeLogo(t))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, header.Logo)
+		assert.Equal(t, "png", header.logoFormat)
+	})
+
+	t.Run("NewHeader_LogoNotAnImage", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "logo.png")
+		os.WriteFile(path, []byte("not an image"), 0o600)
+		_, err := NewHeader("MCG Clinic", "", path)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("NewHeader_LogoMissing", func(t *testing.T) {
+		_, err := NewHeader("MCG Clinic", "", filepath.Join(t.TempDir(), "missing.png"))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetPatientSummary(t *testing.T) {
+	t.Run("GetPatientSummary_Success", func(t *testing.T) {
+		header, _ := NewHeader("MCG Clinic", "1 Example Street", writeLogo(t))
+		mockPatientSvc, service := getMocksAndService(header)
+		mockPatientSvc.On("GetPatient", mock.Anything, 7).Return(patient, nil)
+
+		summary, err := service.GetPatientSummary(context.Background(), 7)
+		assert.Nil(t, err)
+		assert.True(t, bytes.HasPrefix(summary, []byte("%PDF-")))
+		assert.Contains(t, string(summary), "/Subtype /Image")
+		assert.True(t, bytes.HasSuffix(bytes.TrimSpace(summary), []byte("%%EOF")))
+	})
+
+	t.Run("GetPatientSummary_PatientNotFound", func(t *testing.T) {
+		mockPatientSvc, service := getMocksAndService(Header{})
+		mockPatientSvc.On("GetPatient", mock.Anything, 9).Return(models.Patient{}, customerrors.NewInvalidInputError("patient id not found"))
+
+		_, err := service.GetPatientSummary(context.Background(), 9)
+		var inputError customerrors.InvalidInputError
+		assert.True(t, errors.As(err, &inputError))
+	})
+}
+
+func TestRender(t *testing.T) {
+	t.Run("Render_SinglePage", func(t *testing.T) {
+		pages, err := render(&bytes.Buffer{}, patient, Header{Title: "MCG Clinic"}, time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, 1, pages)
+	})
+
+	t.Run("Render_RunsOntoFurtherPages", func(t *testing.T) {
+		long := patient
+		long.DiagnosedConditions = nil
+		for i := range 120 {
+			long.DiagnosedConditions = append(long.DiagnosedConditions, models.DiagnosedCondition{
+				Id: i, Name: fmt.Sprintf("Condition %v", i), Code: "E11.9", Date: date("2020-01-02"),
+				ClinicalStatus: models.ClinicalStatusActive, VerificationStatus: models.VerificationStatusConfirmed,
+			})
+		}
+		long.Attatchments = []models.Attatchment{{Id: 1, Name: "notes.txt", Type: "notes", Description: strings.Repeat("a very long description ", 200)}}
+
+		summary := &bytes.Buffer{}
+		pages, err := render(summary, long, Header{Title: "MCG Clinic"}, time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, 4, pages)
+		assert.Equal(t, pages, strings.Count(summary.String(), "<</Type /Page\n"))
+	})
+
+	t.Run("Render_NothingRecorded", func(t *testing.T) {
+		pages, err := render(&bytes.Buffer{}, models.Patient{Id: 8, Name: "Sam"}, Header{}, time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, 1, pages)
+	})
+}
+
+func TestConditionRows(t *testing.T) {
+	onset := date("2019-03-01")
+	rows := conditionRows([]models.DiagnosedCondition{
+		{Name: "Type 2 diabetes mellitus", Code: "E11.9", CodeSystem: models.CodeSystemICD10CM, Date: date("2020-01-02"), OnsetDate: &onset,
+			ClinicalStatus: models.ClinicalStatusActive, VerificationStatus: models.VerificationStatusConfirmed},
+		{Name: "Asthma", Code: "195967001", CodeSystem: models.CodeSystemSNOMEDCT, Date: date("2021-02-03"),
+			ClinicalStatus: models.ClinicalStatusRelapse, VerificationStatus: models.VerificationStatusProvisional},
+		{Name: "Hypertension", Code: "I10", Date: date("2021-02-03"),
+			ClinicalStatus: models.ClinicalStatusResolved, VerificationStatus: models.VerificationStatusConfirmed},
+		{Name: "Migraine", Code: "G43.909", Date: date("2021-02-03"),
+			ClinicalStatus: models.ClinicalStatusActive, VerificationStatus: models.VerificationStatusRefuted},
+	})
+	assert.Equal(t, [][]string{
+		{"E11.9", "Type 2 diabetes mellitus", "2019-03-01", "2020-01-02", "active"},
+		{"195967001 (SNOMED CT)", "Asthma", "", "2021-02-03", "relapse (provisional)"},
+	}, rows)
+}
+
+func TestAge(t *testing.T) {
+	assert.Equal(t, 43, age(date("1980-05-17"), date("2024-05-16")))
+	assert.Equal(t, 44, age(date("1980-05-17"), date("2024-05-17")))
+	assert.Equal(t, 3, age(date("2020-02-29"), date("2024-02-28")))
+}
+
+func TestOtherIdentifiers(t *testing.T) {
+	assert.Equal(t, "", otherIdentifiers([]models.PatientIdentifier{{System: models.IdentifierSystemExternal, Value: "MRN-7"}}))
+	assert.Equal(t, "http://hl7.org/fhir/sid/us-ssn: 123-45-6789; partner: P-1", otherIdentifiers([]models.PatientIdentifier{
+		{System: models.IdentifierSystemExternal, Value: "MRN-7"},
+		{System: "http://hl7.org/fhir/sid/us-ssn", Value: "123-45-6789"},
+		{System: "partner", Value: "P-1"},
+	}))
+}